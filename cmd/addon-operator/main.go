@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"github.com/flant/kube-client/klogtologrus"
+	log "github.com/sirupsen/logrus"
 	"gopkg.in/alecthomas/kingpin.v2"
 
 	sh_app "github.com/flant/shell-operator/pkg/app"
@@ -53,9 +54,13 @@ func main() {
 			operator.Start()
 
 			// Block action by waiting signals from OS.
+			// The second signal forces exit without waiting for running tasks.
 			utils_signal.WaitForProcessInterruption(func() {
-				operator.Shutdown()
-				os.Exit(1)
+				if err := operator.Shutdown(); err != nil {
+					log.Errorf("Shutdown: %v", err)
+					os.Exit(1)
+				}
+				os.Exit(0)
 			})
 
 			return nil
@@ -64,6 +69,7 @@ func main() {
 
 	debug.DefineDebugCommands(kpApp)
 	app.DefineDebugCommands(kpApp)
+	addon_operator.DefineModuleDocsCommand(kpApp)
 
 	kingpin.MustParse(kpApp.Parse(os.Args[1:]))
 }
@@ -38,6 +38,10 @@ func Bootstrap(op *AddonOperator) error {
 		return err
 	}
 
+	if err := module_manager.CheckLimitsShell(); err != nil {
+		log.Errorf("Resource limits of shell hooks can not be applied, hooks with limits will fail: %s", err)
+	}
+
 	log.Infof("Addon-operator namespace: %s", app.Namespace)
 
 	op.WithContext(context.Background())
@@ -89,6 +93,18 @@ func AssembleAddonOperator(op *AddonOperator, modulesDir string, globalHooksDir
 		return fmt.Errorf("initialize Helm resources manager: %s", err)
 	}
 
+	// Kubernetes Events for module lifecycle and values patches share one broadcaster.
+	if app.EmitEvents || app.ValuesHistoryEvents {
+		broadcaster, recorder := InitDefaultEventRecorder(op.KubeClient)
+		op.EventBroadcaster = broadcaster
+		if app.EmitEvents {
+			op.EventRecorder = recorder
+		}
+		if app.ValuesHistoryEvents {
+			op.ValuesHistoryEventRecorder = recorder
+		}
+	}
+
 	SetupModuleManager(op, modulesDir, globalHooksDir, tempDir, runtimeConfig)
 
 	err = op.InitModuleManager()
@@ -96,6 +112,14 @@ func AssembleAddonOperator(op *AddonOperator, modulesDir string, globalHooksDir
 		return err
 	}
 
+	// Validating webhook for the ConfigMap uses schemas loaded by the ModuleManager.
+	if app.ConfigValidationWebhook {
+		err = op.InitConfigValidationWebhook()
+		if err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
@@ -113,6 +137,7 @@ func SetupModuleManager(op *AddonOperator, modulesDir string, globalHooksDir str
 	op.ModuleManager.WithContext(op.ctx)
 	op.ModuleManager.WithDirectories(modulesDir, globalHooksDir, tempDir)
 	op.ModuleManager.WithKubeConfigManager(op.KubeConfigManager)
+	op.KubeConfigManager.WithConversions(op.ModuleManager.ConversionRegistry())
 	op.ModuleManager.WithHelm(op.Helm)
 	op.ModuleManager.WithScheduleManager(op.ScheduleManager)
 	op.ModuleManager.WithKubeEventManager(op.KubeEventsManager)
@@ -120,4 +145,7 @@ func SetupModuleManager(op *AddonOperator, modulesDir string, globalHooksDir str
 	op.ModuleManager.WithMetricStorage(op.MetricStorage)
 	op.ModuleManager.WithHookMetricStorage(op.HookMetricStorage)
 	op.ModuleManager.WithHelmResourcesManager(op.HelmResourcesManager)
+	if app.ValuesHistoryEvents {
+		op.ModuleManager.WithValuesHistoryHandler(op.RecordValuesHistoryEvent)
+	}
 }
@@ -0,0 +1,152 @@
+package addon_operator
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	sh_app "github.com/flant/shell-operator/pkg/app"
+	"github.com/flant/shell-operator/pkg/webhook/validating"
+	. "github.com/flant/shell-operator/pkg/webhook/validating/types"
+	log "github.com/sirupsen/logrus"
+	admissionv1 "k8s.io/api/admission/v1"
+	v1 "k8s.io/api/admissionregistration/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/flant/addon-operator/pkg/app"
+	"github.com/flant/addon-operator/pkg/kube_config_manager"
+	"github.com/flant/addon-operator/pkg/utils"
+	"github.com/flant/addon-operator/pkg/values/validation"
+)
+
+const (
+	configValidationConfigurationId = "config"
+	configValidationWebhookId       = "configmap"
+	configValidationWebhookName     = "configmap.addon-operator.flant.com"
+)
+
+// InitConfigValidationWebhook starts a validating webhook server for the ConfigMap
+// and creates a ValidatingWebhookConfiguration. Modules should be loaded
+// before this call, so schemas are available in the ValuesValidator.
+func (op *AddonOperator) InitConfigValidationWebhook() error {
+	op.ValidatingWebhookManager = validating.NewWebhookManager()
+	op.ValidatingWebhookManager.WithKubeClient(op.KubeClient)
+	op.ValidatingWebhookManager.Settings = sh_app.ValidatingWebhookSettings
+	op.ValidatingWebhookManager.Namespace = app.Namespace
+	op.ValidatingWebhookManager.DefaultConfigurationId = configValidationConfigurationId
+
+	err := op.ValidatingWebhookManager.Init()
+	if err != nil {
+		return fmt.Errorf("init config validation webhook: %v", err)
+	}
+
+	op.ValidatingWebhookManager.AddWebhook(configValidationWebhookConfig())
+	op.ValidatingWebhookManager.WithValidatingEventHandler(op.ValidateConfigMapEvent)
+
+	err = op.ValidatingWebhookManager.Start()
+	if err != nil {
+		return fmt.Errorf("start config validation webhook: %v", err)
+	}
+	return nil
+}
+
+// configValidationWebhookConfig returns a webhook for ConfigMaps in the operator namespace.
+// Other ConfigMaps in the namespace are allowed by the handler.
+func configValidationWebhookConfig() *validating.ValidatingWebhookConfig {
+	failurePolicy := v1.Ignore
+	sideEffects := v1.SideEffectClassNone
+	timeoutSeconds := int32(10)
+
+	cfg := &validating.ValidatingWebhookConfig{
+		ValidatingWebhook: &v1.ValidatingWebhook{
+			Name: configValidationWebhookName,
+			Rules: []v1.RuleWithOperations{
+				{
+					Operations: []v1.OperationType{v1.Create, v1.Update},
+					Rule: v1.Rule{
+						APIGroups:   []string{""},
+						APIVersions: []string{"v1"},
+						Resources:   []string{"configmaps"},
+					},
+				},
+			},
+			NamespaceSelector: &metav1.LabelSelector{
+				MatchLabels: map[string]string{
+					"kubernetes.io/metadata.name": app.Namespace,
+				},
+			},
+			FailurePolicy:  &failurePolicy,
+			SideEffects:    &sideEffects,
+			TimeoutSeconds: &timeoutSeconds,
+		},
+	}
+	cfg.UpdateIds(configValidationConfigurationId, configValidationWebhookId)
+	return cfg
+}
+
+// ValidateConfigMapEvent parses the ConfigMap from the AdmissionReview and validates
+// global and module sections with config-values schemas.
+func (op *AddonOperator) ValidateConfigMapEvent(event ValidatingEvent) (*ValidatingResponse, error) {
+	request := event.Review.Request
+	if request == nil {
+		return nil, fmt.Errorf("AdmissionReview has no request")
+	}
+	if request.Name != app.ConfigMapName || request.Namespace != app.Namespace || request.Operation == admissionv1.Delete {
+		return &ValidatingResponse{Allowed: true}, nil
+	}
+
+	logEntry := log.WithField("operator.component", "configValidationWebhook").
+		WithField("configmap", request.Namespace+"/"+request.Name)
+
+	var cm corev1.ConfigMap
+	err := json.Unmarshal(request.Object.Raw, &cm)
+	if err != nil {
+		return nil, fmt.Errorf("parse ConfigMap: %v", err)
+	}
+
+	kubeConfig, err := kube_config_manager.ParseConfigMapData(cm.Data)
+	if err == nil {
+		err = op.KubeConfigManager.ResolveSecretRefs(kubeConfig)
+	}
+	if err != nil {
+		logEntry.Infof("Reject ConfigMap change: %v", err)
+		return &ValidatingResponse{
+			Allowed: false,
+			Message: fmt.Sprintf("ConfigMap/%s is not valid: %v", app.ConfigMapName, err),
+		}, nil
+	}
+
+	deprecations, err := op.ModuleManager.ValidateKubeConfig(kubeConfig)
+	if err != nil {
+		// The config is not applied, so its values from Secrets are not registered
+		// for masking. Mask them explicitly.
+		message := validationErrorMessage(err, kubeConfig.SecretValues())
+		logEntry.Infof("Reject ConfigMap change: %s", message)
+		return &ValidatingResponse{
+			Allowed: false,
+			Message: message,
+		}, nil
+	}
+
+	// Deprecated settings are allowed, kubectl shows warnings to the user.
+	var warnings []string
+	for _, d := range deprecations {
+		warnings = append(warnings, d.String())
+	}
+	return &ValidatingResponse{Allowed: true, Warnings: warnings}, nil
+}
+
+// validationErrorMessage returns field-level validation errors one per line.
+// Secrets are replaced with the mask.
+func validationErrorMessage(err error, secrets []string) string {
+	errs := validation.ValidationErrors(err)
+	lines := make([]string, 0, len(errs))
+	for _, e := range errs {
+		redacted := *e
+		redacted.Value = utils.RedactStrings(e.Value, secrets)
+		redacted.Message = utils.RedactStringsInText(e.Message, secrets)
+		lines = append(lines, redacted.Error())
+	}
+	return strings.Join(lines, "\n")
+}
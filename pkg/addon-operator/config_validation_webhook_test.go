@@ -0,0 +1,125 @@
+package addon_operator
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	. "github.com/flant/shell-operator/pkg/webhook/validating/types"
+	. "github.com/onsi/gomega"
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	"github.com/flant/addon-operator/pkg/app"
+	"github.com/flant/addon-operator/pkg/kube_config_manager"
+	"github.com/flant/addon-operator/pkg/utils"
+)
+
+func Test_ValidateConfigMapEvent(t *testing.T) {
+	op, res := assembleTestAddonOperator(t, "config_validation")
+	app.Namespace = res.cmNamespace
+	app.ConfigMapName = res.cmName
+
+	_, err := op.KubeClient.CoreV1().Secrets(res.cmNamespace).Create(context.TODO(), &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "module-one"},
+		Data:       map[string][]byte{"password": []byte("webhook-test-password"), "short": []byte("webhook")},
+	}, metav1.CreateOptions{})
+	NewWithT(t).Expect(err).ShouldNot(HaveOccurred())
+
+	review := func(name string, data string) ValidatingEvent {
+		return ValidatingEvent{
+			Review: &admissionv1.AdmissionReview{
+				Request: &admissionv1.AdmissionRequest{
+					Name:      name,
+					Namespace: res.cmNamespace,
+					Operation: admissionv1.Update,
+					Object: runtime.RawExtension{
+						Raw: []byte(`{"apiVersion":"v1","kind":"ConfigMap","data":` + data + `}`),
+					},
+				},
+			},
+		}
+	}
+
+	tests := []struct {
+		name     string
+		cmName   string
+		data     string
+		allowed  bool
+		message  string
+		warnings []string
+	}{
+		{"valid", res.cmName, `{"global":"clusterName: main\n","moduleOne":"replicas: 3\n"}`, true, "", nil},
+		{"invalid module section", res.cmName, `{"moduleOne":"replicas: 0\n"}`, false, "moduleOne.replicas should be greater than or equal to 1", nil},
+		{"invalid global section", res.cmName, `{"global":"clusterName: 1\n"}`, false, "global.clusterName must be of type string", nil},
+		{"not YAML", res.cmName, `{"moduleOne":"replicas: [\n"}`, false, "is not valid", nil},
+		{"other ConfigMap", "other", `{"moduleOne":"replicas: 0\n"}`, true, "", nil},
+		{"renamed setting", res.cmName, `{"moduleOne":"replicaCount: 3\n"}`, true, "", []string{"'moduleOne.replicaCount' is renamed to 'moduleOne.replicas'"}},
+		{"secret reference", res.cmName, `{"moduleOne":"password:\n  secretKeyRef:\n    name: module-one\n    key: password\n"}`, true, "", nil},
+		{"missing Secret", res.cmName, `{"moduleOne":"password:\n  secretKeyRef:\n    name: unknown\n    key: password\n"}`, false, "Secret/unknown referenced at '/password' is not found", nil},
+		{"invalid secret value", res.cmName, `{"moduleOne":"password:\n  secretKeyRef:\n    name: module-one\n    key: short\n"}`, false, "moduleOne.password should be at least 8 chars long, got \"<secret>\"", nil},
+		{"invalid renamed setting", res.cmName, `{"moduleOne":"replicaCount: 0\n"}`, false, "moduleOne.replicas should be greater than or equal to 1", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			resp, err := op.ValidateConfigMapEvent(review(tt.cmName, tt.data))
+			g.Expect(err).ShouldNot(HaveOccurred())
+			g.Expect(resp.Allowed).To(Equal(tt.allowed), resp.Message)
+			g.Expect(resp.Message).To(ContainSubstring(tt.message))
+			g.Expect(resp.Warnings).To(Equal(tt.warnings))
+		})
+	}
+}
+
+func Test_ValidateConfigMapEvent_rejected_keeps_masks(t *testing.T) {
+	g := NewWithT(t)
+	op, res := assembleTestAddonOperator(t, "config_validation")
+	app.Namespace = res.cmNamespace
+	app.ConfigMapName = res.cmName
+
+	_, err := op.KubeClient.CoreV1().Secrets(res.cmNamespace).Create(context.TODO(), &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "module-one"},
+		Data:       map[string][]byte{"password": []byte("applied-test-password"), "short": []byte("reject")},
+	}, metav1.CreateOptions{})
+	g.Expect(err).ShouldNot(HaveOccurred())
+
+	secretRef := func(key string) string {
+		return `password:
+  secretKeyRef:
+    name: module-one
+    key: ` + key + "\n"
+	}
+
+	// Apply the config with the reference.
+	kubeConfig, err := kube_config_manager.ParseConfigMapData(map[string]string{"moduleOne": secretRef("password")})
+	g.Expect(err).ShouldNot(HaveOccurred())
+	g.Expect(op.KubeConfigManager.ResolveSecretRefs(kubeConfig)).Should(Succeed())
+	_, err = op.ModuleManager.HandleNewKubeConfig(kubeConfig)
+	g.Expect(err).ShouldNot(HaveOccurred())
+	g.Expect(utils.IsSecretValue("applied-test-password")).To(BeTrue())
+
+	// Rejected change should not replace masks of the applied config.
+	data, err := json.Marshal(map[string]string{"moduleOne": secretRef("short")})
+	g.Expect(err).ShouldNot(HaveOccurred())
+	resp, err := op.ValidateConfigMapEvent(ValidatingEvent{
+		Review: &admissionv1.AdmissionReview{
+			Request: &admissionv1.AdmissionRequest{
+				Name:      res.cmName,
+				Namespace: res.cmNamespace,
+				Operation: admissionv1.Update,
+				Object: runtime.RawExtension{
+					Raw: []byte(`{"apiVersion":"v1","kind":"ConfigMap","data":` + string(data) + `}`),
+				},
+			},
+		},
+	})
+	g.Expect(err).ShouldNot(HaveOccurred())
+	g.Expect(resp.Allowed).To(BeFalse())
+	g.Expect(resp.Message).To(ContainSubstring(`got "<secret>"`))
+	g.Expect(utils.IsSecretValue("applied-test-password")).To(BeTrue())
+	g.Expect(utils.IsSecretValue("reject")).To(BeFalse())
+}
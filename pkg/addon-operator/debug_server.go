@@ -1,6 +1,7 @@
 package addon_operator
 
 import (
+	"encoding/json"
 	"fmt"
 	"net/http"
 	"os"
@@ -10,6 +11,9 @@ import (
 	"github.com/go-chi/chi/v5"
 
 	"github.com/flant/addon-operator/pkg/app"
+	"github.com/flant/addon-operator/pkg/module_manager"
+	"github.com/flant/addon-operator/pkg/utils"
+	"github.com/flant/addon-operator/pkg/values/validation"
 )
 
 func RegisterDebugGlobalRoutes(dbgSrv *debug.Server, op *AddonOperator) {
@@ -20,15 +24,31 @@ func RegisterDebugGlobalRoutes(dbgSrv *debug.Server, op *AddonOperator) {
 	})
 
 	dbgSrv.Route("/global/values.{format:(json|yaml)}", func(_ *http.Request) (interface{}, error) {
-		return op.ModuleManager.GlobalValues()
+		return op.redactValues(op.ModuleManager.GlobalValues())
+	})
+
+	dbgSrv.Route("/global/values/explain.{format:(json|yaml)}", func(_ *http.Request) (interface{}, error) {
+		return op.redactExplanation(op.ModuleManager.ExplainGlobalValues())
+	})
+
+	dbgSrv.Route("/global/values/validate.{format:(json|yaml)}", func(_ *http.Request) (interface{}, error) {
+		values, err := op.ModuleManager.GlobalValues()
+		if err != nil {
+			return nil, err
+		}
+		return validationReport(op.ModuleManager.GetValuesValidator().ValidateGlobalValues(values)), nil
 	})
 
 	dbgSrv.Route("/global/config.{format:(json|yaml)}", func(_ *http.Request) (interface{}, error) {
-		return op.ModuleManager.GlobalConfigValues(), nil
+		return op.redactValues(op.ModuleManager.GlobalConfigValues(), nil)
 	})
 
 	dbgSrv.Route("/global/patches.{format:(json|yaml)}", func(_ *http.Request) (interface{}, error) {
-		return op.ModuleManager.GlobalValuesPatches(), nil
+		return redactSecrets(op.redactPatches(op.ModuleManager.GlobalValuesPatches()), nil)
+	})
+
+	dbgSrv.Route("/global/history.{format:(json|yaml)}", func(_ *http.Request) (interface{}, error) {
+		return redactSecrets(op.redactHistory(op.ModuleManager.GlobalValuesHistory()), nil)
 	})
 
 	dbgSrv.Route("/global/snapshots.{format:(json|yaml)}", func(r *http.Request) (interface{}, error) {
@@ -44,8 +64,15 @@ func RegisterDebugGlobalRoutes(dbgSrv *debug.Server, op *AddonOperator) {
 }
 
 func RegisterDebugModuleRoutes(dbgSrv *debug.Server, op *AddonOperator) {
-	dbgSrv.Route("/module/list.{format:(json|yaml|text)}", func(_ *http.Request) (interface{}, error) {
-		return map[string][]string{"enabledModules": op.ModuleManager.GetEnabledModuleNames()}, nil
+	dbgSrv.Route("/module/list.{format:(json|yaml|text)}", func(r *http.Request) (interface{}, error) {
+		modules := op.ModuleList()
+		if debug.FormatFromRequest(r) == "text" {
+			return modules, nil
+		}
+		return map[string]interface{}{
+			"enabledModules": op.ModuleManager.GetEnabledModuleNames(),
+			"modules":        modules,
+		}, nil
 	})
 
 	dbgSrv.Route("/module/{name}/{type:(config|values)}.{format:(json|yaml)}", func(r *http.Request) (interface{}, error) {
@@ -59,13 +86,39 @@ func RegisterDebugModuleRoutes(dbgSrv *debug.Server, op *AddonOperator) {
 
 		switch valType {
 		case "config":
-			return m.ConfigValues(), nil
+			return op.redactValues(m.ConfigValues(), nil)
 		case "values":
-			return m.Values()
+			return op.redactValues(m.Values())
 		}
 		return "no values", nil
 	})
 
+	dbgSrv.Route("/module/{name}/values/explain.{format:(json|yaml)}", func(r *http.Request) (interface{}, error) {
+		modName := chi.URLParam(r, "name")
+
+		m := op.ModuleManager.GetModule(modName)
+		if m == nil {
+			return nil, fmt.Errorf("Module not found")
+		}
+
+		return op.redactExplanation(op.ModuleManager.ExplainModuleValues(m.Name))
+	})
+
+	dbgSrv.Route("/module/{name}/values/validate.{format:(json|yaml)}", func(r *http.Request) (interface{}, error) {
+		modName := chi.URLParam(r, "name")
+
+		m := op.ModuleManager.GetModule(modName)
+		if m == nil {
+			return nil, fmt.Errorf("Module not found")
+		}
+
+		values, err := m.Values()
+		if err != nil {
+			return nil, err
+		}
+		return validationReport(op.ModuleManager.GetValuesValidator().ValidateModuleValues(m.ValuesKey(), values)), nil
+	})
+
 	dbgSrv.Route("/module/{name}/render", func(r *http.Request) (interface{}, error) {
 		modName := chi.URLParam(r, "name")
 
@@ -80,7 +133,11 @@ func RegisterDebugModuleRoutes(dbgSrv *debug.Server, op *AddonOperator) {
 		}
 		defer os.Remove(valuesPath)
 
-		return op.Helm.NewClient().Render(m.Name, m.Path, []string{valuesPath}, nil, app.Namespace)
+		manifests, err := op.Helm.NewClient().Render(m.Name, m.Path, []string{valuesPath}, nil, app.Namespace)
+		if err != nil {
+			return nil, err
+		}
+		return utils.RedactSecretsInText(manifests), nil
 	})
 
 	dbgSrv.Route("/module/{name}/patches.json", func(r *http.Request) (interface{}, error) {
@@ -91,7 +148,18 @@ func RegisterDebugModuleRoutes(dbgSrv *debug.Server, op *AddonOperator) {
 			return nil, fmt.Errorf("Unknown module %s", modName)
 		}
 
-		return op.ModuleManager.ModuleDynamicValuesPatches(modName), nil
+		return redactSecrets(op.redactPatches(op.ModuleManager.ModuleDynamicValuesPatches(modName)), nil)
+	})
+
+	dbgSrv.Route("/module/{name}/history.{format:(json|yaml)}", func(r *http.Request) (interface{}, error) {
+		modName := chi.URLParam(r, "name")
+
+		m := op.ModuleManager.GetModule(modName)
+		if m == nil {
+			return nil, fmt.Errorf("Module not found")
+		}
+
+		return redactSecrets(op.redactHistory(op.ModuleManager.ModuleValuesHistory(m.Name)), nil)
 	})
 
 	dbgSrv.Route("/module/resource-monitor.{format:(json|yaml)}", func(_ *http.Request) (interface{}, error) {
@@ -128,3 +196,81 @@ func RegisterDebugModuleRoutes(dbgSrv *debug.Server, op *AddonOperator) {
 		return snapshots, nil
 	})
 }
+
+// redactSecrets masks values resolved from Secrets in the object.
+// The object is converted to generic maps to find values in structs.
+func redactSecrets(obj interface{}, err error) (interface{}, error) {
+	if err != nil {
+		return nil, err
+	}
+	data, err := json.Marshal(obj)
+	if err != nil {
+		return nil, err
+	}
+	var res interface{}
+	err = json.Unmarshal(data, &res)
+	if err != nil {
+		return nil, err
+	}
+	return utils.RedactSecrets(res), nil
+}
+
+// redactValues masks values of settings marked with 'x-sensitive' and values resolved from Secrets.
+func (op *AddonOperator) redactValues(values utils.Values, err error) (interface{}, error) {
+	if err != nil {
+		return nil, err
+	}
+	return redactSecrets(op.ModuleManager.GetValuesValidator().RedactSensitive(values), nil)
+}
+
+func (op *AddonOperator) redactExplanation(explanation module_manager.ValuesExplanation, err error) (interface{}, error) {
+	if err != nil {
+		return nil, err
+	}
+	res := make(module_manager.ValuesExplanation, 0, len(explanation))
+	for _, item := range explanation {
+		item.Value = op.ModuleManager.GetValuesValidator().RedactSensitiveAt(item.Path, item.Value)
+		res = append(res, item)
+	}
+	return redactSecrets(res, nil)
+}
+
+// redactPatches returns a copy of patches with masked values of sensitive settings.
+func (op *AddonOperator) redactPatches(patches []utils.ValuesPatch) []utils.ValuesPatch {
+	res := make([]utils.ValuesPatch, 0, len(patches))
+	for _, patch := range patches {
+		res = append(res, op.redactPatch(patch))
+	}
+	return res
+}
+
+func (op *AddonOperator) redactPatch(patch utils.ValuesPatch) utils.ValuesPatch {
+	res := utils.ValuesPatch{Operations: make([]*utils.ValuesPatchOperation, 0, len(patch.Operations))}
+	for _, operation := range patch.Operations {
+		redacted := *operation
+		redacted.Value = op.ModuleManager.GetValuesValidator().RedactSensitiveAt(operation.Path, operation.Value)
+		res.Operations = append(res.Operations, &redacted)
+	}
+	return res
+}
+
+func (op *AddonOperator) redactHistory(records []module_manager.ValuesHistoryRecord) []module_manager.ValuesHistoryRecord {
+	res := make([]module_manager.ValuesHistoryRecord, 0, len(records))
+	for _, record := range records {
+		record.Patch = op.redactPatch(record.Patch)
+		res = append(res, record)
+	}
+	return res
+}
+
+// validationReport returns structured errors from the values validation.
+func validationReport(err error) map[string]interface{} {
+	errs := validation.ValidationErrors(err)
+	if errs == nil {
+		errs = []*validation.ValidationError{}
+	}
+	return map[string]interface{}{
+		"valid":  err == nil,
+		"errors": errs,
+	}
+}
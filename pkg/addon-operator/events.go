@@ -0,0 +1,66 @@
+package addon_operator
+
+import (
+	klient "github.com/flant/kube-client/client"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/tools/record"
+
+	"github.com/flant/addon-operator/pkg/app"
+)
+
+// Reasons for Kubernetes Events.
+const (
+	EventReasonModuleEnabled      = "ModuleEnabled"
+	EventReasonModuleRunSucceeded = "ModuleRunSucceeded"
+	EventReasonModuleRunFailed    = "ModuleRunFailed"
+	EventReasonModuleDisabled     = "ModuleDisabled"
+	EventReasonModuleDeleteFailed = "ModuleDeleteFailed"
+	EventReasonModulePurged       = "ModulePurged"
+	EventReasonModulePurgeFailed  = "ModulePurgeFailed"
+	EventReasonHookFailed         = "HookFailed"
+	EventReasonRetriesExhausted   = "RetriesExhausted"
+	EventReasonConfigInvalid      = "ConfigInvalid"
+	EventReasonConfigValid        = "ConfigValid"
+)
+
+// ModuleEventAnnotation is an annotation with the module name in Events for modules.
+const ModuleEventAnnotation = "addon-operator.flant.com/module"
+
+// InitDefaultEventRecorder starts an event broadcaster that sends Events to the operator's namespace.
+func InitDefaultEventRecorder(kubeClient klient.Client) (record.EventBroadcaster, record.EventRecorder) {
+	broadcaster := record.NewBroadcaster()
+	broadcaster.StartRecordingToSink(&typedcorev1.EventSinkImpl{
+		Interface: kubeClient.CoreV1().Events(app.Namespace),
+	})
+	recorder := broadcaster.NewRecorder(scheme.Scheme, v1.EventSource{Component: app.AppName})
+	return broadcaster, recorder
+}
+
+// eventObjectRef is a reference to the operator's ConfigMap. All Events are attached to it.
+func eventObjectRef() *v1.ObjectReference {
+	return &v1.ObjectReference{
+		APIVersion: "v1",
+		Kind:       "ConfigMap",
+		Name:       app.ConfigMapName,
+		Namespace:  app.Namespace,
+	}
+}
+
+// RecordEvent publishes an Event for the operator's ConfigMap. It is a noop if events are disabled.
+func (op *AddonOperator) RecordEvent(eventType, reason, messageFmt string, args ...interface{}) {
+	if op.EventRecorder == nil {
+		return
+	}
+	op.EventRecorder.Eventf(eventObjectRef(), eventType, reason, messageFmt, args...)
+}
+
+// RecordModuleEvent publishes an Event for the operator's ConfigMap with the module name in annotations.
+func (op *AddonOperator) RecordModuleEvent(moduleName string, eventType, reason, messageFmt string, args ...interface{}) {
+	if op.EventRecorder == nil {
+		return
+	}
+	annotations := map[string]string{ModuleEventAnnotation: moduleName}
+	op.EventRecorder.AnnotatedEventf(eventObjectRef(), annotations, eventType, reason, messageFmt, args...)
+}
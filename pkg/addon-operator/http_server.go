@@ -32,6 +32,9 @@ func RegisterDefaultRoutes(op *AddonOperator) {
 		if op.IsStartupConvergeDone() {
 			w.WriteHeader(http.StatusOK)
 			_, _ = w.Write([]byte("Startup converge done.\n"))
+			if failing := op.FailingModules.List(); len(failing) > 0 {
+				_, _ = w.Write([]byte(fmt.Sprintf("Degraded: failing modules: %s\n", strings.Join(failing, ", "))))
+			}
 		} else {
 			w.WriteHeader(http.StatusInternalServerError)
 			_, _ = w.Write([]byte("Startup converge in progress\n"))
@@ -60,6 +63,10 @@ func RegisterDefaultRoutes(op *AddonOperator) {
 			}
 		}
 
+		if failing := op.FailingModules.List(); len(failing) > 0 {
+			statusLines = append(statusLines, fmt.Sprintf("DEGRADED: %s", strings.Join(failing, ", ")))
+		}
+
 		_, _ = writer.Write([]byte(strings.Join(statusLines, "\n") + "\n"))
 	})
 }
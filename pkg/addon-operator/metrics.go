@@ -38,6 +38,14 @@ func RegisterHookMetrics(metricStorage *metric_storage.MetricStorage) {
 			"module": "",
 			"hook":   "",
 		})
+	// startup timing
+	metricStorage.RegisterGauge("{PREFIX}startup_seconds", map[string]string{"phase": ""})
+	metricStorage.RegisterGauge(
+		"{PREFIX}hooks_load_seconds",
+		map[string]string{
+			"module": "",
+			"phase":  "",
+		})
 	// ConfigMap validation errors
 	metricStorage.RegisterCounter("{PREFIX}config_values_errors_total", map[string]string{})
 
@@ -55,6 +63,9 @@ func RegisterHookMetrics(metricStorage *metric_storage.MetricStorage) {
 		buckets_1msTo10s,
 	)
 	metricStorage.RegisterCounter("{PREFIX}module_run_errors_total", map[string]string{"module": ""})
+	metricStorage.RegisterCounter("{PREFIX}task_retries_exhausted_total", map[string]string{"module": "", "hook": ""})
+	metricStorage.RegisterCounter("{PREFIX}hook_timeout_total", map[string]string{"module": "", "hook": ""})
+	metricStorage.RegisterCounter("{PREFIX}hook_limit_exceeded_total", map[string]string{"module": "", "hook": "", "limit": ""})
 
 	moduleHookLabels := map[string]string{
 		"module":     "",
@@ -150,12 +161,16 @@ func StartLiveTicksUpdater(metricStorage *metric_storage.MetricStorage) {
 func StartTasksQueueLengthUpdater(metricStorage *metric_storage.MetricStorage, tqs *queue.TaskQueueSet) {
 	go func() {
 		for {
-			// Gather task queues lengths.
-			tqs.Iterate(func(queue *queue.TaskQueue) {
-				queueLen := float64(queue.Length())
-				metricStorage.GaugeSet("{PREFIX}tasks_queue_length", queueLen, map[string]string{"queue": queue.Name})
-			})
+			UpdateTasksQueueLength(metricStorage, tqs)
 			time.Sleep(5 * time.Second)
 		}
 	}()
 }
+
+// UpdateTasksQueueLength gathers task queues lengths.
+func UpdateTasksQueueLength(metricStorage *metric_storage.MetricStorage, tqs *queue.TaskQueueSet) {
+	tqs.Iterate(func(queue *queue.TaskQueue) {
+		queueLen := float64(queue.Length())
+		metricStorage.GaugeSet("{PREFIX}tasks_queue_length", queueLen, map[string]string{"queue": queue.Name})
+	})
+}
@@ -0,0 +1,129 @@
+package addon_operator
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/alecthomas/kingpin.v2"
+
+	"github.com/flant/addon-operator/pkg/app"
+	"github.com/flant/addon-operator/pkg/module_manager"
+	"github.com/flant/addon-operator/pkg/utils"
+	"github.com/flant/addon-operator/pkg/values/docs"
+	"github.com/flant/addon-operator/pkg/values/validation"
+)
+
+// DefineModuleDocsCommand adds 'module docs' command. It should be called after app.DefineDebugCommands.
+func DefineModuleDocsCommand(kpApp *kingpin.Application) {
+	moduleCmd := kpApp.GetCommand("module")
+	if moduleCmd == nil {
+		moduleCmd = kpApp.Command("module", "List modules and dump their values")
+	}
+
+	var moduleName string
+	var format string
+	var outputDir string
+	docsCmd := moduleCmd.Command("docs", "Generate settings reference for global values and modules from OpenAPI schemas.").
+		Action(func(c *kingpin.ParseContext) error {
+			return GenerateModuleDocs(app.ModulesDir, app.GlobalHooksDir, moduleName, docs.Format(format), outputDir)
+		})
+	docsCmd.Arg("module_name", "Generate a page only for this module.").StringVar(&moduleName)
+	docsCmd.Flag("modules-dir", "paths where to search for module directories").
+		Envar("MODULES_DIR").
+		Default(app.ModulesDir).
+		StringVar(&app.ModulesDir)
+	docsCmd.Flag("global-hooks-dir", "a path where to search for global hook files (and OpenAPI schemas)").
+		Envar("GLOBAL_HOOKS_DIR").
+		Default(app.GlobalHooksDir).
+		StringVar(&app.GlobalHooksDir)
+	docsCmd.Flag("format", "Output format: markdown|html.").
+		Default(string(docs.Markdown)).
+		EnumVar(&format, string(docs.Markdown), string(docs.HTML))
+	docsCmd.Flag("output-dir", "Write a page per module, global.<ext> and index.<ext> with the ConfigMap example into this directory. Pages are printed to stdout if not set.").
+		StringVar(&outputDir)
+}
+
+// GenerateModuleDocs loads OpenAPI schemas and renders reference pages for config values.
+// If moduleName is set, only the page for this module is rendered.
+func GenerateModuleDocs(modulesDir string, globalHooksDir string, moduleName string, format docs.Format, outputDir string) error {
+	mm := module_manager.NewModuleManager()
+	mm.WithDirectories(modulesDir, globalHooksDir, "")
+	err := mm.LoadSchemas()
+	if err != nil {
+		return err
+	}
+	storage := mm.GetValuesValidator().SchemaStorage
+
+	pages := make([]*docs.Page, 0)
+	if moduleName == "" {
+		if s := storage.GlobalValuesSchema(validation.ConfigValuesSchema); s != nil {
+			pages = append(pages, docs.NewPage(utils.GlobalValuesKey, "Global settings", utils.GlobalValuesKey, s))
+		}
+	}
+	for _, name := range mm.GetModuleNames() {
+		if moduleName != "" && name != moduleName {
+			continue
+		}
+		m := mm.GetModule(name)
+		s := storage.ModuleValuesSchema(m.ValuesKey(), validation.ConfigValuesSchema)
+		pages = append(pages, docs.NewPage(name, fmt.Sprintf("Module %s", name), m.ValuesKey(), s))
+	}
+	if moduleName != "" && len(pages) == 0 {
+		return fmt.Errorf("module '%s' is not found in %s", moduleName, modulesDir)
+	}
+
+	files := make([]string, 0)
+	contents := make(map[string][]byte)
+	for _, page := range pages {
+		buf := new(bytes.Buffer)
+		err := docs.RenderPage(buf, format, page)
+		if err != nil {
+			return fmt.Errorf("render '%s' page: %v", page.Name, err)
+		}
+		fileName := page.Name + "." + format.Ext()
+		files = append(files, fileName)
+		contents[fileName] = buf.Bytes()
+	}
+
+	// Index with the aggregated ConfigMap example.
+	if moduleName == "" {
+		configMap, err := docs.ConfigMapExample(app.ConfigMapName, pages)
+		if err != nil {
+			return fmt.Errorf("render ConfigMap example: %v", err)
+		}
+		buf := new(bytes.Buffer)
+		err = docs.RenderIndex(buf, format, &docs.Index{
+			Title:     "Settings",
+			Pages:     pages,
+			ConfigMap: configMap,
+			Ext:       format.Ext(),
+		})
+		if err != nil {
+			return fmt.Errorf("render index page: %v", err)
+		}
+		fileName := "index." + format.Ext()
+		files = append([]string{fileName}, files...)
+		contents[fileName] = buf.Bytes()
+	}
+
+	if outputDir == "" {
+		for _, fileName := range files {
+			fmt.Println(string(contents[fileName]))
+		}
+		return nil
+	}
+
+	err = os.MkdirAll(outputDir, 0o755)
+	if err != nil {
+		return fmt.Errorf("create output directory: %v", err)
+	}
+	for _, fileName := range files {
+		err = os.WriteFile(filepath.Join(outputDir, fileName), contents[fileName], 0o644)
+		if err != nil {
+			return fmt.Errorf("write '%s': %v", fileName, err)
+		}
+	}
+	return nil
+}
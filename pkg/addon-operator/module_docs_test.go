@@ -0,0 +1,37 @@
+package addon_operator
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/onsi/gomega"
+
+	"github.com/flant/addon-operator/pkg/values/docs"
+)
+
+func Test_GenerateModuleDocs(t *testing.T) {
+	g := NewWithT(t)
+
+	modulesDir := filepath.Join("testdata", "config_validation", "modules")
+	globalHooksDir := filepath.Join("testdata", "config_validation", "global-hooks")
+	outputDir := t.TempDir()
+
+	err := GenerateModuleDocs(modulesDir, globalHooksDir, "", docs.Markdown, outputDir)
+	g.Expect(err).ShouldNot(HaveOccurred())
+
+	entries, err := os.ReadDir(outputDir)
+	g.Expect(err).ShouldNot(HaveOccurred())
+	names := make([]string, 0)
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	g.Expect(names).To(ConsistOf("index.md", "global.md", "module-one.md"))
+
+	page, err := os.ReadFile(filepath.Join(outputDir, "module-one.md"))
+	g.Expect(err).ShouldNot(HaveOccurred())
+	g.Expect(string(page)).To(ContainSubstring("## `replicas`\n\n- Type: `integer`\n- value >= 1\n"))
+
+	err = GenerateModuleDocs(modulesDir, globalHooksDir, "unknown-module", docs.HTML, outputDir)
+	g.Expect(err).Should(HaveOccurred())
+}
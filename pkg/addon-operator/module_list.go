@@ -0,0 +1,98 @@
+package addon_operator
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	sh_task "github.com/flant/shell-operator/pkg/task"
+	"github.com/flant/shell-operator/pkg/task/queue"
+
+	"github.com/flant/addon-operator/pkg/module_manager"
+	"github.com/flant/addon-operator/pkg/task"
+)
+
+// ModuleListItem is a module status with the number of module tasks in queues.
+type ModuleListItem struct {
+	*module_manager.ModuleStatus
+	QueueBacklog int `json:"queueBacklog"`
+}
+
+// ModuleList is a list of all known modules in order.
+type ModuleList []ModuleListItem
+
+// String renders module list as a table.
+func (l ModuleList) String() string {
+	var b strings.Builder
+	w := tabwriter.NewWriter(&b, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tENABLED\tSOURCE\tPHASE\tSYNC\tREVISION\tLAST SUCCESS\tBACKLOG\tERROR")
+	for _, item := range l {
+		lastSuccess := "-"
+		if item.LastSuccessTime != nil {
+			lastSuccess = item.LastSuccessTime.Format(time.RFC3339)
+		}
+		revision := item.HelmRevision
+		if revision == "" {
+			revision = "-"
+		}
+		lastError := item.LastError
+		if lastError == "" && len(item.HookErrors) > 0 {
+			hookNames := make([]string, 0, len(item.HookErrors))
+			for hookName := range item.HookErrors {
+				hookNames = append(hookNames, hookName)
+			}
+			sort.Strings(hookNames)
+			lastError = fmt.Sprintf("%s: %s", hookNames[0], item.HookErrors[hookNames[0]])
+		}
+		fmt.Fprintf(w, "%s\t%v\t%s\t%s\t%s\t%s\t%s\t%d\t%s\n",
+			item.Name,
+			item.Enabled,
+			item.EnabledSource,
+			item.Phase,
+			item.Synchronization,
+			revision,
+			lastSuccess,
+			item.QueueBacklog,
+			strings.ReplaceAll(lastError, "\n", " "),
+		)
+	}
+	_ = w.Flush()
+	return b.String()
+}
+
+// ModuleList returns statuses for all known modules.
+func (op *AddonOperator) ModuleList() ModuleList {
+	backlog := ModulesTasksInQueues(op.TaskQueues)
+
+	list := make(ModuleList, 0)
+	for _, moduleName := range op.ModuleManager.GetModuleNames() {
+		status := op.ModuleManager.GetModuleStatus(moduleName)
+		if status == nil {
+			continue
+		}
+		list = append(list, ModuleListItem{
+			ModuleStatus: status,
+			QueueBacklog: backlog[moduleName],
+		})
+	}
+	return list
+}
+
+// ModulesTasksInQueues returns a number of tasks related to each module in all queues.
+func ModulesTasksInQueues(tqs *queue.TaskQueueSet) map[string]int {
+	res := make(map[string]int)
+	if tqs == nil {
+		return res
+	}
+	tqs.Iterate(func(q *queue.TaskQueue) {
+		q.Iterate(func(t sh_task.Task) {
+			hm := task.HookMetadataAccessor(t)
+			if hm.ModuleName != "" {
+				res[hm.ModuleName]++
+			}
+		})
+	})
+	return res
+}
@@ -0,0 +1,187 @@
+package addon_operator
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	klient "github.com/flant/kube-client/client"
+	log "github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/yaml"
+
+	"github.com/flant/addon-operator/pkg/app"
+)
+
+// StatusConfigMapName returns a name of the ConfigMap with modules status.
+func StatusConfigMapName() string {
+	if app.StatusConfigMapName != "" {
+		return app.StatusConfigMapName
+	}
+	return app.ConfigMapName + "-status"
+}
+
+// UpdateModuleStatus queues module status to store it as a YAML document in the status ConfigMap.
+// The ConfigMap is updated in background, so API latency does not delay tasks.
+// Errors are not fatal for tasks and only logged.
+func (op *AddonOperator) UpdateModuleStatus(moduleName string) {
+	if op.KubeClient == nil {
+		return
+	}
+
+	status := op.ModuleManager.GetModuleStatus(moduleName)
+	if status == nil {
+		return
+	}
+
+	data, err := yaml.Marshal(status)
+	if err != nil {
+		log.Errorf("Marshal status for module '%s': %v", moduleName, err)
+		return
+	}
+
+	op.moduleStatusWriter.once.Do(func() {
+		go op.runModuleStatusWriter()
+	})
+	op.moduleStatusWriter.add(moduleName, string(data))
+}
+
+// moduleStatusRetryDelay is a delay before the next attempt to save statuses after an error.
+var moduleStatusRetryDelay = 5 * time.Second
+
+// moduleStatusWriter collects module statuses to save them to the status ConfigMap.
+// Statuses queued while the previous update is in progress are saved in one batch.
+type moduleStatusWriter struct {
+	mu      sync.Mutex
+	pending map[string]string
+	notify  chan struct{}
+	once    sync.Once
+}
+
+func newModuleStatusWriter() *moduleStatusWriter {
+	return &moduleStatusWriter{
+		pending: make(map[string]string),
+		notify:  make(chan struct{}, 1),
+	}
+}
+
+func (w *moduleStatusWriter) add(moduleName string, data string) {
+	w.mu.Lock()
+	w.pending[moduleName] = data
+	w.mu.Unlock()
+
+	select {
+	case w.notify <- struct{}{}:
+	default:
+	}
+}
+
+// requeue returns statuses from the failed batch unless newer statuses are queued.
+func (w *moduleStatusWriter) requeue(batch map[string]string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for moduleName, data := range batch {
+		if _, has := w.pending[moduleName]; !has {
+			w.pending[moduleName] = data
+		}
+	}
+}
+
+func (w *moduleStatusWriter) take() map[string]string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	batch := w.pending
+	w.pending = make(map[string]string)
+	return batch
+}
+
+// runModuleStatusWriter saves queued statuses until the operator is stopped.
+func (op *AddonOperator) runModuleStatusWriter() {
+	w := op.moduleStatusWriter
+	for {
+		select {
+		case <-op.ctx.Done():
+			return
+		case <-w.notify:
+		}
+
+		batch := w.take()
+		if len(batch) == 0 {
+			continue
+		}
+
+		err := saveStatusConfigMap(op.ctx, op.KubeClient, StatusConfigMapName(), batch, op.ModuleManager.GetModuleNames())
+		if err == nil {
+			continue
+		}
+		log.Errorf("Update modules status in ConfigMap/%s: %v", StatusConfigMapName(), err)
+		w.requeue(batch)
+		select {
+		case <-op.ctx.Done():
+			return
+		case <-time.After(moduleStatusRetryDelay):
+		}
+		select {
+		case w.notify <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// saveStatusConfigMap creates a ConfigMap or updates keys in its data.
+// Keys for modules that are not in moduleNames are deleted.
+func saveStatusConfigMap(ctx context.Context, kubeClient klient.Client, name string, statuses map[string]string, moduleNames []string) error {
+	cms := kubeClient.CoreV1().ConfigMaps(app.Namespace)
+
+	known := make(map[string]struct{}, len(moduleNames))
+	for _, moduleName := range moduleNames {
+		known[moduleName] = struct{}{}
+	}
+
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		obj, err := cms.Get(ctx, name, metav1.GetOptions{})
+		if errors.IsNotFound(err) {
+			obj = &v1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      name,
+					Namespace: app.Namespace,
+				},
+				Data: make(map[string]string, len(statuses)),
+			}
+			for key, value := range statuses {
+				obj.Data[key] = value
+			}
+			_, err = cms.Create(ctx, obj, metav1.CreateOptions{})
+			return err
+		}
+		if err != nil {
+			return fmt.Errorf("get: %v", err)
+		}
+
+		if obj.Data == nil {
+			obj.Data = make(map[string]string)
+		}
+		changed := false
+		for key, value := range statuses {
+			if obj.Data[key] != value {
+				obj.Data[key] = value
+				changed = true
+			}
+		}
+		for key := range obj.Data {
+			if _, has := known[key]; !has {
+				delete(obj.Data, key)
+				changed = true
+			}
+		}
+		if !changed {
+			return nil
+		}
+		_, err = cms.Update(ctx, obj, metav1.UpdateOptions{})
+		return err
+	})
+}
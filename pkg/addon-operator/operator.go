@@ -19,7 +19,10 @@ import (
 	. "github.com/flant/shell-operator/pkg/utils/measure"
 	log "github.com/sirupsen/logrus"
 	uuid "gopkg.in/satori/go.uuid.v1"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/record"
 
+	"github.com/flant/addon-operator/pkg/app"
 	"github.com/flant/addon-operator/pkg/helm"
 	"github.com/flant/addon-operator/pkg/helm_resources_manager"
 	. "github.com/flant/addon-operator/pkg/hook/types"
@@ -51,14 +54,28 @@ type AddonOperator struct {
 	// converge state
 	ConvergeState *ConvergeState
 
+	// FailingModules are modules with failed ModuleRun tasks moved out of the 'main' queue.
+	FailingModules *FailingModules
+
 	// Initial KubeConfig to bypass initial loading from the ConfigMap.
 	InitialKubeConfig *kube_config_manager.KubeConfig
+
+	// EventRecorder publishes Kubernetes Events about modules and hooks.
+	EventBroadcaster record.EventBroadcaster
+	EventRecorder    record.EventRecorder
+	// ValuesHistoryEventRecorder publishes Kubernetes Events about values patches from hooks.
+	ValuesHistoryEventRecorder record.EventRecorder
+
+	moduleStatusWriter *moduleStatusWriter
 }
 
 func NewAddonOperator() *AddonOperator {
 	return &AddonOperator{
-		ShellOperator: &shell_operator.ShellOperator{},
-		ConvergeState: NewConvergeState(),
+		ShellOperator:  &shell_operator.ShellOperator{},
+		ConvergeState:  NewConvergeState(),
+		FailingModules: NewFailingModules(),
+
+		moduleStatusWriter: newModuleStatusWriter(),
 	}
 }
 
@@ -74,6 +91,15 @@ func (op *AddonOperator) Stop() {
 	}
 }
 
+// saveConvertedConfigValues saves module config values converted from older versions
+// of the config-values schema back to the ConfigMap if write-back is enabled.
+// It is called outside of SafeReadConfig as saving locks the KubeConfigManager.
+func (op *AddonOperator) saveConvertedConfigValues() {
+	if app.ConfigConversionWriteBack {
+		op.ModuleManager.SaveConvertedConfigValues()
+	}
+}
+
 func (op *AddonOperator) IsStartupConvergeDone() bool {
 	return op.ConvergeState.firstRunPhase == firstDone
 }
@@ -105,6 +131,7 @@ func (op *AddonOperator) InitModuleManager() error {
 		if err != nil {
 			return fmt.Errorf("init module manager: load config from ConfigMap: %s", err)
 		}
+		op.saveConvertedConfigValues()
 	} else {
 		_, err = op.ModuleManager.HandleNewKubeConfig(op.InitialKubeConfig)
 		if err != nil {
@@ -115,6 +142,9 @@ func (op *AddonOperator) InitModuleManager() error {
 	// Initialize 'valid kube config' flag.
 	op.ModuleManager.SetKubeConfigValid(true)
 
+	// Run '--config' for hooks of modules enabled by config in parallel.
+	op.ModuleManager.PreloadModuleHooks()
+
 	// ManagerEventsHandlers created, register handlers to create tasks from events.
 	op.RegisterManagerEventsHandlers()
 
@@ -489,6 +519,7 @@ func (op *AddonOperator) HandleConvergeModules(t sh_task.Task, logLabels map[str
 		if handleErr == nil {
 			// KubeConfigChanged task should be removed.
 			res.Status = queue.Success
+			op.saveConvertedConfigValues()
 
 			if state == nil {
 				logEntry.Infof("ConvergeModules: kube config modification detected, no changes in config values")
@@ -587,6 +618,10 @@ func (op *AddonOperator) HandleConvergeModules(t sh_task.Task, logLabels map[str
 
 		if op.ConvergeState.Phase == WaitDeleteAndRunModules {
 			logEntry.Infof("ConvergeModules: ModuleRun tasks done, execute AfterAll global hooks")
+			// Hooks of modules that are not registered on startup are searched again if modules are enabled later.
+			if !op.IsStartupConvergeDone() {
+				op.ModuleManager.DropPreloadedModuleHooks()
+			}
 			// Put AfterAll tasks before current task.
 			tasks, handleErr := op.CreateAfterAllTasks(t.GetLogLabels(), hm.EventDescription)
 			if handleErr == nil {
@@ -801,11 +836,15 @@ func (op *AddonOperator) StartModuleManagerEventHandler() {
 				if kubeConfigEvent == kube_config_manager.KubeConfigInvalid {
 					op.ModuleManager.SetKubeConfigValid(false)
 					eventLogEntry.Infof("KubeConfig become invalid")
+					op.RecordEvent(v1.EventTypeWarning, EventReasonConfigInvalid,
+						"ConfigMap/%s has invalid values, modules are not converged until values are fixed", app.ConfigMapName)
 				}
 
 				if kubeConfigEvent == kube_config_manager.KubeConfigChanged {
 					if !op.ModuleManager.GetKubeConfigValid() {
 						eventLogEntry.Infof("KubeConfig become valid")
+						op.RecordEvent(v1.EventTypeNormal, EventReasonConfigValid,
+							"ConfigMap/%s values are valid", app.ConfigMapName)
 					}
 					// Config is valid now, add task to update ModuleManager state.
 					op.ModuleManager.SetKubeConfigValid(true)
@@ -1109,8 +1148,12 @@ func (op *AddonOperator) HandleModulePurge(t sh_task.Task, labels map[string]str
 	if err != nil {
 		// Purge is for unknown modules, just print warning.
 		logEntry.Warnf("Module purge failed, no retry. Error: %s", err)
+		op.RecordModuleEvent(hm.ModuleName, v1.EventTypeWarning, EventReasonModulePurgeFailed,
+			"Purge Helm release for unknown module '%s' failed: %s", hm.ModuleName, err)
 	} else {
 		logEntry.Debugf("Module purge success")
+		op.RecordModuleEvent(hm.ModuleName, v1.EventTypeNormal, EventReasonModulePurged,
+			"Helm release for unknown module '%s' is purged", hm.ModuleName)
 	}
 	status = queue.Success
 	return
@@ -1136,19 +1179,27 @@ func (op *AddonOperator) HandleModuleDelete(t sh_task.Task, labels map[string]st
 		// op.ModuleManager.DisableModuleHooks(hm.ModuleName)
 		// Remove all hooks from parallel queues.
 		op.DrainModuleQueues(hm.ModuleName)
+		RemoveModuleRunTasks(op.TaskQueues.GetByName(ModuleRunRetryQueueName), hm.ModuleName)
 		err = op.ModuleManager.DeleteModule(hm.ModuleName, t.GetLogLabels())
 	}
 
-	module.State.LastModuleErr = err
+	module.State.SetLastModuleErr(err)
 	if err != nil {
 		op.MetricStorage.CounterAdd("{PREFIX}module_delete_errors_total", 1.0, map[string]string{"module": hm.ModuleName})
 		logEntry.Errorf("Module delete failed, requeue task to retry after delay. Failed count is %d. Error: %s", t.GetFailureCount()+1, err)
 		t.UpdateFailureMessage(err.Error())
 		t.WithQueuedAt(time.Now())
 		status = queue.Fail
+		op.UpdateModuleStatus(hm.ModuleName)
+		op.RecordModuleEvent(hm.ModuleName, v1.EventTypeWarning, EventReasonModuleDeleteFailed,
+			"Module '%s' delete failed: %s", hm.ModuleName, err)
 	} else {
 		logEntry.Debugf("Module delete success '%s'", hm.ModuleName)
 		status = queue.Success
+		op.FailingModules.Remove(hm.ModuleName)
+		op.UpdateModuleStatus(hm.ModuleName)
+		op.RecordModuleEvent(hm.ModuleName, v1.EventTypeNormal, EventReasonModuleDisabled,
+			"Module '%s' is disabled, Helm release is deleted", hm.ModuleName)
 	}
 
 	return
@@ -1175,12 +1226,28 @@ func (op *AddonOperator) HandleModuleRun(t sh_task.Task, labels map[string]strin
 	hm := task.HookMetadataAccessor(t)
 	module := op.ModuleManager.GetModule(hm.ModuleName)
 
+	runLock := op.FailingModules.RunLock(hm.ModuleName)
+	runLock.Lock()
+	defer runLock.Unlock()
+
 	// Break error loop when module becomes disabled.
 	if !op.ModuleManager.IsModuleEnabled(module.Name) {
+		op.FailingModules.Remove(hm.ModuleName)
 		res.Status = queue.Success
 		return
 	}
 
+	if t.GetQueueName() == ModuleRunRetryQueueName {
+		// Module is recovered or will be run by the next converge in the 'main' queue.
+		if !op.FailingModules.Has(hm.ModuleName) || QueueHasModuleRunTask(op.TaskQueues.GetMain(), hm.ModuleName) {
+			logEntry.Infof("ModuleRun in the retry queue is not needed anymore, drop task")
+			res.Status = queue.Success
+			return
+		}
+	} else {
+		RemoveModuleRunTasks(op.TaskQueues.GetByName(ModuleRunRetryQueueName), hm.ModuleName)
+	}
+
 	metricLabels := map[string]string{
 		"module":     hm.ModuleName,
 		"activation": labels["event.type"],
@@ -1192,6 +1259,7 @@ func (op *AddonOperator) HandleModuleRun(t sh_task.Task, labels map[string]strin
 
 	var moduleRunErr error
 	valuesChanged := false
+	helmRun := false
 
 	// First module run on operator startup or when module is enabled.
 	if module.State.Phase == module_manager.Startup {
@@ -1209,11 +1277,11 @@ func (op *AddonOperator) HandleModuleRun(t sh_task.Task, labels map[string]strin
 				// Run onStartup hooks.
 				moduleRunErr = module.RunOnStartup(t.GetLogLabels())
 				if moduleRunErr == nil {
-					module.State.Phase = module_manager.OnStartupDone
+					module.State.SetPhase(module_manager.OnStartupDone)
 				}
 				treg.End()
 			} else {
-				module.State.Phase = module_manager.OnStartupDone
+				module.State.SetPhase(module_manager.OnStartupDone)
 			}
 		}
 	}
@@ -1221,10 +1289,10 @@ func (op *AddonOperator) HandleModuleRun(t sh_task.Task, labels map[string]strin
 	if module.State.Phase == module_manager.OnStartupDone {
 		logEntry.Debugf("ModuleRun '%s' phase", module.State.Phase)
 		if module.HasKubernetesHooks() {
-			module.State.Phase = module_manager.QueueSynchronizationTasks
+			module.State.SetPhase(module_manager.QueueSynchronizationTasks)
 		} else {
 			// Skip Synchronization process if there are no kubernetes hooks.
-			module.State.Phase = module_manager.EnableScheduleBindings
+			module.State.SetPhase(module_manager.EnableScheduleBindings)
 		}
 	}
 
@@ -1273,7 +1341,7 @@ func (op *AddonOperator) HandleModuleRun(t sh_task.Task, labels map[string]strin
 				WithMetadata(taskMeta)
 			newTask.WithQueuedAt(time.Now())
 
-			if info.QueueName == t.GetQueueName() {
+			if info.QueueName == op.TaskQueues.MainName {
 				// Ignore "waitForSynchronization: false" for hooks in the main queue.
 				// There is no way to not wait for these hooks.
 				mainSyncTasks = append(mainSyncTasks, newTask)
@@ -1316,10 +1384,10 @@ func (op *AddonOperator) HandleModuleRun(t sh_task.Task, labels map[string]strin
 
 			if len(parallelSyncTasksToWait) == 0 {
 				// Skip waiting tasks in parallel queues, proceed to schedule bindings.
-				module.State.Phase = module_manager.EnableScheduleBindings
+				module.State.SetPhase(module_manager.EnableScheduleBindings)
 			} else {
 				// There are tasks to wait.
-				module.State.Phase = module_manager.WaitForSynchronization
+				module.State.SetPhase(module_manager.WaitForSynchronization)
 				logEntry.WithField("module.state", "wait-for-synchronization").
 					Debugf("ModuleRun wait for Synchronization")
 			}
@@ -1338,7 +1406,7 @@ func (op *AddonOperator) HandleModuleRun(t sh_task.Task, labels map[string]strin
 	if module.State.Phase == module_manager.WaitForSynchronization {
 		if module.State.Synchronization().IsComplete() {
 			// Proceed with the next phase.
-			module.State.Phase = module_manager.EnableScheduleBindings
+			module.State.SetPhase(module_manager.EnableScheduleBindings)
 			logEntry.Info("Synchronization done for module hooks")
 		} else {
 			// Debug messages every fifth second: print Synchronization state.
@@ -1358,7 +1426,7 @@ func (op *AddonOperator) HandleModuleRun(t sh_task.Task, labels map[string]strin
 		logEntry.Debugf("ModuleRun '%s' phase", module.State.Phase)
 
 		op.ModuleManager.EnableModuleScheduleBindings(hm.ModuleName)
-		module.State.Phase = module_manager.CanRunHelm
+		module.State.SetPhase(module_manager.CanRunHelm)
 	}
 
 	// Module start is done, module is ready to run hooks and helm chart.
@@ -1366,17 +1434,51 @@ func (op *AddonOperator) HandleModuleRun(t sh_task.Task, labels map[string]strin
 		logEntry.Debugf("ModuleRun '%s' phase", module.State.Phase)
 		// run beforeHelm, helm, afterHelm
 		valuesChanged, moduleRunErr = module.Run(t.GetLogLabels())
+		module.State.SetLastRunTime(time.Now())
+		helmRun = true
+	}
+
+	if module_manager.IsHookCanceled(op.ModuleManager.Context(), moduleRunErr) {
+		logEntry.Infof("ModuleRun is canceled in phase '%s', operator is shutting down: %v", module.State.Phase, moduleRunErr)
+		res.Status = queue.Repeat
+		return
 	}
 
-	module.State.LastModuleErr = moduleRunErr
+	module.State.SetLastModuleErr(moduleRunErr)
+	defer op.UpdateModuleStatus(hm.ModuleName)
 	if moduleRunErr != nil {
 		res.Status = queue.Fail
 		logEntry.Errorf("ModuleRun failed in phase '%s'. Requeue task to retry after delay. Failed count is %d. Error: %s", module.State.Phase, t.GetFailureCount()+1, moduleRunErr)
 		op.MetricStorage.CounterAdd("{PREFIX}module_run_errors_total", 1.0, map[string]string{"module": hm.ModuleName})
 		t.UpdateFailureMessage(moduleRunErr.Error())
 		t.WithQueuedAt(time.Now())
+		op.RecordModuleEvent(hm.ModuleName, v1.EventTypeWarning, EventReasonModuleRunFailed,
+			"Module '%s' run failed in phase '%s': %s", hm.ModuleName, module.State.Phase, moduleRunErr)
+		if applyRetryPolicy(module.RetryPolicy, t, &res) {
+			logEntry.Warnf("ModuleRun failed %d times, give up: module is marked as failed, continue converge", t.GetFailureCount()+1)
+			op.MetricStorage.CounterAdd("{PREFIX}task_retries_exhausted_total", 1.0, map[string]string{"module": hm.ModuleName, "hook": ""})
+			op.RecordModuleEvent(hm.ModuleName, v1.EventTypeWarning, EventReasonRetriesExhausted,
+				"Module '%s' run failed %d times, give up", hm.ModuleName, t.GetFailureCount()+1)
+			op.FailingModules.Add(hm.ModuleName, moduleRunErr.Error())
+		} else if ShouldMoveToRetryQueue(t) {
+			newTask := op.MoveModuleRunToRetryQueue(t, moduleRunErr.Error())
+			logEntry.Warnf("ModuleRun failed %d times, move task to the queue '%s', continue converge", t.GetFailureCount()+1, ModuleRunRetryQueueName)
+			op.logTaskAdd(logEntry, "append", newTask)
+			res.Status = queue.Success
+			res.DelayBeforeNextTask = 0
+		}
 	} else {
 		res.Status = queue.Success
+		op.FailingModules.Remove(hm.ModuleName)
+		if helmRun {
+			module.State.SetLastSuccessTime(module.State.LastRunTime)
+			if hm.DoModuleStartup {
+				op.RecordModuleEvent(hm.ModuleName, v1.EventTypeNormal, EventReasonModuleEnabled,
+					"Module '%s' is enabled and started", hm.ModuleName)
+			}
+			op.RecordModuleEvent(hm.ModuleName, v1.EventTypeNormal, EventReasonModuleRunSucceeded,
+				"Module '%s' hooks and Helm release are successfully applied", hm.ModuleName)
+		}
 		if valuesChanged {
 			logEntry.Infof("ModuleRun success, values changed, restart module")
 			// One of afterHelm hooks changes values, run ModuleRun again: copy task, but disable startup hooks.
@@ -1412,7 +1514,7 @@ func (op *AddonOperator) HandleModuleHookRun(t sh_task.Task, labels map[string]s
 		return
 	}
 
-	err := taskHook.RateLimitWait(context.Background())
+	err := taskHook.RateLimitWait(op.ModuleManager.Context())
 	if err != nil {
 		// This could happen when the Context is
 		// canceled, or the expected wait time exceeds the Context's Deadline.
@@ -1496,6 +1598,11 @@ func (op *AddonOperator) HandleModuleHookRun(t sh_task.Task, labels map[string]s
 		allowed := 0.0
 
 		beforeChecksum, afterChecksum, err := op.ModuleManager.RunModuleHook(hm.HookName, hm.BindingType, hm.BindingContext, t.GetLogLabels())
+		if module_manager.IsHookCanceled(op.ModuleManager.Context(), err) {
+			logEntry.Infof("Module hook is canceled, operator is shutting down: %v", err)
+			res.Status = queue.Repeat
+			return
+		}
 		if err != nil {
 			if hm.AllowFailure {
 				allowed = 1.0
@@ -1509,6 +1616,18 @@ func (op *AddonOperator) HandleModuleHookRun(t sh_task.Task, labels map[string]s
 				t.WithQueuedAt(time.Now())
 				res.Status = queue.Fail
 				taskHook.Module.State.SetLastHookErr(hm.HookName, err)
+				op.RecordModuleEvent(hm.ModuleName, v1.EventTypeWarning, EventReasonHookFailed,
+					"Module hook '%s' failed: %s", hm.HookName, err)
+				retryPolicy := taskHook.RetryPolicy()
+				if retryPolicy == nil {
+					retryPolicy = taskHook.Module.RetryPolicy
+				}
+				if applyRetryPolicy(retryPolicy, t, &res) {
+					logEntry.Warnf("Module hook failed %d times, give up", t.GetFailureCount()+1)
+					op.MetricStorage.CounterAdd("{PREFIX}task_retries_exhausted_total", 1.0, map[string]string{"module": hm.ModuleName, "hook": hm.HookName})
+					op.RecordModuleEvent(hm.ModuleName, v1.EventTypeWarning, EventReasonRetriesExhausted,
+						"Module hook '%s' failed %d times, give up", hm.HookName, t.GetFailureCount()+1)
+				}
 			}
 		} else {
 			success = 1.0
@@ -1599,7 +1718,7 @@ func (op *AddonOperator) HandleGlobalHookRun(t sh_task.Task, labels map[string]s
 	hm := task.HookMetadataAccessor(t)
 	taskHook := op.ModuleManager.GetGlobalHook(hm.HookName)
 
-	err := taskHook.RateLimitWait(context.Background())
+	err := taskHook.RateLimitWait(op.ModuleManager.Context())
 	if err != nil {
 		// This could happen when the Context is
 		// canceled, or the expected wait time exceeds the Context's Deadline.
@@ -1684,6 +1803,11 @@ func (op *AddonOperator) HandleGlobalHookRun(t sh_task.Task, labels map[string]s
 		dynamicEnabledChecksumBeforeHookRun := op.ModuleManager.DynamicEnabledChecksum()
 		// Run Global hook.
 		beforeChecksum, afterChecksum, err := op.ModuleManager.RunGlobalHook(hm.HookName, hm.BindingType, hm.BindingContext, t.GetLogLabels())
+		if module_manager.IsHookCanceled(op.ModuleManager.Context(), err) {
+			logEntry.Infof("Global hook is canceled, operator is shutting down: %v", err)
+			res.Status = queue.Repeat
+			return
+		}
 		if err != nil {
 			if hm.AllowFailure {
 				allowed = 1.0
@@ -1695,6 +1819,14 @@ func (op *AddonOperator) HandleGlobalHookRun(t sh_task.Task, labels map[string]s
 				t.UpdateFailureMessage(err.Error())
 				t.WithQueuedAt(time.Now())
 				res.Status = queue.Fail
+				op.RecordEvent(v1.EventTypeWarning, EventReasonHookFailed,
+					"Global hook '%s' failed: %s", hm.HookName, err)
+				if applyRetryPolicy(taskHook.RetryPolicy(), t, &res) {
+					logEntry.Warnf("Global hook failed %d times, give up", t.GetFailureCount()+1)
+					op.MetricStorage.CounterAdd("{PREFIX}task_retries_exhausted_total", 1.0, map[string]string{"module": "", "hook": hm.HookName})
+					op.RecordEvent(v1.EventTypeWarning, EventReasonRetriesExhausted,
+						"Global hook '%s' failed %d times, give up", hm.HookName, t.GetFailureCount()+1)
+				}
 			}
 		} else {
 			// Calculate new checksum of *Enabled values.
@@ -1941,11 +2073,6 @@ func (op *AddonOperator) UpdateFirstConvergeStatus(convergeTasks int) {
 	}
 }
 
-func (op *AddonOperator) Shutdown() {
-	op.KubeConfigManager.Stop()
-	op.ShellOperator.Shutdown()
-}
-
 // taskDescriptionForTaskFlowLog returns a human friendly description of the task.
 func taskDescriptionForTaskFlowLog(tsk sh_task.Task, action string, phase string, status string) string {
 	hm := task.HookMetadataAccessor(tsk)
@@ -8,21 +8,27 @@ import (
 	"strings"
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/flant/kube-client/fake"
+	"github.com/flant/shell-operator/pkg/hook/binding_context"
 	. "github.com/flant/shell-operator/pkg/hook/types"
+	"github.com/flant/shell-operator/pkg/metric_storage"
 	shell_operator "github.com/flant/shell-operator/pkg/shell-operator"
 	sh_task "github.com/flant/shell-operator/pkg/task"
 	"github.com/flant/shell-operator/pkg/task/queue"
 	file_utils "github.com/flant/shell-operator/pkg/utils/file"
 	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	log "github.com/sirupsen/logrus"
 	logrus_test "github.com/sirupsen/logrus/hooks/test"
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	k8types "k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
 	"sigs.k8s.io/yaml"
 
+	"github.com/flant/addon-operator/pkg/app"
 	mockhelm "github.com/flant/addon-operator/pkg/helm/test/mock"
 	mockhelmresmgr "github.com/flant/addon-operator/pkg/helm_resources_manager/test/mock"
 	. "github.com/flant/addon-operator/pkg/hook/types"
@@ -331,6 +337,124 @@ func Test_Operator_ConvergeModules_main_queue_only(t *testing.T) {
 	}
 }
 
+// This test case checks Events emitted for module lifecycle during converge.
+func Test_Operator_ConvergeModules_events(t *testing.T) {
+	g := NewWithT(t)
+	// Mute messages about registration and tasks queueing.
+	log.SetLevel(log.ErrorLevel)
+
+	op, res := assembleTestAddonOperator(t, "converge__main_queue_only")
+	recorder := record.NewFakeRecorder(100)
+	op.EventRecorder = recorder
+	op.BootstrapMainQueue(op.TaskQueues)
+
+	res.helmClient.ReleaseNames = []string{"moduleToPurge", "module-beta"}
+
+	op.TaskQueues.StartMain()
+
+	// Wait until converge is done.
+	g.Eventually(convergeDone(op), "30s", "200ms").Should(BeTrue())
+
+	events := make([]string, 0)
+	for len(recorder.Events) > 0 {
+		events = append(events, <-recorder.Events)
+	}
+
+	g.Expect(events).Should(ContainElement("Normal ModulePurged Helm release for unknown module 'moduleToPurge' is purged"))
+	g.Expect(events).Should(ContainElement("Normal ModuleDisabled Module 'module-beta' is disabled, Helm release is deleted"))
+	g.Expect(events).Should(ContainElement("Normal ModuleEnabled Module 'module-alpha' is enabled and started"))
+	g.Expect(events).Should(ContainElement("Normal ModuleRunSucceeded Module 'module-alpha' hooks and Helm release are successfully applied"))
+}
+
+// This test case checks that converge in the 'main' queue is not blocked
+// by the failing module running in the retry queue.
+func Test_Operator_ConvergeModules_failing_module_in_retry_queue(t *testing.T) {
+	g := NewWithT(t)
+	// Mute messages about registration and tasks queueing.
+	log.SetLevel(log.ErrorLevel)
+
+	defer func(v int) { app.ModuleRunRetryQueueAfterFailures = v }(app.ModuleRunRetryQueueAfterFailures)
+	app.ModuleRunRetryQueueAfterFailures = 1
+
+	// The hook in module-failing fails on the first run and blocks on next runs until the block file is removed.
+	// The hook in module-alpha waits until the hook in module-failing is blocked.
+	tmpDir := t.TempDir()
+	blockFile := filepath.Join(tmpDir, "block")
+	g.Expect(os.WriteFile(blockFile, nil, 0o644)).Should(Succeed())
+	t.Setenv("FAILING_HOOK_MARKER", filepath.Join(tmpDir, "marker"))
+	t.Setenv("FAILING_HOOK_BLOCK", blockFile)
+	t.Setenv("FAILING_HOOK_RUNNING", filepath.Join(tmpDir, "running"))
+	defer os.Remove(blockFile)
+
+	op, _ := assembleTestAddonOperator(t, "converge__failing_module")
+	op.BootstrapMainQueue(op.TaskQueues)
+	op.TaskQueues.StartMain()
+
+	// Converge is done while ModuleRun for module-failing is still running in the retry queue.
+	g.Eventually(convergeDone(op), "10s", "200ms").Should(BeTrue())
+	g.Expect(filepath.Join(tmpDir, "running")).Should(BeAnExistingFile())
+	g.Expect(op.FailingModules.List()).Should(Equal([]string{"module-failing"}))
+	g.Expect(op.ModuleManager.GetModule("module-alpha").State.LastModuleErr).ShouldNot(HaveOccurred())
+	g.Expect(op.TaskQueues.GetByName(ModuleRunRetryQueueName).IsEmpty()).Should(BeFalse())
+}
+
+// This test case checks modules status in the status ConfigMap after converge.
+func Test_Operator_ConvergeModules_status(t *testing.T) {
+	g := NewWithT(t)
+	// Mute messages about registration and tasks queueing.
+	log.SetLevel(log.ErrorLevel)
+
+	op, res := assembleTestAddonOperator(t, "converge__main_queue_only")
+	op.BootstrapMainQueue(op.TaskQueues)
+
+	res.helmClient.ReleaseNames = []string{"module-beta"}
+
+	// Status of the module that is no longer exists should be deleted.
+	_, err := op.KubeClient.CoreV1().ConfigMaps(app.Namespace).Create(context.TODO(), &v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: StatusConfigMapName(), Namespace: app.Namespace},
+		Data:       map[string]string{"removed-module": "enabled: true\n"},
+	}, metav1.CreateOptions{})
+	g.Expect(err).ShouldNot(HaveOccurred())
+
+	op.TaskQueues.StartMain()
+
+	// Wait until converge is done.
+	g.Eventually(convergeDone(op), "30s", "200ms").Should(BeTrue())
+
+	// Statuses are saved in background.
+	var cm *v1.ConfigMap
+	g.Eventually(func() map[string]string {
+		cm, err = op.KubeClient.CoreV1().ConfigMaps(app.Namespace).Get(context.TODO(), StatusConfigMapName(), metav1.GetOptions{})
+		g.Expect(err).ShouldNot(HaveOccurred())
+		return cm.Data
+	}, "5s", "50ms").Should(And(
+		HaveKeyWithValue("module-alpha", ContainSubstring("lastSuccessTime")),
+		HaveKey("module-beta"),
+		Not(HaveKey("removed-module")),
+	))
+
+	alpha := new(module_manager.ModuleStatus)
+	g.Expect(yaml.Unmarshal([]byte(cm.Data["module-alpha"]), alpha)).Should(Succeed())
+	g.Expect(alpha.Enabled).Should(BeTrue())
+	g.Expect(alpha.EnabledSource).Should(Equal(module_manager.EnabledSourceConfigMap))
+	g.Expect(alpha.Phase).Should(Equal(module_manager.CanRunHelm))
+	g.Expect(alpha.LastRunTime).ShouldNot(BeNil())
+	g.Expect(alpha.LastError).Should(BeEmpty())
+
+	beta := new(module_manager.ModuleStatus)
+	g.Expect(yaml.Unmarshal([]byte(cm.Data["module-beta"]), beta)).Should(Succeed())
+	g.Expect(beta.Enabled).Should(BeFalse())
+	g.Expect(beta.EnabledSource).Should(Equal(module_manager.EnabledSourceConfigMap))
+	g.Expect(beta.Phase).Should(Equal(module_manager.Startup))
+
+	list := op.ModuleList()
+	g.Expect(list).Should(HaveLen(2))
+	g.Expect(list[0].Name).Should(Equal("module-alpha"))
+	g.Expect(list[0].LastSuccessTime).ShouldNot(BeNil())
+	g.Expect(list[1].Name).Should(Equal("module-beta"))
+	g.Expect(list.String()).Should(ContainSubstring("module-beta"))
+}
+
 // This test case checks tasks sequence in the 'main' queue when
 // global section is changed during converge.
 func Test_HandleConvergeModules_global_changed_during_converge(t *testing.T) {
@@ -592,3 +716,67 @@ func Test_Operator_logTask(t *testing.T) {
 
 	g.Expect(hasWaitForSynchronizationMessages).Should(BeFalse(), "should not log messages about WaitForSynchronization")
 }
+
+// Test_HandleGlobalHookRun_RetryPolicy checks that a failed hook is retried
+// with a delay from 'settings.retry' and dropped when attempts are exhausted.
+func Test_HandleGlobalHookRun_RetryPolicy(t *testing.T) {
+	g := NewWithT(t)
+	log.SetLevel(log.ErrorLevel)
+
+	op, _ := assembleTestAddonOperator(t, "retry_policy__give_up")
+	op.MetricStorage = metric_storage.NewMetricStorage()
+	op.MetricStorage.WithNewRegistry()
+
+	hookNames := op.ModuleManager.GetGlobalHooksNames()
+	g.Expect(hookNames).To(HaveLen(1))
+	g.Expect(op.ModuleManager.GetGlobalHook(hookNames[0]).RetryPolicy()).ShouldNot(BeNil())
+
+	tsk := sh_task.NewTask(task.GlobalHookRun).
+		WithMetadata(task.HookMetadata{
+			HookName:       hookNames[0],
+			BindingType:    OnStartup,
+			BindingContext: []binding_context.BindingContext{{Binding: string(OnStartup)}},
+		})
+	exhaustedLabels := map[string]string{"module": "", "hook": hookNames[0]}
+	exhausted := op.MetricStorage.Counter("{PREFIX}task_retries_exhausted_total", exhaustedLabels).With(exhaustedLabels)
+
+	// The first failure: the task is retried with the delay from the policy.
+	res := op.HandleGlobalHookRun(tsk, map[string]string{})
+	g.Expect(res.Status).To(Equal(queue.Fail))
+	g.Expect(res.DelayBeforeNextTask).To(Equal(3 * time.Second))
+	g.Expect(testutil.ToFloat64(exhausted)).To(Equal(0.0))
+
+	// The second failure: attempts are exhausted, the task is dropped from the queue.
+	tsk.IncrementFailureCount()
+	res = op.HandleGlobalHookRun(tsk, map[string]string{})
+	g.Expect(res.Status).To(Equal(queue.Success))
+	g.Expect(testutil.ToFloat64(exhausted)).To(Equal(1.0))
+}
+
+// Synchronization tasks of main queue hooks should be run before ModuleRun
+// even if ModuleRun is moved to the retry queue and "waitForSynchronization: false" is set.
+func Test_HandleModuleRun_retry_queue_main_Synchronization(t *testing.T) {
+	g := NewWithT(t)
+	log.SetLevel(log.ErrorLevel)
+
+	op, _ := assembleTestAddonOperator(t, "module_run__retry_queue_synchronization")
+	op.BootstrapMainQueue(op.TaskQueues)
+	op.TaskQueues.StartMain()
+	g.Eventually(convergeDone(op), "30s", "200ms").Should(BeTrue())
+
+	// Restart the module run from the Synchronization phase in the retry queue.
+	module := op.ModuleManager.GetModule("module-alpha")
+	module.State.SetPhase(module_manager.QueueSynchronizationTasks)
+	op.FailingModules.Add(module.Name, "failed")
+
+	tsk := sh_task.NewTask(task.ModuleRun).
+		WithQueueName(ModuleRunRetryQueueName).
+		WithMetadata(task.HookMetadata{
+			ModuleName: module.Name,
+		})
+	res := op.HandleModuleRun(tsk, map[string]string{})
+
+	g.Expect(res.Status).To(Equal(queue.Keep))
+	g.Expect(res.HeadTasks).To(HaveLen(1))
+	g.Expect(res.HeadTasks[0].GetQueueName()).To(Equal(op.TaskQueues.MainName))
+}
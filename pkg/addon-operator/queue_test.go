@@ -1,8 +1,10 @@
 package addon_operator
 
 import (
+	"context"
 	"testing"
 
+	"github.com/flant/addon-operator/pkg/app"
 	"github.com/flant/addon-operator/pkg/task"
 	sh_task "github.com/flant/shell-operator/pkg/task"
 	"github.com/flant/shell-operator/pkg/task/queue"
@@ -466,3 +468,62 @@ func Test_ConvergeModulesInQueue(t *testing.T) {
 		})
 	}
 }
+
+func Test_ModulesTasksInQueues(t *testing.T) {
+	tqs := queue.NewTaskQueueSet()
+	tqs.WithContext(context.Background())
+	tqs.NewNamedQueue("main", nil)
+	tqs.NewNamedQueue("parallel", nil)
+
+	Task := &sh_task.BaseTask{Type: task.ModuleRun, Id: "1"}
+	tqs.GetByName("main").AddLast(Task.WithMetadata(task.HookMetadata{ModuleName: "alpha"}))
+	Task = &sh_task.BaseTask{Type: task.GlobalHookRun, Id: "2"}
+	tqs.GetByName("main").AddLast(Task.WithMetadata(task.HookMetadata{HookName: "global-hook"}))
+	Task = &sh_task.BaseTask{Type: task.ModuleHookRun, Id: "3"}
+	tqs.GetByName("parallel").AddLast(Task.WithMetadata(task.HookMetadata{ModuleName: "alpha"}))
+	Task = &sh_task.BaseTask{Type: task.ModuleHookRun, Id: "4"}
+	tqs.GetByName("parallel").AddLast(Task.WithMetadata(task.HookMetadata{ModuleName: "beta"}))
+
+	require.Equal(t, map[string]int{"alpha": 2, "beta": 1}, ModulesTasksInQueues(tqs))
+}
+
+func Test_MoveModuleRunToRetryQueue(t *testing.T) {
+	defer func(v int) { app.ModuleRunRetryQueueAfterFailures = v }(app.ModuleRunRetryQueueAfterFailures)
+
+	op := NewAddonOperator()
+	op.TaskQueues = queue.NewTaskQueueSet()
+	op.TaskQueues.WithContext(context.Background())
+	op.TaskQueues.NewNamedQueue("main", nil)
+	// Queue is not started to not handle tasks.
+	op.TaskQueues.NewNamedQueue(ModuleRunRetryQueueName, nil)
+
+	Task := &sh_task.BaseTask{Type: task.ModuleRun, Id: "1", QueueName: "main"}
+	Task.WithMetadata(task.HookMetadata{ModuleName: "alpha", IsReloadAll: true})
+	op.TaskQueues.GetMain().AddLast(Task)
+
+	app.ModuleRunRetryQueueAfterFailures = 0
+	require.False(t, ShouldMoveToRetryQueue(Task))
+
+	app.ModuleRunRetryQueueAfterFailures = 2
+	require.False(t, ShouldMoveToRetryQueue(Task))
+	Task.IncrementFailureCount()
+	require.True(t, ShouldMoveToRetryQueue(Task))
+
+	// Move twice, only one task should be in the retry queue.
+	op.MoveModuleRunToRetryQueue(Task, "helm failed")
+	newTask := op.MoveModuleRunToRetryQueue(Task, "helm failed")
+
+	retryQueue := op.TaskQueues.GetByName(ModuleRunRetryQueueName)
+	require.Equal(t, 1, retryQueue.Length())
+	require.Equal(t, ModuleRunRetryQueueName, newTask.GetQueueName())
+	require.False(t, task.HookMetadataAccessor(newTask).IsReloadAll)
+	require.False(t, ShouldMoveToRetryQueue(newTask))
+	require.True(t, QueueHasModuleRunTask(retryQueue, "alpha"))
+	require.Equal(t, []string{"alpha"}, op.FailingModules.List())
+
+	RemoveModuleRunTasks(retryQueue, "alpha")
+	require.Equal(t, 0, retryQueue.Length())
+
+	op.FailingModules.Remove("alpha")
+	require.False(t, op.FailingModules.Has("alpha"))
+}
@@ -0,0 +1,26 @@
+package addon_operator
+
+import (
+	sh_task "github.com/flant/shell-operator/pkg/task"
+	"github.com/flant/shell-operator/pkg/task/queue"
+
+	"github.com/flant/addon-operator/pkg/utils"
+)
+
+// applyRetryPolicy changes the result of a failed task according to the retry policy.
+// It sets a delay before the next attempt or returns true if the task should be dropped
+// from the queue. Queue's default backoff is used if policy is nil.
+func applyRetryPolicy(policy *utils.RetryPolicy, t sh_task.Task, res *queue.TaskResult) (giveUp bool) {
+	if policy == nil {
+		return false
+	}
+
+	attempts := t.GetFailureCount() + 1
+	if policy.ShouldGiveUp(attempts) {
+		res.Status = queue.Success
+		return true
+	}
+
+	res.DelayBeforeNextTask = policy.Delay(t.GetFailureCount())
+	return false
+}
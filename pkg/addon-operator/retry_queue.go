@@ -0,0 +1,140 @@
+package addon_operator
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	sh_task "github.com/flant/shell-operator/pkg/task"
+	"github.com/flant/shell-operator/pkg/task/queue"
+
+	"github.com/flant/addon-operator/pkg/app"
+	"github.com/flant/addon-operator/pkg/task"
+	"github.com/flant/addon-operator/pkg/utils"
+)
+
+// ModuleRunRetryQueueName is a queue for ModuleRun tasks that fail in the 'main' queue.
+const ModuleRunRetryQueueName = "module-run-retry"
+
+// FailingModules is a set of modules with failed ModuleRun tasks
+// that are moved out of the 'main' queue. Operator is degraded if set is not empty.
+type FailingModules struct {
+	m       sync.RWMutex
+	modules map[string]string
+
+	// runLocks prevent parallel ModuleRun tasks for the same module in the 'main' and the retry queues.
+	// ModuleRun tasks for different modules are not blocked.
+	runLocks map[string]*sync.Mutex
+}
+
+func NewFailingModules() *FailingModules {
+	return &FailingModules{
+		modules:  make(map[string]string),
+		runLocks: make(map[string]*sync.Mutex),
+	}
+}
+
+// RunLock returns a lock to run the module.
+func (f *FailingModules) RunLock(moduleName string) *sync.Mutex {
+	f.m.Lock()
+	defer f.m.Unlock()
+	lock, has := f.runLocks[moduleName]
+	if !has {
+		lock = new(sync.Mutex)
+		f.runLocks[moduleName] = lock
+	}
+	return lock
+}
+
+// Add marks module as failing.
+func (f *FailingModules) Add(moduleName string, errMsg string) {
+	f.m.Lock()
+	defer f.m.Unlock()
+	f.modules[moduleName] = errMsg
+}
+
+// Remove marks module as not failing.
+func (f *FailingModules) Remove(moduleName string) {
+	f.m.Lock()
+	defer f.m.Unlock()
+	delete(f.modules, moduleName)
+}
+
+// Has returns true if module is failing.
+func (f *FailingModules) Has(moduleName string) bool {
+	f.m.RLock()
+	defer f.m.RUnlock()
+	_, has := f.modules[moduleName]
+	return has
+}
+
+// List returns sorted names of failing modules.
+func (f *FailingModules) List() []string {
+	f.m.RLock()
+	defer f.m.RUnlock()
+	names := make([]string, 0, len(f.modules))
+	for name := range f.modules {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ShouldMoveToRetryQueue returns true if failed ModuleRun task should leave the 'main' queue.
+func ShouldMoveToRetryQueue(t sh_task.Task) bool {
+	if app.ModuleRunRetryQueueAfterFailures <= 0 || t.GetQueueName() != "main" {
+		return false
+	}
+	return t.GetFailureCount()+1 >= app.ModuleRunRetryQueueAfterFailures
+}
+
+// MoveModuleRunToRetryQueue adds a copy of the failed ModuleRun task to the retry queue
+// and marks module as failing. The retry queue is started on first use.
+func (op *AddonOperator) MoveModuleRunToRetryQueue(t sh_task.Task, errMsg string) sh_task.Task {
+	hm := task.HookMetadataAccessor(t)
+	// Module is not a part of the current converge anymore.
+	hm.IsReloadAll = false
+
+	newLabels := utils.MergeLabels(t.GetLogLabels(), map[string]string{"queue": ModuleRunRetryQueueName})
+	delete(newLabels, "task.id")
+	newTask := sh_task.NewTask(task.ModuleRun).
+		WithLogLabels(newLabels).
+		WithQueueName(ModuleRunRetryQueueName).
+		WithMetadata(hm).
+		WithQueuedAt(time.Now())
+
+	op.CreateAndStartQueue(ModuleRunRetryQueueName)
+	// Only one ModuleRun task for the module in the retry queue.
+	RemoveModuleRunTasks(op.TaskQueues.GetByName(ModuleRunRetryQueueName), hm.ModuleName)
+	op.TaskQueues.GetByName(ModuleRunRetryQueueName).AddLast(newTask)
+
+	op.FailingModules.Add(hm.ModuleName, errMsg)
+	return newTask
+}
+
+// RemoveModuleRunTasks deletes all ModuleRun tasks for the module from the queue.
+func RemoveModuleRunTasks(q *queue.TaskQueue, moduleName string) {
+	if q == nil {
+		return
+	}
+	q.Filter(func(t sh_task.Task) bool {
+		if t.GetType() != task.ModuleRun {
+			return true
+		}
+		return task.HookMetadataAccessor(t).ModuleName != moduleName
+	})
+}
+
+// QueueHasModuleRunTask returns true if queue has ModuleRun tasks for the module including the first task.
+func QueueHasModuleRunTask(q *queue.TaskQueue, moduleName string) bool {
+	if q == nil {
+		return false
+	}
+	has := false
+	q.Iterate(func(t sh_task.Task) {
+		if t.GetType() == task.ModuleRun && task.HookMetadataAccessor(t).ModuleName == moduleName {
+			has = true
+		}
+	})
+	return has
+}
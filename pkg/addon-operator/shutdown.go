@@ -0,0 +1,70 @@
+package addon_operator
+
+import (
+	"fmt"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/flant/addon-operator/pkg/app"
+)
+
+// Shutdown stops the operator gracefully. New events are not accepted, tasks that are running
+// in queues are allowed to finish within the grace period, so Helm releases are not left in
+// a pending state. Then resource monitors are paused and metrics are updated with the final state.
+// Hooks that are still running after the grace period are canceled: Go hooks receive a canceled
+// context and process groups of shell hooks are killed. An error is returned in this case.
+func (op *AddonOperator) Shutdown() error {
+	var shutdownErr error
+
+	log.Infof("Shutdown: stop accepting events")
+	op.KubeConfigManager.Stop()
+	if op.ScheduleManager != nil {
+		op.ScheduleManager.Stop()
+	}
+	if op.KubeEventsManager != nil {
+		op.KubeEventsManager.PauseHandleEvents()
+	}
+	if op.EventBroadcaster != nil {
+		op.EventBroadcaster.Shutdown()
+	}
+
+	if op.TaskQueues != nil {
+		// Queues stop after the current task is done.
+		op.TaskQueues.Stop()
+		log.Infof("Shutdown: wait up to %s for running tasks", app.ShutdownGracePeriod)
+		start := time.Now()
+		op.TaskQueues.WaitStopWithTimeout(app.ShutdownGracePeriod)
+		if time.Since(start) < app.ShutdownGracePeriod {
+			log.Infof("Shutdown: running tasks are done")
+		} else {
+			log.Warnf("Shutdown: grace period %s is exceeded, cancel running tasks", app.ShutdownGracePeriod)
+			shutdownErr = fmt.Errorf("grace period %s is exceeded", app.ShutdownGracePeriod)
+		}
+	}
+
+	// Cancel the hook context: Go hooks are stopped, process groups of shell hooks are killed.
+	if op.ModuleManager != nil {
+		op.ModuleManager.Stop()
+	}
+
+	log.Infof("Shutdown: pause resource monitors")
+	if op.HelmResourcesManager != nil {
+		op.HelmResourcesManager.PauseMonitors()
+	}
+
+	log.Infof("Shutdown: flush metrics")
+	if op.MetricStorage != nil {
+		if op.TaskQueues != nil {
+			UpdateTasksQueueLength(op.MetricStorage, op.TaskQueues)
+		}
+		op.MetricStorage.Stop()
+	}
+	if op.HookMetricStorage != nil {
+		op.HookMetricStorage.Stop()
+	}
+
+	op.Stop()
+	log.Infof("Shutdown: done")
+	return shutdownErr
+}
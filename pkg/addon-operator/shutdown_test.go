@@ -0,0 +1,183 @@
+package addon_operator
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	sh_task "github.com/flant/shell-operator/pkg/task"
+	"github.com/flant/shell-operator/pkg/task/queue"
+	"github.com/stretchr/testify/require"
+
+	"github.com/flant/addon-operator/pkg/app"
+	mockhelmresmgr "github.com/flant/addon-operator/pkg/helm_resources_manager/test/mock"
+	"github.com/flant/addon-operator/pkg/kube_config_manager"
+	"github.com/flant/addon-operator/pkg/module_manager"
+	"github.com/flant/addon-operator/pkg/task"
+)
+
+// Test_TaskQueues_Stop checks that the running task is finished after the queue is stopped
+// on Shutdown and the next task is not started.
+func Test_TaskQueues_Stop(t *testing.T) {
+	tqs := queue.NewTaskQueueSet()
+	tqs.WithContext(context.Background())
+
+	started := make(chan struct{})
+	finished := make(chan struct{})
+	var handled int32
+	tqs.NewNamedQueue("main", func(_ sh_task.Task) queue.TaskResult {
+		if atomic.AddInt32(&handled, 1) == 1 {
+			close(started)
+			time.Sleep(300 * time.Millisecond)
+			close(finished)
+		}
+		return queue.TaskResult{Status: queue.Success}
+	})
+	q := tqs.GetByName("main")
+	q.AddLast(&sh_task.BaseTask{Type: task.ModuleRun, Id: "first"})
+	q.AddLast(&sh_task.BaseTask{Type: task.ModuleRun, Id: "second"})
+	q.Start()
+
+	<-started
+	tqs.Stop()
+
+	select {
+	case <-finished:
+	case <-time.After(5 * time.Second):
+		t.Fatal("running task is not finished")
+	}
+	require.Never(t, func() bool {
+		return atomic.LoadInt32(&handled) > 1
+	}, 500*time.Millisecond, 50*time.Millisecond)
+}
+
+// shutdownSteps records steps of Shutdown in order.
+type shutdownSteps struct {
+	m     sync.Mutex
+	steps []string
+}
+
+func (s *shutdownSteps) add(step string) {
+	s.m.Lock()
+	s.steps = append(s.steps, step)
+	s.m.Unlock()
+}
+
+func (s *shutdownSteps) list() []string {
+	s.m.Lock()
+	defer s.m.Unlock()
+	return append([]string{}, s.steps...)
+}
+
+type shutdownKubeConfigManager struct {
+	kube_config_manager.KubeConfigManager
+	steps *shutdownSteps
+}
+
+func (m *shutdownKubeConfigManager) Stop() {
+	m.steps.add("stop events")
+}
+
+type shutdownHelmResourcesManager struct {
+	*mockhelmresmgr.MockHelmResourcesManager
+	steps *shutdownSteps
+}
+
+func (m *shutdownHelmResourcesManager) PauseMonitors() {
+	m.steps.add("pause monitors")
+}
+
+// newShutdownTestOperator returns an operator with the 'main' queue. The handler is called
+// for the first task with the ModuleManager context.
+func newShutdownTestOperator(t *testing.T, steps *shutdownSteps, handler func(ctx context.Context)) (*AddonOperator, chan struct{}) {
+	op := NewAddonOperator()
+	op.WithContext(context.Background())
+	op.KubeConfigManager = &shutdownKubeConfigManager{steps: steps}
+	op.HelmResourcesManager = &shutdownHelmResourcesManager{MockHelmResourcesManager: &mockhelmresmgr.MockHelmResourcesManager{}, steps: steps}
+	op.ModuleManager = module_manager.NewModuleManager()
+	op.ModuleManager.WithContext(context.Background())
+
+	op.TaskQueues = queue.NewTaskQueueSet()
+	op.TaskQueues.WithContext(context.Background())
+	started := make(chan struct{})
+	var handled int32
+	op.TaskQueues.NewNamedQueue("main", func(_ sh_task.Task) queue.TaskResult {
+		if atomic.AddInt32(&handled, 1) == 1 {
+			close(started)
+			handler(op.ModuleManager.Context())
+			steps.add("task done")
+		} else {
+			steps.add("next task")
+		}
+		return queue.TaskResult{Status: queue.Success}
+	})
+	q := op.TaskQueues.GetByName("main")
+	q.AddLast(&sh_task.BaseTask{Type: task.ModuleRun, Id: "first"})
+	q.AddLast(&sh_task.BaseTask{Type: task.ModuleRun, Id: "second"})
+	q.Start()
+	return op, started
+}
+
+// Test_Shutdown_RunningTaskFinished checks that the running task is allowed to finish
+// within the grace period and that steps of Shutdown are done in order.
+func Test_Shutdown_RunningTaskFinished(t *testing.T) {
+	defer func(v time.Duration) { app.ShutdownGracePeriod = v }(app.ShutdownGracePeriod)
+	app.ShutdownGracePeriod = 10 * time.Second
+
+	steps := &shutdownSteps{}
+	var canceledInTask int32
+	op, started := newShutdownTestOperator(t, steps, func(ctx context.Context) {
+		time.Sleep(300 * time.Millisecond)
+		if ctx.Err() != nil {
+			atomic.StoreInt32(&canceledInTask, 1)
+		}
+	})
+
+	<-started
+	start := time.Now()
+	err := op.Shutdown()
+
+	require.NoError(t, err)
+	require.Less(t, time.Since(start), app.ShutdownGracePeriod)
+	require.Zero(t, atomic.LoadInt32(&canceledInTask), "ModuleManager context should not be canceled while the task is running")
+	require.Error(t, op.ModuleManager.Context().Err(), "ModuleManager context should be canceled after Shutdown")
+	require.Equal(t, []string{"stop events", "task done", "pause monitors"}, steps.list())
+}
+
+// Test_Shutdown_GracePeriodExceeded checks that the ModuleManager context is canceled
+// after the grace period to stop the running Go hook and that Shutdown returns an error.
+func Test_Shutdown_GracePeriodExceeded(t *testing.T) {
+	defer func(v time.Duration) { app.ShutdownGracePeriod = v }(app.ShutdownGracePeriod)
+	app.ShutdownGracePeriod = 300 * time.Millisecond
+
+	steps := &shutdownSteps{}
+	canceled := make(chan time.Time, 1)
+	op, started := newShutdownTestOperator(t, steps, func(ctx context.Context) {
+		select {
+		case <-ctx.Done():
+			canceled <- time.Now()
+		case <-time.After(10 * time.Second):
+		}
+	})
+
+	<-started
+	start := time.Now()
+	err := op.Shutdown()
+
+	require.Error(t, err, "exit code should be non-zero when the grace period is exceeded")
+	select {
+	case canceledAt := <-canceled:
+		require.GreaterOrEqual(t, canceledAt.Sub(start), app.ShutdownGracePeriod)
+	case <-time.After(5 * time.Second):
+		t.Fatal("running task is not canceled after the grace period")
+	}
+	require.Eventually(t, func() bool {
+		return len(steps.list()) == 3
+	}, 5*time.Second, 50*time.Millisecond)
+	// Monitors are paused after the grace period, the running task is done after its context is canceled.
+	require.Equal(t, "stop events", steps.list()[0])
+	require.ElementsMatch(t, []string{"stop events", "task done", "pause monitors"}, steps.list())
+	require.NotContains(t, steps.list(), "next task")
+}
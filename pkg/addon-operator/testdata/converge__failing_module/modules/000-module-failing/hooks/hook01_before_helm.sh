@@ -0,0 +1,17 @@
+#!/usr/bin/env bash
+
+if [[ $1 == "--config" ]] ; then
+cat <<EOF2
+configVersion: v1
+beforeHelm: 10
+EOF2
+exit 0
+fi
+
+# The first run fails fast, next runs block until the block file is removed.
+if [[ -f "$FAILING_HOOK_MARKER" ]] ; then
+  touch "$FAILING_HOOK_RUNNING"
+  while [[ -f "$FAILING_HOOK_BLOCK" ]] ; do sleep 0.1 ; done
+fi
+touch "$FAILING_HOOK_MARKER"
+exit 1
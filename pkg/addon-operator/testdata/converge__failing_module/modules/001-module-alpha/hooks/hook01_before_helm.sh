@@ -0,0 +1,16 @@
+#!/usr/bin/env bash
+
+if [[ $1 == "--config" ]] ; then
+cat <<EOF2
+configVersion: v1
+beforeHelm: 10
+EOF2
+exit 0
+fi
+
+# Wait until module-failing is running in the retry queue, but not forever.
+for i in $(seq 1 200) ; do
+  [[ -f "$FAILING_HOOK_RUNNING" ]] && break
+  sleep 0.1
+done
+echo "module-alpha"
@@ -0,0 +1,13 @@
+#!/usr/bin/env bash
+
+if [[ $1 == "--config" ]] ; then
+cat <<EOF
+configVersion: v1
+kubernetes:
+- name: monitor-pods
+  kind: Pod
+  waitForSynchronization: false
+EOF
+else
+  echo "hook_one"
+fi
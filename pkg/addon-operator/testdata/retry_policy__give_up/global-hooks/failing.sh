@@ -0,0 +1,18 @@
+#!/usr/bin/env bash
+
+if [[ $1 == "--config" ]] ; then
+cat <<EOF
+configVersion: v1
+onStartup: 1
+settings:
+  executionMinInterval: 1s
+  executionBurst: 1
+  retry:
+    maxAttempts: 2
+    initialDelay: 3s
+    giveUpAction: Skip
+EOF
+else
+echo "failing hook"
+exit 1
+fi
@@ -0,0 +1,47 @@
+package addon_operator
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+
+	"github.com/flant/addon-operator/pkg/module_manager"
+)
+
+// EventReasonValuesPatched is a reason for Events about values patches from hooks.
+const EventReasonValuesPatched = "ValuesPatched"
+
+// RecordValuesHistoryEvent publishes an Event for the operator's ConfigMap to make values
+// patches visible in 'kubectl describe'. Events are sent by the broadcaster in background,
+// so the hook is not blocked by the API server. It is a noop if values history Events are disabled.
+func (op *AddonOperator) RecordValuesHistoryEvent(record module_manager.ValuesHistoryRecord) {
+	if op.ValuesHistoryEventRecorder == nil {
+		return
+	}
+
+	// Events are readable by anyone with access to Events in the namespace, so values of
+	// sensitive settings and values resolved from Secrets are masked as in debug output.
+	operations, err := redactSecrets(op.redactPatch(record.Patch).Operations, nil)
+	buf := new(bytes.Buffer)
+	if err == nil {
+		// The mask is not escaped to keep the message readable.
+		enc := json.NewEncoder(buf)
+		enc.SetEscapeHTML(false)
+		err = enc.Encode(operations)
+	}
+	patch := strings.TrimSpace(buf.String())
+	if err != nil {
+		patch = err.Error()
+	}
+
+	if record.Module == "" {
+		op.ValuesHistoryEventRecorder.Eventf(eventObjectRef(), v1.EventTypeNormal, EventReasonValuesPatched,
+			"Hook '%s' (binding %s) patched global values, checksum %s: %s", record.Hook, record.Binding, record.Checksum, patch)
+		return
+	}
+	annotations := map[string]string{ModuleEventAnnotation: record.Module}
+	op.ValuesHistoryEventRecorder.AnnotatedEventf(eventObjectRef(), annotations, v1.EventTypeNormal, EventReasonValuesPatched,
+		"Hook '%s' (binding %s) patched module '%s' values, checksum %s: %s", record.Hook, record.Binding, record.Module, record.Checksum, patch)
+}
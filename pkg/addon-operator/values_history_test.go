@@ -0,0 +1,53 @@
+package addon_operator
+
+import (
+	"encoding/json"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"k8s.io/client-go/tools/record"
+
+	"github.com/flant/addon-operator/pkg/module_manager"
+	"github.com/flant/addon-operator/pkg/utils"
+)
+
+func Test_RecordValuesHistoryEvent(t *testing.T) {
+	g := NewWithT(t)
+
+	op := NewAddonOperator()
+	op.ModuleManager = module_manager.NewModuleManager()
+	err := op.ModuleManager.GetValuesValidator().SchemaStorage.AddModuleValuesSchemas("moduleOne", []byte(`
+type: object
+properties:
+  replicas:
+    type: integer
+  password:
+    type: string
+    x-sensitive: true
+`), nil)
+	g.Expect(err).ShouldNot(HaveOccurred())
+	utils.SetSecretValues("test/values-history-event", []string{"history-event-token"})
+	defer utils.SetSecretValues("test/values-history-event", nil)
+
+	// Noop if values history Events are disabled.
+	op.RecordValuesHistoryEvent(module_manager.ValuesHistoryRecord{Hook: "hook.sh"})
+
+	recorder := record.NewFakeRecorder(10)
+	op.ValuesHistoryEventRecorder = recorder
+
+	op.RecordValuesHistoryEvent(module_manager.ValuesHistoryRecord{
+		Module:   "module-one",
+		Hook:     "module-one/hooks/hook.sh",
+		Binding:  "beforeHelm",
+		Checksum: "123",
+		Patch: utils.ValuesPatch{Operations: []*utils.ValuesPatchOperation{
+			{Op: "add", Path: "/moduleOne/replicas", Value: json.RawMessage(`2`)},
+			{Op: "add", Path: "/moduleOne/password", Value: "history-event-password"},
+			{Op: "add", Path: "/moduleOne/token", Value: "history-event-token"},
+		}},
+	})
+
+	g.Expect(recorder.Events).To(HaveLen(1))
+	// Values of sensitive settings and values from Secrets are masked.
+	g.Expect(<-recorder.Events).To(Equal(`Normal ValuesPatched Hook 'module-one/hooks/hook.sh' (binding beforeHelm) patched module 'module-one' values, checksum 123: [{"op":"add","path":"/moduleOne/replicas","value":2},{"op":"add","path":"/moduleOne/password","value":"<secret>"},{"op":"add","path":"/moduleOne/token","value":"<secret>"}]`))
+}
@@ -33,11 +33,33 @@ var (
 	ModulesDir     = "modules"
 
 	UnnumberedModuleOrder = 1
+
+	ValuesHistorySize   = 20
+	ValuesHistoryEvents = false
+
+	EmitEvents = false
+
+	StatusConfigMapName = ""
+
+	ModuleRunRetryQueueAfterFailures = 0
+
+	HookTimeout time.Duration = 0
+
+	ShutdownGracePeriod = 30 * time.Second
+
+	HookConfigWorkers = 8
+
+	ConfigConversionWriteBack = false
+
+	ConfigValidationWebhook = false
 )
 
 const (
 	DefaultTempDir         = "/tmp/addon-operator"
 	DefaultDebugUnixSocket = "/var/run/addon-operator/debug.socket"
+
+	DefaultValidatingWebhookServiceName       = "addon-operator-validating-svc"
+	DefaultValidatingWebhookConfigurationName = "addon-operator-config"
 )
 
 // DefineStartCommandFlags init global flags with default values
@@ -114,10 +136,63 @@ func DefineStartCommandFlags(kpApp *kingpin.Application, cmd *kingpin.CmdClause)
 		Default(ConfigMapName).
 		StringVar(&ConfigMapName)
 
+	cmd.Flag("values-history-size", "Number of values patches to keep in memory for global values and for each module.").
+		Envar("ADDON_OPERATOR_VALUES_HISTORY_SIZE").
+		Default(strconv.Itoa(ValuesHistorySize)).
+		IntVar(&ValuesHistorySize)
+
+	cmd.Flag("values-history-events", "Create Kubernetes Events for values patches from hooks.").
+		Envar("ADDON_OPERATOR_VALUES_HISTORY_EVENTS").
+		Default("false").
+		BoolVar(&ValuesHistoryEvents)
+
+	cmd.Flag("emit-events", "Create Kubernetes Events for module lifecycle, hook failures and config changes.").
+		Envar("ADDON_OPERATOR_EMIT_EVENTS").
+		Default(strconv.FormatBool(EmitEvents)).
+		BoolVar(&EmitEvents)
+
+	cmd.Flag("status-config-map", "Name of a ConfigMap to store modules status. Default is the name of the values ConfigMap with the '-status' suffix.").
+		Envar("ADDON_OPERATOR_STATUS_CONFIG_MAP").
+		StringVar(&StatusConfigMapName)
+
+	cmd.Flag("module-run-retry-queue-after-failures", "Move a failing ModuleRun task from the 'main' queue to the retry queue after this number of failures, so converge can proceed with other modules. Zero disables the retry queue.").
+		Envar("ADDON_OPERATOR_MODULE_RUN_RETRY_QUEUE_AFTER_FAILURES").
+		Default(strconv.Itoa(ModuleRunRetryQueueAfterFailures)).
+		IntVar(&ModuleRunRetryQueueAfterFailures)
+
+	cmd.Flag("hook-timeout", "Default timeout for shell hooks and enabled scripts. Hook can override it with 'settings.timeout'. Zero means no timeout.").
+		Envar("ADDON_OPERATOR_HOOK_TIMEOUT").
+		Default(HookTimeout.String()).
+		DurationVar(&HookTimeout)
+
+	cmd.Flag("shutdown-grace-period", "Time to wait for running tasks to finish on shutdown. Go hooks that are still running after this period are canceled.").
+		Envar("ADDON_OPERATOR_SHUTDOWN_GRACE_PERIOD").
+		Default(ShutdownGracePeriod.String()).
+		DurationVar(&ShutdownGracePeriod)
+
+	cmd.Flag("config-conversion-write-back", "Save module config values converted from older schema versions back to the ConfigMap.").
+		Envar("ADDON_OPERATOR_CONFIG_CONVERSION_WRITE_BACK").
+		Default(strconv.FormatBool(ConfigConversionWriteBack)).
+		BoolVar(&ConfigConversionWriteBack)
+
+	cmd.Flag("hook-config-workers", "Number of hooks to run with --config in parallel on hooks registration.").
+		Envar("ADDON_OPERATOR_HOOK_CONFIG_WORKERS").
+		Default(strconv.Itoa(HookConfigWorkers)).
+		IntVar(&HookConfigWorkers)
+
+	cmd.Flag("config-validation-webhook", "Serve a validating admission webhook to reject invalid changes of the ConfigMap. Webhook server is configured with --validating-webhook-* flags.").
+		Envar("ADDON_OPERATOR_CONFIG_VALIDATION_WEBHOOK").
+		Default(strconv.FormatBool(ConfigValidationWebhook)).
+		BoolVar(&ConfigValidationWebhook)
+
 	sh_app.DefineKubeClientFlags(cmd)
 	sh_app.DefineJqFlags(cmd)
 	sh_app.DefineLoggingFlags(cmd)
 
+	sh_app.ValidatingWebhookSettings.ServiceName = DefaultValidatingWebhookServiceName
+	sh_app.ValidatingWebhookSettings.ConfigurationName = DefaultValidatingWebhookConfigurationName
+	sh_app.DefineValidatingWebhookFlags(cmd)
+
 	sh_app.DebugUnixSocket = DefaultDebugUnixSocket
 	sh_app.DefineDebugFlags(kpApp, cmd)
 }
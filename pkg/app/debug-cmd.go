@@ -25,9 +25,20 @@ func DefineDebugCommands(kpApp *kingpin.Application) {
 	AddOutputJsonYamlFlag(globalListCmd)
 	sh_app.DefineDebugUnixSocketFlag(globalListCmd)
 
+	var explainValues bool
+	var validateValues bool
 	globalValuesCmd := globalCmd.Command("values", "Dump current global values.").
 		Action(func(c *kingpin.ParseContext) error {
-			dump, err := Global(sh_debug.DefaultClient()).Values(sh_debug.OutputFormat)
+			var dump []byte
+			var err error
+			switch {
+			case explainValues:
+				dump, err = Global(sh_debug.DefaultClient()).ValuesExplain(sh_debug.OutputFormat)
+			case validateValues:
+				dump, err = Global(sh_debug.DefaultClient()).ValuesValidate(sh_debug.OutputFormat)
+			default:
+				dump, err = Global(sh_debug.DefaultClient()).Values(sh_debug.OutputFormat)
+			}
 			if err != nil {
 				return err
 			}
@@ -35,6 +46,8 @@ func DefineDebugCommands(kpApp *kingpin.Application) {
 			return nil
 		})
 	// -o json|yaml and --debug-unix-socket <file>
+	AddExplainFlag(globalValuesCmd, &explainValues)
+	AddValidateFlag(globalValuesCmd, &validateValues)
 	AddOutputJsonYamlFlag(globalValuesCmd)
 	sh_app.DefineDebugUnixSocketFlag(globalValuesCmd)
 
@@ -63,6 +76,19 @@ func DefineDebugCommands(kpApp *kingpin.Application) {
 	// --debug-unix-socket <file>
 	sh_app.DefineDebugUnixSocketFlag(globalPatchesCmd)
 
+	globalHistoryCmd := globalCmd.Command("history", "Dump recent global values patches from hooks.").
+		Action(func(c *kingpin.ParseContext) error {
+			dump, err := Global(sh_debug.DefaultClient()).History(sh_debug.OutputFormat)
+			if err != nil {
+				return err
+			}
+			fmt.Println(string(dump))
+			return nil
+		})
+	// -o json|yaml and --debug-unix-socket <file>
+	AddOutputJsonYamlFlag(globalHistoryCmd)
+	sh_app.DefineDebugUnixSocketFlag(globalHistoryCmd)
+
 	globalSnapshotsCmd := globalCmd.Command("snapshots", "Dump snapshots for all global hooks.").
 		Action(func(c *kingpin.ParseContext) error {
 			out, err := Global(sh_debug.DefaultClient()).Snapshots(sh_debug.OutputFormat)
@@ -78,7 +104,7 @@ func DefineDebugCommands(kpApp *kingpin.Application) {
 
 	moduleCmd := sh_app.CommandWithDefaultUsageTemplate(kpApp, "module", "List modules and dump their values")
 
-	moduleListCmd := moduleCmd.Command("list", "List available modules and their enabled status.").
+	moduleListCmd := moduleCmd.Command("list", "List available modules with enabled status, run phase, errors and timings.").
 		Action(func(c *kingpin.ParseContext) error {
 			modules, err := Module(sh_debug.DefaultClient()).List(sh_debug.OutputFormat)
 			if err != nil {
@@ -94,7 +120,16 @@ func DefineDebugCommands(kpApp *kingpin.Application) {
 	var moduleName string
 	moduleValuesCmd := moduleCmd.Command("values", "Dump module values by name.").
 		Action(func(c *kingpin.ParseContext) error {
-			dump, err := Module(sh_debug.DefaultClient()).Name(moduleName).Values(sh_debug.OutputFormat)
+			var dump []byte
+			var err error
+			switch {
+			case explainValues:
+				dump, err = Module(sh_debug.DefaultClient()).Name(moduleName).ValuesExplain(sh_debug.OutputFormat)
+			case validateValues:
+				dump, err = Module(sh_debug.DefaultClient()).Name(moduleName).ValuesValidate(sh_debug.OutputFormat)
+			default:
+				dump, err = Module(sh_debug.DefaultClient()).Name(moduleName).Values(sh_debug.OutputFormat)
+			}
 			if err != nil {
 				return err
 			}
@@ -102,6 +137,8 @@ func DefineDebugCommands(kpApp *kingpin.Application) {
 			return nil
 		})
 	moduleValuesCmd.Arg("module_name", "").Required().StringVar(&moduleName)
+	AddExplainFlag(moduleValuesCmd, &explainValues)
+	AddValidateFlag(moduleValuesCmd, &validateValues)
 	// -o json|yaml and --debug-unix-socket <file>
 	AddOutputJsonYamlFlag(moduleValuesCmd)
 	sh_app.DefineDebugUnixSocketFlag(moduleValuesCmd)
@@ -146,6 +183,20 @@ func DefineDebugCommands(kpApp *kingpin.Application) {
 	// --debug-unix-socket <file>
 	sh_app.DefineDebugUnixSocketFlag(modulePatchesCmd)
 
+	moduleHistoryCmd := moduleCmd.Command("history", "Dump recent module values patches from hooks by name.").
+		Action(func(c *kingpin.ParseContext) error {
+			dump, err := Module(sh_debug.DefaultClient()).Name(moduleName).History(sh_debug.OutputFormat)
+			if err != nil {
+				return err
+			}
+			fmt.Println(string(dump))
+			return nil
+		})
+	moduleHistoryCmd.Arg("module_name", "").Required().StringVar(&moduleName)
+	// -o json|yaml and --debug-unix-socket <file>
+	AddOutputJsonYamlFlag(moduleHistoryCmd)
+	sh_app.DefineDebugUnixSocketFlag(moduleHistoryCmd)
+
 	moduleResourceMonitorCmd := moduleCmd.Command("resource-monitor", "Dump resource monitors.").
 		Action(func(c *kingpin.ParseContext) error {
 			out, err := Module(sh_debug.DefaultClient()).Name(moduleName).ResourceMonitor(sh_debug.OutputFormat)
@@ -181,6 +232,16 @@ func AddOutputJsonYamlFlag(cmd *kingpin.CmdClause) {
 		EnumVar(&sh_debug.OutputFormat, "json", "yaml")
 }
 
+func AddExplainFlag(cmd *kingpin.CmdClause, explain *bool) {
+	cmd.Flag("explain", "Show a source layer for each value: values.yaml, ConfigMap, OpenAPI default or hook patch.").
+		BoolVar(explain)
+}
+
+func AddValidateFlag(cmd *kingpin.CmdClause, validate *bool) {
+	cmd.Flag("validate", "Validate values with the OpenAPI schema and show errors with paths, offending values and expected constraints.").
+		BoolVar(validate)
+}
+
 type GlobalRequest struct {
 	client *sh_debug.Client
 }
@@ -199,6 +260,16 @@ func (gr *GlobalRequest) Values(format string) ([]byte, error) {
 	return gr.client.Get(url)
 }
 
+func (gr *GlobalRequest) ValuesExplain(format string) ([]byte, error) {
+	url := fmt.Sprintf("http://unix/global/values/explain.%s", format)
+	return gr.client.Get(url)
+}
+
+func (gr *GlobalRequest) ValuesValidate(format string) ([]byte, error) {
+	url := fmt.Sprintf("http://unix/global/values/validate.%s", format)
+	return gr.client.Get(url)
+}
+
 func (gr *GlobalRequest) Config(format string) ([]byte, error) {
 	url := fmt.Sprintf("http://unix/global/config.%s", format)
 	return gr.client.Get(url)
@@ -208,6 +279,11 @@ func (gr *GlobalRequest) Patches() ([]byte, error) {
 	return gr.client.Get("http://unix/global/patches.json")
 }
 
+func (gr *GlobalRequest) History(format string) ([]byte, error) {
+	url := fmt.Sprintf("http://unix/global/history.%s", format)
+	return gr.client.Get(url)
+}
+
 func (gr *GlobalRequest) Snapshots(format string) ([]byte, error) {
 	url := fmt.Sprintf("http://unix/global/snapshots.%s", format)
 	return gr.client.Get(url)
@@ -242,6 +318,16 @@ func (mr *ModuleRequest) Values(format string) ([]byte, error) {
 	return mr.client.Get(url)
 }
 
+func (mr *ModuleRequest) ValuesExplain(format string) ([]byte, error) {
+	url := fmt.Sprintf("http://unix/module/%s/values/explain.%s", mr.name, format)
+	return mr.client.Get(url)
+}
+
+func (mr *ModuleRequest) ValuesValidate(format string) ([]byte, error) {
+	url := fmt.Sprintf("http://unix/module/%s/values/validate.%s", mr.name, format)
+	return mr.client.Get(url)
+}
+
 func (mr *ModuleRequest) Render() ([]byte, error) {
 	url := fmt.Sprintf("http://unix/module/%s/render", mr.name)
 	return mr.client.Get(url)
@@ -257,6 +343,11 @@ func (mr *ModuleRequest) Config(format string) ([]byte, error) {
 	return mr.client.Get(url)
 }
 
+func (mr *ModuleRequest) History(format string) ([]byte, error) {
+	url := fmt.Sprintf("http://unix/module/%s/history.%s", mr.name, format)
+	return mr.client.Get(url)
+}
+
 func (mr *ModuleRequest) Snapshots(format string) ([]byte, error) {
 	url := fmt.Sprintf("http://unix/module/%s/snapshots.%s", mr.name, format)
 	return mr.client.Get(url)
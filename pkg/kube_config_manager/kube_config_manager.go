@@ -12,12 +12,14 @@ import (
 	log "github.com/sirupsen/logrus"
 	"gopkg.in/yaml.v3"
 	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/fields"
 	corev1 "k8s.io/client-go/informers/core/v1"
 	"k8s.io/client-go/tools/cache"
 
 	"github.com/flant/addon-operator/pkg/utils"
+	"github.com/flant/addon-operator/pkg/values/conversion"
 )
 
 // KubeConfigManager watches for changes in ConfigMap/addon-operator and provides
@@ -30,6 +32,7 @@ type KubeConfigManager interface {
 	WithNamespace(namespace string)
 	WithConfigMapName(configMap string)
 	WithRuntimeConfig(config *config.Config)
+	WithConversions(conversions *conversion.ConversionRegistry)
 	SaveGlobalConfigValues(values utils.Values) error
 	SaveModuleConfigValues(moduleName string, values utils.Values) error
 	Init() error
@@ -37,6 +40,7 @@ type KubeConfigManager interface {
 	Stop()
 	KubeConfigEventCh() chan KubeConfigEvent
 	SafeReadConfig(handler func(config *KubeConfig))
+	ResolveSecretRefs(config *KubeConfig) error
 }
 
 type kubeConfigManager struct {
@@ -49,10 +53,22 @@ type kubeConfigManager struct {
 
 	m             sync.Mutex
 	currentConfig *KubeConfig
+	// currentData is the data of the last ConfigMap to parse it again when referenced Secrets are changed.
+	currentData map[string]string
+
+	// handleLock serializes handling of ConfigMap and Secret events.
+	handleLock sync.Mutex
+	// Informers for Secrets referenced in module sections by name.
+	secretInformers map[string]context.CancelFunc
+	// isInvalid is true if the last ConfigMap was invalid. It should be accessed under handleLock.
+	isInvalid bool
 
 	// Checksums to ignore self-initiated updates.
 	knownChecksums *Checksums
 
+	// Conversions of module config values to save values with the latest version.
+	conversions *conversion.ConversionRegistry
+
 	// Channel to emit events.
 	configEventCh chan KubeConfigEvent
 
@@ -67,10 +83,11 @@ var _ KubeConfigManager = &kubeConfigManager{}
 
 func NewKubeConfigManager() KubeConfigManager {
 	return &kubeConfigManager{
-		currentConfig:  NewConfig(),
-		knownChecksums: NewChecksums(),
-		configEventCh:  make(chan KubeConfigEvent, 1),
-		logEntry:       log.WithField("component", "KubeConfigManager"),
+		currentConfig:   NewConfig(),
+		knownChecksums:  NewChecksums(),
+		configEventCh:   make(chan KubeConfigEvent, 1),
+		secretInformers: make(map[string]context.CancelFunc),
+		logEntry:        log.WithField("component", "KubeConfigManager"),
 	}
 }
 
@@ -94,6 +111,20 @@ func (kcm *kubeConfigManager) WithRuntimeConfig(config *config.Config) {
 	kcm.runtimeConfig = config
 }
 
+// WithConversions sets conversions of module config values. Values saved by hooks are
+// in the latest version of the config-values schema.
+func (kcm *kubeConfigManager) WithConversions(conversions *conversion.ConversionRegistry) {
+	kcm.conversions = conversions
+}
+
+// conversionChain returns conversions for the module or nil.
+func (kcm *kubeConfigManager) conversionChain(moduleName string) *conversion.Chain {
+	if kcm.conversions == nil {
+		return nil
+	}
+	return kcm.conversions.Get(moduleName)
+}
+
 func (kcm *kubeConfigManager) SaveGlobalConfigValues(values utils.Values) error {
 	globalKubeConfig, err := GetGlobalKubeConfigFromValues(values)
 	if err != nil {
@@ -144,11 +175,28 @@ func (kcm *kubeConfigManager) SaveModuleConfigValues(moduleName string, values u
 	}
 
 	// Put checksum to known to ignore self-update.
+	// Resolved values of Secrets are replaced back with references.
+	var secretRefs []SecretRef
 	kcm.withLock(func() {
 		kcm.knownChecksums.Add(moduleName, moduleKubeConfig.Checksum)
+		if modCfg, has := kcm.currentConfig.Modules[moduleName]; has {
+			secretRefs = modCfg.SecretRefs
+			// Values are in the latest version, references are moved with converted values.
+			if converted, err := ConvertModuleKubeConfig(modCfg, kcm.conversionChain(moduleName)); err == nil {
+				secretRefs = converted.SecretRefs
+			}
+		}
 	})
 
-	err = ConfigMapMergeValues(kcm.KubeClient, kcm.Namespace, kcm.ConfigMapName, moduleKubeConfig.Values)
+	// Values are saved in the latest version of the config-values schema.
+	cmValues := RestoreSecretRefs(moduleKubeConfig.Values, utils.ModuleNameToValuesKey(moduleName), secretRefs)
+	if chain := kcm.conversionChain(moduleName); chain != nil {
+		cmValues = utils.MergeValues(cmValues, utils.Values{
+			utils.ModuleNameToValuesKey(moduleName) + conversion.VersionKeySuffix: chain.LatestVersion,
+		})
+	}
+
+	err = ConfigMapMergeValues(kcm.KubeClient, kcm.Namespace, kcm.ConfigMapName, cmValues)
 	if err != nil {
 		kcm.withLock(func() {
 			kcm.knownChecksums.Remove(moduleName, moduleKubeConfig.Checksum)
@@ -177,7 +225,8 @@ func (kcm *kubeConfigManager) loadConfig() error {
 		return nil
 	}
 
-	newConfig, err := ParseConfigMapData(obj.Data)
+	kcm.currentData = obj.Data
+	newConfig, err := kcm.parseConfig(obj.Data)
 	if err != nil {
 		return err
 	}
@@ -186,6 +235,32 @@ func (kcm *kubeConfigManager) loadConfig() error {
 	return nil
 }
 
+// parseConfig parses ConfigMap data and resolves references to Secrets.
+func (kcm *kubeConfigManager) parseConfig(data map[string]string) (*KubeConfig, error) {
+	cfg, err := ParseConfigMapData(data)
+	if err != nil {
+		return nil, err
+	}
+	err = kcm.ResolveSecretRefs(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// ResolveSecretRefs replaces references in module sections with values from Secrets in the namespace.
+func (kcm *kubeConfigManager) ResolveSecretRefs(config *KubeConfig) error {
+	return ResolveSecretRefs(config, kcm.getSecret)
+}
+
+func (kcm *kubeConfigManager) getSecret(name string) (*v1.Secret, error) {
+	obj, err := kcm.KubeClient.CoreV1().Secrets(kcm.Namespace).Get(context.TODO(), name, metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		return nil, nil
+	}
+	return obj, err
+}
+
 func (kcm *kubeConfigManager) Init() error {
 	kcm.logEntry.Debug("INIT: KUBE_CONFIG")
 
@@ -263,18 +338,33 @@ func (kcm *kubeConfigManager) isGlobalChanged(newConfig *KubeConfig) bool {
 // handleNewCm determine changes in kube config. It sends KubeConfigChanged event if something
 // changed or KubeConfigInvalid event if ConfigMap is incorrect.
 func (kcm *kubeConfigManager) handleCmEvent(obj *v1.ConfigMap) error {
+	kcm.handleLock.Lock()
+	defer kcm.handleLock.Unlock()
+
 	// ConfigMap is deleted, reset cached config and fire event.
 	if obj == nil {
 		kcm.m.Lock()
 		kcm.currentConfig = NewConfig()
+		kcm.currentData = nil
 		kcm.m.Unlock()
+		kcm.watchSecrets(nil)
 		kcm.configEventCh <- KubeConfigChanged
 		return nil
 	}
 
+	kcm.m.Lock()
+	kcm.currentData = obj.Data
+	kcm.m.Unlock()
+
+	// Watch referenced Secrets before resolving to catch creation of a missing Secret.
 	newConfig, err := ParseConfigMapData(obj.Data)
+	if err == nil {
+		kcm.watchSecrets(SecretRefNames(newConfig))
+		err = kcm.ResolveSecretRefs(newConfig)
+	}
 	if err != nil {
 		// Do not update caches to detect changes on next update.
+		kcm.isInvalid = true
 		kcm.configEventCh <- KubeConfigInvalid
 		kcm.logEntry.Errorf("ConfigMap/%s invalid: %v", kcm.ConfigMapName, err)
 		return err
@@ -324,8 +414,10 @@ func (kcm *kubeConfigManager) handleCmEvent(obj *v1.ConfigMap) error {
 	kcm.currentConfig = newConfig
 	kcm.m.Unlock()
 
-	// Fire event if ConfigMap has changes.
-	if globalChanged || modulesChanged {
+	// Fire event if ConfigMap has changes or becomes valid, e.g. a missing Secret is created.
+	wasInvalid := kcm.isInvalid
+	kcm.isInvalid = false
+	if globalChanged || modulesChanged || wasInvalid {
 		kcm.configEventCh <- KubeConfigChanged
 	}
 
@@ -335,6 +427,10 @@ func (kcm *kubeConfigManager) handleCmEvent(obj *v1.ConfigMap) error {
 func (kcm *kubeConfigManager) Start() {
 	kcm.logEntry.Debugf("Start kube config manager")
 
+	kcm.handleLock.Lock()
+	kcm.watchSecrets(SecretRefNames(kcm.currentConfig))
+	kcm.handleLock.Unlock()
+
 	// define resyncPeriod for informer
 	resyncPeriod := time.Duration(5) * time.Minute
 
@@ -373,6 +469,73 @@ func (kcm *kubeConfigManager) Start() {
 	}()
 }
 
+// watchSecrets starts informers for new referenced Secrets and stops informers
+// for Secrets that are not referenced anymore. It should be called under handleLock.
+func (kcm *kubeConfigManager) watchSecrets(names map[string]struct{}) {
+	if kcm.ctx == nil {
+		return
+	}
+	for name, cancel := range kcm.secretInformers {
+		if _, has := names[name]; !has {
+			cancel()
+			delete(kcm.secretInformers, name)
+		}
+	}
+	for name := range names {
+		if _, has := kcm.secretInformers[name]; has {
+			continue
+		}
+		ctx, cancel := context.WithCancel(kcm.ctx)
+		kcm.secretInformers[name] = cancel
+		kcm.startSecretInformer(ctx, name)
+	}
+}
+
+func (kcm *kubeConfigManager) startSecretInformer(ctx context.Context, name string) {
+	resyncPeriod := time.Duration(5) * time.Minute
+	indexers := cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc}
+	tweakListOptions := func(options *metav1.ListOptions) {
+		options.FieldSelector = fields.OneTermEqualSelector("metadata.name", name).String()
+	}
+
+	secretInformer := corev1.NewFilteredSecretInformer(kcm.KubeClient, kcm.Namespace, resyncPeriod, indexers, tweakListOptions)
+	secretInformer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			kcm.handleSecretEvent(name, "add")
+		},
+		UpdateFunc: func(prevObj interface{}, obj interface{}) {
+			// Ignore resync.
+			if prevObj.(*v1.Secret).ResourceVersion == obj.(*v1.Secret).ResourceVersion {
+				return
+			}
+			kcm.handleSecretEvent(name, "update")
+		},
+		DeleteFunc: func(obj interface{}) {
+			kcm.handleSecretEvent(name, "delete")
+		},
+	})
+
+	go func() {
+		secretInformer.Run(ctx.Done())
+	}()
+}
+
+// handleSecretEvent parses the last ConfigMap data again to update values from the changed Secret.
+func (kcm *kubeConfigManager) handleSecretEvent(name string, eventName string) {
+	var data map[string]string
+	kcm.withLock(func() {
+		data = kcm.currentData
+	})
+	if data == nil {
+		return
+	}
+	kcm.logEntry.Debugf("Secret/%s '%s' event, check module sections in ConfigMap/%s", name, eventName, kcm.ConfigMapName)
+	err := kcm.handleCmEvent(&v1.ConfigMap{Data: data})
+	if err != nil {
+		kcm.logEntry.Errorf("Handle Secret/%s '%s' error: %s", name, eventName, err)
+	}
+}
+
 func (kcm *kubeConfigManager) Stop() {
 	if kcm.cancel != nil {
 		kcm.cancel()
@@ -441,3 +441,44 @@ func Test_KubeConfigManager_error_on_Init(t *testing.T) {
 		g.Expect(vals).To(HaveKey("modParam2"), "Module config values should contain modParam2 key")
 	})
 }
+
+// Converted values should be saved with the latest version, so they are not converted again.
+func Test_KubeConfigManager_SaveModuleConfigValues_converted(t *testing.T) {
+	g := NewWithT(t)
+	kubeClient := klient.NewFake(nil)
+
+	kcm := initKubeConfigManager(t, kubeClient, map[string]string{
+		"convertedModule": "oldParam: val1\n",
+	}, "")
+	kcm.WithConversions(newTestConversions("converted-module"))
+
+	defer kcm.Stop()
+
+	// KubeConfigManager does not convert values, ModuleManager does.
+	readModuleConfig := func() (modCfg *ModuleKubeConfig) {
+		kcm.SafeReadConfig(func(config *KubeConfig) {
+			modCfg = config.Modules["converted-module"]
+		})
+		return modCfg
+	}
+	modCfg := readModuleConfig()
+	g.Expect(modCfg.ConfigVersion).To(Equal(0))
+	checksum := modCfg.Checksum
+
+	err := kcm.SaveModuleConfigValues("converted-module", utils.Values{
+		"convertedModule": map[string]interface{}{"newParam": "val1"},
+	})
+	g.Expect(err).ShouldNot(HaveOccurred())
+
+	cm, err := kubeClient.CoreV1().ConfigMaps("default").Get(context.TODO(), testConfigMapName, metav1.GetOptions{})
+	g.Expect(err).ShouldNot(HaveOccurred(), "ConfigMap get")
+	g.Expect(cm.Data).To(HaveKeyWithValue("convertedModule", "newParam: val1\n"))
+	g.Expect(cm.Data).To(HaveKeyWithValue("convertedModuleConfigVersion", "2\n"))
+
+	// The saved section is read with the latest version.
+	g.Eventually(func() string {
+		return readModuleConfig().Checksum
+	}, "20s", "100ms").ShouldNot(Equal(checksum))
+	g.Expect(readModuleConfig().ConfigVersion).To(Equal(2))
+	g.Expect(readModuleConfig().Values).To(HaveKeyWithValue("convertedModule", map[string]interface{}{"newParam": "val1"}))
+}
@@ -2,9 +2,11 @@ package kube_config_manager
 
 import (
 	"fmt"
+	"strconv"
 	"strings"
 
 	"github.com/flant/addon-operator/pkg/utils"
+	"github.com/flant/addon-operator/pkg/values/conversion"
 )
 
 // GetModulesNamesFromConfigData returns all keys in kube config except global
@@ -18,8 +20,9 @@ func GetModulesNamesFromConfigData(configData map[string]string) (map[string]boo
 			continue
 		}
 
-		// Treat Enabled flags as module section.
+		// Treat Enabled flags and config versions as module section.
 		key = strings.TrimSuffix(key, "Enabled")
+		key = strings.TrimSuffix(key, conversion.VersionKeySuffix)
 
 		modName := utils.ModuleNameFromValuesKey(key)
 
@@ -36,6 +39,14 @@ type ModuleKubeConfig struct {
 	utils.ModuleConfig
 	Checksum   string
 	ConfigData map[string]string
+	// ConfigVersion is a version of config values in the ConfigMap, 0 if the version
+	// key is absent: such values have version 1.
+	ConfigVersion int
+	// ConvertedFrom is a version of config values in the ConfigMap if they were converted
+	// to the latest version, or 0 if no conversion was done. See ConvertModuleKubeConfig.
+	ConvertedFrom int
+	// SecretRefs are references to Secrets resolved in module values.
+	SecretRefs []SecretRef
 }
 
 func (m *ModuleKubeConfig) GetEnabled() string {
@@ -84,8 +95,70 @@ func ExtractModuleKubeConfig(moduleName string, configData map[string]string) (*
 		return nil, fmt.Errorf("possible bug!!! No section '%s' for module '%s'", utils.ModuleNameToValuesKey(moduleName), moduleName)
 	}
 
+	configVersion, err := parseConfigVersion(moduleConfig, configData)
+	if err != nil {
+		return nil, err
+	}
+
 	return &ModuleKubeConfig{
-		ModuleConfig: *moduleConfig,
-		Checksum:     moduleConfig.Checksum(),
+		ModuleConfig:  *moduleConfig,
+		Checksum:      moduleConfig.Checksum(),
+		ConfigVersion: configVersion,
 	}, nil
 }
+
+// parseConfigVersion returns the version of module config values stored in the ConfigMap key
+// with the VersionKeySuffix. The version is added to the raw config to change the checksum.
+func parseConfigVersion(moduleConfig *utils.ModuleConfig, configData map[string]string) (int, error) {
+	versionKey := moduleConfig.ModuleConfigKey + conversion.VersionKeySuffix
+	versionString, has := configData[versionKey]
+	if !has {
+		return 0, nil
+	}
+	version, err := strconv.Atoi(strings.TrimSpace(versionString))
+	if err != nil {
+		return 0, fmt.Errorf("version key '%s' should be an integer, got '%s'", versionKey, versionString)
+	}
+	moduleConfig.RawConfig = append(moduleConfig.RawConfig, versionString)
+	return version, nil
+}
+
+// ConvertModuleKubeConfig converts module config values to the latest version of the config-values
+// schema. It returns a converted copy or the input section if no conversion is needed.
+// References to Secrets are moved with resolved values.
+func ConvertModuleKubeConfig(m *ModuleKubeConfig, chain *conversion.Chain) (*ModuleKubeConfig, error) {
+	version := m.ConfigVersion
+	if version == 0 {
+		version = 1
+	}
+	if chain == nil || version == chain.LatestVersion {
+		return m, nil
+	}
+
+	values := map[string]interface{}{}
+	if moduleValues, has := m.Values[m.ModuleConfigKey]; has {
+		var ok bool
+		values, ok = moduleValues.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("convert config values at key '%s': values should be an object to convert from version %d", m.ModuleConfigKey, version)
+		}
+	} else if version < chain.LatestVersion {
+		// Nothing to convert.
+		return m, nil
+	}
+
+	converted, err := chain.Convert(version, values)
+	if err != nil {
+		return nil, fmt.Errorf("convert config values at key '%s': %v", m.ModuleConfigKey, err)
+	}
+
+	res := *m
+	res.Values = make(utils.Values, len(m.Values))
+	for k, v := range m.Values {
+		res.Values[k] = v
+	}
+	res.Values[m.ModuleConfigKey] = converted
+	res.ConvertedFrom = version
+	res.SecretRefs = moveSecretRefs(converted, m.SecretRefs)
+	return &res, nil
+}
@@ -0,0 +1,147 @@
+package kube_config_manager
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	v1 "k8s.io/api/core/v1"
+
+	"github.com/flant/addon-operator/pkg/values/conversion"
+)
+
+// newTestConversions returns the version 2 of the module config-values schema
+// that renames 'oldParam' to 'newParam'.
+func newTestConversions(moduleName string) *conversion.ConversionRegistry {
+	r := conversion.NewRegistry()
+	r.SetLatestVersion(moduleName, 2)
+	r.AddSteps(moduleName, 2, []conversion.Step{
+		{Move: &conversion.MoveStep{From: "oldParam", To: "newParam"}},
+	})
+	return r
+}
+
+func Test_ConvertModuleKubeConfig(t *testing.T) {
+	conversions := newTestConversions("converted-module")
+
+	tests := []struct {
+		name          string
+		moduleName    string
+		configData    map[string]string
+		wantErr       bool
+		convertedFrom int
+		values        interface{}
+	}{
+		{
+			name:       "no conversions for module",
+			moduleName: "plain-module",
+			configData: map[string]string{"plainModule": "oldParam: 1\n"},
+			values:     map[string]interface{}{"oldParam": 1.0},
+		},
+		{
+			name:          "version is absent",
+			moduleName:    "converted-module",
+			configData:    map[string]string{"convertedModule": "oldParam: 1\n"},
+			convertedFrom: 1,
+			values:        map[string]interface{}{"newParam": 1.0},
+		},
+		{
+			name:       "latest version is present",
+			moduleName: "converted-module",
+			configData: map[string]string{
+				"convertedModule":              "newParam: 1\n",
+				"convertedModuleConfigVersion": "2",
+			},
+			values: map[string]interface{}{"newParam": 1.0},
+		},
+		{
+			name:       "old version is present",
+			moduleName: "converted-module",
+			configData: map[string]string{
+				"convertedModule":              "oldParam: 1\n",
+				"convertedModuleConfigVersion": "1\n",
+			},
+			convertedFrom: 1,
+			values:        map[string]interface{}{"newParam": 1.0},
+		},
+		{
+			name:       "version is newer than latest",
+			moduleName: "converted-module",
+			configData: map[string]string{
+				"convertedModule":              "newParam: 1\n",
+				"convertedModuleConfigVersion": "3",
+			},
+			wantErr: true,
+		},
+		{
+			name:       "section is not an object",
+			moduleName: "converted-module",
+			configData: map[string]string{"convertedModule": "- oldParam\n"},
+			wantErr:    true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			modCfg, err := ExtractModuleKubeConfig(tt.moduleName, tt.configData)
+			require.NoError(t, err)
+			checksum := modCfg.Checksum
+
+			converted, err := ConvertModuleKubeConfig(modCfg, conversions.Get(tt.moduleName))
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.convertedFrom, converted.ConvertedFrom)
+			assert.Equal(t, tt.values, converted.Values[converted.ModuleConfigKey])
+			assert.Equal(t, checksum, converted.Checksum, "checksum of the ConfigMap section should be kept")
+			if tt.convertedFrom != 0 {
+				assert.Equal(t, map[string]interface{}{"oldParam": 1.0}, modCfg.Values[modCfg.ModuleConfigKey], "input section should not be modified")
+			}
+		})
+	}
+}
+
+func Test_ExtractModuleKubeConfig_ConfigVersion(t *testing.T) {
+	modCfg, err := ExtractModuleKubeConfig("converted-module", map[string]string{
+		"convertedModule":              "newParam: 1\n",
+		"convertedModuleConfigVersion": "2\n",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 2, modCfg.ConfigVersion)
+
+	_, err = ExtractModuleKubeConfig("converted-module", map[string]string{
+		"convertedModule":              "newParam: 1\n",
+		"convertedModuleConfigVersion": "v2",
+	})
+	assert.Error(t, err)
+}
+
+func Test_ConvertModuleKubeConfig_SecretRefs(t *testing.T) {
+	conversions := newTestConversions("converted-module")
+
+	modCfg, err := ExtractModuleKubeConfig("converted-module", map[string]string{
+		"convertedModule": "oldParam:\n  secretKeyRef:\n    name: db\n    key: password\n",
+	})
+	require.NoError(t, err)
+	err = modCfg.resolveSecretRefs(func(name string) (*v1.Secret, error) {
+		return &v1.Secret{Data: map[string][]byte{"password": []byte("converted-test-password")}}, nil
+	}, map[string]*v1.Secret{})
+	require.NoError(t, err)
+	require.Len(t, modCfg.SecretRefs, 1)
+	assert.Equal(t, "/oldParam", modCfg.SecretRefs[0].Path)
+
+	converted, err := ConvertModuleKubeConfig(modCfg, conversions.Get("converted-module"))
+	require.NoError(t, err)
+	require.Len(t, converted.SecretRefs, 1)
+	assert.Equal(t, "/newParam", converted.SecretRefs[0].Path, "reference should be moved with the value")
+	assert.Equal(t, "/oldParam", modCfg.SecretRefs[0].Path, "input references should not be modified")
+
+	// Converted values are saved with the reference.
+	restored := RestoreSecretRefs(converted.Values, "convertedModule", converted.SecretRefs)
+	assert.Equal(t, map[string]interface{}{"newParam": map[string]interface{}{
+		"secretKeyRef": map[string]interface{}{"name": "db", "key": "password"},
+	}}, restored["convertedModule"])
+}
@@ -0,0 +1,331 @@
+package kube_config_manager
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	utils_checksum "github.com/flant/shell-operator/pkg/utils/checksum"
+	v1 "k8s.io/api/core/v1"
+
+	"github.com/flant/addon-operator/pkg/utils"
+)
+
+// SecretKeyRefKey is a key of the object in module config values that references
+// a key of the Secret in the operator namespace:
+//
+//	moduleName:
+//	  password:
+//	    secretKeyRef:
+//	      name: module-credentials
+//	      key: password
+const SecretKeyRefKey = "secretKeyRef"
+
+// SecretRef is a reference to a key of the Secret in module config values.
+type SecretRef struct {
+	// Path is a JSON pointer to the value in the module section: /db/password.
+	Path string
+	Name string
+	Key  string
+	// value is a resolved value to restore the reference when config values are saved.
+	value string
+}
+
+// SecretGetter returns the Secret from the operator namespace or nil if it is not found.
+type SecretGetter func(name string) (*v1.Secret, error)
+
+// SecretRefNames returns names of Secrets referenced in module sections.
+func SecretRefNames(cfg *KubeConfig) map[string]struct{} {
+	names := make(map[string]struct{})
+	if cfg == nil {
+		return names
+	}
+	for _, modCfg := range cfg.Modules {
+		_, _ = walkSecretRefs(modCfg.Values[modCfg.ModuleConfigKey], nil, func(_ []string, name string, _ string) (string, error) {
+			names[name] = struct{}{}
+			return "", nil
+		})
+	}
+	return names
+}
+
+// ResolveSecretRefs replaces references in module sections with values from Secrets.
+// Checksums of module sections are updated to detect changes in referenced keys.
+// Resolved values are not registered for masking: the config may be rejected,
+// use SetSecretValues when the config is applied.
+func ResolveSecretRefs(cfg *KubeConfig, getSecret SecretGetter) error {
+	if cfg == nil {
+		return nil
+	}
+	secrets := make(map[string]*v1.Secret)
+	for _, moduleName := range sortedModuleNames(cfg) {
+		err := cfg.Modules[moduleName].resolveSecretRefs(getSecret, secrets)
+		if err != nil {
+			return fmt.Errorf("module '%s' section: %v", moduleName, err)
+		}
+	}
+	return nil
+}
+
+func (m *ModuleKubeConfig) resolveSecretRefs(getSecret SecretGetter, secrets map[string]*v1.Secret) error {
+	section, has := m.Values[m.ModuleConfigKey]
+	if !has {
+		return nil
+	}
+
+	refs := make([]SecretRef, 0)
+	resolved, err := walkSecretRefs(section, []string{}, func(tokens []string, name string, key string) (string, error) {
+		secret, cached := secrets[name]
+		if !cached {
+			var err error
+			secret, err = getSecret(name)
+			if err != nil {
+				return "", fmt.Errorf("get Secret/%s: %v", name, err)
+			}
+			secrets[name] = secret
+		}
+		if secret == nil {
+			return "", fmt.Errorf("Secret/%s referenced at '%s' is not found", name, toJSONPointer(tokens))
+		}
+		data, hasKey := secret.Data[key]
+		if !hasKey {
+			return "", fmt.Errorf("Secret/%s referenced at '%s' has no key '%s'", name, toJSONPointer(tokens), key)
+		}
+		value := string(data)
+		refs = append(refs, SecretRef{Path: toJSONPointer(tokens), Name: name, Key: key, value: value})
+		return value, nil
+	})
+	if err != nil {
+		return err
+	}
+	if len(refs) == 0 {
+		return nil
+	}
+
+	m.Values[m.ModuleConfigKey] = resolved
+	m.SecretRefs = refs
+
+	// Module is reloaded if the referenced key is changed.
+	checksumParts := append([]string{}, m.RawConfig...)
+	for _, ref := range refs {
+		checksumParts = append(checksumParts, ref.Path, ref.value)
+	}
+	m.Checksum = utils_checksum.CalculateChecksum(checksumParts...)
+	return nil
+}
+
+// SecretValues returns values resolved from Secrets in the module section.
+func (m *ModuleKubeConfig) SecretValues() []string {
+	if m == nil {
+		return nil
+	}
+	values := make([]string, 0, len(m.SecretRefs))
+	for _, ref := range m.SecretRefs {
+		values = append(values, ref.value)
+	}
+	return values
+}
+
+// SecretValues returns values resolved from Secrets in all module sections.
+func (cfg *KubeConfig) SecretValues() []string {
+	values := make([]string, 0)
+	if cfg == nil {
+		return values
+	}
+	for _, modCfg := range cfg.Modules {
+		values = append(values, modCfg.SecretValues()...)
+	}
+	return values
+}
+
+// SetSecretValues registers values resolved from Secrets in the applied config to mask them
+// in debug output and logs. Values registered for moduleNames without a section are dropped.
+func SetSecretValues(cfg *KubeConfig, moduleNames []string) {
+	names := make(map[string]struct{}, len(moduleNames))
+	for _, name := range moduleNames {
+		names[name] = struct{}{}
+	}
+	if cfg != nil {
+		for name := range cfg.Modules {
+			names[name] = struct{}{}
+		}
+	}
+	for name := range names {
+		var modCfg *ModuleKubeConfig
+		if cfg != nil {
+			modCfg = cfg.Modules[name]
+		}
+		utils.SetSecretValues(secretValuesScope(name), modCfg.SecretValues())
+	}
+}
+
+// secretValuesScope is a scope of values resolved from Secrets for the module section.
+func secretValuesScope(moduleName string) string {
+	return "secretKeyRef/" + moduleName
+}
+
+// walkSecretRefs calls fn for each reference and returns a copy of the value with
+// references replaced by fn results.
+func walkSecretRefs(value interface{}, tokens []string, fn func(tokens []string, name string, key string) (string, error)) (interface{}, error) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		if name, key, isRef, err := parseSecretKeyRef(v); isRef {
+			if err != nil {
+				return nil, fmt.Errorf("bad %s at '%s': %v", SecretKeyRefKey, toJSONPointer(tokens), err)
+			}
+			return fn(tokens, name, key)
+		}
+		res := make(map[string]interface{}, len(v))
+		for k, item := range v {
+			resolved, err := walkSecretRefs(item, appendToken(tokens, k), fn)
+			if err != nil {
+				return nil, err
+			}
+			res[k] = resolved
+		}
+		return res, nil
+	case []interface{}:
+		res := make([]interface{}, 0, len(v))
+		for i, item := range v {
+			resolved, err := walkSecretRefs(item, appendToken(tokens, fmt.Sprintf("%d", i)), fn)
+			if err != nil {
+				return nil, err
+			}
+			res = append(res, resolved)
+		}
+		return res, nil
+	}
+	return value, nil
+}
+
+// parseSecretKeyRef returns true if the object is a reference: an object with the only key 'secretKeyRef'.
+func parseSecretKeyRef(obj map[string]interface{}) (name string, key string, isRef bool, err error) {
+	refObj, has := obj[SecretKeyRefKey]
+	if !has || len(obj) != 1 {
+		return "", "", false, nil
+	}
+	ref, ok := refObj.(map[string]interface{})
+	if !ok {
+		return "", "", true, fmt.Errorf("should be an object with 'name' and 'key'")
+	}
+	name, _ = ref["name"].(string)
+	key, _ = ref["key"].(string)
+	if name == "" || key == "" {
+		return "", "", true, fmt.Errorf("'name' and 'key' should be non-empty strings")
+	}
+	return name, key, true, nil
+}
+
+// moveSecretRefs returns references for paths of resolved values in the converted section.
+// Conversions may move values, so references are found by values. A value at several paths
+// is restored at each of them to keep it out of the ConfigMap.
+func moveSecretRefs(section interface{}, refs []SecretRef) []SecretRef {
+	if len(refs) == 0 {
+		return refs
+	}
+	byValue := make(map[string]SecretRef, len(refs))
+	for _, ref := range refs {
+		byValue[ref.value] = ref
+	}
+
+	res := make([]SecretRef, 0, len(refs))
+	var walk func(value interface{}, tokens []string)
+	walk = func(value interface{}, tokens []string) {
+		switch v := value.(type) {
+		case string:
+			if ref, has := byValue[v]; has {
+				ref.Path = toJSONPointer(tokens)
+				res = append(res, ref)
+			}
+		case map[string]interface{}:
+			keys := make([]string, 0, len(v))
+			for k := range v {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+			for _, k := range keys {
+				walk(v[k], appendToken(tokens, k))
+			}
+		case []interface{}:
+			for i, item := range v {
+				walk(item, appendToken(tokens, fmt.Sprintf("%d", i)))
+			}
+		}
+	}
+	walk(section, nil)
+	return res
+}
+
+// RestoreSecretRefs returns a copy of module values with resolved values replaced back
+// with references to keep secrets out of the ConfigMap. Values changed by hooks are not restored.
+func RestoreSecretRefs(values utils.Values, valuesKey string, refs []SecretRef) utils.Values {
+	section, has := values[valuesKey]
+	if !has || len(refs) == 0 {
+		return values
+	}
+
+	byPath := make(map[string]SecretRef, len(refs))
+	for _, ref := range refs {
+		byPath[ref.Path] = ref
+	}
+
+	var restore func(value interface{}, tokens []string) interface{}
+	restore = func(value interface{}, tokens []string) interface{} {
+		if ref, isRef := byPath[toJSONPointer(tokens)]; isRef && value == ref.value {
+			return map[string]interface{}{
+				SecretKeyRefKey: map[string]interface{}{
+					"name": ref.Name,
+					"key":  ref.Key,
+				},
+			}
+		}
+		switch v := value.(type) {
+		case map[string]interface{}:
+			res := make(map[string]interface{}, len(v))
+			for k, item := range v {
+				res[k] = restore(item, appendToken(tokens, k))
+			}
+			return res
+		case []interface{}:
+			res := make([]interface{}, 0, len(v))
+			for i, item := range v {
+				res = append(res, restore(item, appendToken(tokens, fmt.Sprintf("%d", i))))
+			}
+			return res
+		}
+		return value
+	}
+
+	res := make(utils.Values, len(values))
+	for k, v := range values {
+		res[k] = v
+	}
+	res[valuesKey] = restore(section, []string{})
+	return res
+}
+
+func sortedModuleNames(cfg *KubeConfig) []string {
+	names := make([]string, 0, len(cfg.Modules))
+	for name := range cfg.Modules {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func appendToken(tokens []string, token string) []string {
+	res := make([]string, 0, len(tokens)+1)
+	res = append(res, tokens...)
+	return append(res, token)
+}
+
+// toJSONPointer returns a JSON pointer for path tokens.
+func toJSONPointer(tokens []string) string {
+	escaper := strings.NewReplacer("~", "~0", "/", "~1")
+	var sb strings.Builder
+	for _, token := range tokens {
+		sb.WriteString("/")
+		sb.WriteString(escaper.Replace(token))
+	}
+	return sb.String()
+}
@@ -0,0 +1,135 @@
+package kube_config_manager
+
+import (
+	"context"
+	"testing"
+
+	klient "github.com/flant/kube-client/client"
+	. "github.com/onsi/gomega"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/flant/addon-operator/pkg/utils"
+)
+
+func Test_ResolveSecretRefs(t *testing.T) {
+	g := NewWithT(t)
+
+	secrets := map[string]*v1.Secret{
+		"db": {Data: map[string][]byte{"password": []byte("resolve-test-pass")}},
+	}
+	getSecret := func(name string) (*v1.Secret, error) {
+		return secrets[name], nil
+	}
+
+	cfg, err := ParseConfigMapData(map[string]string{
+		"moduleOne": `
+user: admin
+db:
+  password:
+    secretKeyRef:
+      name: db
+      key: password
+replicas:
+- secretKeyRef:
+    name: db
+    key: password
+`,
+	})
+	g.Expect(err).ShouldNot(HaveOccurred())
+	checksum := cfg.Modules["module-one"].Checksum
+	g.Expect(SecretRefNames(cfg)).To(Equal(map[string]struct{}{"db": {}}))
+
+	err = ResolveSecretRefs(cfg, getSecret)
+	g.Expect(err).ShouldNot(HaveOccurred())
+
+	modCfg := cfg.Modules["module-one"]
+	g.Expect(modCfg.Values["moduleOne"]).To(Equal(map[string]interface{}{
+		"user":     "admin",
+		"db":       map[string]interface{}{"password": "resolve-test-pass"},
+		"replicas": []interface{}{"resolve-test-pass"},
+	}))
+	g.Expect(modCfg.SecretRefs).To(HaveLen(2))
+	g.Expect(modCfg.Checksum).ShouldNot(Equal(checksum), "checksum should depend on resolved values")
+	g.Expect(modCfg.SecretValues()).To(Equal([]string{"resolve-test-pass", "resolve-test-pass"}))
+	g.Expect(utils.IsSecretValue("resolve-test-pass")).To(BeFalse(), "values should be registered only when the config is applied")
+
+	SetSecretValues(cfg, nil)
+	g.Expect(utils.IsSecretValue("resolve-test-pass")).To(BeTrue())
+	SetSecretValues(nil, []string{"module-one"})
+	g.Expect(utils.IsSecretValue("resolve-test-pass")).To(BeFalse(), "values of the removed section should be dropped")
+
+	// References are restored if values are not changed.
+	values := utils.Values{"moduleOne": map[string]interface{}{
+		"user":     "root",
+		"db":       map[string]interface{}{"password": "resolve-test-pass"},
+		"replicas": []interface{}{"changed"},
+	}}
+	restored := RestoreSecretRefs(values, "moduleOne", modCfg.SecretRefs)
+	g.Expect(restored["moduleOne"]).To(Equal(map[string]interface{}{
+		"user": "root",
+		"db": map[string]interface{}{"password": map[string]interface{}{
+			"secretKeyRef": map[string]interface{}{"name": "db", "key": "password"},
+		}},
+		"replicas": []interface{}{"changed"},
+	}))
+	g.Expect(values["moduleOne"].(map[string]interface{})["db"]).To(Equal(map[string]interface{}{"password": "resolve-test-pass"}), "input values should not be modified")
+
+	// Errors.
+	for _, data := range []string{
+		"password:\n  secretKeyRef:\n    name: unknown\n    key: password\n",
+		"password:\n  secretKeyRef:\n    name: db\n    key: unknown\n",
+		"password:\n  secretKeyRef:\n    name: db\n",
+	} {
+		cfg, err := ParseConfigMapData(map[string]string{"moduleOne": data})
+		g.Expect(err).ShouldNot(HaveOccurred())
+		err = ResolveSecretRefs(cfg, getSecret)
+		g.Expect(err).Should(HaveOccurred(), data)
+		g.Expect(err.Error()).Should(ContainSubstring("/password"))
+	}
+}
+
+func Test_KubeConfigManager_SecretRef_change(t *testing.T) {
+	g := NewWithT(t)
+
+	kubeClient := klient.NewFake(nil)
+	secret := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "db", Namespace: "default", ResourceVersion: "1"},
+		Data:       map[string][]byte{"password": []byte("kcm-test-pass-1")},
+	}
+	_, err := kubeClient.CoreV1().Secrets("default").Create(context.TODO(), secret, metav1.CreateOptions{})
+	g.Expect(err).ShouldNot(HaveOccurred())
+
+	kcm := initKubeConfigManager(t, kubeClient, map[string]string{
+		"moduleOne": "password:\n  secretKeyRef:\n    name: db\n    key: password\n",
+	}, "")
+	defer kcm.Stop()
+
+	password := func() interface{} {
+		var res interface{}
+		kcm.SafeReadConfig(func(config *KubeConfig) {
+			res = config.Modules["module-one"].Values["moduleOne"].(map[string]interface{})["password"]
+		})
+		return res
+	}
+	g.Expect(password()).To(Equal("kcm-test-pass-1"))
+
+	// Secret change should fire the event with new values.
+	secret.Data["password"] = []byte("kcm-test-pass-2")
+	secret.ResourceVersion = "2"
+	_, err = kubeClient.CoreV1().Secrets("default").Update(context.TODO(), secret, metav1.UpdateOptions{})
+	g.Expect(err).ShouldNot(HaveOccurred())
+
+	g.Eventually(kcm.KubeConfigEventCh(), "20s", "100ms").Should(Receive(Equal(KubeConfigChanged)))
+	g.Expect(password()).To(Equal("kcm-test-pass-2"))
+
+	// Saved values should keep the reference.
+	err = kcm.SaveModuleConfigValues("module-one", utils.Values{
+		"moduleOne": map[string]interface{}{"password": "kcm-test-pass-2", "replicas": 2},
+	})
+	g.Expect(err).ShouldNot(HaveOccurred())
+	cm, err := kubeClient.CoreV1().ConfigMaps("default").Get(context.TODO(), testConfigMapName, metav1.GetOptions{})
+	g.Expect(err).ShouldNot(HaveOccurred())
+	g.Expect(cm.Data["moduleOne"]).ShouldNot(ContainSubstring("kcm-test-pass"))
+	g.Expect(cm.Data["moduleOne"]).Should(ContainSubstring("secretKeyRef"))
+}
@@ -0,0 +1,97 @@
+package module_manager
+
+import (
+	log "github.com/sirupsen/logrus"
+
+	"github.com/flant/addon-operator/pkg/app"
+	"github.com/flant/addon-operator/pkg/kube_config_manager"
+	"github.com/flant/addon-operator/pkg/utils"
+	"github.com/flant/addon-operator/pkg/values/validation"
+)
+
+const configDeprecatedSettingsMetric = "{PREFIX}config_values_deprecated_settings"
+
+// migrateKubeConfig moves values of renamed settings in global and module sections
+// and returns warnings about deprecated settings by module name or "global".
+// Sections with renamed settings are copied, input KubeConfig is not modified.
+func (mm *moduleManager) migrateKubeConfig(kubeConfig *kube_config_manager.KubeConfig) (*kube_config_manager.KubeConfig, map[string][]validation.Deprecation) {
+	if kubeConfig == nil {
+		return nil, nil
+	}
+
+	deprecations := make(map[string][]validation.Deprecation)
+	res := &kube_config_manager.KubeConfig{
+		Global:  kubeConfig.Global,
+		Modules: make(map[string]*kube_config_manager.ModuleKubeConfig, len(kubeConfig.Modules)),
+	}
+
+	if kubeConfig.Global != nil {
+		values, globalDeprecations := mm.ValuesValidator.MigrateConfigValues(validation.GlobalSchema, "", kubeConfig.Global.Values)
+		if len(globalDeprecations) > 0 {
+			deprecations[utils.GlobalValuesKey] = globalDeprecations
+			globalCfg := *kubeConfig.Global
+			globalCfg.Values = values
+			// Checksum is compared with the checksum of saved values to detect changes.
+			if checksum, err := values.Checksum(); err == nil {
+				globalCfg.Checksum = checksum
+			}
+			res.Global = &globalCfg
+		}
+	}
+
+	for moduleName, modCfg := range kubeConfig.Modules {
+		res.Modules[moduleName] = modCfg
+		module := mm.GetModule(moduleName)
+		if module == nil {
+			continue
+		}
+		values, moduleDeprecations := mm.ValuesValidator.MigrateConfigValues(validation.ModuleSchema, module.ValuesKey(), modCfg.Values)
+		if len(moduleDeprecations) > 0 {
+			deprecations[moduleName] = moduleDeprecations
+			newModCfg := *modCfg
+			newModCfg.Values = values
+			res.Modules[moduleName] = &newModCfg
+		}
+	}
+
+	return res, deprecations
+}
+
+// updateConfigDeprecations logs warnings about deprecated settings in the ConfigMap,
+// exposes them as a metric and saves them for the module status.
+func (mm *moduleManager) updateConfigDeprecations(deprecations map[string][]validation.Deprecation) {
+	for name, items := range deprecations {
+		for _, d := range items {
+			log.WithField("module", name).Warnf("ConfigMap/%s: %s", app.ConfigMapName, d.String())
+		}
+	}
+
+	if mm.metricStorage != nil {
+		mm.metricStorage.Gauge(configDeprecatedSettingsMetric, map[string]string{"module": "", "setting": ""}).Reset()
+		for name, items := range deprecations {
+			moduleLabel := name
+			// Empty "module" label for global settings as in other metrics.
+			if name == utils.GlobalValuesKey {
+				moduleLabel = ""
+			}
+			for _, d := range items {
+				mm.metricStorage.GaugeSet(configDeprecatedSettingsMetric, 1.0, map[string]string{
+					"module":  moduleLabel,
+					"setting": d.Field,
+				})
+			}
+		}
+	}
+
+	mm.valuesLayersLock.Lock()
+	mm.configDeprecations = deprecations
+	mm.valuesLayersLock.Unlock()
+}
+
+// ConfigDeprecations returns warnings about deprecated settings in the ConfigMap section
+// of the module or of the global section if name is "global".
+func (mm *moduleManager) ConfigDeprecations(name string) []validation.Deprecation {
+	mm.valuesLayersLock.RLock()
+	defer mm.valuesLayersLock.RUnlock()
+	return mm.configDeprecations[name]
+}
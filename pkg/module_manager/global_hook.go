@@ -1,6 +1,7 @@
 package module_manager
 
 import (
+	"errors"
 	"fmt"
 	"path/filepath"
 	"strings"
@@ -60,6 +61,15 @@ func (g *GlobalHook) WithGoConfig(config *go_hook.HookConfig) (err error) {
 	return nil
 }
 
+// RetryPolicy returns a retry policy from the Go hook config or from 'settings.retry'
+// of shell, plugin and WASM hooks. Nil means that the policy is not set.
+func (g *GlobalHook) RetryPolicy() *utils.RetryPolicy {
+	if policy := g.CommonHook.RetryPolicy(); policy != nil {
+		return policy
+	}
+	return g.Config.Retry
+}
+
 func (gh *GlobalHook) GetConfigDescription() string {
 	msgs := []string{}
 	if gh.Config.BeforeAll != nil {
@@ -157,6 +167,9 @@ func (h *GlobalHook) Run(bindingType BindingType, bindingContext []BindingContex
 	globalHookExecutor := NewHookExecutor(h, bindingContext, h.Config.Version, h.moduleManager.KubeObjectPatcher)
 	globalHookExecutor.WithLogLabels(logLabels)
 	globalHookExecutor.WithHelm(h.moduleManager.helm)
+	globalHookExecutor.WithContext(h.moduleManager.Context())
+	globalHookExecutor.WithTimeout(effectiveHookTimeout(h.Config.Timeout))
+	globalHookExecutor.WithLimits(h.Config.Limits)
 	hookResult, err := globalHookExecutor.Run()
 	if hookResult != nil && hookResult.Usage != nil {
 		metricLabels := map[string]string{
@@ -171,7 +184,15 @@ func (h *GlobalHook) Run(bindingType BindingType, bindingContext []BindingContex
 		h.moduleManager.metricStorage.GaugeSet("{PREFIX}global_hook_run_max_rss_bytes", float64(hookResult.Usage.MaxRss)*1024, metricLabels)
 	}
 	if err != nil {
-		return fmt.Errorf("global hook '%s' failed: %s", h.Name, err)
+		var timeoutErr *HookTimeoutError
+		if errors.As(err, &timeoutErr) {
+			h.moduleManager.metricStorage.CounterAdd("{PREFIX}hook_timeout_total", 1.0, map[string]string{"module": "", "hook": h.Name})
+		}
+		var limitErr *HookLimitExceededError
+		if errors.As(err, &limitErr) {
+			h.moduleManager.metricStorage.CounterAdd("{PREFIX}hook_limit_exceeded_total", 1.0, map[string]string{"module": "", "hook": h.Name, "limit": limitErr.Limit})
+		}
+		return fmt.Errorf("global hook '%s' failed: %w", h.Name, err)
 	}
 
 	// Apply metric operations
@@ -221,6 +242,7 @@ func (h *GlobalHook) Run(bindingType BindingType, bindingContext []BindingContex
 			}
 
 			h.moduleManager.UpdateGlobalConfigValues(configValuesPatchResult.Values)
+			h.moduleManager.registerGlobalSensitiveValues()
 
 			logEntry.Debugf("Global hook '%s': kube config global values updated", h.Name)
 			logEntry.Debugf("New kube config global values:\n%s\n", h.moduleManager.kubeGlobalConfigValues.DebugString())
@@ -262,6 +284,8 @@ func (h *GlobalHook) Run(bindingType BindingType, bindingContext []BindingContex
 			if err != nil {
 				return fmt.Errorf("global hook '%s': global values after patch apply: %s", h.Name, err)
 			}
+			h.moduleManager.registerSensitiveValues(utils.GlobalValuesKey, newGlobalValues)
+			h.moduleManager.recordGlobalValuesPatch(h.Name, historyBindingName(bindingType, bindingContext), valuesPatchResult.ValuesPatch, newGlobalValues)
 			logEntry.Debugf("Global hook '%s': kube global values updated", h.Name)
 			logEntry.Debugf("New global values:\n%s", newGlobalValues.DebugString())
 		}
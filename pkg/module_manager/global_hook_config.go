@@ -3,6 +3,7 @@ package module_manager
 import (
 	"errors"
 	"fmt"
+	"time"
 
 	"github.com/davecgh/go-spew/spew"
 	types2 "github.com/flant/shell-operator/pkg/kube_events_manager/types"
@@ -12,6 +13,7 @@ import (
 
 	. "github.com/flant/addon-operator/pkg/hook/types"
 	"github.com/flant/addon-operator/pkg/module_manager/go_hook"
+	"github.com/flant/addon-operator/pkg/utils"
 
 	. "github.com/flant/shell-operator/pkg/hook/types"
 
@@ -36,6 +38,12 @@ type GlobalHookConfig struct {
 	// effective config values
 	BeforeAll *BeforeAllConfig
 	AfterAll  *AfterAllConfig
+	// Timeout for the hook process from 'settings.timeout'.
+	Timeout time.Duration
+	// Limits for the hook process from 'settings'.
+	Limits HookLimits
+	// Retry is a retry policy from 'settings.retry'.
+	Retry *utils.RetryPolicy
 }
 
 type BeforeAllConfig struct {
@@ -49,11 +57,12 @@ type AfterAllConfig struct {
 }
 
 type GlobalHookConfigV0 struct {
-	BeforeAll interface{} `json:"beforeAll"`
-	AfterAll  interface{} `json:"afterAll"`
+	BeforeAll interface{}   `json:"beforeAll"`
+	AfterAll  interface{}   `json:"afterAll"`
+	Settings  *HookSettings `json:"settings"`
 }
 
-func GetGlobalHookConfigSchema(version string) *spec.Schema {
+func GetGlobalHookConfigSchema(version string) (*spec.Schema, error) {
 	globalHookVersion := "global-hook-" + version
 	if _, ok := config.Schemas[globalHookVersion]; !ok {
 		schema := config.Schemas[version]
@@ -80,9 +89,17 @@ func GetGlobalHookConfigSchema(version string) *spec.Schema {
 `
 		}
 		config.Schemas[globalHookVersion] = schema
+		if version == "v1" {
+			err := addHookSettingsToSchema(config.GetSchema(globalHookVersion))
+			if err != nil {
+				delete(config.Schemas, globalHookVersion)
+				delete(config.SchemasCache, globalHookVersion)
+				return nil, err
+			}
+		}
 	}
 
-	return config.GetSchema(globalHookVersion)
+	return config.GetSchema(globalHookVersion), nil
 }
 
 // LoadAndValidate loads config from bytes and validate it. Returns multierror.
@@ -93,7 +110,12 @@ func (c *GlobalHookConfig) LoadAndValidate(data []byte) error {
 		return err
 	}
 
-	err = config.ValidateConfig(vu.Obj, GetGlobalHookConfigSchema(vu.Version), "")
+	schema, err := GetGlobalHookConfigSchema(vu.Version)
+	if err != nil {
+		return err
+	}
+
+	err = config.ValidateConfig(vu.Obj, schema, "")
 	if err != nil {
 		return err
 	}
@@ -170,6 +192,18 @@ func (c *GlobalHookConfig) ConvertAndCheckV1() (err error) {
 		return err
 	}
 
+	c.Timeout, err = ConvertHookTimeout(c.GlobalV1.Settings)
+	if err != nil {
+		return err
+	}
+	c.Limits, err = ConvertHookLimits(c.GlobalV1.Settings)
+	if err != nil {
+		return err
+	}
+	if c.GlobalV1.Settings != nil {
+		c.Retry = c.GlobalV1.Settings.Retry
+	}
+
 	return nil
 }
 
@@ -250,6 +284,10 @@ func NewGlobalHookConfigFromGoConfig(input *go_hook.HookConfig) (*GlobalHookConf
 		HookConfig: hookConfig,
 	}
 
+	if input.Settings != nil {
+		cfg.Timeout = input.Settings.Timeout
+	}
+
 	if input.OnBeforeAll != nil {
 		cfg.BeforeAll = &BeforeAllConfig{}
 		cfg.BeforeAll.BindingName = string(BeforeAll)
@@ -278,6 +316,11 @@ func NewHookConfigFromGoConfig(input *go_hook.HookConfig) (config.HookConfig, er
 			ExecutionBurst:       input.Settings.ExecutionBurst,
 		}
 	}
+	if input.Settings != nil && input.Settings.Retry != nil {
+		if err := input.Settings.Retry.Validate(); err != nil {
+			return c, fmt.Errorf("settings.retry: %v", err)
+		}
+	}
 
 	if input.OnStartup != nil {
 		c.OnStartup = &OnStartupConfig{}
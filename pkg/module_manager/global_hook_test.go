@@ -1,6 +1,8 @@
 package module_manager
 
 import (
+	"os"
+	"path/filepath"
 	"testing"
 
 	. "github.com/flant/addon-operator/pkg/hook/types"
@@ -58,3 +60,31 @@ beforeAll: 23
 
 func Test_GlobalHook_Description(t *testing.T) {
 }
+
+func Test_searchHookPaths(t *testing.T) {
+	g := NewWithT(t)
+
+	dir := t.TempDir()
+	for name, mode := range map[string]os.FileMode{
+		"hook.sh":             0o755,
+		"hook.sh.config.yaml": 0o755,
+		"not-executable.sh":   0o644,
+		"hook.wasm":           0o644,
+		"lib/executable.wasm": 0o755,
+		".hidden/hook.sh":     0o755,
+	} {
+		path := filepath.Join(dir, name)
+		g.Expect(os.MkdirAll(filepath.Dir(path), 0o755)).To(Succeed())
+		g.Expect(os.WriteFile(path, nil, mode)).To(Succeed())
+	}
+
+	shellPaths, wasmPaths, err := searchHookPaths(dir)
+	g.Expect(err).ShouldNot(HaveOccurred())
+	g.Expect(shellPaths).To(Equal([]string{filepath.Join(dir, "hook.sh")}))
+	g.Expect(wasmPaths).To(Equal([]string{filepath.Join(dir, "hook.wasm"), filepath.Join(dir, "lib/executable.wasm")}))
+
+	shellPaths, wasmPaths, err = searchHookPaths(filepath.Join(dir, "absent"))
+	g.Expect(err).ShouldNot(HaveOccurred())
+	g.Expect(shellPaths).To(BeEmpty())
+	g.Expect(wasmPaths).To(BeEmpty())
+}
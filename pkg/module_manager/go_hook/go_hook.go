@@ -1,6 +1,7 @@
 package go_hook
 
 import (
+	"context"
 	"time"
 
 	"github.com/flant/shell-operator/pkg/kube/object_patch"
@@ -10,6 +11,7 @@ import (
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 
 	"github.com/flant/addon-operator/pkg/module_manager/go_hook/metrics"
+	"github.com/flant/addon-operator/pkg/utils"
 )
 
 type GoHook interface {
@@ -30,8 +32,20 @@ type ObjectPatcher interface {
 	DeleteObjectNonCascading(apiVersion, kind, namespace, name, subresource string) error
 }
 
+// PatchCollector collects operations on Kubernetes objects. It is implemented
+// by object_patch.PatchCollector in the operator and by a collector of operation
+// specs in plugin hooks.
+type PatchCollector interface {
+	Create(object interface{}, options ...object_patch.CreateOption)
+	Delete(apiVersion, kind, namespace, name string, options ...object_patch.DeleteOption)
+	MergePatch(mergePatch interface{}, apiVersion, kind, namespace, name string, options ...object_patch.PatchOption)
+	JSONPatch(jsonPatch interface{}, apiVersion, kind, namespace, name string, options ...object_patch.PatchOption)
+	Filter(filterFunc func(*unstructured.Unstructured) (*unstructured.Unstructured, error),
+		apiVersion, kind, namespace, name string, options ...object_patch.FilterOption)
+}
+
 type patchCollectorProxy struct {
-	patcher *object_patch.PatchCollector
+	patcher PatchCollector
 }
 
 // Deprecated. Use Create from PatchCollector.
@@ -124,11 +138,13 @@ type FilterResult interface{}
 type Snapshots map[string][]FilterResult
 
 type HookInput struct {
+	// Context is canceled on operator shutdown or when the hook timeout is exceeded.
+	Context          context.Context
 	Snapshots        Snapshots
 	Values           *PatchableValues
 	ConfigValues     *PatchableValues
 	MetricsCollector MetricsCollector
-	PatchCollector   *object_patch.PatchCollector
+	PatchCollector   PatchCollector
 	LogEntry         *logrus.Entry
 	BindingActions   *[]BindingAction
 }
@@ -165,6 +181,10 @@ type HookConfigSettings struct {
 	// EnableSchedulesOnStartup
 	// set to true, if you need to run 'Schedule' hooks without waiting addon-operator readiness
 	EnableSchedulesOnStartup bool
+	// Retry is a policy for retries of a failed hook. Shell-operator's default backoff is used if nil.
+	Retry *utils.RetryPolicy
+	// Timeout is a deadline for HookInput.Context. Default timeout is used if zero.
+	Timeout time.Duration
 }
 
 type ScheduleConfig struct {
@@ -84,6 +84,38 @@ func (p *PatchableValues) Remove(path string) {
 	p.patchOperations = append(p.patchOperations, op)
 }
 
+// Append adds the value to the end of the array. The array is created if the path does not exist.
+func (p *PatchableValues) Append(path string, value interface{}) {
+	p.addOperation(utils.AppendOp, path, value)
+}
+
+// Merge applies the JSON Merge Patch (RFC 7386) to the object: keys with nil values are deleted,
+// nested objects are merged. The object is created if the path does not exist.
+func (p *PatchableValues) Merge(path string, patch map[string]interface{}) {
+	p.addOperation(utils.MergeOp, path, patch)
+}
+
+// Increment adds delta to the number. The path that does not exist is treated as 0.
+func (p *PatchableValues) Increment(path string, delta float64) {
+	p.addOperation(utils.IncrementOp, path, delta)
+}
+
+// TestAndSet sets the value only if the current value is equal to expected.
+// Nil expected means that the path does not exist. If the current value is different,
+// the whole values patch is not applied and the hook fails.
+func (p *PatchableValues) TestAndSet(path string, expected interface{}, value interface{}) {
+	p.addOperation(utils.TestOp, path, expected)
+	p.Set(path, value)
+}
+
+func (p *PatchableValues) addOperation(op string, path string, value interface{}) {
+	p.patchOperations = append(p.patchOperations, &utils.ValuesPatchOperation{
+		Op:    op,
+		Path:  convertDotFilePathToSlashPath(path),
+		Value: value,
+	})
+}
+
 func (p *PatchableValues) GetPatches() []*utils.ValuesPatchOperation {
 	return p.patchOperations
 }
@@ -6,6 +6,8 @@ import (
 	"path/filepath"
 	"sort"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/flant/shell-operator/pkg/hook"
 	"github.com/flant/shell-operator/pkg/hook/controller"
@@ -14,6 +16,7 @@ import (
 	log "github.com/sirupsen/logrus"
 
 	"github.com/flant/addon-operator/pkg/module_manager/go_hook"
+	"github.com/flant/addon-operator/pkg/module_manager/wasm_hook"
 	"github.com/flant/addon-operator/pkg/utils"
 	"github.com/flant/addon-operator/sdk"
 )
@@ -26,6 +29,7 @@ type Hook interface {
 	GetName() string
 	GetPath() string
 	GetGoHook() go_hook.GoHook
+	GetWasmHook() *wasm_hook.Hook
 	GetValues() (utils.Values, error)
 	GetConfigValues() utils.Values
 	PrepareTmpFilesForHookRun(bindingContext []byte) (map[string]string, error)
@@ -38,6 +42,8 @@ type CommonHook struct {
 	moduleManager *moduleManager
 
 	GoHook go_hook.GoHook
+
+	WasmHook *wasm_hook.Hook
 }
 
 func (c *CommonHook) WithModuleManager(moduleManager *moduleManager) {
@@ -48,6 +54,10 @@ func (c *CommonHook) WithGoHook(h go_hook.GoHook) {
 	c.GoHook = h
 }
 
+func (c *CommonHook) WithWasmHook(h *wasm_hook.Hook) {
+	c.WasmHook = h
+}
+
 func (h *CommonHook) GetName() string {
 	return h.Name
 }
@@ -60,6 +70,10 @@ func (h *CommonHook) GetGoHook() go_hook.GoHook {
 	return h.GoHook
 }
 
+func (h *CommonHook) GetWasmHook() *wasm_hook.Hook {
+	return h.WasmHook
+}
+
 // SynchronizationNeeded is true if there is binding with executeHookOnSynchronization.
 func (h *CommonHook) SynchronizationNeeded() bool {
 	for _, kubeBinding := range h.Config.OnKubernetesEvents {
@@ -86,15 +100,36 @@ func (h *CommonHook) ShouldEnableSchedulesOnStartup() bool {
 	return false
 }
 
-// SearchGlobalHooks recursively find all executables in hooksDir. Absent hooksDir is not an error.
+// RetryPolicy returns a retry policy for Go hooks or nil if policy is not set.
+// GlobalHook and ModuleHook also return a policy from 'settings.retry' of other hooks.
+func (h *CommonHook) RetryPolicy() *utils.RetryPolicy {
+	if h.GoHook == nil {
+		return nil
+	}
+
+	s := h.GoHook.Config().Settings
+	if s == nil {
+		return nil
+	}
+
+	return s.Retry
+}
+
+// SearchGlobalHooks recursively find all executables and *.wasm files in hooksDir. Absent hooksDir is not an error.
 func SearchGlobalHooks(hooksDir string) (hooks []*GlobalHook, err error) {
 	if hooksDir == "" {
 		log.Warnf("Global hooks directory path is empty! No global hooks to load.")
 		return nil, nil
 	}
 
+	hooksDir = globalHooksDir(hooksDir)
+	shellPaths, wasmPaths, err := searchHookPaths(hooksDir)
+	if err != nil {
+		return nil, err
+	}
+
 	hooks = make([]*GlobalHook, 0)
-	shellHooks, err := SearchGlobalShellHooks(hooksDir)
+	shellHooks, err := newGlobalShellHooks(hooksDir, shellPaths)
 	if err != nil {
 		return nil, err
 	}
@@ -106,37 +141,34 @@ func SearchGlobalHooks(hooksDir string) (hooks []*GlobalHook, err error) {
 	}
 	hooks = append(hooks, goHooks...)
 
+	wasmHooks, err := newGlobalWasmHooks(hooksDir, wasmPaths)
+	if err != nil {
+		return nil, err
+	}
+	hooks = append(hooks, wasmHooks...)
+
 	sort.SliceStable(hooks, func(i, j int) bool {
 		return hooks[i].Path < hooks[j].Path
 	})
 
-	log.Debugf("Search global hooks: %d shell, %d golang", len(shellHooks), len(goHooks))
+	log.Debugf("Search global hooks: %d shell, %d golang, %d wasm", len(shellHooks), len(goHooks), len(wasmHooks))
 
 	return hooks, nil
 }
 
-// SearchGlobalHooks recursively find all executables in hooksDir. Absent hooksDir is not an error.
+// SearchGlobalShellHooks recursively find all executables in hooksDir. Absent hooksDir is not an error.
 func SearchGlobalShellHooks(hooksDir string) (hooks []*GlobalHook, err error) {
-	if _, err := os.Stat(hooksDir); os.IsNotExist(err) {
-		return nil, nil
-	}
-
-	hooksSubDir := filepath.Join(hooksDir, "hooks")
-	if _, err := os.Stat(hooksSubDir); !os.IsNotExist(err) {
-		hooksDir = hooksSubDir
-	}
-	hooksRelativePaths, err := utils_file.RecursiveGetExecutablePaths(hooksDir)
+	hooksDir = globalHooksDir(hooksDir)
+	shellPaths, _, err := searchHookPaths(hooksDir)
 	if err != nil {
 		return nil, err
 	}
+	return newGlobalShellHooks(hooksDir, shellPaths)
+}
 
+func newGlobalShellHooks(hooksDir string, hookPaths []string) (hooks []*GlobalHook, err error) {
 	hooks = make([]*GlobalHook, 0)
-
-	// sort hooks by path
-	sort.Strings(hooksRelativePaths)
-	log.Debugf("  Hook paths: %+v", hooksRelativePaths)
-
-	for _, hookPath := range hooksRelativePaths {
+	for _, hookPath := range hookPaths {
 		hookName, err := filepath.Rel(hooksDir, hookPath)
 		if err != nil {
 			return nil, err
@@ -180,10 +212,51 @@ func SearchGlobalGoHooks() (hooks []*GlobalHook, err error) {
 	return hooks, nil
 }
 
+func newGlobalWasmHooks(hooksDir string, hookPaths []string) (hooks []*GlobalHook, err error) {
+	wasmHooks, err := loadWasmHooks(hookPaths)
+	if err != nil {
+		return nil, err
+	}
+
+	hooks = make([]*GlobalHook, 0)
+	for i, hookPath := range hookPaths {
+		hookName, err := filepath.Rel(hooksDir, hookPath)
+		if err != nil {
+			return nil, err
+		}
+
+		globalHook := NewGlobalHook(hookName, hookPath)
+		globalHook.WithWasmHook(wasmHooks[i])
+		hooks = append(hooks, globalHook)
+	}
+
+	count := "no"
+	if len(hooks) > 0 {
+		count = strconv.Itoa(len(hooks))
+	}
+	log.Infof("Found %s global WASM hooks in '%s'", count, hooksDir)
+
+	return hooks, nil
+}
+
+// globalHooksDir returns the 'hooks' subdirectory of hooksDir if it exists.
+func globalHooksDir(hooksDir string) string {
+	hooksSubDir := filepath.Join(hooksDir, "hooks")
+	if _, err := os.Stat(hooksSubDir); !os.IsNotExist(err) {
+		return hooksSubDir
+	}
+	return hooksDir
+}
+
 func SearchModuleHooks(module *Module) (hooks []*ModuleHook, err error) {
+	shellPaths, wasmPaths, err := searchHookPaths(filepath.Join(module.Path, "hooks"))
+	if err != nil {
+		return nil, err
+	}
+
 	hooks = make([]*ModuleHook, 0)
 
-	shellHooks, err := SearchModuleShellHooks(module)
+	shellHooks, err := newModuleShellHooks(module, shellPaths)
 	if err != nil {
 		return nil, err
 	}
@@ -195,6 +268,12 @@ func SearchModuleHooks(module *Module) (hooks []*ModuleHook, err error) {
 	}
 	hooks = append(hooks, goHooks...)
 
+	wasmHooks, err := newModuleWasmHooks(module, wasmPaths)
+	if err != nil {
+		return nil, err
+	}
+	hooks = append(hooks, wasmHooks...)
+
 	sort.SliceStable(hooks, func(i, j int) bool {
 		return hooks[i].Path < hooks[j].Path
 	})
@@ -203,23 +282,16 @@ func SearchModuleHooks(module *Module) (hooks []*ModuleHook, err error) {
 }
 
 func SearchModuleShellHooks(module *Module) (hooks []*ModuleHook, err error) {
-	hooksDir := filepath.Join(module.Path, "hooks")
-	if _, err := os.Stat(hooksDir); os.IsNotExist(err) {
-		return nil, nil
-	}
-
-	hooksRelativePaths, err := utils_file.RecursiveGetExecutablePaths(hooksDir)
+	shellPaths, _, err := searchHookPaths(filepath.Join(module.Path, "hooks"))
 	if err != nil {
 		return nil, err
 	}
+	return newModuleShellHooks(module, shellPaths)
+}
 
+func newModuleShellHooks(module *Module, hookPaths []string) (hooks []*ModuleHook, err error) {
 	hooks = make([]*ModuleHook, 0)
-
-	// sort hooks by path
-	sort.Strings(hooksRelativePaths)
-	log.Debugf("  Hook paths: %+v", hooksRelativePaths)
-
-	for _, hookPath := range hooksRelativePaths {
+	for _, hookPath := range hookPaths {
 		hookName, err := filepath.Rel(filepath.Dir(module.Path), hookPath)
 		if err != nil {
 			return nil, err
@@ -257,16 +329,85 @@ func SearchModuleGoHooks(module *Module) (hooks []*ModuleHook, err error) {
 	return hooks, nil
 }
 
+func newModuleWasmHooks(module *Module, hookPaths []string) (hooks []*ModuleHook, err error) {
+	wasmHooks, err := loadWasmHooks(hookPaths)
+	if err != nil {
+		return nil, err
+	}
+
+	hooks = make([]*ModuleHook, 0)
+	for i, hookPath := range hookPaths {
+		hookName, err := filepath.Rel(filepath.Dir(module.Path), hookPath)
+		if err != nil {
+			return nil, err
+		}
+
+		moduleHook := NewModuleHook(hookName, hookPath)
+		moduleHook.WithModule(module)
+		moduleHook.WithWasmHook(wasmHooks[i])
+
+		hooks = append(hooks, moduleHook)
+	}
+
+	return hooks, nil
+}
+
+// searchHookPaths walks the dir once and returns sorted paths of shell hooks and *.wasm hooks.
+// Hidden directories and files are ignored, static config files are not hooks.
+// Shell hooks should be executable, *.wasm files are never shell hooks. Absent dir is not an error.
+func searchHookPaths(dir string) (shellPaths []string, wasmPaths []string, err error) {
+	if _, err := os.Stat(dir); os.IsNotExist(err) {
+		return nil, nil, nil
+	}
+
+	shellPaths = make([]string, 0)
+	wasmPaths = make([]string, 0)
+	err = filepath.Walk(dir, func(path string, f os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if strings.HasPrefix(f.Name(), ".") {
+			if f.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if f.IsDir() || IsHookConfigFile(path) {
+			return nil
+		}
+		if filepath.Ext(path) == wasm_hook.FileExt {
+			wasmPaths = append(wasmPaths, path)
+			return nil
+		}
+		if !utils_file.IsFileExecutable(f) {
+			log.Warnf("File '%s' is skipped: no executable permissions, chmod +x is required to run this hook", path)
+			return nil
+		}
+		shellPaths = append(shellPaths, path)
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	sort.Strings(shellPaths)
+	sort.Strings(wasmPaths)
+	log.Debugf("  Hook paths: %+v, WASM hook paths: %+v", shellPaths, wasmPaths)
+	return shellPaths, wasmPaths, nil
+}
+
 func (mm *moduleManager) RegisterGlobalHooks() error {
 	log.Debug("Search and register global hooks")
 
 	mm.globalHooksOrder = make(map[sh_op_types.BindingType][]*GlobalHook)
 	mm.globalHooksByName = make(map[string]*GlobalHook)
 
+	searchStart := time.Now()
 	hooks, err := SearchGlobalHooks(mm.GlobalHooksDir)
 	if err != nil {
 		return err
 	}
+	mm.observeHooksLoad("", "search", searchStart)
 	if len(hooks) > 0 {
 		log.Debugf("Found %d global hooks:", len(hooks))
 		for _, h := range hooks {
@@ -276,7 +417,16 @@ func (mm *moduleManager) RegisterGlobalHooks() error {
 		log.Debugf("Found no global hooks in %s", mm.GlobalHooksDir)
 	}
 
+	configStart := time.Now()
+	configHooks := make([]Hook, 0, len(hooks))
 	for _, globalHook := range hooks {
+		configHooks = append(configHooks, globalHook)
+	}
+	configs, _, configErrs := mm.hookConfigs(configHooks)
+	mm.observeHooksLoad("", "config", configStart)
+
+	registerStart := time.Now()
+	for i, globalHook := range hooks {
 		logEntry := log.WithField("hook", globalHook.Name).
 			WithField("hook.type", "global")
 
@@ -286,9 +436,7 @@ func (mm *moduleManager) RegisterGlobalHooks() error {
 		if globalHook.GoHook != nil {
 			goConfig = globalHook.GoHook.Config()
 		} else {
-			hookExecutor := NewHookExecutor(globalHook, nil, "", nil)
-			hookExecutor.WithHelm(mm.helm)
-			yamlConfigBytes, err = hookExecutor.Config()
+			yamlConfigBytes, err = configs[i], configErrs[i]
 			if err != nil {
 				logEntry.Errorf("Run --config: %s", err)
 				return fmt.Errorf("global hook --config run problem")
@@ -349,10 +497,11 @@ func (mm *moduleManager) RegisterGlobalHooks() error {
 				"module": "", // empty "module" label for label set consistency with module hooks
 			})
 	}
+	mm.observeHooksLoad("", "register", registerStart)
 
 	// Load validation schemas
 	openApiDir := filepath.Join(mm.GlobalHooksDir, "openapi")
-	configBytes, valuesBytes, err := ReadOpenAPIFiles(openApiDir)
+	configBytes, valuesBytes, err := mm.readOpenAPISchemas(openApiDir)
 	if err != nil {
 		return fmt.Errorf("read global openAPI schemas: %v", err)
 	}
@@ -378,17 +527,41 @@ func (mm *moduleManager) RegisterModuleHooks(module *Module, logLabels map[strin
 
 	registeredModuleHooks := make(map[sh_op_types.BindingType][]*ModuleHook)
 
-	hooks, err := SearchModuleHooks(module)
-	if err != nil {
-		logEntry.Errorf("Search module hooks: %s", err)
-		return err
+	var hooks []*ModuleHook
+	var configs [][]byte
+	var configErrs []error
+	var err error
+	if preloaded := mm.takePreloadedModuleHooks(module.Name); preloaded != nil {
+		// Hooks are found and run with '--config' in Init.
+		if preloaded.searchErr != nil {
+			logEntry.Errorf("Search module hooks: %s", preloaded.searchErr)
+			return preloaded.searchErr
+		}
+		hooks, configs, configErrs = preloaded.hooks, preloaded.configs, preloaded.configErrs
+	} else {
+		searchStart := time.Now()
+		hooks, err = SearchModuleHooks(module)
+		if err != nil {
+			logEntry.Errorf("Search module hooks: %s", err)
+			return err
+		}
+		mm.observeHooksLoad(module.Name, "search", searchStart)
+
+		configStart := time.Now()
+		configHooks := make([]Hook, 0, len(hooks))
+		for _, moduleHook := range hooks {
+			configHooks = append(configHooks, moduleHook)
+		}
+		configs, _, configErrs = mm.hookConfigs(configHooks)
+		mm.observeHooksLoad(module.Name, "config", configStart)
 	}
 	logEntry.Debugf("Found %d hooks", len(hooks))
 	for _, h := range hooks {
 		logEntry.Debugf("  ModuleHook: Name=%s, Path=%s", h.Name, h.Path)
 	}
 
-	for _, moduleHook := range hooks {
+	registerStart := time.Now()
+	for i, moduleHook := range hooks {
 		hookLogEntry := logEntry.WithField("hook", moduleHook.Name).
 			WithField("hook.type", "module")
 
@@ -398,9 +571,7 @@ func (mm *moduleManager) RegisterModuleHooks(module *Module, logLabels map[strin
 		if moduleHook.GoHook != nil {
 			goConfig = moduleHook.GoHook.Config()
 		} else {
-			hookExecutor := NewHookExecutor(moduleHook, nil, "", nil)
-			hookExecutor.WithHelm(mm.helm)
-			yamlConfigBytes, err = hookExecutor.Config()
+			yamlConfigBytes, err = configs[i], configErrs[i]
 			if err != nil {
 				hookLogEntry.Errorf("Run --config: %s", err)
 				return fmt.Errorf("module hook --config run problem")
@@ -461,6 +632,7 @@ func (mm *moduleManager) RegisterModuleHooks(module *Module, logLabels map[strin
 				"hook":   moduleHook.Name,
 			})
 	}
+	mm.observeHooksLoad(module.Name, "register", registerStart)
 
 	// Save registered hooks in mm.modulesHooksOrderByName
 	if mm.modulesHooksOrderByName[module.Name] == nil {
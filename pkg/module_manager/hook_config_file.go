@@ -0,0 +1,34 @@
+package module_manager
+
+import (
+	"os"
+	"strings"
+)
+
+// HookConfigFileSuffix is a suffix of the static hook config file.
+const HookConfigFileSuffix = ".config.yaml"
+
+// IsHookConfigFile returns true if path is a static hook config file, not a hook.
+func IsHookConfigFile(path string) bool {
+	return strings.HasSuffix(path, HookConfigFileSuffix)
+}
+
+// HookConfigFilePath returns a path of the static config file for the hook: 'hook.sh.config.yaml' for 'hook.sh'.
+// The full hook name is used, so hooks with the same name and different extensions have separate files.
+func HookConfigFilePath(hookPath string) string {
+	return hookPath + HookConfigFileSuffix
+}
+
+// readHookConfigFile returns the content of the static config file for the hook and its path.
+// Empty path is returned if there is no such file, so the hook should be executed with '--config'.
+func readHookConfigFile(hookPath string) ([]byte, string, error) {
+	path := HookConfigFilePath(hookPath)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, "", nil
+		}
+		return nil, "", err
+	}
+	return data, path, nil
+}
@@ -0,0 +1,182 @@
+package module_manager
+
+import (
+	"sync"
+	"time"
+
+	"github.com/flant/addon-operator/pkg/app"
+	"github.com/flant/addon-operator/pkg/module_manager/wasm_hook"
+)
+
+// runParallel calls fn for indexes from 0 to n-1 in at most workers goroutines.
+// Errors are returned in the index order, so callers do not depend on the scheduling.
+func runParallel(workers int, n int, fn func(i int) error) []error {
+	errs := make([]error, n)
+	if workers < 1 {
+		workers = 1
+	}
+	if workers > n {
+		workers = n
+	}
+
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				errs[i] = fn(i)
+			}
+		}()
+	}
+	for i := 0; i < n; i++ {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+
+	return errs
+}
+
+// hookConfigs runs hooks with '--config' in parallel. Results are in the order of hooks.
+// Go hooks have nil config, their config is returned by GoHook.Config.
+// Durations are measured for each hook.
+func (mm *moduleManager) hookConfigs(hooks []Hook) ([][]byte, []time.Duration, []error) {
+	configs := make([][]byte, len(hooks))
+	durations := make([]time.Duration, len(hooks))
+	errs := runParallel(app.HookConfigWorkers, len(hooks), func(i int) error {
+		if hooks[i].GetGoHook() != nil {
+			return nil
+		}
+		start := time.Now()
+		hookExecutor := NewHookExecutor(hooks[i], nil, "", nil)
+		hookExecutor.WithHelm(mm.helm)
+		config, err := hookExecutor.Config()
+		configs[i] = config
+		durations[i] = time.Since(start)
+		return err
+	})
+	return configs, durations, errs
+}
+
+// preloadedModuleHooks are hooks of the module found and run with '--config' on startup.
+type preloadedModuleHooks struct {
+	hooks      []*ModuleHook
+	searchErr  error
+	configs    [][]byte
+	configErrs []error
+}
+
+// PreloadModuleHooks searches hooks of modules enabled by config and runs them with '--config'
+// in one pool of app.HookConfigWorkers, so startup does not wait for modules one by one.
+// Hooks of disabled modules are not run. Results are in the order of modules and hooks.
+// RegisterModuleHooks uses them once and searches hooks again for later registrations.
+func (mm *moduleManager) PreloadModuleHooks() {
+	modules := make([]*Module, 0)
+	mm.valuesLayersLock.RLock()
+	for _, module := range mm.modules.List() {
+		if _, enabled := mm.enabledModulesByConfig[module.Name]; enabled {
+			modules = append(modules, module)
+		}
+	}
+	mm.valuesLayersLock.RUnlock()
+
+	searchStart := time.Now()
+	moduleHooks := make([][]*ModuleHook, len(modules))
+	searchErrs := runParallel(app.HookConfigWorkers, len(modules), func(i int) error {
+		start := time.Now()
+		hooks, err := SearchModuleHooks(modules[i])
+		moduleHooks[i] = hooks
+		if err == nil {
+			mm.observeHooksLoad(modules[i].Name, "search", start)
+		}
+		return err
+	})
+	mm.observeStartup("module_hooks_search", searchStart)
+
+	configStart := time.Now()
+	configHooks := make([]Hook, 0)
+	for _, hooks := range moduleHooks {
+		for _, moduleHook := range hooks {
+			configHooks = append(configHooks, moduleHook)
+		}
+	}
+	configs, durations, configErrs := mm.hookConfigs(configHooks)
+	mm.observeStartup("module_hooks_config", configStart)
+
+	preloaded := make(map[string]*preloadedModuleHooks, len(modules))
+	offset := 0
+	for i, module := range modules {
+		hooks := moduleHooks[i]
+		preloaded[module.Name] = &preloadedModuleHooks{
+			hooks:      hooks,
+			searchErr:  searchErrs[i],
+			configs:    configs[offset : offset+len(hooks)],
+			configErrs: configErrs[offset : offset+len(hooks)],
+		}
+		if searchErrs[i] == nil {
+			var configDuration time.Duration
+			for _, d := range durations[offset : offset+len(hooks)] {
+				configDuration += d
+			}
+			mm.metricStorage.GaugeSet("{PREFIX}hooks_load_seconds", configDuration.Seconds(), map[string]string{
+				"module": module.Name,
+				"phase":  "config",
+			})
+		}
+		offset += len(hooks)
+	}
+
+	mm.preloadedModuleHooksLock.Lock()
+	mm.preloadedModuleHooks = preloaded
+	mm.preloadedModuleHooksLock.Unlock()
+}
+
+// DropPreloadedModuleHooks forgets hooks preloaded for modules that are not registered
+// during the startup converge, e.g. modules disabled by 'enabled' scripts.
+func (mm *moduleManager) DropPreloadedModuleHooks() {
+	mm.preloadedModuleHooksLock.Lock()
+	mm.preloadedModuleHooks = nil
+	mm.preloadedModuleHooksLock.Unlock()
+}
+
+// takePreloadedModuleHooks returns hooks preloaded for the module and forgets them.
+func (mm *moduleManager) takePreloadedModuleHooks(moduleName string) *preloadedModuleHooks {
+	mm.preloadedModuleHooksLock.Lock()
+	defer mm.preloadedModuleHooksLock.Unlock()
+	preloaded := mm.preloadedModuleHooks[moduleName]
+	delete(mm.preloadedModuleHooks, moduleName)
+	return preloaded
+}
+
+// loadWasmHooks compiles WASM hooks in parallel. Results are in the order of paths.
+func loadWasmHooks(paths []string) ([]*wasm_hook.Hook, error) {
+	hooks := make([]*wasm_hook.Hook, len(paths))
+	errs := runParallel(app.HookConfigWorkers, len(paths), func(i int) error {
+		var err error
+		hooks[i], err = wasm_hook.Load(paths[i])
+		return err
+	})
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+	return hooks, nil
+}
+
+// observeHooksLoad sets a duration of the hooks registration phase. Module is empty for global hooks.
+func (mm *moduleManager) observeHooksLoad(moduleName string, phase string, start time.Time) {
+	mm.metricStorage.GaugeSet("{PREFIX}hooks_load_seconds", time.Since(start).Seconds(), map[string]string{
+		"module": moduleName,
+		"phase":  phase,
+	})
+}
+
+// observeStartup sets a duration of the module manager initialization phase.
+func (mm *moduleManager) observeStartup(phase string, start time.Time) {
+	mm.metricStorage.GaugeSet("{PREFIX}startup_seconds", time.Since(start).Seconds(), map[string]string{
+		"phase": phase,
+	})
+}
@@ -0,0 +1,68 @@
+package module_manager
+
+import (
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+)
+
+func Test_runParallel(t *testing.T) {
+	g := NewWithT(t)
+
+	var running, maxRunning int32
+	errs := runParallel(3, 10, func(i int) error {
+		n := atomic.AddInt32(&running, 1)
+		for {
+			m := atomic.LoadInt32(&maxRunning)
+			if n <= m || atomic.CompareAndSwapInt32(&maxRunning, m, n) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+		atomic.AddInt32(&running, -1)
+		if i%4 == 1 {
+			return fmt.Errorf("error %d", i)
+		}
+		return nil
+	})
+
+	g.Expect(maxRunning).To(BeNumerically("<=", 3))
+	g.Expect(errs).To(HaveLen(10))
+	for i, err := range errs {
+		if i%4 == 1 {
+			g.Expect(err).To(MatchError(fmt.Sprintf("error %d", i)))
+		} else {
+			g.Expect(err).ShouldNot(HaveOccurred())
+		}
+	}
+
+	g.Expect(runParallel(3, 0, func(i int) error { return nil })).To(BeEmpty())
+}
+
+func Test_PreloadModuleHooks(t *testing.T) {
+	g := NewWithT(t)
+
+	_, res := initModuleManager(t, "preload_module_hooks")
+	mm := res.moduleManager
+
+	// Hooks are preloaded only for modules enabled by config.
+	mm.PreloadModuleHooks()
+	g.Expect(mm.preloadedModuleHooks).To(HaveLen(1))
+	g.Expect(mm.preloadedModuleHooks).To(HaveKey("module-one"))
+	preloaded := mm.preloadedModuleHooks["module-one"]
+	g.Expect(preloaded.searchErr).ShouldNot(HaveOccurred())
+	g.Expect(preloaded.hooks).To(HaveLen(1))
+	g.Expect(preloaded.configErrs).To(Equal([]error{nil}))
+	g.Expect(string(preloaded.configs[0])).To(ContainSubstring("beforeHelm: 1"))
+
+	// Preloaded hooks are used once.
+	g.Expect(mm.takePreloadedModuleHooks("module-one")).To(Equal(preloaded))
+	g.Expect(mm.takePreloadedModuleHooks("module-one")).To(BeNil())
+
+	mm.PreloadModuleHooks()
+	mm.DropPreloadedModuleHooks()
+	g.Expect(mm.takePreloadedModuleHooks("module-one")).To(BeNil())
+}
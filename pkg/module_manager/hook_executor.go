@@ -1,12 +1,16 @@
 package module_manager
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/flant/shell-operator/pkg/kube/object_patch"
 	log "github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/api/resource"
 
 	sh_app "github.com/flant/shell-operator/pkg/app"
 	"github.com/flant/shell-operator/pkg/executor"
@@ -16,6 +20,7 @@ import (
 	"github.com/flant/addon-operator/pkg/helm"
 	"github.com/flant/addon-operator/pkg/module_manager/go_hook"
 	"github.com/flant/addon-operator/pkg/module_manager/go_hook/metrics"
+	"github.com/flant/addon-operator/pkg/module_manager/wasm_hook"
 	"github.com/flant/addon-operator/pkg/utils"
 )
 
@@ -33,6 +38,10 @@ type HookExecutor struct {
 	KubernetesPatchPath   string
 	LogLabels             map[string]string
 	Helm                  *helm.ClientFactory
+	Timeout               time.Duration
+	Limits                HookLimits
+	// Ctx is a parent context for hooks. Shell hooks are killed when it is canceled.
+	Ctx context.Context
 }
 
 func NewHookExecutor(h Hook, context []BindingContext, configVersion string, objectPatcher *object_patch.ObjectPatcher) *HookExecutor {
@@ -53,6 +62,21 @@ func (e *HookExecutor) WithHelm(helm *helm.ClientFactory) {
 	e.Helm = helm
 }
 
+// WithContext sets a parent context for hooks. Processes of shell hooks are killed when it is canceled.
+func (e *HookExecutor) WithContext(ctx context.Context) {
+	e.Ctx = ctx
+}
+
+// WithTimeout sets a timeout for the hook process or a deadline for the Go hook context. Zero means no timeout.
+func (e *HookExecutor) WithTimeout(timeout time.Duration) {
+	e.Timeout = timeout
+}
+
+// WithLimits sets resource limits and allowed environment variables for the hook process.
+func (e *HookExecutor) WithLimits(limits HookLimits) {
+	e.Limits = limits
+}
+
 type HookResult struct {
 	Usage                   *executor.CmdUsage
 	Patches                 map[utils.ValuesPatchType]*utils.ValuesPatch
@@ -65,6 +89,9 @@ func (e *HookExecutor) Run() (result *HookResult, err error) {
 	if e.Hook.GetGoHook() != nil {
 		return e.RunGoHook()
 	}
+	if e.Hook.GetWasmHook() != nil {
+		return e.RunWasmHook()
+	}
 
 	result = &HookResult{
 		Patches: make(map[utils.ValuesPatchType]*utils.ValuesPatch),
@@ -99,17 +126,18 @@ func (e *HookExecutor) Run() (result *HookResult, err error) {
 	e.KubernetesPatchPath = tmpFiles["KUBERNETES_PATCH_PATH"]
 
 	envs := make([]string, 0)
-	envs = append(envs, os.Environ()...)
+	envs = append(envs, filterEnv(os.Environ(), e.Limits.AllowedEnv)...)
 	for envName, filePath := range tmpFiles {
 		envs = append(envs, fmt.Sprintf("%s=%s", envName, filePath))
 	}
 
 	cmd := executor.MakeCommand("", e.Hook.GetPath(), []string{}, envs)
+	applyHookLimits(cmd, e.Limits)
 
-	usage, err := executor.RunAndLogLines(cmd, e.LogLabels)
+	usage, err := runAndLogLines(e.Ctx, cmd, e.Timeout, e.LogLabels)
 	result.Usage = usage
 	if err != nil {
-		return result, err
+		return result, hookLimitError(e.Limits, usage, err)
 	}
 
 	result.Patches[utils.ConfigMapPatch], err = utils.ValuesPatchFromFile(e.ConfigValuesPatchPath)
@@ -180,7 +208,18 @@ func (e *HookExecutor) RunGoHook() (result *HookResult, err error) {
 	metricsCollector := metrics.NewCollector(e.Hook.GetName())
 	patchCollector := object_patch.NewPatchCollector()
 
+	ctx := e.Ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if e.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, e.Timeout)
+		defer cancel()
+	}
+
 	err = goHook.Run(&go_hook.HookInput{
+		Context:          ctx,
 		Snapshots:        formattedSnapshots,
 		Values:           patchableValues,
 		ConfigValues:     patchableConfigValues,
@@ -190,7 +229,7 @@ func (e *HookExecutor) RunGoHook() (result *HookResult, err error) {
 		BindingActions:   bindingActions,
 	})
 	if err != nil {
-		return nil, err
+		return nil, goHookContextError(ctx, e.Timeout, err)
 	}
 
 	result = &HookResult{
@@ -206,12 +245,112 @@ func (e *HookExecutor) RunGoHook() (result *HookResult, err error) {
 	return result, nil
 }
 
+// RunWasmHook runs the WASM hook in-process. Timeout and CPU time limit are deadlines
+// for the hook run, memory limit is a limit for the hook linear memory.
+func (e *HookExecutor) RunWasmHook() (result *HookResult, err error) {
+	wasmHook := e.Hook.GetWasmHook()
+	if wasmHook == nil {
+		return
+	}
+
+	values, err := e.Hook.GetValues()
+	if err != nil {
+		return nil, err
+	}
+
+	patchableValues, err := go_hook.NewPatchableValues(values)
+	if err != nil {
+		return nil, err
+	}
+
+	patchableConfigValues, err := go_hook.NewPatchableValues(e.Hook.GetConfigValues())
+	if err != nil {
+		return nil, err
+	}
+
+	bindingContextBytes, err := ConvertBindingContextList(e.ConfigVersion, e.Context).Json()
+	if err != nil {
+		return nil, err
+	}
+
+	ctx := e.Ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if e.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, e.Timeout)
+		defer cancel()
+	}
+	runCtx := ctx
+	if e.Limits.CPUTime > 0 {
+		var cancel context.CancelFunc
+		runCtx, cancel = context.WithTimeout(ctx, e.Limits.CPUTime)
+		defer cancel()
+	}
+
+	output, err := wasmHook.Run(runCtx, &wasm_hook.HookInput{
+		HookName:       e.Hook.GetName(),
+		BindingContext: bindingContextBytes,
+		Values:         patchableValues,
+		ConfigValues:   patchableConfigValues,
+		LogEntry:       log.WithFields(utils.LabelsToLogFields(e.LogLabels)).WithField("output", "wasm"),
+		MemoryLimit:    e.Limits.Memory,
+	})
+	if err != nil {
+		switch {
+		case ctx.Err() != nil:
+			return nil, goHookContextError(ctx, e.Timeout, err)
+		case runCtx.Err() == context.DeadlineExceeded:
+			return nil, &HookLimitExceededError{Limit: HookLimitCPUTime, Value: e.Limits.CPUTime.String(), Err: err}
+		case errors.Is(err, wasm_hook.ErrMemoryLimitExceeded):
+			memoryLimit := e.Limits.Memory
+			if memoryLimit <= 0 {
+				memoryLimit = wasm_hook.DefaultMemoryLimit
+			}
+			return nil, &HookLimitExceededError{Limit: HookLimitMemory, Value: resource.NewQuantity(memoryLimit, resource.BinarySI).String(), Err: err}
+		}
+		return nil, err
+	}
+
+	result = &HookResult{
+		Patches: map[utils.ValuesPatchType]*utils.ValuesPatch{
+			utils.MemoryValuesPatch: {Operations: patchableValues.GetPatches()},
+			utils.ConfigMapPatch:    {Operations: patchableConfigValues.GetPatches()},
+		},
+		Metrics:                 output.Metrics,
+		ObjectPatcherOperations: output.ObjectPatcherOperations,
+	}
+
+	return result, nil
+}
+
 func (e *HookExecutor) Config() (configOutput []byte, err error) {
 	// Config() is called directly for go hooks
 	if e.Hook.GetGoHook() != nil {
 		return nil, nil
 	}
 
+	// A static config file is used instead of the hook execution.
+	configFileData, configFilePath, err := readHookConfigFile(e.Hook.GetPath())
+	if err != nil {
+		return nil, fmt.Errorf("read config file for hook '%s': %v", e.Hook.GetName(), err)
+	}
+	if configFilePath != "" {
+		log.Debugf("Hook '%s' config from '%s':\n%s", e.Hook.GetName(), configFilePath, string(configFileData))
+		return configFileData, nil
+	}
+
+	if wasmHook := e.Hook.GetWasmHook(); wasmHook != nil {
+		output, err := wasmHook.Config(context.Background())
+		if err != nil {
+			log.Debugf("Hook '%s' config failed: %v", e.Hook.GetName(), err)
+			return nil, err
+		}
+		log.Debugf("Hook '%s' config output:\n%s", e.Hook.GetName(), string(output))
+		return output, nil
+	}
+
 	envs := make([]string, 0)
 	envs = append(envs, os.Environ()...)
 
@@ -1,13 +1,20 @@
 package module_manager
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/flant/addon-operator/pkg/module_manager/go_hook"
 	"github.com/flant/addon-operator/sdk"
 	. "github.com/onsi/gomega"
 
 	. "github.com/flant/shell-operator/pkg/hook/binding_context"
+	sh_op_types "github.com/flant/shell-operator/pkg/hook/types"
 
 	_ "github.com/flant/addon-operator/pkg/module_manager/test/go_hooks/global-hooks"
 )
@@ -44,3 +51,130 @@ func Test_Config_GoHook(t *testing.T) {
 	g.Expect(res.Patches).ShouldNot(BeEmpty())
 	g.Expect(res.Metrics).ShouldNot(BeEmpty())
 }
+
+// waitContextGoHook returns only when its context is done.
+type waitContextGoHook struct {
+	config *go_hook.HookConfig
+}
+
+func (h *waitContextGoHook) Config() *go_hook.HookConfig {
+	return h.config
+}
+
+func (h *waitContextGoHook) Run(input *go_hook.HookInput) error {
+	<-input.Context.Done()
+	return input.Context.Err()
+}
+
+func Test_GoHook_Context(t *testing.T) {
+	g := NewWithT(t)
+
+	moduleManager := NewModuleManager()
+
+	goHook := &waitContextGoHook{
+		config: &go_hook.HookConfig{
+			OnStartup: &go_hook.OrderedConfig{Order: 1},
+			Settings:  &go_hook.HookConfigSettings{Timeout: 100 * time.Millisecond},
+		},
+	}
+	gh := NewGlobalHook("wait.go", "/global-hooks/wait.go")
+	gh.WithGoHook(goHook)
+	err := gh.WithGoConfig(goHook.Config())
+	g.Expect(err).ShouldNot(HaveOccurred())
+	gh.WithModuleManager(moduleManager)
+	g.Expect(gh.Config.Timeout).To(Equal(100 * time.Millisecond))
+
+	// Deadline is exceeded.
+	e := NewHookExecutor(gh, []BindingContext{}, "v1", nil)
+	e.WithTimeout(gh.Config.Timeout)
+	_, err = e.Run()
+	g.Expect(err).Should(HaveOccurred())
+	var timeoutErr *HookTimeoutError
+	g.Expect(errors.As(err, &timeoutErr)).To(BeTrue())
+	g.Expect(IsHookCanceled(moduleManager.Context(), err)).To(BeFalse())
+
+	// Parent context is canceled, e.g. on shutdown.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	e = NewHookExecutor(gh, []BindingContext{}, "v1", nil)
+	e.WithContext(ctx)
+	_, err = e.Run()
+	g.Expect(err).Should(HaveOccurred())
+	g.Expect(IsHookCanceled(ctx, err)).To(BeTrue())
+}
+
+type canceledRequestGoHook struct {
+	config *go_hook.HookConfig
+}
+
+func (h *canceledRequestGoHook) Config() *go_hook.HookConfig {
+	return h.config
+}
+
+func (h *canceledRequestGoHook) Run(_ *go_hook.HookInput) error {
+	return fmt.Errorf("list objects: %w", context.Canceled)
+}
+
+func Test_GoHook_Canceled_by_hook(t *testing.T) {
+	g := NewWithT(t)
+
+	moduleManager := NewModuleManager()
+
+	goHook := &canceledRequestGoHook{
+		config: &go_hook.HookConfig{OnStartup: &go_hook.OrderedConfig{Order: 1}},
+	}
+	gh := NewGlobalHook("canceled.go", "/global-hooks/canceled.go")
+	gh.WithGoHook(goHook)
+	err := gh.WithGoConfig(goHook.Config())
+	g.Expect(err).ShouldNot(HaveOccurred())
+	gh.WithModuleManager(moduleManager)
+
+	// Hook returns context.Canceled from its own request, the operator is running.
+	e := NewHookExecutor(gh, []BindingContext{}, "v1", nil)
+	e.WithContext(moduleManager.Context())
+	_, err = e.Run()
+	g.Expect(errors.Is(err, context.Canceled)).To(BeTrue())
+	g.Expect(IsHookCanceled(moduleManager.Context(), err)).To(BeFalse(), "hook error should be treated as a failure")
+}
+
+func Test_Config_StaticFile(t *testing.T) {
+	g := NewWithT(t)
+
+	dir := t.TempDir()
+	hookPath := filepath.Join(dir, "hook.sh")
+	g.Expect(os.WriteFile(hookPath, []byte("#!/bin/sh\necho '{\"configVersion\":\"v1\",\"onStartup\":1}'\n"), 0o755)).To(Succeed())
+
+	gh := NewGlobalHook("hook.sh", hookPath)
+
+	// No config file: the hook is executed with --config.
+	configOutput, err := NewHookExecutor(gh, nil, "", nil).Config()
+	g.Expect(err).ShouldNot(HaveOccurred())
+	g.Expect(string(configOutput)).To(ContainSubstring(`"onStartup":1`))
+
+	// The config file without the hook extension may belong to another hook, e.g. 'hook.py'.
+	g.Expect(os.WriteFile(filepath.Join(dir, "hook.config.yaml"), []byte("configVersion: v1\nonStartup: 10\n"), 0o644)).To(Succeed())
+	configOutput, err = NewHookExecutor(gh, nil, "", nil).Config()
+	g.Expect(err).ShouldNot(HaveOccurred())
+	g.Expect(string(configOutput)).To(ContainSubstring(`"onStartup":1`))
+
+	g.Expect(os.WriteFile(filepath.Join(dir, "hook.sh.config.yaml"), []byte("configVersion: v1\nonStartup: 20\n"), 0o644)).To(Succeed())
+	configOutput, err = NewHookExecutor(gh, nil, "", nil).Config()
+	g.Expect(err).ShouldNot(HaveOccurred())
+	g.Expect(gh.WithConfig(configOutput)).To(Succeed())
+	g.Expect(gh.Order(sh_op_types.OnStartup)).To(Equal(20.0))
+}
+
+func Test_Config_Env(t *testing.T) {
+	g := NewWithT(t)
+
+	t.Setenv("HOOK_CONFIG_TEST_TOKEN", "secret")
+
+	dir := t.TempDir()
+	hookPath := filepath.Join(dir, "hook.sh")
+	g.Expect(os.WriteFile(hookPath, []byte("#!/bin/sh\necho \"{\\\"token\\\":\\\"${HOOK_CONFIG_TEST_TOKEN}\\\",\\\"path\\\":\\\"${PATH}\\\"}\"\n"), 0o755)).To(Succeed())
+
+	configOutput, err := NewHookExecutor(NewGlobalHook("hook.sh", hookPath), nil, "", nil).Config()
+	g.Expect(err).ShouldNot(HaveOccurred())
+	g.Expect(string(configOutput)).To(ContainSubstring(`"token":"secret"`))
+	g.Expect(string(configOutput)).To(ContainSubstring(`"path":"` + os.Getenv("PATH") + `"`))
+}
@@ -0,0 +1,196 @@
+package module_manager
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/flant/shell-operator/pkg/executor"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// Limit names for the hook_limit_exceeded_total metric.
+const (
+	HookLimitCPUTime = "cpu"
+	HookLimitMemory  = "memory"
+)
+
+// HookLimits are resource limits for the shell hook process from the 'settings' section.
+type HookLimits struct {
+	// CPUTime is a limit of CPU time for the hook process (RLIMIT_CPU).
+	CPUTime time.Duration
+	// Memory is a limit of the hook process address space in bytes (RLIMIT_AS).
+	Memory int64
+	// AllowedEnv is a list of environment variables passed from the operator environment.
+	// Nil means that all variables are passed.
+	AllowedEnv []string
+}
+
+// ConvertHookLimits returns resource limits from hook settings.
+func ConvertHookLimits(settings *HookSettings) (HookLimits, error) {
+	limits := HookLimits{}
+	if settings == nil {
+		return limits, nil
+	}
+
+	if settings.CPUTime != "" {
+		cpuTime, err := time.ParseDuration(settings.CPUTime)
+		if err != nil {
+			return limits, fmt.Errorf("settings.cpuTime is invalid: %v", err)
+		}
+		if cpuTime <= 0 {
+			return limits, fmt.Errorf("settings.cpuTime should be positive, got %s", settings.CPUTime)
+		}
+		limits.CPUTime = cpuTime
+	}
+
+	if settings.Memory != "" {
+		memory, err := resource.ParseQuantity(settings.Memory)
+		if err != nil {
+			return limits, fmt.Errorf("settings.memory is invalid: %v", err)
+		}
+		if memory.Value() <= 0 {
+			return limits, fmt.Errorf("settings.memory should be positive, got %s", settings.Memory)
+		}
+		limits.Memory = memory.Value()
+	}
+
+	limits.AllowedEnv = settings.AllowedEnv
+	return limits, nil
+}
+
+// HookLimitExceededError is returned when the shell hook process is failed because of the resource limit.
+type HookLimitExceededError struct {
+	Limit string
+	Value string
+	Err   error
+}
+
+func (e *HookLimitExceededError) Error() string {
+	return fmt.Sprintf("%s limit %s exceeded: %v", e.Limit, e.Value, e.Err)
+}
+
+func (e *HookLimitExceededError) Unwrap() error {
+	return e.Err
+}
+
+// filterEnv returns variables from environ with names from allowed list. PATH is always passed.
+// All variables are returned if allowed is nil.
+func filterEnv(environ []string, allowed []string) []string {
+	if allowed == nil {
+		return environ
+	}
+
+	allowedNames := map[string]struct{}{"PATH": {}}
+	for _, name := range allowed {
+		allowedNames[name] = struct{}{}
+	}
+
+	res := make([]string, 0)
+	for _, env := range environ {
+		name := strings.SplitN(env, "=", 2)[0]
+		if _, has := allowedNames[name]; has {
+			res = append(res, env)
+		}
+	}
+	return res
+}
+
+// limitsShell is a shell to set rlimits for the hook process.
+const limitsShell = "/bin/sh"
+
+// CheckLimitsShell returns an error if the shell to apply hook limits is missing.
+// Hooks with limits fail to start without the shell.
+func CheckLimitsShell() error {
+	info, err := os.Stat(limitsShell)
+	if err != nil {
+		return err
+	}
+	if info.IsDir() || info.Mode()&0o111 == 0 {
+		return fmt.Errorf("%s is not executable", limitsShell)
+	}
+	return nil
+}
+
+// applyHookLimits sets rlimits for the hook process. The hook is started via 'sh -c' with 'ulimit'
+// commands, so limits are applied before the hook is executed.
+func applyHookLimits(cmd *exec.Cmd, limits HookLimits) {
+	ulimits := make([]string, 0)
+	if limits.CPUTime > 0 {
+		// RLIMIT_CPU is in seconds, round up to not kill the hook earlier.
+		// SIGXCPU is sent on the soft limit, SIGKILL is sent on the hard limit
+		// if the hook ignores SIGXCPU.
+		seconds := int64((limits.CPUTime + time.Second - 1) / time.Second)
+		ulimits = append(ulimits, fmt.Sprintf("ulimit -S -t %d", seconds), fmt.Sprintf("ulimit -H -t %d", seconds+1))
+	}
+	if limits.Memory > 0 {
+		// RLIMIT_AS is in kilobytes for ulimit. It limits the virtual address space, not RSS.
+		ulimits = append(ulimits, fmt.Sprintf("ulimit -v %d", (limits.Memory+1023)/1024))
+	}
+	if len(ulimits) == 0 {
+		return
+	}
+
+	script := strings.Join(append(ulimits, `exec "$0" "$@"`), " && ")
+	cmd.Args = append([]string{"sh", "-c", script, cmd.Path}, cmd.Args[1:]...)
+	cmd.Path = limitsShell
+}
+
+// hookLimitError returns HookLimitExceededError if the hook process is killed because of its limits.
+// Only the signal is checked, or the exit status 128+signal if the hook is a shell script
+// and its child process is killed:
+//   - SIGXCPU means the CPU time limit, SIGKILL too if the process used all CPU time;
+//   - SIGKILL, SIGSEGV or SIGABRT mean the memory limit: programs abort or crash
+//     when an allocation fails under RLIMIT_AS.
+//
+// Hooks that handle a failed allocation and exit with an error are not detected.
+func hookLimitError(limits HookLimits, usage *executor.CmdUsage, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	sig, ok := killSignal(err)
+	if !ok {
+		return err
+	}
+
+	cpuTimeExceeded := usage != nil && usage.User+usage.Sys >= limits.CPUTime
+	if limits.CPUTime > 0 && (sig == syscall.SIGXCPU || sig == syscall.SIGKILL && cpuTimeExceeded) {
+		return &HookLimitExceededError{Limit: HookLimitCPUTime, Value: limits.CPUTime.String(), Err: err}
+	}
+
+	if limits.Memory > 0 && (sig == syscall.SIGKILL || sig == syscall.SIGSEGV || sig == syscall.SIGABRT) {
+		limitErr := &HookLimitExceededError{Limit: HookLimitMemory, Value: resource.NewQuantity(limits.Memory, resource.BinarySI).String(), Err: err}
+		if usage != nil {
+			// MaxRss is in kilobytes.
+			limitErr.Err = fmt.Errorf("%w, max RSS %s", err, resource.NewQuantity(usage.MaxRss*1024, resource.BinarySI).String())
+		}
+		return limitErr
+	}
+
+	return err
+}
+
+// killSignal returns the signal that killed the process, or the signal from
+// the exit status 128+signal.
+func killSignal(err error) (syscall.Signal, bool) {
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) {
+		return 0, false
+	}
+	status, ok := exitErr.Sys().(syscall.WaitStatus)
+	if !ok {
+		return 0, false
+	}
+	if status.Signaled() {
+		return status.Signal(), true
+	}
+	if status.Exited() && status.ExitStatus() > 128 {
+		return syscall.Signal(status.ExitStatus() - 128), true
+	}
+	return 0, false
+}
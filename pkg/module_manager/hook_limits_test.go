@@ -0,0 +1,99 @@
+package module_manager
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/flant/shell-operator/pkg/executor"
+	. "github.com/onsi/gomega"
+)
+
+func Test_filterEnv(t *testing.T) {
+	g := NewWithT(t)
+
+	environ := []string{"PATH=/bin", "HOME=/root", "TOKEN=secret", "HTTP_PROXY=proxy:3128"}
+
+	g.Expect(filterEnv(environ, nil)).To(Equal(environ))
+	g.Expect(filterEnv(environ, []string{})).To(Equal([]string{"PATH=/bin"}))
+	g.Expect(filterEnv(environ, []string{"HTTP_PROXY"})).To(Equal([]string{"PATH=/bin", "HTTP_PROXY=proxy:3128"}))
+}
+
+func Test_applyHookLimits_CPUTime(t *testing.T) {
+	g := NewWithT(t)
+
+	limits := HookLimits{CPUTime: time.Second}
+	cmd := executor.MakeCommand("", "/bin/sh", []string{"-c", "while :; do :; done"}, []string{})
+	applyHookLimits(cmd, limits)
+	g.Expect(cmd.Path).To(Equal("/bin/sh"))
+	g.Expect(cmd.Args[2]).To(Equal(`ulimit -S -t 1 && ulimit -H -t 2 && exec "$0" "$@"`))
+
+	start := time.Now()
+	usage, err := runAndLogLines(context.Background(), cmd, 30*time.Second, map[string]string{})
+	err = hookLimitError(limits, usage, err)
+
+	g.Expect(time.Since(start)).To(BeNumerically("<", 20*time.Second))
+	var limitErr *HookLimitExceededError
+	g.Expect(errors.As(err, &limitErr)).To(BeTrue())
+	g.Expect(limitErr.Limit).To(Equal(HookLimitCPUTime))
+}
+
+func Test_applyHookLimits_NoLimits(t *testing.T) {
+	g := NewWithT(t)
+
+	cmd := executor.MakeCommand("", "/bin/sh", []string{"-c", "exit 1"}, []string{})
+	applyHookLimits(cmd, HookLimits{})
+	g.Expect(cmd.Args).To(Equal([]string{"/bin/sh", "-c", "exit 1"}))
+
+	usage, err := runAndLogLines(context.Background(), cmd, 0, map[string]string{})
+	err = hookLimitError(HookLimits{Memory: 1 << 30}, usage, err)
+	g.Expect(err).Should(HaveOccurred())
+	var limitErr *HookLimitExceededError
+	g.Expect(errors.As(err, &limitErr)).To(BeFalse())
+}
+
+func Test_applyHookLimits_Memory(t *testing.T) {
+	g := NewWithT(t)
+
+	limits := HookLimits{Memory: 64 << 20}
+	cmd := executor.MakeCommand("", "/bin/sh", []string{"-c", "kill -SEGV $$"}, []string{})
+	applyHookLimits(cmd, limits)
+	g.Expect(cmd.Args[2]).To(Equal(`ulimit -v 65536 && exec "$0" "$@"`))
+
+	usage, err := runAndLogLines(context.Background(), cmd, 0, map[string]string{})
+	err = hookLimitError(limits, usage, err)
+
+	var limitErr *HookLimitExceededError
+	g.Expect(errors.As(err, &limitErr)).To(BeTrue())
+	g.Expect(limitErr.Limit).To(Equal(HookLimitMemory))
+	g.Expect(limitErr.Value).To(Equal("64Mi"))
+	g.Expect(err.Error()).To(ContainSubstring("max RSS"))
+
+	// A shell script reports a child process killed by SIGABRT with the exit status 134.
+	cmd = executor.MakeCommand("", "/bin/sh", []string{"-c", "exit 134"}, []string{})
+	usage, err = runAndLogLines(context.Background(), cmd, 0, map[string]string{})
+	err = hookLimitError(limits, usage, err)
+	g.Expect(errors.As(err, &limitErr)).To(BeTrue())
+	g.Expect(limitErr.Limit).To(Equal(HookLimitMemory))
+
+	// Output of the hook is not checked.
+	cmd = executor.MakeCommand("", "/bin/sh", []string{"-c", "echo 'out of memory' >&2; exit 2"}, []string{})
+	usage, err = runAndLogLines(context.Background(), cmd, 0, map[string]string{})
+	err = hookLimitError(limits, usage, err)
+	g.Expect(err).Should(HaveOccurred())
+	g.Expect(errors.As(err, &limitErr)).To(BeFalse())
+
+	// A crash is not counted if the memory limit is not set.
+	cmd = executor.MakeCommand("", "/bin/sh", []string{"-c", "kill -SEGV $$"}, []string{})
+	usage, err = runAndLogLines(context.Background(), cmd, 0, map[string]string{})
+	err = hookLimitError(HookLimits{}, usage, err)
+	g.Expect(err).Should(HaveOccurred())
+	g.Expect(errors.As(err, &limitErr)).To(BeFalse())
+}
+
+func Test_CheckLimitsShell(t *testing.T) {
+	g := NewWithT(t)
+
+	g.Expect(CheckLimitsShell()).Should(Succeed())
+}
@@ -0,0 +1,258 @@
+package module_manager
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	sh_app "github.com/flant/shell-operator/pkg/app"
+	"github.com/flant/shell-operator/pkg/executor"
+	"github.com/go-openapi/spec"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/flant/addon-operator/pkg/app"
+	"github.com/flant/addon-operator/pkg/utils"
+)
+
+// HookSettings are addon-operator specific fields in the 'settings' section of the hook config.
+type HookSettings struct {
+	Timeout    string   `json:"timeout,omitempty"`
+	CPUTime    string   `json:"cpuTime,omitempty"`
+	Memory     string   `json:"memory,omitempty"`
+	AllowedEnv []string `json:"allowedEnv,omitempty"`
+	// Retry is a retry policy for failed tasks of the hook.
+	Retry *utils.RetryPolicy `json:"retry,omitempty"`
+}
+
+// addHookSettingsToSchema adds addon-operator specific fields to the 'settings' section of the shell-operator schema.
+func addHookSettingsToSchema(schema *spec.Schema) error {
+	if schema == nil {
+		return fmt.Errorf("hook config schema is not loaded")
+	}
+	settings, ok := schema.Properties["settings"]
+	if !ok {
+		return fmt.Errorf("hook config schema has no 'settings' property")
+	}
+	if settings.Properties == nil {
+		settings.Properties = map[string]spec.Schema{}
+	}
+	settings.Properties["timeout"] = *spec.StringProperty().WithExample("30s")
+	settings.Properties["cpuTime"] = *spec.StringProperty().WithExample("30s")
+	settings.Properties["memory"] = *spec.StringProperty().WithExample("512Mi")
+	settings.Properties["allowedEnv"] = *spec.ArrayProperty(spec.StringProperty())
+	settings.Properties["retry"] = spec.Schema{SchemaProps: spec.SchemaProps{
+		Type:                 []string{"object"},
+		AdditionalProperties: &spec.SchemaOrBool{Allows: false},
+		Properties: map[string]spec.Schema{
+			"maxAttempts":  *spec.Int64Property().WithMinimum(0, false),
+			"initialDelay": *spec.StringProperty().WithExample("5s"),
+			"maxDelay":     *spec.StringProperty().WithExample("32s"),
+			"jitter":       *spec.StringProperty().WithExample("1s"),
+			"giveUpAction": *spec.StringProperty().WithEnum(string(utils.GiveUpRetry), string(utils.GiveUpSkip)),
+		},
+	}}
+	schema.Properties["settings"] = settings
+	return nil
+}
+
+// ConvertHookTimeout returns a timeout from hook settings. Zero means that the default timeout is used.
+func ConvertHookTimeout(settings *HookSettings) (time.Duration, error) {
+	if settings == nil || settings.Timeout == "" {
+		return 0, nil
+	}
+	timeout, err := time.ParseDuration(settings.Timeout)
+	if err != nil {
+		return 0, fmt.Errorf("settings.timeout is invalid: %v", err)
+	}
+	if timeout < 0 {
+		return 0, fmt.Errorf("settings.timeout should not be negative, got %s", settings.Timeout)
+	}
+	return timeout, nil
+}
+
+// effectiveHookTimeout returns a timeout from hook config or a default timeout.
+func effectiveHookTimeout(timeout time.Duration) time.Duration {
+	if timeout > 0 {
+		return timeout
+	}
+	return app.HookTimeout
+}
+
+// HookTimeoutError is returned when the shell hook process is killed after timeout
+// or when the Go hook returns after its context deadline is exceeded.
+type HookTimeoutError struct {
+	Timeout time.Duration
+}
+
+func (e *HookTimeoutError) Error() string {
+	return fmt.Sprintf("timeout %s exceeded", e.Timeout)
+}
+
+// goHookContextError returns HookTimeoutError if the Go hook context deadline is exceeded and
+// an error wrapping context.Canceled if the hook is stopped because of the operator shutdown.
+func goHookContextError(ctx context.Context, timeout time.Duration, err error) error {
+	switch ctx.Err() {
+	case context.DeadlineExceeded:
+		return &HookTimeoutError{Timeout: timeout}
+	case context.Canceled:
+		return fmt.Errorf("%w: %v", context.Canceled, err)
+	}
+	return err
+}
+
+// IsHookCanceled returns true if the hook is stopped because of the operator shutdown.
+// Canceled hooks should not be treated as failed. ctx is the operator context: a hook
+// may return context.Canceled from its own requests while the operator is running.
+func IsHookCanceled(ctx context.Context, err error) bool {
+	return ctx.Err() != nil && errors.Is(err, context.Canceled)
+}
+
+// runAndLogLines runs a command and logs its stdout and stderr lines.
+// The command is started in a new process group. The whole group is killed when timeout
+// is exceeded or when ctx is canceled on the operator shutdown. Zero timeout means no timeout.
+//
+// It is a copy of executor.RunAndLogLines from shell-operator v1.1.3 with the process group
+// setup and kill added. The upstream function starts the command itself, so the process group ID
+// is not known outside of it, and exec.Cmd.Cancel is not available with Go 1.19. Keep the logging
+// and usage parts in sync with the pinned shell-operator version when it is updated.
+func runAndLogLines(ctx context.Context, cmd *exec.Cmd, timeout time.Duration, logLabels map[string]string) (*executor.CmdUsage, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	logEntry := log.WithFields(utils.LabelsToLogFields(logLabels))
+	stdoutLogEntry := logEntry.WithField("output", "stdout")
+	stderrLogEntry := logEntry.WithField("output", "stderr")
+
+	logEntry.Debugf("Executing command '%s' in '%s' dir", strings.Join(cmd.Args, " "), cmd.Dir)
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return nil, err
+	}
+
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Setpgid = true
+
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+
+	// The process group is killed only until the process is waited, so its ID is not reused.
+	var lock sync.Mutex
+	finished := false
+	timedOut := false
+	canceled := false
+	pgid := cmd.Process.Pid
+	killGroup := func(reason string, flag *bool) {
+		lock.Lock()
+		defer lock.Unlock()
+		if finished {
+			return
+		}
+		logEntry.Errorf("Command '%s' %s, kill process group %d", strings.Join(cmd.Args, " "), reason, pgid)
+		// Negative pid means the process group.
+		_ = syscall.Kill(-pgid, syscall.SIGKILL)
+		*flag = true
+	}
+	if timeout > 0 {
+		timer := time.AfterFunc(timeout, func() {
+			killGroup(fmt.Sprintf("exceeds timeout %s", timeout), &timedOut)
+		})
+		defer timer.Stop()
+	}
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			killGroup("is canceled, operator is shutting down", &canceled)
+		case <-done:
+		}
+	}()
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		logLines(stdout, stdoutLogEntry)
+	}()
+	go func() {
+		defer wg.Done()
+		logLines(stderr, stderrLogEntry)
+	}()
+	wg.Wait()
+
+	err = cmd.Wait()
+
+	lock.Lock()
+	finished = true
+	isTimedOut, isCanceled := timedOut, canceled
+	lock.Unlock()
+
+	var usage *executor.CmdUsage
+	if cmd.ProcessState != nil {
+		usage = &executor.CmdUsage{
+			Sys:  cmd.ProcessState.SystemTime(),
+			User: cmd.ProcessState.UserTime(),
+		}
+		if v, ok := cmd.ProcessState.SysUsage().(*syscall.Rusage); ok {
+			usage.MaxRss = int64(v.Maxrss)
+		}
+	}
+
+	if isTimedOut {
+		return usage, &HookTimeoutError{Timeout: timeout}
+	}
+	if isCanceled {
+		return usage, fmt.Errorf("%w: %v", context.Canceled, err)
+	}
+	return usage, err
+}
+
+// logLines logs lines from the command output. JSON lines are proxied as is if
+// shell-operator's LogProxyHookJSON is enabled, as in executor.RunAndLogLines of v1.1.3.
+func logLines(r io.Reader, logEntry *log.Entry) {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if sh_app.LogProxyHookJSON {
+			if logLine, ok := proxyJSONLogLine(line, logEntry); ok {
+				logEntry.WithField(sh_app.ProxyJsonLogKey, true).Log(log.FatalLevel, logLine)
+				continue
+			}
+		}
+		logEntry.Info(line)
+	}
+}
+
+// proxyJSONLogLine adds log entry fields to the JSON object from the line.
+func proxyJSONLogLine(line string, logEntry *log.Entry) (string, bool) {
+	var logMap map[string]interface{}
+	if err := json.Unmarshal([]byte(line), &logMap); err != nil || logMap == nil {
+		logEntry.Debugf("json log line is not an object: %v", err)
+		return "", false
+	}
+	for k, v := range logEntry.Data {
+		logMap[k] = v
+	}
+	logLine, err := json.Marshal(logMap)
+	if err != nil {
+		logEntry.Debugf("marshal json log line: %v", err)
+		return "", false
+	}
+	return string(logLine), true
+}
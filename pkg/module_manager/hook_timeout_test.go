@@ -0,0 +1,132 @@
+package module_manager
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/flant/shell-operator/pkg/executor"
+	"github.com/go-openapi/spec"
+	. "github.com/onsi/gomega"
+
+	"github.com/flant/addon-operator/pkg/utils"
+)
+
+func Test_runAndLogLines_Timeout(t *testing.T) {
+	g := NewWithT(t)
+
+	// A child process holds stdout open, so the whole process group should be killed.
+	cmd := executor.MakeCommand("", "/bin/sh", []string{"-c", "sleep 30 & echo started; sleep 30"}, []string{})
+
+	start := time.Now()
+	_, err := runAndLogLines(context.Background(), cmd, 200*time.Millisecond, map[string]string{})
+
+	g.Expect(time.Since(start)).To(BeNumerically("<", 10*time.Second))
+	g.Expect(err).Should(HaveOccurred())
+	var timeoutErr *HookTimeoutError
+	g.Expect(errors.As(err, &timeoutErr)).To(BeTrue())
+	g.Expect(timeoutErr.Timeout).To(Equal(200 * time.Millisecond))
+}
+
+func Test_runAndLogLines_Canceled(t *testing.T) {
+	g := NewWithT(t)
+
+	// The operator is shutting down: the whole process group of the hook should be killed.
+	ctx, cancel := context.WithCancel(context.Background())
+	cmd := executor.MakeCommand("", "/bin/sh", []string{"-c", "sleep 30 & echo started; sleep 30"}, []string{})
+	time.AfterFunc(200*time.Millisecond, cancel)
+
+	start := time.Now()
+	_, err := runAndLogLines(ctx, cmd, 0, map[string]string{})
+
+	g.Expect(time.Since(start)).To(BeNumerically("<", 10*time.Second))
+	g.Expect(err).Should(HaveOccurred())
+	g.Expect(IsHookCanceled(ctx, err)).To(BeTrue())
+}
+
+func Test_runAndLogLines_NoTimeoutExceeded(t *testing.T) {
+	g := NewWithT(t)
+
+	cmd := executor.MakeCommand("", "/bin/sh", []string{"-c", "echo ok"}, []string{})
+	usage, err := runAndLogLines(context.Background(), cmd, 10*time.Second, map[string]string{})
+	g.Expect(err).ShouldNot(HaveOccurred())
+	g.Expect(usage).ShouldNot(BeNil())
+
+	cmd = executor.MakeCommand("", "/bin/sh", []string{"-c", "exit 1"}, []string{})
+	_, err = runAndLogLines(context.Background(), cmd, 10*time.Second, map[string]string{})
+	g.Expect(err).Should(HaveOccurred())
+	var timeoutErr *HookTimeoutError
+	g.Expect(errors.As(err, &timeoutErr)).To(BeFalse())
+}
+
+func Test_runAndLogLines_KeepsStdin(t *testing.T) {
+	g := NewWithT(t)
+
+	cmd := executor.MakeCommand("", "/bin/sh", []string{"-c", `read line && test "$line" = input`}, []string{})
+	cmd.Stdin = strings.NewReader("input\n")
+	_, err := runAndLogLines(context.Background(), cmd, 10*time.Second, map[string]string{})
+	g.Expect(err).ShouldNot(HaveOccurred())
+}
+
+func Test_addHookSettingsToSchema(t *testing.T) {
+	g := NewWithT(t)
+
+	schema, err := GetModuleHookConfigSchema("v1")
+	g.Expect(err).ShouldNot(HaveOccurred())
+	g.Expect(schema.Properties["settings"].Properties).To(HaveKey("executionBurst"))
+	g.Expect(schema.Properties["settings"].Properties).To(HaveKey("timeout"))
+	g.Expect(schema.Properties["settings"].Properties).To(HaveKey("allowedEnv"))
+	g.Expect(schema.Properties["settings"].Properties).To(HaveKey("retry"))
+
+	err = addHookSettingsToSchema(&spec.Schema{})
+	g.Expect(err).Should(HaveOccurred())
+}
+
+func Test_HookSettings_Retry(t *testing.T) {
+	g := NewWithT(t)
+
+	cfg := &ModuleHookConfig{}
+	err := cfg.LoadAndValidate([]byte(`
+configVersion: v1
+beforeHelm: 10
+settings:
+  executionMinInterval: 1s
+  executionBurst: 1
+  retry:
+    maxAttempts: 3
+    initialDelay: 1s
+    giveUpAction: Skip
+`))
+	g.Expect(err).ShouldNot(HaveOccurred())
+	g.Expect(cfg.Retry).To(Equal(&utils.RetryPolicy{
+		MaxAttempts:  3,
+		InitialDelay: time.Second,
+		GiveUpAction: utils.GiveUpSkip,
+	}))
+
+	globalCfg := &GlobalHookConfig{}
+	err = globalCfg.LoadAndValidate([]byte(`
+configVersion: v1
+beforeAll: 10
+`))
+	g.Expect(err).ShouldNot(HaveOccurred())
+	g.Expect(globalCfg.Retry).To(BeNil())
+
+	err = (&ModuleHookConfig{}).LoadAndValidate([]byte(`
+configVersion: v1
+settings:
+  retry:
+    giveUpAction: Ignore
+`))
+	g.Expect(err).Should(HaveOccurred())
+
+	err = (&ModuleHookConfig{}).LoadAndValidate([]byte(`
+configVersion: v1
+settings:
+  retry:
+    attempts: 3
+`))
+	g.Expect(err).Should(HaveOccurred())
+}
@@ -2,6 +2,7 @@ package module_manager
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -13,6 +14,7 @@ import (
 	"github.com/kennygrant/sanitize"
 	log "github.com/sirupsen/logrus"
 	uuid "gopkg.in/satori/go.uuid.v1"
+	"sigs.k8s.io/yaml"
 
 	. "github.com/flant/addon-operator/pkg/hook/types"
 	. "github.com/flant/shell-operator/pkg/hook/binding_context"
@@ -29,6 +31,7 @@ import (
 	"github.com/flant/addon-operator/pkg/helm/client"
 	"github.com/flant/addon-operator/pkg/utils"
 	"github.com/flant/addon-operator/pkg/values/validation"
+	"github.com/flant/addon-operator/sdk"
 )
 
 type Module struct {
@@ -39,6 +42,8 @@ type Module struct {
 	CommonStaticConfig *utils.ModuleConfig
 	// module values from modules/<module name>/values.yaml
 	StaticConfig *utils.ModuleConfig
+	// retry policy for failed ModuleRun and module hooks from modules/<module name>/module.yaml
+	RetryPolicy *utils.RetryPolicy
 
 	State *ModuleState
 
@@ -191,7 +196,7 @@ func (m *Module) Delete(logLabels map[string]string) error {
 	}
 
 	// Cleanup state.
-	m.State = NewModuleState()
+	m.State.Reset()
 	return nil
 }
 
@@ -254,6 +259,7 @@ func (m *Module) runHelmInstall(logLabels map[string]string) error {
 		return err
 	}
 	checksum := utils.CalculateStringsChecksum(renderedManifests)
+	m.State.SetHelmChecksum(checksum)
 
 	manifests, err := manifest.ListFromYamlDocs(renderedManifests)
 	if err != nil {
@@ -286,6 +292,10 @@ func (m *Module) runHelmInstall(logLabels map[string]string) error {
 		if !m.moduleManager.HelmResourcesManager.HasMonitor(m.Name) {
 			m.moduleManager.HelmResourcesManager.StartMonitor(m.Name, manifests, app.Namespace)
 		}
+		// Revision is not changed, get it once for module status.
+		if m.State.HelmRevision == "" {
+			m.updateHelmRevision(helmClient, helmReleaseName, logEntry)
+		}
 		return nil
 	}
 
@@ -318,9 +328,21 @@ func (m *Module) runHelmInstall(logLabels map[string]string) error {
 	// Start monitor resources if release was successful
 	m.moduleManager.HelmResourcesManager.StartMonitor(m.Name, manifests, app.Namespace)
 
+	m.updateHelmRevision(helmClient, helmReleaseName, logEntry)
+
 	return nil
 }
 
+// updateHelmRevision saves a revision of the Helm release into the module state.
+func (m *Module) updateHelmRevision(helmClient client.HelmClient, releaseName string, logEntry *log.Entry) {
+	revision, _, err := helmClient.LastReleaseStatus(releaseName)
+	if err != nil {
+		logEntry.Debugf("get helm release '%s' revision: %v", releaseName, err)
+		return
+	}
+	m.State.SetHelmRevision(revision)
+}
+
 // ShouldRunHelmUpgrade tells if there is a case to run `helm upgrade`:
 //   - Helm chart in not installed yet.
 //   - Last release has FAILED status.
@@ -396,7 +418,7 @@ func (m *Module) runHooksByBinding(binding BindingType, logLabels map[string]str
 	for _, moduleHookName := range moduleHooks {
 		moduleHook := m.moduleManager.GetModuleHook(moduleHookName)
 
-		err = moduleHook.RateLimitWait(context.Background())
+		err = moduleHook.RateLimitWait(m.moduleManager.Context())
 		if err != nil {
 			// This could happen when the Context is
 			// canceled, or the expected wait time exceeds the Context's Deadline.
@@ -454,7 +476,7 @@ func (m *Module) runHooksByBindingAndCheckValues(binding BindingType, logLabels
 	for _, moduleHookName := range moduleHooks {
 		moduleHook := m.moduleManager.GetModuleHook(moduleHookName)
 
-		err = moduleHook.RateLimitWait(context.Background())
+		err = moduleHook.RateLimitWait(m.moduleManager.Context())
 		if err != nil {
 			// This could happen when the Context is
 			// canceled, or the expected wait time exceeds the Context's Deadline.
@@ -853,7 +875,7 @@ func (m *Module) runEnabledScript(precedingEnabledModules []string, logLabels ma
 
 	cmd := executor.MakeCommand("", enabledScriptPath, []string{}, envs)
 
-	usage, err := executor.RunAndLogLines(cmd, logLabels)
+	usage, err := runAndLogLines(m.moduleManager.Context(), cmd, app.HookTimeout, logLabels)
 	if usage != nil {
 		// usage metrics
 		metricLabels := map[string]string{
@@ -867,6 +889,10 @@ func (m *Module) runEnabledScript(precedingEnabledModules []string, logLabels ma
 		m.moduleManager.metricStorage.HistogramObserve("{PREFIX}module_hook_run_user_cpu_seconds", usage.User.Seconds(), metricLabels, nil)
 		m.moduleManager.metricStorage.GaugeSet("{PREFIX}module_hook_run_max_rss_bytes", float64(usage.MaxRss)*1024, metricLabels)
 	}
+	var timeoutErr *HookTimeoutError
+	if errors.As(err, &timeoutErr) {
+		m.moduleManager.metricStorage.CounterAdd("{PREFIX}hook_timeout_total", 1.0, map[string]string{"module": m.Name, "hook": "enabled"})
+	}
 	if err != nil {
 		logEntry.Errorf("Fail to run enabled script '%s': %s", enabledScriptPath, err)
 		return false, err
@@ -923,9 +949,16 @@ func (mm *moduleManager) RegisterModules() error {
 			return fmt.Errorf("bad module values")
 		}
 
+		// load settings from module.yaml
+		err = module.loadManifest()
+		if err != nil {
+			logEntry.Errorf("Load %s: %s", ManifestFileName, err)
+			return fmt.Errorf("bad module manifest")
+		}
+
 		// Load validation schemas
 		openAPIPath := filepath.Join(module.Path, "openapi")
-		configBytes, valuesBytes, err := ReadOpenAPIFiles(openAPIPath)
+		configBytes, valuesBytes, err := mm.readOpenAPISchemas(openAPIPath)
 		if err != nil {
 			return fmt.Errorf("module '%s' read openAPI schemas: %v", module.Name, err)
 		}
@@ -939,6 +972,14 @@ func (mm *moduleManager) RegisterModules() error {
 			return fmt.Errorf("add module '%s' schemas: %v", module.Name, err)
 		}
 
+		for version, fn := range sdk.Registry().ConfigConversions(module.Name) {
+			mm.conversions.AddFunc(module.Name, version, fn)
+		}
+		err = mm.conversions.LoadModuleConversions(module.Name, openAPIPath, configBytes)
+		if err != nil {
+			return fmt.Errorf("module '%s' load config conversions: %v", module.Name, err)
+		}
+
 		logEntry.Infof("Module from '%s'. %s", module.Path, mm.ValuesValidator.SchemaStorage.ModuleSchemasDescription(module.ValuesKey()))
 	}
 
@@ -976,6 +1017,36 @@ func (m *Module) loadStaticValues() (err error) {
 	return nil
 }
 
+// ModuleManifest is a content of the optional module.yaml file.
+type ModuleManifest struct {
+	Retry *utils.RetryPolicy `json:"retry,omitempty"`
+}
+
+// loadManifest loads module settings from module.yaml.
+// Module has no retry policy if module.yaml is not exists.
+func (m *Module) loadManifest() error {
+	manifestPath := filepath.Join(m.Path, ManifestFileName)
+
+	if _, err := os.Stat(manifestPath); os.IsNotExist(err) {
+		log.Debugf("module %s has no manifest", m.Name)
+		return nil
+	}
+
+	data, err := os.ReadFile(manifestPath)
+	if err != nil {
+		return fmt.Errorf("cannot read '%s': %s", manifestPath, err)
+	}
+
+	var manifest ModuleManifest
+	err = yaml.Unmarshal(data, &manifest)
+	if err != nil {
+		return fmt.Errorf("parse '%s': %s", manifestPath, err)
+	}
+
+	m.RetryPolicy = manifest.Retry
+	return nil
+}
+
 func dumpData(filePath string, data []byte) error {
 	err := os.WriteFile(filePath, data, 0o644)
 	if err != nil {
@@ -1,6 +1,7 @@
 package module_manager
 
 import (
+	"errors"
 	"fmt"
 	"path/filepath"
 	"strings"
@@ -65,6 +66,15 @@ func (m *ModuleHook) WithGoConfig(config *go_hook.HookConfig) (err error) {
 	return nil
 }
 
+// RetryPolicy returns a retry policy from the Go hook config or from 'settings.retry'
+// of shell, plugin and WASM hooks. Nil means that the policy is not set.
+func (m *ModuleHook) RetryPolicy() *utils.RetryPolicy {
+	if policy := m.CommonHook.RetryPolicy(); policy != nil {
+		return policy
+	}
+	return m.Config.Retry
+}
+
 func (m *ModuleHook) GetConfigDescription() string {
 	msgs := []string{}
 	if m.Config.BeforeHelm != nil {
@@ -163,6 +173,9 @@ func (h *ModuleHook) Run(bindingType BindingType, context []BindingContext, logL
 	moduleHookExecutor := NewHookExecutor(h, context, h.Config.Version, h.moduleManager.KubeObjectPatcher)
 	moduleHookExecutor.WithLogLabels(logLabels)
 	moduleHookExecutor.WithHelm(h.moduleManager.helm)
+	moduleHookExecutor.WithContext(h.moduleManager.Context())
+	moduleHookExecutor.WithTimeout(effectiveHookTimeout(h.Config.Timeout))
+	moduleHookExecutor.WithLimits(h.Config.Limits)
 	hookResult, err := moduleHookExecutor.Run()
 	if hookResult != nil && hookResult.Usage != nil {
 		// usage metrics
@@ -171,7 +184,15 @@ func (h *ModuleHook) Run(bindingType BindingType, context []BindingContext, logL
 		h.moduleManager.metricStorage.GaugeSet("{PREFIX}module_hook_run_max_rss_bytes", float64(hookResult.Usage.MaxRss)*1024, metricLabels)
 	}
 	if err != nil {
-		return fmt.Errorf("module hook '%s' failed: %s", h.Name, err)
+		var timeoutErr *HookTimeoutError
+		if errors.As(err, &timeoutErr) {
+			h.moduleManager.metricStorage.CounterAdd("{PREFIX}hook_timeout_total", 1.0, map[string]string{"module": h.Module.Name, "hook": h.Name})
+		}
+		var limitErr *HookLimitExceededError
+		if errors.As(err, &limitErr) {
+			h.moduleManager.metricStorage.CounterAdd("{PREFIX}hook_limit_exceeded_total", 1.0, map[string]string{"module": h.Module.Name, "hook": h.Name, "limit": limitErr.Limit})
+		}
+		return fmt.Errorf("module hook '%s' failed: %w", h.Name, err)
 	}
 
 	moduleName := h.Module.Name
@@ -229,6 +250,7 @@ func (h *ModuleHook) Run(bindingType BindingType, context []BindingContext, logL
 			}
 
 			h.moduleManager.UpdateModuleConfigValues(moduleName, configValuesPatchResult.Values)
+			h.moduleManager.registerModuleSensitiveValues(h.Module)
 			logEntry.Debugf("Module hook '%s': kube module '%s' config values updated:\n%s", h.Name, moduleName, h.moduleManager.kubeModulesConfigValues[moduleName].DebugString())
 		}
 	}
@@ -262,6 +284,8 @@ func (h *ModuleHook) Run(bindingType BindingType, context []BindingContext, logL
 			if err != nil {
 				return fmt.Errorf("get module values after values patch: %s", err)
 			}
+			h.moduleManager.registerSensitiveValues(h.Module.ValuesKey(), newValues)
+			h.moduleManager.recordModuleValuesPatch(moduleName, h.Name, historyBindingName(bindingType, context), valuesPatchResult.ValuesPatch, newValues)
 			logEntry.Debugf("Module hook '%s': dynamic module '%s' values updated:\n%s", h.Name, moduleName, newValues.DebugString())
 		}
 	}
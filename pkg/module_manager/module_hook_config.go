@@ -2,6 +2,7 @@ package module_manager
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/go-openapi/spec"
 	"sigs.k8s.io/yaml"
@@ -10,6 +11,7 @@ import (
 
 	. "github.com/flant/addon-operator/pkg/hook/types"
 	"github.com/flant/addon-operator/pkg/module_manager/go_hook"
+	"github.com/flant/addon-operator/pkg/utils"
 
 	"github.com/flant/shell-operator/pkg/hook/config"
 )
@@ -26,6 +28,12 @@ type ModuleHookConfig struct {
 	BeforeHelm      *BeforeHelmConfig
 	AfterHelm       *AfterHelmConfig
 	AfterDeleteHelm *AfterDeleteHelmConfig
+	// Timeout for the hook process from 'settings.timeout'.
+	Timeout time.Duration
+	// Limits for the hook process from 'settings'.
+	Limits HookLimits
+	// Retry is a retry policy from 'settings.retry'.
+	Retry *utils.RetryPolicy
 }
 
 type BeforeHelmConfig struct {
@@ -44,12 +52,13 @@ type AfterDeleteHelmConfig struct {
 }
 
 type ModuleHookConfigV0 struct {
-	BeforeHelm      interface{} `json:"beforeHelm"`
-	AfterHelm       interface{} `json:"afterHelm"`
-	AfterDeleteHelm interface{} `json:"afterDeleteHelm"`
+	BeforeHelm      interface{}   `json:"beforeHelm"`
+	AfterHelm       interface{}   `json:"afterHelm"`
+	AfterDeleteHelm interface{}   `json:"afterDeleteHelm"`
+	Settings        *HookSettings `json:"settings"`
 }
 
-func GetModuleHookConfigSchema(version string) *spec.Schema {
+func GetModuleHookConfigSchema(version string) (*spec.Schema, error) {
 	globalHookVersion := "module-hook-" + version
 	if _, ok := config.Schemas[globalHookVersion]; !ok {
 		schema := config.Schemas[version]
@@ -82,9 +91,17 @@ func GetModuleHookConfigSchema(version string) *spec.Schema {
 `
 		}
 		config.Schemas[globalHookVersion] = schema
+		if version == "v1" {
+			err := addHookSettingsToSchema(config.GetSchema(globalHookVersion))
+			if err != nil {
+				delete(config.Schemas, globalHookVersion)
+				delete(config.SchemasCache, globalHookVersion)
+				return nil, err
+			}
+		}
 	}
 
-	return config.GetSchema(globalHookVersion)
+	return config.GetSchema(globalHookVersion), nil
 }
 
 // LoadAndValidate loads config from bytes and validate it. Returns multierror.
@@ -95,7 +112,12 @@ func (c *ModuleHookConfig) LoadAndValidate(data []byte) error {
 		return err
 	}
 
-	err = config.ValidateConfig(vu.Obj, GetModuleHookConfigSchema(vu.Version), "")
+	schema, err := GetModuleHookConfigSchema(vu.Version)
+	if err != nil {
+		return err
+	}
+
+	err = config.ValidateConfig(vu.Obj, schema, "")
 	if err != nil {
 		return err
 	}
@@ -178,6 +200,18 @@ func (c *ModuleHookConfig) ConvertAndCheckV1() (err error) {
 		return err
 	}
 
+	c.Timeout, err = ConvertHookTimeout(c.ModuleV1.Settings)
+	if err != nil {
+		return err
+	}
+	c.Limits, err = ConvertHookLimits(c.ModuleV1.Settings)
+	if err != nil {
+		return err
+	}
+	if c.ModuleV1.Settings != nil {
+		c.Retry = c.ModuleV1.Settings.Retry
+	}
+
 	return nil
 }
 
@@ -272,6 +306,10 @@ func NewModuleHookConfigFromGoConfig(input *go_hook.HookConfig) (*ModuleHookConf
 		HookConfig: hookConfig,
 	}
 
+	if input.Settings != nil {
+		cfg.Timeout = input.Settings.Timeout
+	}
+
 	if input.OnBeforeHelm != nil {
 		cfg.BeforeHelm = &BeforeHelmConfig{}
 		cfg.BeforeHelm.BindingName = string(BeforeHelm)
@@ -2,6 +2,7 @@ package module_manager
 
 import (
 	"testing"
+	"time"
 
 	. "github.com/onsi/gomega"
 
@@ -105,6 +106,50 @@ afterDeleteHelm: 18
 				g.Expect(config.AfterDeleteHelm.Order).To(Equal(18.0))
 			},
 		},
+		{
+			"load v1 module config with settings",
+			"hook_v1",
+			`
+configVersion: v1
+beforeHelm: 10
+settings:
+  executionMinInterval: 5s
+  executionBurst: 3
+  timeout: 30s
+  cpuTime: 10s
+  memory: 256Mi
+  allowedEnv:
+  - HTTP_PROXY
+`,
+			func() {
+				g.Expect(err).ShouldNot(HaveOccurred())
+				g.Expect(config.HasBinding(BeforeHelm)).To(BeTrue())
+				g.Expect(config.Settings).ShouldNot(BeNil())
+				g.Expect(config.Settings.ExecutionBurst).To(Equal(3))
+				g.Expect(config.Timeout).To(Equal(30 * time.Second))
+				g.Expect(config.Limits.CPUTime).To(Equal(10 * time.Second))
+				g.Expect(config.Limits.Memory).To(Equal(int64(256 * 1024 * 1024)))
+				g.Expect(config.Limits.AllowedEnv).To(Equal([]string{"HTTP_PROXY"}))
+			},
+		},
+		{
+			"load v1 module config with bad timeout",
+			"hook_v1",
+			`{"configVersion": "v1", "beforeHelm": 10, "settings": {"executionMinInterval": "5s", "executionBurst": 3, "timeout": "5 minutes"}}`,
+			func() {
+				g.Expect(err).Should(HaveOccurred())
+				g.Expect(err.Error()).Should(ContainSubstring("settings.timeout is invalid"))
+			},
+		},
+		{
+			"load v1 module config with bad memory limit",
+			"hook_v1",
+			`{"configVersion": "v1", "beforeHelm": 10, "settings": {"executionMinInterval": "5s", "executionBurst": 3, "memory": "a lot"}}`,
+			func() {
+				g.Expect(err).Should(HaveOccurred())
+				g.Expect(err.Error()).Should(ContainSubstring("settings.memory is invalid"))
+			},
+		},
 		{
 			"load v1 bad module config",
 			"hook_v1",
@@ -16,6 +16,10 @@ import (
 const (
 	PathsSeparator = ":"
 	ValuesFileName = "values.yaml"
+	// ManifestFileName is an optional file with module settings.
+	ManifestFileName = "module.yaml"
+	// CommonOpenAPIDirName is a directory in the modules directory with shared schemas for '$ref'.
+	CommonOpenAPIDirName = "openapi-common"
 )
 
 func SearchModules(modulesDirs string) (*ModuleSet, error) {
@@ -65,8 +69,8 @@ func findModulesInDir(modulesDir string) ([]*Module, error) {
 		if err != nil {
 			return nil, err
 		}
-		// Skip non-directories.
-		if name == "" {
+		// Skip non-directories and shared schemas.
+		if name == "" || name == CommonOpenAPIDirName {
 			continue
 		}
 
@@ -42,6 +42,7 @@ func TestLoadMultiDir(t *testing.T) {
 
 	g.Expect(mods.Has("mod-one")).Should(BeTrue(), "should load module-one as mod-one")
 	g.Expect(mods.Has("mod-two")).Should(BeTrue(), "should load module-one as mod-two")
+	g.Expect(mods.Has("openapi-common")).ShouldNot(BeTrue(), "should skip directory with shared schemas")
 
 	vals, err := LoadCommonStaticValues(dirs)
 	g.Expect(err).ShouldNot(HaveOccurred(), "should load common values")
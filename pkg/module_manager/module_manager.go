@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"path/filepath"
 	"sort"
 	"strings"
 	"sync"
@@ -32,6 +33,7 @@ import (
 	"github.com/flant/addon-operator/pkg/kube_config_manager"
 	"github.com/flant/addon-operator/pkg/module_manager/go_hook"
 	"github.com/flant/addon-operator/pkg/utils"
+	"github.com/flant/addon-operator/pkg/values/conversion"
 	"github.com/flant/addon-operator/pkg/values/validation"
 )
 
@@ -40,9 +42,11 @@ import (
 type ModuleManager interface {
 	Init() error
 	Start()
+	Stop()
 
 	// Dependencies
 	WithContext(ctx context.Context)
+	Context() context.Context
 	WithDirectories(modulesDir string, globalHooksDir string, tempDir string) ModuleManager
 	WithKubeEventManager(kube_events_manager.KubeEventsManager)
 	WithKubeObjectPatcher(*object_patch.ObjectPatcher)
@@ -64,6 +68,8 @@ type ModuleManager interface {
 	GetModuleHookNames(moduleName string) []string
 	GetModuleHook(name string) *ModuleHook
 	GetModuleHooksInOrder(moduleName string, bindingType BindingType) []string
+	GetModuleStatus(moduleName string) *ModuleStatus
+	ModuleEnabledSource(moduleName string) string
 
 	GlobalStaticAndConfigValues() utils.Values
 	GlobalStaticAndNewValues(newValues utils.Values) utils.Values
@@ -79,10 +85,21 @@ type ModuleManager interface {
 	UpdateModuleDynamicValuesPatches(moduleName string, valuesPatch utils.ValuesPatch)
 	ApplyModuleDynamicValuesPatches(moduleName string, values utils.Values) (utils.Values, error)
 
+	ExplainGlobalValues() (ValuesExplanation, error)
+	ExplainModuleValues(moduleName string) (ValuesExplanation, error)
+
+	GlobalValuesHistory() []ValuesHistoryRecord
+	ModuleValuesHistory(moduleName string) []ValuesHistoryRecord
+	WithValuesHistoryHandler(handler func(record ValuesHistoryRecord))
+
 	GetValuesValidator() *validation.ValuesValidator
 
 	GetKubeConfigValid() bool
 	SetKubeConfigValid(valid bool)
+	ValidateKubeConfig(kubeConfig *kube_config_manager.KubeConfig) ([]validation.Deprecation, error)
+	ConfigDeprecations(name string) []validation.Deprecation
+	ConversionRegistry() *conversion.ConversionRegistry
+	SaveConvertedConfigValues()
 
 	// Methods to change module manager's state.
 	RefreshStateFromHelmReleases(logLabels map[string]string) (*ModulesState, error)
@@ -96,6 +113,8 @@ type ModuleManager interface {
 	RunModuleHook(hookName string, binding BindingType, bindingContext []BindingContext, logLabels map[string]string) (beforeChecksum string, afterChecksum string, err error)
 
 	RegisterModuleHooks(module *Module, logLabels map[string]string) error
+	PreloadModuleHooks()
+	DropPreloadedModuleHooks()
 
 	HandleKubeEvent(kubeEvent KubeEvent, createGlobalTaskFn func(*GlobalHook, controller.BindingExecutionInfo), createModuleTaskFn func(*Module, *ModuleHook, controller.BindingExecutionInfo))
 	HandleGlobalEnableKubernetesBindings(hookName string, createTaskFn func(*GlobalHook, controller.BindingExecutionInfo)) error
@@ -144,6 +163,8 @@ type moduleManager struct {
 	metricStorage        *metric_storage.MetricStorage
 	hookMetricStorage    *metric_storage.MetricStorage
 	ValuesValidator      *validation.ValuesValidator
+	// Conversions of module config values from older versions of the config-values schema.
+	conversions *conversion.ConversionRegistry
 
 	// All known modules from specified directories ($MODULES_DIR)
 	modules *ModuleSet
@@ -161,6 +182,9 @@ type moduleManager struct {
 	// List of modules enabled by values.yaml or by kube config.
 	// This list is changed on ConfigMap updates.
 	enabledModulesByConfig map[string]struct{}
+	// Sources of enabled flags by config and final sources after dynamic enabled and enabled scripts.
+	enabledByConfigSources map[string]string
+	enabledSources         map[string]string
 
 	// List of effectively enabled modules after running enabled scripts.
 	enabledModules []string
@@ -174,6 +198,10 @@ type moduleManager struct {
 	// Note: one module hook can have several binding types.
 	modulesHooksOrderByName map[string]map[BindingType][]*ModuleHook
 
+	// Module hooks found and run with '--config' on startup, see PreloadModuleHooks.
+	preloadedModuleHooks     map[string]*preloadedModuleHooks
+	preloadedModuleHooksLock sync.Mutex
+
 	globalSynchronizationState *SynchronizationState
 
 	// VALUE STORAGES
@@ -188,6 +216,10 @@ type moduleManager struct {
 	kubeGlobalConfigValues utils.Values
 	// module values from ConfigMap, only for enabled modules
 	kubeModulesConfigValues map[string]utils.Values
+	// Deprecated settings in the ConfigMap by module name or "global".
+	configDeprecations map[string][]validation.Deprecation
+	// Versions of module config values in the ConfigMap for converted modules.
+	convertedConfigVersions map[string]int
 
 	// addon-operator config is valid.
 	kubeConfigValid bool
@@ -198,6 +230,18 @@ type moduleManager struct {
 	globalDynamicValuesPatches []utils.ValuesPatch
 	// Pathces for dynamic module values
 	modulesDynamicValuesPatches map[string][]utils.ValuesPatch
+
+	// Hook names for paths in global dynamic values patches.
+	globalDynamicValuesPatchesSources map[string]string
+	// Hook names for paths in module dynamic values patches.
+	modulesDynamicValuesPatchesSources map[string]map[string]string
+
+	// History of patches for global values.
+	globalValuesHistory *ValuesHistory
+	// History of patches for module values.
+	modulesValuesHistory map[string]*ValuesHistory
+	// A callback to publish new history records.
+	valuesHistoryHandler func(record ValuesHistoryRecord)
 }
 
 var _ ModuleManager = &moduleManager{}
@@ -206,9 +250,12 @@ var _ ModuleManager = &moduleManager{}
 func NewModuleManager() *moduleManager {
 	return &moduleManager{
 		ValuesValidator: validation.NewValuesValidator(),
+		conversions:     conversion.NewRegistry(),
 
 		modules:                     new(ModuleSet),
 		enabledModulesByConfig:      make(map[string]struct{}),
+		enabledByConfigSources:      make(map[string]string),
+		enabledSources:              make(map[string]string),
 		enabledModules:              make([]string, 0),
 		dynamicEnabled:              make(map[string]*bool),
 		globalHooksByName:           make(map[string]*GlobalHook),
@@ -220,6 +267,12 @@ func NewModuleManager() *moduleManager {
 		globalDynamicValuesPatches:  make([]utils.ValuesPatch, 0),
 		modulesDynamicValuesPatches: make(map[string][]utils.ValuesPatch),
 
+		globalDynamicValuesPatchesSources:  make(map[string]string),
+		modulesDynamicValuesPatchesSources: make(map[string]map[string]string),
+
+		globalValuesHistory:  NewValuesHistory(app.ValuesHistorySize),
+		modulesValuesHistory: make(map[string]*ValuesHistory),
+
 		globalSynchronizationState: NewSynchronizationState(),
 	}
 }
@@ -267,6 +320,15 @@ func (mm *moduleManager) WithContext(ctx context.Context) {
 	mm.ctx, mm.cancel = context.WithCancel(ctx)
 }
 
+// Context returns a context that is canceled when the module manager is stopped.
+// Go hooks receive a context derived from it.
+func (mm *moduleManager) Context() context.Context {
+	if mm.ctx == nil {
+		return context.Background()
+	}
+	return mm.ctx
+}
+
 func (mm *moduleManager) Stop() {
 	if mm.cancel != nil {
 		mm.cancel()
@@ -308,6 +370,13 @@ func (mm *moduleManager) HandleNewKubeConfig(kubeConfig *kube_config_manager.Kub
 
 	mm.warnAboutUnknownModules(kubeConfig)
 
+	// Convert module config values from older versions and move renamed settings before validation.
+	kubeConfig, err = mm.convertKubeConfig(kubeConfig)
+	if err != nil {
+		return nil, fmt.Errorf("config not valid: %v", err)
+	}
+	kubeConfig, deprecations := mm.migrateKubeConfig(kubeConfig)
+
 	// Get map of enabled modules after ConfigMap changes.
 	newEnabledByConfig := mm.calculateEnabledModulesByConfig(kubeConfig)
 
@@ -317,6 +386,8 @@ func (mm *moduleManager) HandleNewKubeConfig(kubeConfig *kube_config_manager.Kub
 		return nil, fmt.Errorf("config not valid: %v", err)
 	}
 
+	mm.updateConfigDeprecations(deprecations)
+
 	// Detect changes in global section.
 	hasGlobalChange := false
 	newGlobalValues := mm.kubeGlobalConfigValues
@@ -398,10 +469,20 @@ func (mm *moduleManager) HandleNewKubeConfig(kubeConfig *kube_config_manager.Kub
 	// Update caches from ConfigMap content.
 	mm.valuesLayersLock.Lock()
 	mm.enabledModulesByConfig = newEnabledByConfig
+	mm.enabledByConfigSources = mm.calculateEnabledSourcesByConfig(kubeConfig)
 	mm.kubeGlobalConfigValues = newGlobalValues
 	mm.kubeModulesConfigValues = newKubeModuleConfigValues
+	mm.convertedConfigVersions = convertedConfigVersions(kubeConfig)
 	mm.valuesLayersLock.Unlock()
 
+	// Config is applied, mask values from Secrets and values of sensitive settings
+	// in debug output and logs.
+	kube_config_manager.SetSecretValues(kubeConfig, mm.modules.NamesInOrder())
+	mm.registerGlobalSensitiveValues()
+	for _, moduleName := range mm.modules.NamesInOrder() {
+		mm.registerModuleSensitiveValues(mm.GetModule(moduleName))
+	}
+
 	// Return empty state on global change.
 	if hasGlobalChange || isEnabledChanged {
 		return &ModulesState{}, nil
@@ -503,26 +584,185 @@ func (mm *moduleManager) calculateEnabledModulesWithDynamic(enabledByConfig map[
 	return enabled
 }
 
+// ConversionRegistry returns conversions of module config values. Conversions are loaded
+// with modules, KubeConfigManager uses them to save values in the latest version.
+func (mm *moduleManager) ConversionRegistry() *conversion.ConversionRegistry {
+	return mm.conversions
+}
+
+// convertKubeConfig converts module sections from older versions of the config-values schema.
+// Converted sections are copied, input KubeConfig is not modified.
+func (mm *moduleManager) convertKubeConfig(kubeConfig *kube_config_manager.KubeConfig) (*kube_config_manager.KubeConfig, error) {
+	if kubeConfig == nil {
+		return nil, nil
+	}
+
+	res := &kube_config_manager.KubeConfig{
+		Global:  kubeConfig.Global,
+		Modules: make(map[string]*kube_config_manager.ModuleKubeConfig, len(kubeConfig.Modules)),
+	}
+	for moduleName, modCfg := range kubeConfig.Modules {
+		converted, err := kube_config_manager.ConvertModuleKubeConfig(modCfg, mm.conversions.Get(moduleName))
+		if err != nil {
+			return nil, fmt.Errorf("module '%s' section: %v", moduleName, err)
+		}
+		res.Modules[moduleName] = converted
+	}
+	return res, nil
+}
+
+// convertedConfigVersions returns versions in the ConfigMap for converted module sections.
+func convertedConfigVersions(kubeConfig *kube_config_manager.KubeConfig) map[string]int {
+	res := make(map[string]int)
+	if kubeConfig == nil {
+		return res
+	}
+	for moduleName, modCfg := range kubeConfig.Modules {
+		if modCfg.ConvertedFrom != 0 && modCfg.Values.HasKey(modCfg.ModuleConfigKey) {
+			res[moduleName] = modCfg.ConvertedFrom
+		}
+	}
+	return res
+}
+
+// SaveConvertedConfigValues saves module config values converted from older versions of
+// the config-values schema back to the ConfigMap. It should be called after HandleNewKubeConfig
+// if write-back is enabled. Errors are not fatal: values are converted again on the next read.
+func (mm *moduleManager) SaveConvertedConfigValues() {
+	if mm.kubeConfigManager == nil {
+		return
+	}
+
+	mm.valuesLayersLock.RLock()
+	versions := make(map[string]int, len(mm.convertedConfigVersions))
+	values := make(map[string]utils.Values, len(mm.convertedConfigVersions))
+	for moduleName, version := range mm.convertedConfigVersions {
+		versions[moduleName] = version
+		values[moduleName] = mm.kubeModulesConfigValues[moduleName]
+	}
+	mm.valuesLayersLock.RUnlock()
+
+	moduleNames := make([]string, 0, len(versions))
+	for moduleName := range versions {
+		moduleNames = append(moduleNames, moduleName)
+	}
+	sort.Strings(moduleNames)
+
+	for _, moduleName := range moduleNames {
+		log.Infof("Save module '%s' config values converted from version %d", moduleName, versions[moduleName])
+		err := mm.kubeConfigManager.SaveModuleConfigValues(moduleName, values[moduleName])
+		if err != nil {
+			log.Errorf("Save module '%s' converted config values: %v", moduleName, err)
+			continue
+		}
+		mm.valuesLayersLock.Lock()
+		if mm.convertedConfigVersions[moduleName] == versions[moduleName] {
+			delete(mm.convertedConfigVersions, moduleName)
+		}
+		mm.valuesLayersLock.Unlock()
+	}
+}
+
 // Init — initialize module manager
 func (mm *moduleManager) Init() error {
 	log.Debug("Init ModuleManager")
 
+	mm.initSchemaRefRoots()
+
+	start := time.Now()
 	if err := mm.RegisterGlobalHooks(); err != nil {
 		return err
 	}
+	mm.observeStartup("global_hooks", start)
 
+	start = time.Now()
 	if err := mm.RegisterModules(); err != nil {
 		return err
 	}
+	mm.observeStartup("modules", start)
+
+	return nil
+}
+
+// initSchemaRefRoots sets directories with shared schemas for '$ref' in OpenAPI schemas:
+// '@common/file.yaml' and '@global/config-values.yaml'.
+func (mm *moduleManager) initSchemaRefRoots() {
+	commonDirs := make([]string, 0)
+	for _, dir := range splitToPaths(mm.ModulesDir) {
+		commonDirs = append(commonDirs, filepath.Join(dir, CommonOpenAPIDirName))
+	}
+	mm.ValuesValidator.SchemaStorage.WithRefRoot("common", commonDirs...)
+	mm.ValuesValidator.SchemaStorage.WithRefRoot("global", filepath.Join(mm.GlobalHooksDir, "openapi"))
+}
+
+// LoadSchemas searches modules and loads OpenAPI schemas for global and module values
+// without running hooks. It is used by commands that need only schemas, e.g. 'module docs'.
+func (mm *moduleManager) LoadSchemas() error {
+	mm.initSchemaRefRoots()
+
+	configBytes, valuesBytes, err := mm.readOpenAPISchemas(filepath.Join(mm.GlobalHooksDir, "openapi"))
+	if err != nil {
+		return fmt.Errorf("read global openAPI schemas: %v", err)
+	}
+	err = mm.ValuesValidator.SchemaStorage.AddGlobalValuesSchemas(configBytes, valuesBytes)
+	if err != nil {
+		return fmt.Errorf("add global schemas: %v", err)
+	}
+
+	modules, err := SearchModules(mm.ModulesDir)
+	if err != nil {
+		return err
+	}
+	for _, module := range modules.List() {
+		module.WithModuleManager(mm)
+		configBytes, valuesBytes, err := mm.readOpenAPISchemas(filepath.Join(module.Path, "openapi"))
+		if err != nil {
+			return fmt.Errorf("module '%s' read openAPI schemas: %v", module.Name, err)
+		}
+		err = mm.ValuesValidator.SchemaStorage.AddModuleValuesSchemas(module.ValuesKey(), configBytes, valuesBytes)
+		if err != nil {
+			return fmt.Errorf("add module '%s' schemas: %v", module.Name, err)
+		}
+	}
 
+	mm.modules = modules
 	return nil
 }
 
 // validateKubeConfig checks validity of all sections in ConfigMap with OpenAPI schemas.
 func (mm *moduleManager) validateKubeConfig(kubeConfig *kube_config_manager.KubeConfig, enabledModules map[string]struct{}) error {
+	validationErr := mm.validateKubeConfigValues(kubeConfig, enabledModules)
+
+	// Set valid flag to false if there is validation error
+	mm.SetKubeConfigValuesValid(validationErr == nil)
+
+	return validationErr
+}
+
+// ValidateKubeConfig checks sections in ConfigMap with OpenAPI schemas without changing
+// the module manager state. It is used to validate ConfigMap changes before they are saved.
+// It returns warnings about deprecated settings in valid sections.
+func (mm *moduleManager) ValidateKubeConfig(kubeConfig *kube_config_manager.KubeConfig) ([]validation.Deprecation, error) {
+	kubeConfig, err := mm.convertKubeConfig(kubeConfig)
+	if err != nil {
+		return nil, err
+	}
+	kubeConfig, deprecations := mm.migrateKubeConfig(kubeConfig)
+	err = mm.validateKubeConfigValues(kubeConfig, mm.calculateEnabledModulesByConfig(kubeConfig))
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]validation.Deprecation, 0)
+	for _, name := range append([]string{utils.GlobalValuesKey}, mm.modules.NamesInOrder()...) {
+		res = append(res, deprecations[name]...)
+	}
+	return res, nil
+}
+
+func (mm *moduleManager) validateKubeConfigValues(kubeConfig *kube_config_manager.KubeConfig, enabledModules map[string]struct{}) error {
 	// Ignore empty kube config.
 	if kubeConfig == nil {
-		mm.SetKubeConfigValuesValid(true)
 		return nil
 	}
 	// Validate values in global section merged with static values.
@@ -555,9 +795,6 @@ func (mm *moduleManager) validateKubeConfig(kubeConfig *kube_config_manager.Kube
 		}
 	}
 
-	// Set valid flag to false if there is validation error
-	mm.SetKubeConfigValuesValid(validationErr == nil)
-
 	return validationErr
 }
 
@@ -695,6 +932,9 @@ func (mm *moduleManager) RefreshEnabledState(logLabels map[string]string) (*Modu
 
 	// Update state
 	mm.enabledModules = enabledModules
+	mm.valuesLayersLock.Lock()
+	mm.enabledSources = mm.calculateEnabledSources(enabledByDynamic, enabledModules)
+	mm.valuesLayersLock.Unlock()
 
 	// Return lists for ConvergeModules task.
 	return &ModulesState{
@@ -991,6 +1231,32 @@ func (mm *moduleManager) GlobalValues() (utils.Values, error) {
 	return res, nil
 }
 
+// registerSensitiveValues registers values of sensitive settings in the section
+// of accepted values to mask them in debug output and logs.
+func (mm *moduleManager) registerSensitiveValues(rootName string, values utils.Values) {
+	mm.ValuesValidator.RegisterSensitiveValues(utils.Values{rootName: values[rootName]})
+}
+
+// registerGlobalSensitiveValues registers values of sensitive settings in current global values.
+func (mm *moduleManager) registerGlobalSensitiveValues() {
+	values, err := mm.GlobalValues()
+	if err != nil {
+		log.Errorf("Register sensitive global values: %v", err)
+		return
+	}
+	mm.registerSensitiveValues(utils.GlobalValuesKey, values)
+}
+
+// registerModuleSensitiveValues registers values of sensitive settings in current module values.
+func (mm *moduleManager) registerModuleSensitiveValues(module *Module) {
+	values, err := module.Values()
+	if err != nil {
+		log.Errorf("Register sensitive values of module '%s': %v", module.Name, err)
+		return
+	}
+	mm.registerSensitiveValues(module.ValuesKey(), values)
+}
+
 // GlobalValues return patches for global values
 func (mm *moduleManager) GlobalValuesPatches() []utils.ValuesPatch {
 	return mm.globalDynamicValuesPatches
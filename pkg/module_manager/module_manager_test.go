@@ -7,6 +7,7 @@ import (
 	"path/filepath"
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/davecgh/go-spew/spew"
 	klient "github.com/flant/kube-client/client"
@@ -27,6 +28,7 @@ import (
 	"github.com/flant/addon-operator/pkg/kube_config_manager"
 	_ "github.com/flant/addon-operator/pkg/module_manager/test/go_hooks/global-hooks"
 	"github.com/flant/addon-operator/pkg/utils"
+	"github.com/flant/addon-operator/pkg/values/conversion"
 )
 
 type initModuleManagerResult struct {
@@ -621,6 +623,46 @@ func Test_ModuleManager_DeleteModule(t *testing.T) {
 	assert.Equal(t, res.helmClient.DeleteReleaseExecuted, true, "helm.DeleteRelease must be executed!")
 }
 
+// GetModuleStatus is called by the status updater while tasks change the module state.
+// Run with -race to check.
+func Test_ModuleManager_GetModuleStatus_concurrent(t *testing.T) {
+	const ModuleName = "module"
+
+	mm, _ := initModuleManager(t, "test_delete_module")
+
+	module := mm.GetModule(ModuleName)
+	require.NotNil(t, module, "Should get module %s", ModuleName)
+	err := mm.RegisterModuleHooks(module, map[string]string{})
+	require.NoError(t, err, "Should register module hooks")
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		module.State.SetPhase(CanRunHelm)
+		module.State.SetLastRunTime(time.Now())
+		module.State.SetLastModuleErr(fmt.Errorf("error"))
+		module.State.SetHelmRevision("1")
+		module.State.SetLastHookErr("hook", fmt.Errorf("error"))
+		// DeleteModule resets the state.
+		module.State.Reset()
+	}()
+
+	for {
+		select {
+		case <-done:
+			status := mm.GetModuleStatus(ModuleName)
+			require.NotNil(t, status)
+			assert.Equal(t, Startup, status.Phase, "state should be reset")
+			assert.Empty(t, status.LastError)
+			assert.Empty(t, status.HelmRevision)
+			assert.Empty(t, status.HookErrors)
+			return
+		default:
+			require.NotNil(t, mm.GetModuleStatus(ModuleName))
+		}
+	}
+}
+
 // Modules in test_run_module_hook path:
 // - 000-update-kube-module-config with hook that add and remove some config values.
 // - 000-update-module-dynamic with hook that add some dynamic values.
@@ -1389,3 +1431,87 @@ func valuesPatchesFromYAML(t *testing.T, patches string) []utils.ValuesPatch {
 
 	return []utils.ValuesPatch{*res}
 }
+
+func Test_ModuleManager_ValidateKubeConfig_Deprecations(t *testing.T) {
+	mm := initModuleManagerLight(t, "config_deprecations")
+
+	kubeConfig, err := kube_config_manager.ParseConfigMapData(map[string]string{
+		"global":     "paramString: val1\n",
+		"testModule": "paramNumber: 10\nparamBool: true\n",
+	})
+	require.NoError(t, err)
+
+	deprecations, err := mm.ValidateKubeConfig(kubeConfig)
+	require.NoError(t, err, "renamed settings should be moved before validation")
+	require.Len(t, deprecations, 3)
+	require.Equal(t, "'global.paramString' is renamed to 'global.paramStr'", deprecations[0].String())
+	require.Equal(t, "'testModule.paramNumber' is renamed to 'testModule.paramNum'", deprecations[1].String())
+	require.Equal(t, "'testModule.paramBool' is deprecated: Use paramNum instead.", deprecations[2].String())
+
+	_, err = mm.HandleNewKubeConfig(kubeConfig)
+	require.NoError(t, err)
+	require.Len(t, mm.ConfigDeprecations("global"), 1)
+	require.Len(t, mm.ConfigDeprecations("test-module"), 2)
+
+	// Input config should not be modified.
+	require.Contains(t, kubeConfig.Modules["test-module"].Values["testModule"], "paramNumber")
+
+	// Module config values should be migrated.
+	mod := mm.GetModule("test-module")
+	require.Equal(t, 10.0, mod.ConfigValues()["testModule"].(map[string]interface{})["paramNum"])
+}
+
+// savedConfigValues records module config values saved by the ModuleManager.
+type savedConfigValues struct {
+	kube_config_manager.KubeConfigManager
+	modules map[string]utils.Values
+}
+
+func (s *savedConfigValues) SaveModuleConfigValues(moduleName string, values utils.Values) error {
+	s.modules[moduleName] = values
+	return nil
+}
+
+func Test_ModuleManager_SaveConvertedConfigValues(t *testing.T) {
+	saved := &savedConfigValues{modules: map[string]utils.Values{}}
+	mm := NewModuleManager()
+	mm.WithKubeConfigManager(saved)
+	mm.ConversionRegistry().SetLatestVersion("converted-module", 2)
+	mm.ConversionRegistry().AddSteps("converted-module", 2, []conversion.Step{
+		{Move: &conversion.MoveStep{From: "oldParam", To: "newParam"}},
+	})
+
+	kubeConfig, err := kube_config_manager.ParseConfigMapData(map[string]string{
+		"convertedModule":        "oldParam: val1\n",
+		"convertedModuleEnabled": "true",
+		"otherModule":            "oldParam: val1\n",
+	})
+	require.NoError(t, err)
+
+	// Values are converted, but not saved by HandleNewKubeConfig.
+	_, err = mm.HandleNewKubeConfig(kubeConfig)
+	require.NoError(t, err)
+	require.Empty(t, saved.modules)
+	require.Equal(t, map[string]interface{}{"newParam": "val1"}, mm.ModuleConfigValues("converted-module")["convertedModule"])
+	require.Equal(t, map[string]interface{}{"oldParam": "val1"}, kubeConfig.Modules["converted-module"].Values["convertedModule"], "input config should not be modified")
+
+	mm.SaveConvertedConfigValues()
+	require.Len(t, saved.modules, 1, "only converted module should be saved")
+	require.Equal(t, map[string]interface{}{"newParam": "val1"}, saved.modules["converted-module"]["convertedModule"])
+
+	// Saved values are not saved again.
+	saved.modules = map[string]utils.Values{}
+	mm.SaveConvertedConfigValues()
+	require.Empty(t, saved.modules)
+
+	// Values saved with the latest version are not converted and saved again on the next ConfigMap event.
+	kubeConfig, err = kube_config_manager.ParseConfigMapData(map[string]string{
+		"convertedModule":              "newParam: val1\n",
+		"convertedModuleConfigVersion": "2\n",
+	})
+	require.NoError(t, err)
+	_, err = mm.HandleNewKubeConfig(kubeConfig)
+	require.NoError(t, err)
+	mm.SaveConvertedConfigValues()
+	require.Empty(t, saved.modules)
+}
@@ -3,6 +3,7 @@ package module_manager
 import (
 	"fmt"
 	"sync"
+	"time"
 )
 
 type ModuleRunPhase string
@@ -22,10 +23,17 @@ const (
 	CanRunHelm ModuleRunPhase = "CanRunHelm"
 )
 
+// ModuleState is changed by tasks of the module. Fields reported in the module status
+// should be changed with setters, so the status can be read from other goroutines.
 type ModuleState struct {
 	Enabled              bool
 	Phase                ModuleRunPhase
 	LastModuleErr        error
+	LastRunTime          time.Time
+	LastSuccessTime      time.Time
+	HelmRevision         string
+	HelmChecksum         string
+	statusLock           sync.RWMutex
 	hookErrors           map[string]error
 	hookErrorsLock       sync.RWMutex
 	synchronizationState *SynchronizationState
@@ -40,9 +48,88 @@ func NewModuleState() *ModuleState {
 }
 
 func (s *ModuleState) Synchronization() *SynchronizationState {
+	s.statusLock.RLock()
+	defer s.statusLock.RUnlock()
 	return s.synchronizationState
 }
 
+// Reset clears the state after the module is deleted.
+func (s *ModuleState) Reset() {
+	s.statusLock.Lock()
+	s.Enabled = false
+	s.Phase = Startup
+	s.LastModuleErr = nil
+	s.LastRunTime = time.Time{}
+	s.LastSuccessTime = time.Time{}
+	s.HelmRevision = ""
+	s.HelmChecksum = ""
+	s.synchronizationState = NewSynchronizationState()
+	s.statusLock.Unlock()
+
+	s.hookErrorsLock.Lock()
+	s.hookErrors = make(map[string]error)
+	s.hookErrorsLock.Unlock()
+}
+
+func (s *ModuleState) SetPhase(phase ModuleRunPhase) {
+	s.statusLock.Lock()
+	defer s.statusLock.Unlock()
+	s.Phase = phase
+}
+
+func (s *ModuleState) SetLastModuleErr(err error) {
+	s.statusLock.Lock()
+	defer s.statusLock.Unlock()
+	s.LastModuleErr = err
+}
+
+func (s *ModuleState) SetLastRunTime(t time.Time) {
+	s.statusLock.Lock()
+	defer s.statusLock.Unlock()
+	s.LastRunTime = t
+}
+
+func (s *ModuleState) SetLastSuccessTime(t time.Time) {
+	s.statusLock.Lock()
+	defer s.statusLock.Unlock()
+	s.LastSuccessTime = t
+}
+
+func (s *ModuleState) SetHelmRevision(revision string) {
+	s.statusLock.Lock()
+	defer s.statusLock.Unlock()
+	s.HelmRevision = revision
+}
+
+func (s *ModuleState) SetHelmChecksum(checksum string) {
+	s.statusLock.Lock()
+	defer s.statusLock.Unlock()
+	s.HelmChecksum = checksum
+}
+
+// moduleStateSnapshot is a copy of the module state fields reported in the module status.
+type moduleStateSnapshot struct {
+	Phase           ModuleRunPhase
+	LastModuleErr   error
+	LastRunTime     time.Time
+	LastSuccessTime time.Time
+	HelmRevision    string
+	HelmChecksum    string
+}
+
+func (s *ModuleState) snapshot() moduleStateSnapshot {
+	s.statusLock.RLock()
+	defer s.statusLock.RUnlock()
+	return moduleStateSnapshot{
+		Phase:           s.Phase,
+		LastModuleErr:   s.LastModuleErr,
+		LastRunTime:     s.LastRunTime,
+		LastSuccessTime: s.LastSuccessTime,
+		HelmRevision:    s.HelmRevision,
+		HelmChecksum:    s.HelmChecksum,
+	}
+}
+
 // SetLastHookErr saves error from hook.
 func (s *ModuleState) SetLastHookErr(hookName string, err error) {
 	s.hookErrorsLock.Lock()
@@ -64,3 +151,17 @@ func (s *ModuleState) GetLastHookErr() error {
 
 	return nil
 }
+
+// HookErrors returns a copy of non-empty hook errors.
+func (s *ModuleState) HookErrors() map[string]error {
+	s.hookErrorsLock.RLock()
+	defer s.hookErrorsLock.RUnlock()
+
+	res := make(map[string]error)
+	for name, err := range s.hookErrors {
+		if err != nil {
+			res[name] = err
+		}
+	}
+	return res
+}
@@ -0,0 +1,165 @@
+package module_manager
+
+import (
+	"time"
+
+	"github.com/flant/addon-operator/pkg/kube_config_manager"
+)
+
+// Sources of a module enabled state.
+const (
+	EnabledSourceDefault       = "default"
+	EnabledSourceCommonStatic  = "common values.yaml"
+	EnabledSourceStatic        = "values.yaml"
+	EnabledSourceConfigMap     = "ConfigMap"
+	EnabledSourceDynamic       = "dynamic"
+	EnabledSourceEnabledScript = "enabled script"
+)
+
+// Synchronization states of module hooks with kubernetes bindings.
+const (
+	SynchronizationNotNeeded  = "not needed"
+	SynchronizationNotStarted = "not started"
+	SynchronizationInProgress = "in progress"
+	SynchronizationDone       = "done"
+)
+
+// ModuleStatus is a summary of the module state.
+type ModuleStatus struct {
+	Name            string            `json:"name"`
+	Enabled         bool              `json:"enabled"`
+	EnabledSource   string            `json:"enabledSource"`
+	Phase           ModuleRunPhase    `json:"phase"`
+	Synchronization string            `json:"synchronization"`
+	LastRunTime     *time.Time        `json:"lastRunTime,omitempty"`
+	LastSuccessTime *time.Time        `json:"lastSuccessTime,omitempty"`
+	LastError       string            `json:"lastError,omitempty"`
+	HookErrors      map[string]string `json:"hookErrors,omitempty"`
+	HelmRevision    string            `json:"helmRevision,omitempty"`
+	HelmChecksum    string            `json:"helmChecksum,omitempty"`
+	// Deprecations are warnings about deprecated settings in the ConfigMap.
+	Deprecations []string `json:"deprecations,omitempty"`
+}
+
+// GetModuleStatus returns a summary of the module state. It returns nil for unknown modules.
+func (mm *moduleManager) GetModuleStatus(moduleName string) *ModuleStatus {
+	module := mm.GetModule(moduleName)
+	if module == nil {
+		return nil
+	}
+
+	state := module.State.snapshot()
+	status := &ModuleStatus{
+		Name:            module.Name,
+		Enabled:         mm.IsModuleEnabled(module.Name),
+		EnabledSource:   mm.ModuleEnabledSource(module.Name),
+		Phase:           state.Phase,
+		Synchronization: module.synchronizationStatus(state.Phase),
+		HelmRevision:    state.HelmRevision,
+		HelmChecksum:    state.HelmChecksum,
+	}
+	if !state.LastRunTime.IsZero() {
+		lastRunTime := state.LastRunTime
+		status.LastRunTime = &lastRunTime
+	}
+	if !state.LastSuccessTime.IsZero() {
+		lastSuccessTime := state.LastSuccessTime
+		status.LastSuccessTime = &lastSuccessTime
+	}
+	if state.LastModuleErr != nil {
+		status.LastError = state.LastModuleErr.Error()
+	}
+	hookErrors := module.State.HookErrors()
+	if len(hookErrors) > 0 {
+		status.HookErrors = make(map[string]string, len(hookErrors))
+		for hookName, err := range hookErrors {
+			status.HookErrors[hookName] = err.Error()
+		}
+	}
+	for _, d := range mm.ConfigDeprecations(module.Name) {
+		status.Deprecations = append(status.Deprecations, d.String())
+	}
+	return status
+}
+
+// ModuleEnabledSource returns a source of the last decision about module enabled state.
+func (mm *moduleManager) ModuleEnabledSource(moduleName string) string {
+	mm.valuesLayersLock.RLock()
+	defer mm.valuesLayersLock.RUnlock()
+
+	if source, has := mm.enabledSources[moduleName]; has {
+		return source
+	}
+	if source, has := mm.enabledByConfigSources[moduleName]; has {
+		return source
+	}
+	return EnabledSourceDefault
+}
+
+// calculateEnabledSourcesByConfig returns a source of the enabled flag for each module
+// in the same order as mergeEnabled applies flags in calculateEnabledModulesByConfig.
+func (mm *moduleManager) calculateEnabledSourcesByConfig(config *kube_config_manager.KubeConfig) map[string]string {
+	sources := make(map[string]string)
+
+	for _, module := range mm.modules.List() {
+		source := EnabledSourceDefault
+		if module.CommonStaticConfig.IsEnabled != nil {
+			source = EnabledSourceCommonStatic
+		}
+		if module.StaticConfig.IsEnabled != nil {
+			source = EnabledSourceStatic
+		}
+		if config != nil {
+			if kubeConfig, hasKubeConfig := config.Modules[module.Name]; hasKubeConfig && kubeConfig.IsEnabled != nil {
+				source = EnabledSourceConfigMap
+			}
+		}
+		sources[module.Name] = source
+	}
+
+	return sources
+}
+
+// calculateEnabledSources refines sources by config with dynamic enabled flags and enabled scripts results.
+func (mm *moduleManager) calculateEnabledSources(enabledByDynamic []string, enabledModules []string) map[string]string {
+	sources := make(map[string]string)
+
+	enabledByDynamicMap := make(map[string]struct{}, len(enabledByDynamic))
+	for _, name := range enabledByDynamic {
+		enabledByDynamicMap[name] = struct{}{}
+	}
+	enabledMap := make(map[string]struct{}, len(enabledModules))
+	for _, name := range enabledModules {
+		enabledMap[name] = struct{}{}
+	}
+
+	for _, moduleName := range mm.modules.NamesInOrder() {
+		if mm.dynamicEnabled[moduleName] != nil {
+			sources[moduleName] = EnabledSourceDynamic
+		}
+		_, isEnabledByDynamic := enabledByDynamicMap[moduleName]
+		_, isEnabled := enabledMap[moduleName]
+		if isEnabledByDynamic && !isEnabled {
+			sources[moduleName] = EnabledSourceEnabledScript
+		}
+	}
+
+	return sources
+}
+
+// synchronizationStatus describes Synchronization progress for module hooks with kubernetes bindings.
+func (m *Module) synchronizationStatus(phase ModuleRunPhase) string {
+	if !m.SynchronizationNeeded() {
+		return SynchronizationNotNeeded
+	}
+	switch phase {
+	case Startup, OnStartupDone, QueueSynchronizationTasks:
+		return SynchronizationNotStarted
+	case WaitForSynchronization:
+		if m.State.Synchronization().IsComplete() {
+			return SynchronizationDone
+		}
+		return SynchronizationInProgress
+	}
+	return SynchronizationDone
+}
@@ -52,3 +52,23 @@ func ReadOpenAPIFiles(openApiDir string) (configSchemaBytes, valuesSchemaBytes [
 
 	return
 }
+
+// readOpenAPISchemas reads config-values.yaml and values.yaml from the specified directory
+// and inlines '$ref' to shared schema files.
+func (mm *moduleManager) readOpenAPISchemas(openApiDir string) (configSchemaBytes, valuesSchemaBytes []byte, err error) {
+	configSchemaBytes, valuesSchemaBytes, err = ReadOpenAPIFiles(openApiDir)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	storage := mm.ValuesValidator.SchemaStorage
+	configSchemaBytes, err = storage.ResolveRefs(configSchemaBytes, filepath.Join(openApiDir, "config-values.yaml"))
+	if err != nil {
+		return nil, nil, err
+	}
+	valuesSchemaBytes, err = storage.ResolveRefs(valuesSchemaBytes, filepath.Join(openApiDir, "values.yaml"))
+	if err != nil {
+		return nil, nil, err
+	}
+	return configSchemaBytes, valuesSchemaBytes, nil
+}
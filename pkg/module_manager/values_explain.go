@@ -0,0 +1,330 @@
+package module_manager
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/flant/addon-operator/pkg/app"
+	"github.com/flant/addon-operator/pkg/utils"
+	"github.com/flant/addon-operator/pkg/values/validation"
+)
+
+// ValuesSource describes a value leaf and a layer that provided it.
+type ValuesSource struct {
+	Path   string      `json:"path"`
+	Value  interface{} `json:"value"`
+	Source string      `json:"source"`
+}
+
+// ValuesExplanation is a list of value leaves with their sources sorted by path.
+type ValuesExplanation []ValuesSource
+
+// String renders explanation as a list of "path: value  # source" lines.
+func (e ValuesExplanation) String() string {
+	var b strings.Builder
+	for _, item := range e {
+		value, err := json.Marshal(item.Value)
+		if err != nil {
+			value = []byte(fmt.Sprintf("%v", item.Value))
+		}
+		b.WriteString(fmt.Sprintf("%s: %s  # %s\n", item.Path, value, item.Source))
+	}
+	return b.String()
+}
+
+// NamedValuesLayer is a layer for MergeLayers with a human-readable source description.
+type NamedValuesLayer struct {
+	Source string
+	Layer  interface{}
+}
+
+// valuesExplainer replays merging of values layers and remembers
+// the last layer that changed each leaf.
+type valuesExplainer struct {
+	values  utils.Values
+	sources map[string]string
+}
+
+func newValuesExplainer() *valuesExplainer {
+	return &valuesExplainer{
+		values:  utils.Values{},
+		sources: make(map[string]string),
+	}
+}
+
+// Merge merges layers the same way as MergeLayers does.
+// Leaves defined in the map layer are attributed to this layer even if
+// values are equal to previous ones. Transformers are attributed only
+// for leaves they have changed.
+func (e *valuesExplainer) Merge(layers ...NamedValuesLayer) {
+	for _, layer := range layers {
+		switch l := layer.Layer.(type) {
+		case utils.Values:
+			e.mergeMap(layer.Source, l)
+		case map[string]interface{}:
+			e.mergeMap(layer.Source, l)
+		case string:
+			tmp, _ := utils.NewValuesFromBytes([]byte(l))
+			e.mergeMap(layer.Source, tmp)
+		default:
+			before := flattenValues(e.values)
+			e.values = MergeLayers(e.values, l)
+			for path, value := range flattenValues(e.values) {
+				prev, has := before[path]
+				if !has || !reflect.DeepEqual(prev, value) {
+					e.sources[path] = layer.Source
+				}
+			}
+		}
+	}
+}
+
+func (e *valuesExplainer) mergeMap(source string, layer utils.Values) {
+	e.values = MergeLayers(e.values, layer)
+	for path := range flattenValues(layer) {
+		e.sources[path] = source
+	}
+}
+
+// ApplyPatches applies operations one by one and attributes affected leaves
+// to a source returned by sourceFn.
+func (e *valuesExplainer) ApplyPatches(patches []utils.ValuesPatch, sourceFn func(path string) string) error {
+	for _, patch := range patches {
+		for _, op := range patch.Operations {
+			var err error
+			e.values, _, err = utils.ApplyValuesPatch(e.values, utils.ValuesPatch{Operations: []*utils.ValuesPatchOperation{op}}, utils.IgnoreNonExistentPaths)
+			if err != nil {
+				return err
+			}
+			if op.Op == utils.TestOp {
+				continue
+			}
+			// Drop sources of leaves under the removed path, a later layer may add them again.
+			if op.Op == "remove" {
+				for path := range e.sources {
+					if path == op.Path || strings.HasPrefix(path, op.Path+"/") {
+						delete(e.sources, path)
+					}
+				}
+				continue
+			}
+			for path := range flattenValues(e.values) {
+				if path == op.Path || strings.HasPrefix(path, op.Path+"/") {
+					e.sources[path] = sourceFn(op.Path)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// Explanation returns leaves of the resulting values with their sources.
+func (e *valuesExplainer) Explanation() ValuesExplanation {
+	leaves := flattenValues(e.values)
+
+	paths := make([]string, 0, len(leaves))
+	for path := range leaves {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	res := make(ValuesExplanation, 0, len(paths))
+	for _, path := range paths {
+		source, has := e.sources[path]
+		if !has {
+			source = "unknown"
+		}
+		res = append(res, ValuesSource{Path: path, Value: leaves[path], Source: source})
+	}
+	return res
+}
+
+// flattenValues returns a map of JSON pointers to leaf values. Arrays and empty maps are leaves.
+func flattenValues(values map[string]interface{}) map[string]interface{} {
+	res := make(map[string]interface{})
+	flattenValuesInto(res, "", values)
+	return res
+}
+
+func flattenValuesInto(res map[string]interface{}, prefix string, values map[string]interface{}) {
+	for key, value := range values {
+		path := prefix + "/" + escapeJSONPointer(key)
+		if nested, ok := value.(map[string]interface{}); ok && len(nested) > 0 {
+			flattenValuesInto(res, path, nested)
+			continue
+		}
+		res[path] = value
+	}
+}
+
+func escapeJSONPointer(key string) string {
+	return strings.NewReplacer("~", "~0", "/", "~1").Replace(key)
+}
+
+// explainGlobalValues replays layers from GlobalValues.
+// Caller should hold valuesLayersLock.
+func (mm *moduleManager) explainGlobalValues() (*valuesExplainer, error) {
+	e := newValuesExplainer()
+	e.Merge(
+		NamedValuesLayer{"init", utils.Values{"global": map[string]interface{}{}}},
+		NamedValuesLayer{staticValuesSource(mm.ModulesDir), mm.commonStaticValues.Global()},
+		NamedValuesLayer{filepath.Join(mm.GlobalHooksDir, "openapi", "config-values.yaml") + " default", &ApplyDefaultsForGlobal{validation.ConfigValuesSchema, mm.ValuesValidator}},
+		NamedValuesLayer{configMapSource(utils.GlobalValuesKey), mm.kubeGlobalConfigValues},
+		NamedValuesLayer{filepath.Join(mm.GlobalHooksDir, "openapi", "values.yaml") + " default", &ApplyDefaultsForGlobal{validation.ValuesSchema, mm.ValuesValidator}},
+	)
+
+	err := e.ApplyPatches(mm.globalDynamicValuesPatches, func(path string) string {
+		return patchSource(valuesPatchSource(mm.globalDynamicValuesPatchesSources, path))
+	})
+	if err != nil {
+		return nil, fmt.Errorf("apply global patch error: %s", err)
+	}
+	return e, nil
+}
+
+// ExplainGlobalValues returns global values leaves with layers that provided them.
+func (mm *moduleManager) ExplainGlobalValues() (ValuesExplanation, error) {
+	mm.valuesLayersLock.RLock()
+	defer mm.valuesLayersLock.RUnlock()
+
+	e, err := mm.explainGlobalValues()
+	if err != nil {
+		return nil, err
+	}
+	return e.Explanation(), nil
+}
+
+// ExplainModuleValues returns module values leaves with layers that provided them.
+// Layers are replayed in the same order as in Module.Values.
+func (mm *moduleManager) ExplainModuleValues(moduleName string) (ValuesExplanation, error) {
+	m := mm.GetModule(moduleName)
+	if m == nil {
+		return nil, fmt.Errorf("module '%s' not found", moduleName)
+	}
+
+	mm.valuesLayersLock.RLock()
+	defer mm.valuesLayersLock.RUnlock()
+
+	e, err := mm.explainGlobalValues()
+	if err != nil {
+		return nil, fmt.Errorf("construct module values: %s", err)
+	}
+
+	e.Merge(
+		NamedValuesLayer{"init", utils.Values{m.ValuesKey(): map[string]interface{}{}}},
+		NamedValuesLayer{staticValuesSource(mm.ModulesDir), m.CommonStaticConfig.Values},
+		NamedValuesLayer{filepath.Join(m.Path, ValuesFileName), m.StaticConfig.Values},
+		NamedValuesLayer{filepath.Join(m.Path, "openapi", "config-values.yaml") + " default", &ApplyDefaultsForModule{
+			m.ValuesKey(),
+			validation.ConfigValuesSchema,
+			mm.ValuesValidator,
+		}},
+		NamedValuesLayer{configMapSource(m.ValuesKey()), mm.kubeModulesConfigValues[m.Name]},
+		NamedValuesLayer{filepath.Join(m.Path, "openapi", "values.yaml") + " default", &ApplyDefaultsForModule{
+			m.ValuesKey(),
+			validation.ValuesSchema,
+			mm.ValuesValidator,
+		}},
+	)
+
+	err = e.ApplyPatches(mm.modulesDynamicValuesPatches[m.Name], func(path string) string {
+		return patchSource(valuesPatchSource(mm.modulesDynamicValuesPatchesSources[m.Name], path))
+	})
+	if err != nil {
+		return nil, fmt.Errorf("construct module values: apply module patch error: %s", err)
+	}
+
+	e.Merge(NamedValuesLayer{"enabled modules list", utils.Values{"global": map[string]interface{}{
+		"enabledModules": mm.enabledModules,
+	}}})
+
+	return e.Explanation(), nil
+}
+
+// recordGlobalValuesPatchSource remembers a hook that patched global values paths.
+// Sources for paths that are no longer in compacted patches are dropped.
+func (mm *moduleManager) recordGlobalValuesPatchSource(hookName string, valuesPatch utils.ValuesPatch) {
+	mm.valuesLayersLock.Lock()
+	defer mm.valuesLayersLock.Unlock()
+
+	for _, op := range valuesPatch.Operations {
+		mm.globalDynamicValuesPatchesSources[op.Path] = hookName
+	}
+	pruneValuesPatchSources(mm.globalDynamicValuesPatchesSources, mm.globalDynamicValuesPatches)
+}
+
+// recordModuleValuesPatchSource remembers a hook that patched module values paths.
+// Sources for paths that are no longer in compacted patches are dropped.
+func (mm *moduleManager) recordModuleValuesPatchSource(moduleName string, hookName string, valuesPatch utils.ValuesPatch) {
+	mm.valuesLayersLock.Lock()
+	defer mm.valuesLayersLock.Unlock()
+
+	if _, has := mm.modulesDynamicValuesPatchesSources[moduleName]; !has {
+		mm.modulesDynamicValuesPatchesSources[moduleName] = make(map[string]string)
+	}
+	for _, op := range valuesPatch.Operations {
+		mm.modulesDynamicValuesPatchesSources[moduleName][op.Path] = hookName
+	}
+	pruneValuesPatchSources(mm.modulesDynamicValuesPatchesSources[moduleName], mm.modulesDynamicValuesPatches[moduleName])
+}
+
+// pruneValuesPatchSources deletes sources for paths that are not operation paths
+// or their parents in patches.
+func pruneValuesPatchSources(sources map[string]string, patches []utils.ValuesPatch) {
+	for path := range sources {
+		used := false
+		for _, patch := range patches {
+			for _, op := range patch.Operations {
+				if op.Path == path || strings.HasPrefix(op.Path, path+"/") {
+					used = true
+					break
+				}
+			}
+			if used {
+				break
+			}
+		}
+		if !used {
+			delete(sources, path)
+		}
+	}
+}
+
+// valuesPatchSource returns a hook name for the path or for the nearest parent path.
+// Compaction may split operations into operations for subpaths, e.g. for "merge".
+func valuesPatchSource(sources map[string]string, path string) string {
+	for {
+		if hookName, has := sources[path]; has {
+			return hookName
+		}
+		idx := strings.LastIndex(path, "/")
+		if idx <= 0 {
+			return ""
+		}
+		path = path[:idx]
+	}
+}
+
+func staticValuesSource(modulesDir string) string {
+	paths := splitToPaths(modulesDir)
+	files := make([]string, 0, len(paths))
+	for _, path := range paths {
+		files = append(files, filepath.Join(path, ValuesFileName))
+	}
+	return strings.Join(files, ",")
+}
+
+func configMapSource(key string) string {
+	return fmt.Sprintf("ConfigMap/%s key '%s'", app.ConfigMapName, key)
+}
+
+func patchSource(hookName string) string {
+	if hookName == "" {
+		return "values patch"
+	}
+	return fmt.Sprintf("values patch from hook '%s'", hookName)
+}
@@ -0,0 +1,80 @@
+package module_manager
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+
+	"github.com/flant/addon-operator/pkg/utils"
+)
+
+func Test_ModuleManager_ExplainModuleValues(t *testing.T) {
+	g := NewWithT(t)
+
+	_, res := initModuleManager(t, "load_values__common_and_module_and_kube")
+	mm := res.moduleManager
+
+	patch := utils.ValuesPatch{Operations: []*utils.ValuesPatchOperation{
+		{Op: "add", Path: "/withValues1/c", Value: 42.0},
+	}}
+	mm.UpdateModuleDynamicValuesPatches("with-values-1", patch)
+	mm.recordModuleValuesPatchSource("with-values-1", "hook.sh", patch)
+
+	explanation, err := mm.ExplainModuleValues("with-values-1")
+	g.Expect(err).ShouldNot(HaveOccurred())
+
+	sources := map[string]ValuesSource{}
+	for _, item := range explanation {
+		sources[item.Path] = item
+	}
+
+	g.Expect(sources).Should(HaveKey("/global/a"))
+	g.Expect(sources["/global/a"].Source).Should(HaveSuffix("values.yaml"))
+	g.Expect(sources["/global/param1"].Source).Should(Equal("ConfigMap/addon-operator key 'global'"))
+	g.Expect(sources["/global/param1"].Value).Should(Equal("qwe"))
+	g.Expect(sources["/withValues1/a"].Source).Should(HaveSuffix("000-with-values-1/values.yaml"))
+	g.Expect(sources["/withValues1/a"].Value).Should(Equal(1.0))
+	g.Expect(sources["/withValues1/c"].Source).Should(Equal("values patch from hook 'hook.sh'"))
+	g.Expect(sources["/withValues1/c"].Value).Should(Equal(42.0))
+	g.Expect(sources["/global/enabledModules"].Source).Should(Equal("enabled modules list"))
+
+	// Explanation should describe the same values as Module.Values.
+	values, err := mm.GetModule("with-values-1").Values()
+	g.Expect(err).ShouldNot(HaveOccurred())
+	g.Expect(len(explanation)).Should(Equal(len(flattenValues(values))))
+}
+
+func Test_ModuleManager_ExplainModuleValues_PrunedSources(t *testing.T) {
+	g := NewWithT(t)
+
+	_, res := initModuleManager(t, "load_values__common_and_module_and_kube")
+	mm := res.moduleManager
+
+	patch := utils.ValuesPatch{Operations: []*utils.ValuesPatchOperation{
+		{Op: "add", Path: "/withValues1/obj", Value: map[string]interface{}{}},
+		{Op: "add", Path: "/withValues1/obj/c", Value: 42.0},
+	}}
+	mm.UpdateModuleDynamicValuesPatches("with-values-1", patch)
+	mm.recordModuleValuesPatchSource("with-values-1", "first.sh", patch)
+
+	// The parent path is reset by another hook, operations for subpaths are compacted.
+	patch = utils.ValuesPatch{Operations: []*utils.ValuesPatchOperation{
+		{Op: "add", Path: "/withValues1/obj", Value: map[string]interface{}{}},
+		{Op: "merge", Path: "/withValues1/obj", Value: map[string]interface{}{"d": 1.0}},
+	}}
+	mm.UpdateModuleDynamicValuesPatches("with-values-1", patch)
+	mm.recordModuleValuesPatchSource("with-values-1", "second.sh", patch)
+
+	g.Expect(mm.modulesDynamicValuesPatchesSources["with-values-1"]).Should(Equal(map[string]string{
+		"/withValues1/obj": "second.sh",
+	}))
+
+	explanation, err := mm.ExplainModuleValues("with-values-1")
+	g.Expect(err).ShouldNot(HaveOccurred())
+
+	sources := map[string]string{}
+	for _, item := range explanation {
+		sources[item.Path] = item.Source
+	}
+	g.Expect(sources["/withValues1/obj/d"]).Should(Equal("values patch from hook 'second.sh'"))
+}
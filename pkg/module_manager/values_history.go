@@ -0,0 +1,137 @@
+package module_manager
+
+import (
+	"sync"
+	"time"
+
+	. "github.com/flant/shell-operator/pkg/hook/binding_context"
+	. "github.com/flant/shell-operator/pkg/hook/types"
+
+	"github.com/flant/addon-operator/pkg/app"
+	"github.com/flant/addon-operator/pkg/utils"
+)
+
+// ValuesHistoryRecord describes a values patch stored by a hook.
+type ValuesHistoryRecord struct {
+	Time     time.Time         `json:"time"`
+	Module   string            `json:"module,omitempty"`
+	Hook     string            `json:"hook"`
+	Binding  string            `json:"binding"`
+	Patch    utils.ValuesPatch `json:"patch"`
+	Checksum string            `json:"checksum"`
+}
+
+// ValuesHistory is a bounded list of values patches. Oldest records are dropped first.
+type ValuesHistory struct {
+	m       sync.RWMutex
+	size    int
+	records []ValuesHistoryRecord
+}
+
+func NewValuesHistory(size int) *ValuesHistory {
+	return &ValuesHistory{
+		size:    size,
+		records: make([]ValuesHistoryRecord, 0),
+	}
+}
+
+// Add stores a record and drops the oldest records if history is full.
+func (h *ValuesHistory) Add(record ValuesHistoryRecord) {
+	h.m.Lock()
+	defer h.m.Unlock()
+
+	if h.size <= 0 {
+		return
+	}
+
+	h.records = append(h.records, record)
+	if len(h.records) > h.size {
+		h.records = h.records[len(h.records)-h.size:]
+	}
+}
+
+// List returns a copy of records, oldest first.
+func (h *ValuesHistory) List() []ValuesHistoryRecord {
+	h.m.RLock()
+	defer h.m.RUnlock()
+
+	res := make([]ValuesHistoryRecord, len(h.records))
+	copy(res, h.records)
+	return res
+}
+
+// GlobalValuesHistory returns stored patches for global values.
+func (mm *moduleManager) GlobalValuesHistory() []ValuesHistoryRecord {
+	return mm.globalValuesHistory.List()
+}
+
+// ModuleValuesHistory returns stored patches for module values.
+func (mm *moduleManager) ModuleValuesHistory(moduleName string) []ValuesHistoryRecord {
+	mm.valuesLayersLock.RLock()
+	defer mm.valuesLayersLock.RUnlock()
+
+	history, has := mm.modulesValuesHistory[moduleName]
+	if !has {
+		return []ValuesHistoryRecord{}
+	}
+	return history.List()
+}
+
+// WithValuesHistoryHandler sets a callback that is called for each new values history record.
+func (mm *moduleManager) WithValuesHistoryHandler(handler func(record ValuesHistoryRecord)) {
+	mm.valuesHistoryHandler = handler
+}
+
+// recordGlobalValuesPatch stores history record and sources for a patch saved by a global hook.
+func (mm *moduleManager) recordGlobalValuesPatch(hookName string, binding string, valuesPatch utils.ValuesPatch, newValues utils.Values) {
+	mm.recordGlobalValuesPatchSource(hookName, valuesPatch)
+
+	record := newValuesHistoryRecord("", hookName, binding, valuesPatch, newValues)
+	mm.globalValuesHistory.Add(record)
+
+	if mm.valuesHistoryHandler != nil {
+		mm.valuesHistoryHandler(record)
+	}
+}
+
+// recordModuleValuesPatch stores history record and sources for a patch saved by a module hook.
+func (mm *moduleManager) recordModuleValuesPatch(moduleName string, hookName string, binding string, valuesPatch utils.ValuesPatch, newValues utils.Values) {
+	mm.recordModuleValuesPatchSource(moduleName, hookName, valuesPatch)
+
+	record := newValuesHistoryRecord(moduleName, hookName, binding, valuesPatch, newValues)
+
+	mm.valuesLayersLock.Lock()
+	history, has := mm.modulesValuesHistory[moduleName]
+	if !has {
+		history = NewValuesHistory(app.ValuesHistorySize)
+		mm.modulesValuesHistory[moduleName] = history
+	}
+	mm.valuesLayersLock.Unlock()
+
+	history.Add(record)
+
+	if mm.valuesHistoryHandler != nil {
+		mm.valuesHistoryHandler(record)
+	}
+}
+
+func newValuesHistoryRecord(moduleName string, hookName string, binding string, valuesPatch utils.ValuesPatch, newValues utils.Values) ValuesHistoryRecord {
+	// Checksum error is not critical for history, leave it empty.
+	checksum, _ := newValues.Checksum()
+	return ValuesHistoryRecord{
+		Time:     time.Now(),
+		Module:   moduleName,
+		Hook:     hookName,
+		Binding:  binding,
+		Patch:    valuesPatch,
+		Checksum: checksum,
+	}
+}
+
+// historyBindingName returns a binding name from the binding context or a binding type if there is no context.
+func historyBindingName(bindingType BindingType, bindingContext []BindingContext) string {
+	if len(bindingContext) > 0 && bindingContext[0].Binding != "" {
+		return bindingContext[0].Binding
+	}
+	return string(bindingType)
+}
@@ -0,0 +1,59 @@
+package module_manager
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+
+	"github.com/flant/addon-operator/pkg/utils"
+)
+
+func Test_ValuesHistory_Bounded(t *testing.T) {
+	g := NewWithT(t)
+
+	h := NewValuesHistory(3)
+	for _, hook := range []string{"hook-1", "hook-2", "hook-3", "hook-4", "hook-5"} {
+		h.Add(ValuesHistoryRecord{Hook: hook})
+	}
+
+	records := h.List()
+	g.Expect(records).Should(HaveLen(3))
+	g.Expect(records[0].Hook).Should(Equal("hook-3"))
+	g.Expect(records[2].Hook).Should(Equal("hook-5"))
+}
+
+func Test_ModuleManager_ModuleValuesHistory(t *testing.T) {
+	g := NewWithT(t)
+
+	_, res := initModuleManager(t, "load_values__common_and_module_and_kube")
+	mm := res.moduleManager
+
+	var handled []ValuesHistoryRecord
+	mm.WithValuesHistoryHandler(func(record ValuesHistoryRecord) {
+		handled = append(handled, record)
+	})
+
+	g.Expect(mm.ModuleValuesHistory("with-values-1")).Should(BeEmpty())
+
+	patch := utils.ValuesPatch{Operations: []*utils.ValuesPatchOperation{
+		{Op: "add", Path: "/withValues1/c", Value: 42.0},
+	}}
+	mm.UpdateModuleDynamicValuesPatches("with-values-1", patch)
+	values, err := mm.GetModule("with-values-1").Values()
+	g.Expect(err).ShouldNot(HaveOccurred())
+	mm.recordModuleValuesPatch("with-values-1", "hook.sh", "schedule", patch, values)
+
+	checksum, err := values.Checksum()
+	g.Expect(err).ShouldNot(HaveOccurred())
+
+	records := mm.ModuleValuesHistory("with-values-1")
+	g.Expect(records).Should(HaveLen(1))
+	g.Expect(records[0].Module).Should(Equal("with-values-1"))
+	g.Expect(records[0].Hook).Should(Equal("hook.sh"))
+	g.Expect(records[0].Binding).Should(Equal("schedule"))
+	g.Expect(records[0].Patch).Should(Equal(patch))
+	g.Expect(records[0].Checksum).Should(Equal(checksum))
+	g.Expect(handled).Should(Equal(records))
+
+	g.Expect(mm.GlobalValuesHistory()).Should(BeEmpty())
+}
@@ -0,0 +1,241 @@
+package wasm_hook
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/flant/shell-operator/pkg/kube/object_patch"
+	"github.com/flant/shell-operator/pkg/metric_storage/operation"
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+
+	"github.com/flant/addon-operator/pkg/module_manager/go_hook"
+)
+
+// HostModuleName is a name of the module with host functions.
+const HostModuleName = "addon_operator"
+
+// Results of host functions that accept data from the hook.
+const (
+	resultOK    uint32 = 0
+	resultError uint32 = 1
+)
+
+// hostState is a state of the hook run shared by host functions.
+type hostState struct {
+	input      *HookInput
+	output     *HookOutput
+	errMessage string
+}
+
+type hostStateKey struct{}
+
+// withHostState returns a context for the call of the hook function. Host functions
+// get the state of the current run from the context, so the host module is
+// instantiated once per runtime.
+func withHostState(ctx context.Context, s *hostState) context.Context {
+	return context.WithValue(ctx, hostStateKey{}, s)
+}
+
+func hostStateFrom(ctx context.Context) *hostState {
+	return ctx.Value(hostStateKey{}).(*hostState)
+}
+
+// hostModule defines host functions. They are equivalent to go_hook.HookInput:
+//
+//	log(ptr, len)                                      logs a message
+//	error(ptr, len)                                    sets an error message for the failed hook_run
+//	snapshots() i64                                    snapshots from all binding contexts: {"binding": [{"object": ..., "filterResult": ...}]}
+//	values_get(path_ptr, path_len) i64                 a value by the dot-separated path, empty path for all values
+//	values_set(path_ptr, path_len, ptr, len) i32       sets a value from JSON
+//	values_remove(path_ptr, path_len) i32              removes a value
+//	config_values_get, config_values_set, config_values_remove  the same for config values
+//	metric(ptr, len) i32                               a metric operation as for METRICS_PATH
+//	kubernetes_patch(ptr, len) i32                     an operation as for KUBERNETES_PATCH_PATH
+//
+// Functions returning i32 return 0 on success and 1 if data is invalid. Errors are logged.
+func hostModule(r wazero.Runtime) wazero.HostModuleBuilder {
+	b := r.NewHostModuleBuilder(HostModuleName)
+
+	b.NewFunctionBuilder().WithFunc(func(ctx context.Context, m api.Module, ptr, size uint32) {
+		s := hostStateFrom(ctx)
+		if msg, err := readBytes(m, ptr, size); err == nil {
+			s.input.LogEntry.Info(string(msg))
+		}
+	}).Export("log")
+
+	b.NewFunctionBuilder().WithFunc(func(ctx context.Context, m api.Module, ptr, size uint32) {
+		s := hostStateFrom(ctx)
+		if msg, err := readBytes(m, ptr, size); err == nil {
+			s.errMessage = string(msg)
+		}
+	}).Export("error")
+
+	b.NewFunctionBuilder().WithFunc(func(ctx context.Context, m api.Module) uint64 {
+		s := hostStateFrom(ctx)
+		snapshots, err := extractSnapshots(s.input.BindingContext)
+		if err != nil {
+			s.input.LogEntry.Errorf("snapshots: %v", err)
+			return 0
+		}
+		return s.writePacked(ctx, m, snapshots)
+	}).Export("snapshots")
+
+	exportValues(b, "values_", func(s *hostState) *go_hook.PatchableValues { return s.input.Values })
+	exportValues(b, "config_values_", func(s *hostState) *go_hook.PatchableValues { return s.input.ConfigValues })
+
+	b.NewFunctionBuilder().WithFunc(func(ctx context.Context, m api.Module, ptr, size uint32) uint32 {
+		s := hostStateFrom(ctx)
+		return s.handleData(m, "metric", ptr, size, func(data []byte) error {
+			ops, err := operation.MetricOperationsFromBytes(data)
+			if err != nil {
+				return err
+			}
+			for i := range ops {
+				if ops[i].Group == "" {
+					ops[i].Group = s.input.HookName
+				}
+			}
+			if err := operation.ValidateOperations(ops); err != nil {
+				return err
+			}
+			s.output.Metrics = append(s.output.Metrics, ops...)
+			return nil
+		})
+	}).Export("metric")
+
+	b.NewFunctionBuilder().WithFunc(func(ctx context.Context, m api.Module, ptr, size uint32) uint32 {
+		s := hostStateFrom(ctx)
+		return s.handleData(m, "kubernetes_patch", ptr, size, func(data []byte) error {
+			ops, err := object_patch.ParseOperations(data)
+			if err != nil {
+				return err
+			}
+			s.output.ObjectPatcherOperations = append(s.output.ObjectPatcherOperations, ops...)
+			return nil
+		})
+	}).Export("kubernetes_patch")
+
+	return b
+}
+
+// exportValues defines get, set and remove functions for values with the prefix.
+func exportValues(b wazero.HostModuleBuilder, prefix string, hostValues func(s *hostState) *go_hook.PatchableValues) {
+	b.NewFunctionBuilder().WithFunc(func(ctx context.Context, m api.Module, pathPtr, pathSize uint32) uint64 {
+		s := hostStateFrom(ctx)
+		values := hostValues(s)
+		path, err := readBytes(m, pathPtr, pathSize)
+		if err != nil || values == nil {
+			return 0
+		}
+		if len(path) == 0 {
+			path = []byte("@this")
+		}
+		res, ok := values.GetOk(string(path))
+		if !ok {
+			return 0
+		}
+		return s.writePacked(ctx, m, []byte(res.Raw))
+	}).Export(prefix + "get")
+
+	b.NewFunctionBuilder().WithFunc(func(ctx context.Context, m api.Module, pathPtr, pathSize, ptr, size uint32) uint32 {
+		s := hostStateFrom(ctx)
+		values := hostValues(s)
+		path, err := readBytes(m, pathPtr, pathSize)
+		if err != nil {
+			s.input.LogEntry.Errorf("%sset: %v", prefix, err)
+			return resultError
+		}
+		return s.handleData(m, prefix+"set", ptr, size, func(data []byte) error {
+			if values == nil {
+				return fmt.Errorf("values are not available")
+			}
+			var value interface{}
+			if err := json.Unmarshal(data, &value); err != nil {
+				return err
+			}
+			values.Set(string(path), value)
+			return nil
+		})
+	}).Export(prefix + "set")
+
+	b.NewFunctionBuilder().WithFunc(func(ctx context.Context, m api.Module, pathPtr, pathSize uint32) uint32 {
+		s := hostStateFrom(ctx)
+		values := hostValues(s)
+		return s.handleData(m, prefix+"remove", pathPtr, pathSize, func(path []byte) error {
+			if values == nil {
+				return fmt.Errorf("values are not available")
+			}
+			values.Remove(string(path))
+			return nil
+		})
+	}).Export(prefix + "remove")
+}
+
+// handleData reads data from the hook memory and logs an error from handle.
+func (s *hostState) handleData(m api.Module, name string, ptr, size uint32, handle func(data []byte) error) uint32 {
+	data, err := readBytes(m, ptr, size)
+	if err == nil {
+		err = handle(data)
+	}
+	if err != nil {
+		s.input.LogEntry.Errorf("%s: %v", name, err)
+		return resultError
+	}
+	return resultOK
+}
+
+// writePacked copies data into the memory allocated by the hook and returns packed pointer and length.
+func (s *hostState) writePacked(ctx context.Context, m api.Module, data []byte) uint64 {
+	if len(data) == 0 {
+		return 0
+	}
+	res, err := m.ExportedFunction("alloc").Call(ctx, uint64(len(data)))
+	if err != nil || len(res) != 1 {
+		s.input.LogEntry.Errorf("alloc %d bytes: %v", len(data), err)
+		return 0
+	}
+	ptr := uint32(res[0])
+	if !m.Memory().Write(ptr, data) {
+		s.input.LogEntry.Errorf("alloc returned invalid pointer %d for %d bytes", ptr, len(data))
+		return 0
+	}
+	return uint64(ptr)<<32 | uint64(len(data))
+}
+
+// readBytes returns a copy of the hook memory.
+func readBytes(m api.Module, ptr, size uint32) ([]byte, error) {
+	data, ok := m.Memory().Read(ptr, size)
+	if !ok {
+		return nil, fmt.Errorf("memory range [%d, %d) is out of bounds", ptr, uint64(ptr)+uint64(size))
+	}
+	return append([]byte(nil), data...), nil
+}
+
+// readPacked returns a copy of the hook memory by the packed pointer and length.
+func readPacked(m api.Module, packed uint64) ([]byte, error) {
+	if packed == 0 {
+		return nil, nil
+	}
+	return readBytes(m, uint32(packed>>32), uint32(packed))
+}
+
+// extractSnapshots merges snapshots from the binding context list.
+func extractSnapshots(bindingContext []byte) ([]byte, error) {
+	snapshots := make(map[string][]json.RawMessage)
+	if len(bindingContext) > 0 {
+		var contexts []struct {
+			Snapshots map[string][]json.RawMessage `json:"snapshots"`
+		}
+		if err := json.Unmarshal(bindingContext, &contexts); err != nil {
+			return nil, err
+		}
+		for _, bc := range contexts {
+			for name, items := range bc.Snapshots {
+				snapshots[name] = append(snapshots[name], items...)
+			}
+		}
+	}
+	return json.Marshal(snapshots)
+}
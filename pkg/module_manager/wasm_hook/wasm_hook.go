@@ -0,0 +1,290 @@
+// Package wasm_hook runs hooks compiled to WebAssembly in the operator process.
+//
+// A WASM hook is a '*.wasm' file in the hooks directory. The module should export:
+//
+//	memory                  linear memory
+//	alloc(size i32) i32     allocates size bytes for data passed from the host
+//	hook_config() i64       returns the hook configuration (YAML or JSON, as for shell hooks)
+//	hook_run() i32          runs the hook, returns 0 on success
+//
+// Data is passed between the host and the hook as JSON. Strings and buffers returned by
+// host functions and by hook_config are packed into i64: pointer in high 32 bits,
+// length in low 32 bits. Zero means no data. Host functions are imported from the
+// 'addon_operator' module, see host.go. WASI is available for logging to stdout and stderr,
+// but the hook has no access to the filesystem, environment variables or network.
+package wasm_hook
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/flant/shell-operator/pkg/kube/object_patch"
+	"github.com/flant/shell-operator/pkg/metric_storage/operation"
+	log "github.com/sirupsen/logrus"
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/imports/wasi_snapshot_preview1"
+
+	"github.com/flant/addon-operator/pkg/module_manager/go_hook"
+)
+
+const (
+	// FileExt is an extension of WASM hook files.
+	FileExt = ".wasm"
+
+	// DefaultMemoryLimit is a memory limit for hooks without settings.memory.
+	DefaultMemoryLimit int64 = 128 * 1024 * 1024
+
+	// ConfigTimeout is a timeout for the hook_config call.
+	ConfigTimeout = 10 * time.Second
+
+	// MaxOutputSize limits stdout and stderr of one call. The hook fails if it writes more.
+	MaxOutputSize = 1024 * 1024
+
+	pageSize = 64 * 1024
+)
+
+// ErrMemoryLimitExceeded is returned if the hook failed when its memory could not grow any more.
+var ErrMemoryLimitExceeded = errors.New("memory limit exceeded")
+
+// ErrOutputLimitExceeded is returned if the hook wrote more than MaxOutputSize bytes to stdout or stderr.
+var ErrOutputLimitExceeded = errors.New("output limit exceeded")
+
+// compilationCache is shared between runtimes, so the module is compiled once on load.
+var compilationCache = wazero.NewCompilationCache()
+
+// Hook is a compiled WASM hook.
+type Hook struct {
+	Path string
+	code []byte
+
+	// runtimes are runtimes with the compiled module by the memory limit in pages.
+	// The memory limit is a runtime setting, so a runtime is created for each limit
+	// used by the hook, usually one for hook_config and one for hook_run.
+	runtimes     map[uint32]*hookRuntime
+	runtimesLock sync.Mutex
+}
+
+// hookRuntime is a runtime with WASI and host modules and the compiled hook module.
+// The hook module is instantiated for each call.
+type hookRuntime struct {
+	runtime  wazero.Runtime
+	compiled wazero.CompiledModule
+}
+
+// HookInput contains data for the hook run. Values and ConfigValues are patched by the hook.
+type HookInput struct {
+	// HookName is a default group for metrics.
+	HookName string
+	// BindingContext is a versioned binding context list in JSON.
+	BindingContext []byte
+	Values         *go_hook.PatchableValues
+	ConfigValues   *go_hook.PatchableValues
+	LogEntry       *log.Entry
+	// MemoryLimit is a limit of the hook linear memory in bytes. DefaultMemoryLimit is used if zero.
+	MemoryLimit int64
+}
+
+// HookOutput contains metrics and Kubernetes operations from the hook run.
+type HookOutput struct {
+	Metrics                 []operation.MetricOperation
+	ObjectPatcherOperations []object_patch.Operation
+}
+
+// Load reads and compiles the WASM hook. Compilation errors are returned on load,
+// so a broken hook is not registered.
+func Load(path string) (*Hook, error) {
+	code, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	h := &Hook{Path: path, code: code, runtimes: make(map[uint32]*hookRuntime)}
+	hr, err := h.runtime(memoryLimitPages(DefaultMemoryLimit))
+	if err != nil {
+		return nil, fmt.Errorf("compile '%s': %v", path, err)
+	}
+	for _, name := range []string{"alloc", "hook_config", "hook_run"} {
+		if _, has := hr.compiled.ExportedFunctions()[name]; !has {
+			return nil, fmt.Errorf("compile '%s': function '%s' is not exported", path, name)
+		}
+	}
+
+	return h, nil
+}
+
+func memoryLimitPages(memoryLimit int64) uint32 {
+	if memoryLimit <= 0 {
+		memoryLimit = DefaultMemoryLimit
+	}
+	return uint32((memoryLimit + pageSize - 1) / pageSize)
+}
+
+// runtime returns a runtime with the memory limit and the compiled module. It is created on the first use.
+func (h *Hook) runtime(pages uint32) (*hookRuntime, error) {
+	h.runtimesLock.Lock()
+	defer h.runtimesLock.Unlock()
+
+	if hr, has := h.runtimes[pages]; has {
+		return hr, nil
+	}
+
+	ctx := context.Background()
+	cfg := wazero.NewRuntimeConfig().
+		WithCompilationCache(compilationCache).
+		WithMemoryLimitPages(pages).
+		WithCloseOnContextDone(true)
+	r := wazero.NewRuntimeWithConfig(ctx, cfg)
+
+	compiled, err := r.CompileModule(ctx, h.code)
+	if err == nil {
+		_, err = wasi_snapshot_preview1.Instantiate(ctx, r)
+	}
+	if err == nil {
+		_, err = hostModule(r).Instantiate(ctx)
+	}
+	if err != nil {
+		_ = r.Close(ctx)
+		return nil, err
+	}
+
+	hr := &hookRuntime{runtime: r, compiled: compiled}
+	h.runtimes[pages] = hr
+	return hr, nil
+}
+
+// Config returns the hook configuration from hook_config.
+func (h *Hook) Config(ctx context.Context) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, ConfigTimeout)
+	defer cancel()
+
+	state := &hostState{
+		input:  &HookInput{LogEntry: log.WithField("hook", h.Path)},
+		output: &HookOutput{},
+	}
+	var config []byte
+	err := h.call(ctx, state, "hook_config", func(m api.Module, res uint64) error {
+		var err error
+		config, err = readPacked(m, res)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// Run calls hook_run. ctx deadline is a time budget for the hook.
+func (h *Hook) Run(ctx context.Context, input *HookInput) (*HookOutput, error) {
+	output := &HookOutput{}
+	state := &hostState{input: input, output: output}
+
+	err := h.call(ctx, state, "hook_run", func(_ api.Module, res uint64) error {
+		if uint32(res) != 0 {
+			if state.errMessage != "" {
+				return errors.New(state.errMessage)
+			}
+			return fmt.Errorf("hook_run returned %d", uint32(res))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return output, nil
+}
+
+// call instantiates the module in the runtime with the memory limit and calls the exported function.
+// The module is instantiated for each call, so calls do not share memory.
+func (h *Hook) call(ctx context.Context, state *hostState, name string, handle func(m api.Module, res uint64) error) error {
+	hr, err := h.runtime(memoryLimitPages(state.input.MemoryLimit))
+	if err != nil {
+		return err
+	}
+
+	stdout := &limitedBuffer{limit: MaxOutputSize}
+	stderr := &limitedBuffer{limit: MaxOutputSize}
+	defer func() {
+		logLines(state.input.LogEntry.WithField("output", "stdout"), &stdout.buf)
+		logLines(state.input.LogEntry.WithField("output", "stderr"), &stderr.buf)
+	}()
+
+	ctx = withHostState(ctx, state)
+	modCfg := wazero.NewModuleConfig().
+		WithName("").
+		WithStdout(stdout).
+		WithStderr(stderr).
+		WithStartFunctions("_initialize")
+	m, err := hr.runtime.InstantiateModule(ctx, hr.compiled, modCfg)
+	if err != nil {
+		return contextError(ctx, err)
+	}
+	defer m.Close(context.Background())
+
+	res, err := m.ExportedFunction(name).Call(ctx)
+	if err != nil {
+		err = contextError(ctx, err)
+		if ctx.Err() == nil && memoryExhausted(m) {
+			return fmt.Errorf("%w: %v", ErrMemoryLimitExceeded, err)
+		}
+		return err
+	}
+	if stdout.exceeded || stderr.exceeded {
+		return fmt.Errorf("%w: more than %d bytes are written to stdout or stderr", ErrOutputLimitExceeded, MaxOutputSize)
+	}
+	if len(res) != 1 {
+		return fmt.Errorf("%s returned %d results, expected 1", name, len(res))
+	}
+
+	return handle(m, res[0])
+}
+
+// limitedBuffer is a buffer for the hook output. Writes over the limit are dropped
+// and return an error, so the hook gets an error from fd_write.
+type limitedBuffer struct {
+	buf      bytes.Buffer
+	limit    int
+	exceeded bool
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if b.buf.Len()+len(p) > b.limit {
+		b.exceeded = true
+		n, _ := b.buf.Write(p[:b.limit-b.buf.Len()])
+		return n, ErrOutputLimitExceeded
+	}
+	return b.buf.Write(p)
+}
+
+// memoryExhausted returns true if the memory of the failed module has reached its maximum size.
+// memory.grow returns -1 to the hook in this case and the hook fails to allocate memory.
+// The module is not used after the failure, so the memory is grown to check the limit.
+func memoryExhausted(m api.Module) bool {
+	mem := m.Memory()
+	if mem == nil {
+		return false
+	}
+	_, ok := mem.Grow(1)
+	return !ok
+}
+
+// contextError returns the context error if the module is closed because of the context.
+func contextError(ctx context.Context, err error) error {
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return fmt.Errorf("%w: %v", ctxErr, err)
+	}
+	return err
+}
+
+func logLines(logEntry *log.Entry, buf *bytes.Buffer) {
+	scanner := bufio.NewScanner(buf)
+	for scanner.Scan() {
+		logEntry.Info(scanner.Text())
+	}
+}
@@ -0,0 +1,355 @@
+package wasm_hook
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/flant/addon-operator/pkg/module_manager/go_hook"
+	"github.com/flant/addon-operator/pkg/utils"
+)
+
+// Imported functions in the test module.
+const (
+	fnValuesGet = iota
+	fnValuesSet
+	fnMetric
+	fnError
+	fnSnapshots
+	fnKubernetesPatch
+)
+
+// Data segments in the test module memory.
+var testData = []struct {
+	offset uint32
+	value  string
+}{
+	{16, `{"configVersion":"v1","beforeHelm":10}`},
+	{128, "test.replicas"},
+	{160, "test.copy"},
+	{192, "test.snapshots"},
+	{224, `{"name":"pods_total","set":2}`},
+	{288, `{"operation":"Delete","kind":"Pod","namespace":"default","name":"pod-1"}`},
+	{384, "boom"},
+}
+
+func dataAt(offset uint32) (int64, int64) {
+	for _, d := range testData {
+		if d.offset == offset {
+			return int64(d.offset), int64(len(d.value))
+		}
+	}
+	panic("no data")
+}
+
+func uleb(v uint64) []byte {
+	res := []byte{}
+	for {
+		b := byte(v & 0x7f)
+		v >>= 7
+		if v != 0 {
+			res = append(res, b|0x80)
+			continue
+		}
+		return append(res, b)
+	}
+}
+
+func sleb(v int64) []byte {
+	res := []byte{}
+	for {
+		b := byte(v & 0x7f)
+		v >>= 7
+		if (v == 0 && b&0x40 == 0) || (v == -1 && b&0x40 != 0) {
+			return append(res, b)
+		}
+		res = append(res, b|0x80)
+	}
+}
+
+func vec(items ...[]byte) []byte {
+	res := uleb(uint64(len(items)))
+	for _, item := range items {
+		res = append(res, item...)
+	}
+	return res
+}
+
+func name(s string) []byte {
+	return append(uleb(uint64(len(s))), s...)
+}
+
+func section(id byte, content []byte) []byte {
+	return append(append([]byte{id}, uleb(uint64(len(content)))...), content...)
+}
+
+func concat(parts ...[]byte) []byte {
+	res := []byte{}
+	for _, p := range parts {
+		res = append(res, p...)
+	}
+	return res
+}
+
+func i32Const(v int64) []byte { return append([]byte{0x41}, sleb(v)...) }
+func i64Const(v int64) []byte { return append([]byte{0x42}, sleb(v)...) }
+func call(idx int) []byte     { return append([]byte{0x10}, uleb(uint64(idx))...) }
+
+// dataArgs pushes pointer and length of the data segment.
+func dataArgs(offset uint32) []byte {
+	ptr, size := dataAt(offset)
+	return concat(i32Const(ptr), i32Const(size))
+}
+
+// packedArgs pushes pointer and length from the packed i64 in the local 0.
+func packedArgs() []byte {
+	return []byte{0x20, 0x00, 0x42, 0x20, 0x88, 0xA7, 0x20, 0x00, 0xA7}
+}
+
+// testModule assembles a module with host imports, alloc, hook_config and hook_run with the body.
+func testModule(runBody []byte) []byte {
+	const (
+		i32 = 0x7F
+		i64 = 0x7E
+	)
+	funcType := func(params []byte, results []byte) []byte {
+		return concat([]byte{0x60}, uleb(uint64(len(params))), params, uleb(uint64(len(results))), results)
+	}
+	types := section(1, vec(
+		funcType(nil, []byte{i64}),                        // 0: hook_config, snapshots
+		funcType(nil, []byte{i32}),                        // 1: hook_run
+		funcType([]byte{i32}, []byte{i32}),                // 2: alloc
+		funcType([]byte{i32, i32}, []byte{i64}),           // 3: values_get
+		funcType([]byte{i32, i32, i32, i32}, []byte{i32}), // 4: values_set
+		funcType([]byte{i32, i32}, []byte{i32}),           // 5: metric, kubernetes_patch
+		funcType([]byte{i32, i32}, nil),                   // 6: error
+	))
+	importFunc := func(field string, typeIdx byte) []byte {
+		return concat(name(HostModuleName), name(field), []byte{0x00, typeIdx})
+	}
+	imports := section(2, vec(
+		importFunc("values_get", 3),
+		importFunc("values_set", 4),
+		importFunc("metric", 5),
+		importFunc("error", 6),
+		importFunc("snapshots", 0),
+		importFunc("kubernetes_patch", 5),
+	))
+	functions := section(3, vec([]byte{2}, []byte{0}, []byte{1}))
+	memory := section(5, vec([]byte{0x00, 0x01}))
+	globals := section(6, vec(concat([]byte{i32, 0x01}, i32Const(1024), []byte{0x0B})))
+	exportFunc := func(field string, idx byte) []byte {
+		return concat(name(field), []byte{0x00, idx})
+	}
+	exports := section(7, vec(
+		concat(name("memory"), []byte{0x02, 0x00}),
+		exportFunc("alloc", 6),
+		exportFunc("hook_config", 7),
+		exportFunc("hook_run", 8),
+	))
+
+	body := func(locals []byte, code []byte) []byte {
+		b := concat(locals, code, []byte{0x0B})
+		return append(uleb(uint64(len(b))), b...)
+	}
+	// alloc returns the heap pointer and moves it by size.
+	alloc := body(vec(), []byte{0x23, 0x00, 0x23, 0x00, 0x20, 0x00, 0x6A, 0x24, 0x00})
+	configPtr, configLen := dataAt(16)
+	hookConfig := body(vec(), i64Const(configPtr<<32|configLen))
+	hookRun := body(vec([]byte{0x01, i64}), runBody)
+	code := section(10, vec(alloc, hookConfig, hookRun))
+
+	segments := make([][]byte, 0, len(testData))
+	for _, d := range testData {
+		segments = append(segments, concat([]byte{0x00}, i32Const(int64(d.offset)), []byte{0x0B}, name(d.value)))
+	}
+	data := section(11, vec(segments...))
+
+	return concat([]byte{0x00, 0x61, 0x73, 0x6D, 0x01, 0x00, 0x00, 0x00}, types, imports, functions, memory, globals, exports, code, data)
+}
+
+func loadTestHook(t *testing.T, runBody []byte) *Hook {
+	path := filepath.Join(t.TempDir(), "hook.wasm")
+	if err := os.WriteFile(path, testModule(runBody), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	h, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return h
+}
+
+func testInput(t *testing.T) *HookInput {
+	values, err := go_hook.NewPatchableValues(utils.Values{"test": map[string]interface{}{"replicas": 3}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	configValues, err := go_hook.NewPatchableValues(utils.Values{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &HookInput{
+		HookName: "001-test/hooks/hook.wasm",
+		BindingContext: []byte(`[{"binding":"pods","snapshots":{"pods":[
+{"object":{"kind":"Pod","metadata":{"name":"pod-1"}},"filterResult":"pod-1"}]}}]`),
+		Values:       values,
+		ConfigValues: configValues,
+		LogEntry:     log.WithField("test", t.Name()),
+	}
+}
+
+func Test_WasmHook_Config(t *testing.T) {
+	g := NewWithT(t)
+
+	h := loadTestHook(t, i32Const(0))
+	config, err := h.Config(context.Background())
+	g.Expect(err).ShouldNot(HaveOccurred())
+	g.Expect(string(config)).To(Equal(`{"configVersion":"v1","beforeHelm":10}`))
+}
+
+func Test_WasmHook_Load_MissingExports(t *testing.T) {
+	g := NewWithT(t)
+
+	path := filepath.Join(t.TempDir(), "hook.wasm")
+	g.Expect(os.WriteFile(path, []byte{0x00, 0x61, 0x73, 0x6D, 0x01, 0x00, 0x00, 0x00}, 0o644)).To(Succeed())
+	_, err := Load(path)
+	g.Expect(err).Should(HaveOccurred())
+	g.Expect(err.Error()).To(ContainSubstring("'alloc' is not exported"))
+}
+
+func Test_WasmHook_Run(t *testing.T) {
+	g := NewWithT(t)
+
+	h := loadTestHook(t, concat(
+		// copy test.replicas to test.copy
+		dataArgs(128), call(fnValuesGet), []byte{0x21, 0x00},
+		dataArgs(160), packedArgs(), call(fnValuesSet), []byte{0x1A},
+		// save snapshots to test.snapshots
+		call(fnSnapshots), []byte{0x21, 0x00},
+		dataArgs(192), packedArgs(), call(fnValuesSet), []byte{0x1A},
+		dataArgs(224), call(fnMetric), []byte{0x1A},
+		dataArgs(288), call(fnKubernetesPatch), []byte{0x1A},
+		i32Const(0),
+	))
+
+	input := testInput(t)
+	output, err := h.Run(context.Background(), input)
+	g.Expect(err).ShouldNot(HaveOccurred())
+
+	patches := input.Values.GetPatches()
+	g.Expect(patches).To(HaveLen(2))
+	g.Expect(patches[0]).To(Equal(&utils.ValuesPatchOperation{Op: "add", Path: "/test/copy", Value: 3.0}))
+	g.Expect(patches[1].Path).To(Equal("/test/snapshots"))
+	g.Expect(patches[1].Value).To(Equal(map[string]interface{}{
+		"pods": []interface{}{map[string]interface{}{
+			"object":       map[string]interface{}{"kind": "Pod", "metadata": map[string]interface{}{"name": "pod-1"}},
+			"filterResult": "pod-1",
+		}},
+	}))
+
+	g.Expect(output.Metrics).To(HaveLen(1))
+	g.Expect(output.Metrics[0].Name).To(Equal("pods_total"))
+	g.Expect(output.Metrics[0].Group).To(Equal("001-test/hooks/hook.wasm"))
+	g.Expect(output.ObjectPatcherOperations).To(HaveLen(1))
+}
+
+func Test_WasmHook_Run_Error(t *testing.T) {
+	g := NewWithT(t)
+
+	h := loadTestHook(t, concat(dataArgs(384), call(fnError), i32Const(1)))
+	_, err := h.Run(context.Background(), testInput(t))
+	g.Expect(err).Should(HaveOccurred())
+	g.Expect(err.Error()).To(Equal("boom"))
+}
+
+func Test_WasmHook_Run_Timeout(t *testing.T) {
+	g := NewWithT(t)
+
+	// loop br 0 end
+	h := loadTestHook(t, concat([]byte{0x03, 0x40, 0x0C, 0x00, 0x0B}, i32Const(0)))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err := h.Run(ctx, testInput(t))
+	g.Expect(err).Should(HaveOccurred())
+	g.Expect(errors.Is(err, context.DeadlineExceeded)).To(BeTrue(), err.Error())
+	g.Expect(time.Since(start)).To(BeNumerically("<", 5*time.Second))
+}
+
+func Test_WasmHook_Run_MemoryLimit(t *testing.T) {
+	g := NewWithT(t)
+
+	// loop (br_if 0 (i32.ne (memory.grow (i32.const 1)) (i32.const -1))) end unreachable
+	h := loadTestHook(t, concat(
+		[]byte{0x03, 0x40}, i32Const(1), []byte{0x40, 0x00}, i32Const(-1), []byte{0x47, 0x0D, 0x00, 0x0B},
+		[]byte{0x00},
+	))
+
+	input := testInput(t)
+	input.MemoryLimit = 4 * pageSize
+	_, err := h.Run(context.Background(), input)
+	g.Expect(err).Should(HaveOccurred())
+	g.Expect(errors.Is(err, ErrMemoryLimitExceeded)).To(BeTrue(), err.Error())
+}
+
+func Test_WasmHook_Run_TrapWithLargeMemory(t *testing.T) {
+	g := NewWithT(t)
+
+	// drop (memory.grow (i32.const 18)) unreachable: 19 of 20 pages are used, but memory can grow.
+	h := loadTestHook(t, concat(
+		i32Const(18), []byte{0x40, 0x00, 0x1A},
+		[]byte{0x00},
+	))
+
+	input := testInput(t)
+	input.MemoryLimit = 20 * pageSize
+	_, err := h.Run(context.Background(), input)
+	g.Expect(err).Should(HaveOccurred())
+	g.Expect(errors.Is(err, ErrMemoryLimitExceeded)).To(BeFalse(), err.Error())
+}
+
+func Test_WasmHook_Run_CompiledOnce(t *testing.T) {
+	g := NewWithT(t)
+
+	h := loadTestHook(t, concat(dataArgs(224), call(fnMetric), []byte{0x1A}, i32Const(0)))
+
+	// Runs share the compiled module, but not the state.
+	for i := 0; i < 2; i++ {
+		output, err := h.Run(context.Background(), testInput(t))
+		g.Expect(err).ShouldNot(HaveOccurred())
+		g.Expect(output.Metrics).To(HaveLen(1))
+	}
+	_, err := h.Config(context.Background())
+	g.Expect(err).ShouldNot(HaveOccurred())
+	g.Expect(h.runtimes).To(HaveLen(1))
+
+	input := testInput(t)
+	input.MemoryLimit = 4 * pageSize
+	_, err = h.Run(context.Background(), input)
+	g.Expect(err).ShouldNot(HaveOccurred())
+	g.Expect(h.runtimes).To(HaveLen(2))
+}
+
+func Test_limitedBuffer(t *testing.T) {
+	g := NewWithT(t)
+
+	b := &limitedBuffer{limit: 8}
+	n, err := b.Write([]byte("hello"))
+	g.Expect(err).ShouldNot(HaveOccurred())
+	g.Expect(n).To(Equal(5))
+	g.Expect(b.exceeded).To(BeFalse())
+
+	n, err = b.Write([]byte(" world"))
+	g.Expect(errors.Is(err, ErrOutputLimitExceeded)).To(BeTrue())
+	g.Expect(n).To(Equal(3))
+	g.Expect(b.exceeded).To(BeTrue())
+	g.Expect(b.buf.String()).To(Equal("hello wo"))
+}
@@ -0,0 +1,162 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"time"
+)
+
+// GiveUpAction is an action for a task when all retry attempts are failed.
+type GiveUpAction string
+
+const (
+	// GiveUpRetry keeps retrying the task with the maximum delay.
+	GiveUpRetry GiveUpAction = "Retry"
+	// GiveUpSkip removes the task from the queue. For ModuleRun task
+	// the module is marked as failed and converge continues.
+	GiveUpSkip GiveUpAction = "Skip"
+)
+
+const (
+	DefaultRetryInitialDelay = 5 * time.Second
+	DefaultRetryMaxDelay     = 32 * time.Second
+)
+
+// RetryPolicy describes delays between retries of a failed task and an action when attempts are exhausted.
+type RetryPolicy struct {
+	// MaxAttempts is a number of task runs before give up. 0 means unlimited attempts.
+	MaxAttempts int
+	// InitialDelay is a delay after the first failure. It doubles after each next failure.
+	InitialDelay time.Duration
+	// MaxDelay limits the delay between attempts.
+	MaxDelay time.Duration
+	// Jitter is a maximum random duration added to each delay.
+	Jitter time.Duration
+	// GiveUpAction is an action when MaxAttempts is reached. Default is GiveUpSkip
+	// if MaxAttempts is set and GiveUpRetry otherwise. GiveUpRetry with MaxAttempts is invalid.
+	GiveUpAction GiveUpAction
+}
+
+// Delay returns a delay before the next attempt. failureCount is a number of failures before the current one.
+func (p *RetryPolicy) Delay(failureCount int) time.Duration {
+	initialDelay := p.InitialDelay
+	if initialDelay <= 0 {
+		initialDelay = DefaultRetryInitialDelay
+	}
+	maxDelay := p.MaxDelay
+	if maxDelay <= 0 {
+		maxDelay = DefaultRetryMaxDelay
+	}
+	if maxDelay < initialDelay {
+		maxDelay = initialDelay
+	}
+
+	delay := initialDelay
+	for i := 0; i < failureCount && delay < maxDelay; i++ {
+		delay *= 2
+	}
+	if delay > maxDelay {
+		delay = maxDelay
+	}
+
+	if p.Jitter > 0 {
+		delay += time.Duration(rand.Int63n(int64(p.Jitter)))
+	}
+	return delay
+}
+
+// ShouldGiveUp returns true if the task failed attempts times and should not be retried.
+func (p *RetryPolicy) ShouldGiveUp(attempts int) bool {
+	if p.MaxAttempts <= 0 || p.GiveUpAction == GiveUpRetry {
+		return false
+	}
+	return attempts >= p.MaxAttempts
+}
+
+// Validate returns an error if the policy has invalid fields or MaxAttempts
+// is set with GiveUpRetry: tasks are retried forever and MaxAttempts has no effect.
+func (p *RetryPolicy) Validate() error {
+	if p.MaxAttempts < 0 {
+		return fmt.Errorf("maxAttempts should not be negative, got %d", p.MaxAttempts)
+	}
+	switch p.GiveUpAction {
+	case "", GiveUpSkip:
+	case GiveUpRetry:
+		if p.MaxAttempts > 0 {
+			return fmt.Errorf("maxAttempts has no effect with giveUpAction '%s', use '%s' or remove maxAttempts", GiveUpRetry, GiveUpSkip)
+		}
+	default:
+		return fmt.Errorf("giveUpAction should be one of '%s' or '%s', got '%s'", GiveUpRetry, GiveUpSkip, p.GiveUpAction)
+	}
+	return nil
+}
+
+type retryPolicyJSON struct {
+	MaxAttempts  int          `json:"maxAttempts,omitempty"`
+	InitialDelay string       `json:"initialDelay,omitempty"`
+	MaxDelay     string       `json:"maxDelay,omitempty"`
+	Jitter       string       `json:"jitter,omitempty"`
+	GiveUpAction GiveUpAction `json:"giveUpAction,omitempty"`
+}
+
+// MarshalJSON returns a retry policy in the 'settings.retry' format accepted by UnmarshalJSON.
+func (p RetryPolicy) MarshalJSON() ([]byte, error) {
+	out := retryPolicyJSON{
+		MaxAttempts:  p.MaxAttempts,
+		GiveUpAction: p.GiveUpAction,
+	}
+	if p.InitialDelay > 0 {
+		out.InitialDelay = p.InitialDelay.String()
+	}
+	if p.MaxDelay > 0 {
+		out.MaxDelay = p.MaxDelay.String()
+	}
+	if p.Jitter > 0 {
+		out.Jitter = p.Jitter.String()
+	}
+	return json.Marshal(out)
+}
+
+// UnmarshalJSON parses a retry policy with durations as strings, e.g. "5s".
+func (p *RetryPolicy) UnmarshalJSON(data []byte) error {
+	var in retryPolicyJSON
+	if err := json.Unmarshal(data, &in); err != nil {
+		return err
+	}
+
+	res := RetryPolicy{
+		MaxAttempts:  in.MaxAttempts,
+		GiveUpAction: in.GiveUpAction,
+	}
+	if err := res.Validate(); err != nil {
+		return err
+	}
+	if res.GiveUpAction == "" {
+		res.GiveUpAction = GiveUpRetry
+		if res.MaxAttempts > 0 {
+			res.GiveUpAction = GiveUpSkip
+		}
+	}
+	for _, field := range []struct {
+		name  string
+		value string
+		out   *time.Duration
+	}{
+		{"initialDelay", in.InitialDelay, &res.InitialDelay},
+		{"maxDelay", in.MaxDelay, &res.MaxDelay},
+		{"jitter", in.Jitter, &res.Jitter},
+	} {
+		if field.value == "" {
+			continue
+		}
+		d, err := time.ParseDuration(field.value)
+		if err != nil {
+			return fmt.Errorf("parse %s: %v", field.name, err)
+		}
+		*field.out = d
+	}
+
+	*p = res
+	return nil
+}
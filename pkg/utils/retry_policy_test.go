@@ -0,0 +1,86 @@
+package utils
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	"sigs.k8s.io/yaml"
+)
+
+func Test_RetryPolicy_Delay(t *testing.T) {
+	g := NewWithT(t)
+
+	p := &RetryPolicy{}
+	g.Expect(p.Delay(0)).To(Equal(5 * time.Second))
+	g.Expect(p.Delay(1)).To(Equal(10 * time.Second))
+	g.Expect(p.Delay(2)).To(Equal(20 * time.Second))
+	g.Expect(p.Delay(3)).To(Equal(32 * time.Second))
+	g.Expect(p.Delay(100)).To(Equal(32 * time.Second))
+
+	p = &RetryPolicy{InitialDelay: time.Second, MaxDelay: time.Minute, Jitter: time.Second}
+	for i := 0; i < 10; i++ {
+		d := p.Delay(2)
+		g.Expect(d).To(BeNumerically(">=", 4*time.Second))
+		g.Expect(d).To(BeNumerically("<", 5*time.Second))
+	}
+}
+
+func Test_RetryPolicy_ShouldGiveUp(t *testing.T) {
+	g := NewWithT(t)
+
+	p := &RetryPolicy{MaxAttempts: 3, GiveUpAction: GiveUpSkip}
+	g.Expect(p.ShouldGiveUp(2)).To(BeFalse())
+	g.Expect(p.ShouldGiveUp(3)).To(BeTrue())
+
+	p = &RetryPolicy{MaxAttempts: 3, GiveUpAction: GiveUpRetry}
+	g.Expect(p.ShouldGiveUp(10)).To(BeFalse())
+
+	// Go hooks may set MaxAttempts without GiveUpAction.
+	p = &RetryPolicy{MaxAttempts: 3}
+	g.Expect(p.ShouldGiveUp(3)).To(BeTrue())
+
+	p = &RetryPolicy{GiveUpAction: GiveUpSkip}
+	g.Expect(p.ShouldGiveUp(10)).To(BeFalse())
+}
+
+func Test_RetryPolicy_Unmarshal(t *testing.T) {
+	g := NewWithT(t)
+
+	var p RetryPolicy
+	err := yaml.Unmarshal([]byte(`
+maxAttempts: 5
+initialDelay: 2s
+maxDelay: 1m
+jitter: 500ms
+giveUpAction: Skip
+`), &p)
+	g.Expect(err).ShouldNot(HaveOccurred())
+	g.Expect(p).To(Equal(RetryPolicy{
+		MaxAttempts:  5,
+		InitialDelay: 2 * time.Second,
+		MaxDelay:     time.Minute,
+		Jitter:       500 * time.Millisecond,
+		GiveUpAction: GiveUpSkip,
+	}))
+
+	p = RetryPolicy{}
+	err = yaml.Unmarshal([]byte(`maxAttempts: 1`), &p)
+	g.Expect(err).ShouldNot(HaveOccurred())
+	g.Expect(p.GiveUpAction).To(Equal(GiveUpSkip))
+
+	p = RetryPolicy{}
+	err = yaml.Unmarshal([]byte(`initialDelay: 1s`), &p)
+	g.Expect(err).ShouldNot(HaveOccurred())
+	g.Expect(p.GiveUpAction).To(Equal(GiveUpRetry))
+
+	err = yaml.Unmarshal([]byte("maxAttempts: 3\ngiveUpAction: Retry"), &p)
+	g.Expect(err).Should(HaveOccurred())
+	g.Expect(err.Error()).To(ContainSubstring("maxAttempts has no effect"))
+
+	err = yaml.Unmarshal([]byte(`giveUpAction: Ignore`), &p)
+	g.Expect(err).Should(HaveOccurred())
+
+	err = yaml.Unmarshal([]byte(`initialDelay: five`), &p)
+	g.Expect(err).Should(HaveOccurred())
+}
@@ -0,0 +1,147 @@
+package utils
+
+import (
+	"encoding/base64"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// SecretValueMask replaces values resolved from Secrets and values of sensitive settings
+// in debug output and logs.
+const SecretValueMask = "<secret>"
+
+// SecretTextMinLength is a minimal length of the secret string that is masked inside a text.
+// Shorter secrets are masked only as whole values, so words like 'cluster-admin' in
+// rendered manifests are not broken by a short secret 'admin'.
+const SecretTextMinLength = 8
+
+// secretValues are values resolved from Secrets and string values of sensitive settings.
+// Values are grouped by scope, e.g. a module section, and each scope is replaced
+// when its values are resolved again, so old values are not kept forever.
+var secretValues = struct {
+	sync.RWMutex
+	scopes map[string]map[string]struct{}
+	// set is a union of all scopes.
+	set map[string]struct{}
+}{
+	scopes: make(map[string]map[string]struct{}),
+	set:    make(map[string]struct{}),
+}
+
+// SetSecretValues replaces secret strings of the scope. Empty strings are ignored.
+// Secrets are masked in debug output and logs.
+func SetSecretValues(scope string, values []string) {
+	scopeSet := make(map[string]struct{}, len(values))
+	for _, value := range values {
+		if value != "" {
+			scopeSet[value] = struct{}{}
+		}
+	}
+
+	secretValues.Lock()
+	defer secretValues.Unlock()
+
+	if len(scopeSet) == 0 {
+		if _, has := secretValues.scopes[scope]; !has {
+			return
+		}
+		delete(secretValues.scopes, scope)
+	} else {
+		secretValues.scopes[scope] = scopeSet
+	}
+
+	set := make(map[string]struct{})
+	for _, s := range secretValues.scopes {
+		for value := range s {
+			set[value] = struct{}{}
+		}
+	}
+	secretValues.set = set
+}
+
+// IsSecretValue returns true if the string is registered as a secret.
+func IsSecretValue(value string) bool {
+	secretValues.RLock()
+	defer secretValues.RUnlock()
+	_, has := secretValues.set[value]
+	return has
+}
+
+// RedactSecrets returns a copy of maps and arrays with registered secret strings replaced by the mask.
+// Other types are returned as is.
+func RedactSecrets(value interface{}) interface{} {
+	return redactValue(value, IsSecretValue)
+}
+
+// RedactStrings is like RedactSecrets, but replaces the given strings instead of registered secrets.
+// It is used for values that are not applied yet, e.g. a rejected config.
+func RedactStrings(value interface{}, secrets []string) interface{} {
+	set := make(map[string]struct{}, len(secrets))
+	for _, s := range secrets {
+		if s != "" {
+			set[s] = struct{}{}
+		}
+	}
+	return redactValue(value, func(s string) bool {
+		_, has := set[s]
+		return has
+	})
+}
+
+func redactValue(value interface{}, isSecret func(string) bool) interface{} {
+	switch v := value.(type) {
+	case Values:
+		return Values(redactValue(map[string]interface{}(v), isSecret).(map[string]interface{}))
+	case map[string]interface{}:
+		res := make(map[string]interface{}, len(v))
+		for k, item := range v {
+			res[k] = redactValue(item, isSecret)
+		}
+		return res
+	case []interface{}:
+		res := make([]interface{}, 0, len(v))
+		for _, item := range v {
+			res = append(res, redactValue(item, isSecret))
+		}
+		return res
+	case string:
+		if isSecret(v) {
+			return SecretValueMask
+		}
+	}
+	return value
+}
+
+// RedactSecretsInText replaces registered secret strings and their base64 forms, e.g. in data
+// of rendered Secret manifests, with the mask. Secrets shorter than SecretTextMinLength are
+// not replaced. Longer secrets are replaced first.
+func RedactSecretsInText(text string) string {
+	secretValues.RLock()
+	secrets := make([]string, 0, len(secretValues.set))
+	for s := range secretValues.set {
+		secrets = append(secrets, s)
+	}
+	secretValues.RUnlock()
+
+	return RedactStringsInText(text, secrets)
+}
+
+// RedactStringsInText is like RedactSecretsInText, but replaces the given strings instead of registered secrets.
+func RedactStringsInText(text string, secrets []string) string {
+	forms := make([]string, 0, 2*len(secrets))
+	for _, s := range secrets {
+		if len(s) < SecretTextMinLength {
+			continue
+		}
+		forms = append(forms, s, base64.StdEncoding.EncodeToString([]byte(s)))
+	}
+
+	sort.Slice(forms, func(i, j int) bool {
+		return len(forms[i]) > len(forms[j])
+	})
+	for _, s := range forms {
+		text = strings.ReplaceAll(text, s, SecretValueMask)
+	}
+	return text
+}
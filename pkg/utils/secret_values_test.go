@@ -0,0 +1,84 @@
+package utils
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func Test_RedactSecrets(t *testing.T) {
+	g := NewWithT(t)
+
+	SetSecretValues("test/redact", []string{"redact-test-password", ""})
+	defer SetSecretValues("test/redact", nil)
+
+	values := Values{
+		"moduleOne": map[string]interface{}{
+			"password": "redact-test-password",
+			"user":     "admin",
+			"hosts":    []interface{}{"redact-test-password", "host"},
+			"empty":    "",
+		},
+	}
+
+	redacted := RedactSecrets(values).(Values)
+	g.Expect(redacted).To(Equal(Values{
+		"moduleOne": map[string]interface{}{
+			"password": SecretValueMask,
+			"user":     "admin",
+			"hosts":    []interface{}{SecretValueMask, "host"},
+			"empty":    "",
+		},
+	}))
+	// Input values are not modified.
+	g.Expect(values["moduleOne"].(map[string]interface{})["password"]).To(Equal("redact-test-password"))
+
+	g.Expect(values.DebugString()).ShouldNot(ContainSubstring("redact-test-password"))
+	g.Expect(values.DebugString()).Should(ContainSubstring("password: <secret>"))
+
+	g.Expect(RedactSecretsInText(`value "redact-test-password" is not allowed`)).To(Equal(`value "<secret>" is not allowed`))
+}
+
+func Test_RedactSecretsInText(t *testing.T) {
+	g := NewWithT(t)
+
+	SetSecretValues("test/text", []string{"admin", "text-test-password"})
+	defer SetSecretValues("test/text", nil)
+
+	// Short secrets are masked only as whole values.
+	g.Expect(RedactSecrets("admin")).To(Equal(SecretValueMask))
+	g.Expect(RedactSecretsInText("kind: ClusterRole\nname: cluster-admin")).To(Equal("kind: ClusterRole\nname: cluster-admin"))
+
+	// Base64 form in the data of a rendered Secret is masked.
+	manifest := "kind: Secret\ndata:\n  password: dGV4dC10ZXN0LXBhc3N3b3Jk\nstringData:\n  password: text-test-password\n"
+	g.Expect(RedactSecretsInText(manifest)).To(Equal("kind: Secret\ndata:\n  password: <secret>\nstringData:\n  password: <secret>\n"))
+}
+
+func Test_RedactStrings(t *testing.T) {
+	g := NewWithT(t)
+
+	// Strings are masked without registration.
+	secrets := []string{"short", "strings-test-password"}
+	g.Expect(IsSecretValue("strings-test-password")).To(BeFalse())
+	g.Expect(RedactStrings([]interface{}{"short", "other"}, secrets)).To(Equal([]interface{}{SecretValueMask, "other"}))
+	g.Expect(RedactStringsInText(`value "strings-test-password" is short`, secrets)).To(Equal(`value "<secret>" is short`))
+}
+
+func Test_SetSecretValues(t *testing.T) {
+	g := NewWithT(t)
+
+	SetSecretValues("test/scope-one", []string{"scope-test-old"})
+	SetSecretValues("test/scope-two", []string{"scope-test-shared"})
+	g.Expect(IsSecretValue("scope-test-old")).To(BeTrue())
+
+	// Values of the scope are replaced, other scopes are kept.
+	SetSecretValues("test/scope-one", []string{"scope-test-new"})
+	g.Expect(IsSecretValue("scope-test-old")).To(BeFalse())
+	g.Expect(IsSecretValue("scope-test-new")).To(BeTrue())
+	g.Expect(IsSecretValue("scope-test-shared")).To(BeTrue())
+
+	SetSecretValues("test/scope-one", nil)
+	SetSecretValues("test/scope-two", nil)
+	g.Expect(IsSecretValue("scope-test-new")).To(BeFalse())
+	g.Expect(IsSecretValue("scope-test-shared")).To(BeFalse())
+}
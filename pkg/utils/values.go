@@ -108,9 +108,10 @@ func MergeValues(values ...Values) Values {
 	return res
 }
 
-// DebugString returns values as yaml or an error line if dump is failed
+// DebugString returns values as yaml or an error line if dump is failed.
+// Values resolved from Secrets are masked.
 func (v Values) DebugString() string {
-	b, err := v.YamlBytes()
+	b, err := RedactSecrets(v).(Values).YamlBytes()
 	if err != nil {
 		return "bad values: " + err.Error()
 	}
@@ -31,6 +31,7 @@ func NewValuesPatch() *ValuesPatch {
 }
 
 // ToJsonPatch returns a jsonpatch.Patch with all operations.
+// The json-patch library cannot apply "append", "merge" and "increment" operations, use ApplyStrict instead.
 func (p *ValuesPatch) ToJsonPatch() (jsonpatch.Patch, error) {
 	data, err := json.Marshal(p.Operations)
 	if err != nil {
@@ -48,11 +49,31 @@ func (p *ValuesPatch) ToJsonPatch() (jsonpatch.Patch, error) {
 // - "remove" operation errors are not ignored.
 // - absent paths are not ignored.
 func (p *ValuesPatch) ApplyStrict(doc []byte) ([]byte, error) {
-	patch, err := p.ToJsonPatch()
-	if err != nil {
-		return nil, err
+	if !p.hasExtendedOps() {
+		patch, err := p.ToJsonPatch()
+		if err != nil {
+			return nil, err
+		}
+		return patch.Apply(doc)
+	}
+
+	for _, op := range p.Operations {
+		newDoc, err := op.Apply(doc)
+		if err != nil {
+			return nil, err
+		}
+		doc = newDoc
+	}
+	return doc, nil
+}
+
+func (p *ValuesPatch) hasExtendedOps() bool {
+	for _, op := range p.Operations {
+		if isExtendedOp(op.Op) {
+			return true
+		}
 	}
-	return patch.Apply(doc)
+	return false
 }
 
 // Apply calls jsonpatch.Apply to transform an input JSON document.
@@ -60,11 +81,7 @@ func (p *ValuesPatch) ApplyStrict(doc []byte) ([]byte, error) {
 // - errors from "remove" operations are ignored.
 func (p *ValuesPatch) ApplyIgnoreNonExistentPaths(doc []byte) ([]byte, error) {
 	for _, op := range p.Operations {
-		patch, err := op.ToJsonPatch()
-		if err != nil {
-			return nil, err
-		}
-		newDoc, err := patch.Apply(doc)
+		newDoc, err := op.Apply(doc)
 
 		// Ignore errors for remove operation.
 		if op.Op == "remove" && IsNonExistentPathError(err) {
@@ -86,6 +103,8 @@ func (p *ValuesPatch) MergeOperations(src *ValuesPatch) {
 	p.Operations = append(p.Operations, src.Operations...)
 }
 
+// ValuesPatchOperation is a JSON Patch "add" or "remove" operation or
+// one of "append", "merge", "increment" and "test" operations.
 type ValuesPatchOperation struct {
 	Op    string      `json:"op,omitempty"`
 	Path  string      `json:"path,omitempty"`
@@ -219,52 +238,21 @@ func CompactValuesPatches(valuesPatches []ValuesPatch, newValuesPatch ValuesPatc
 
 // CompactPatches modifies an array of existed patch operations according to the new array
 // of patch operations. The rule is: only last operation for the path should be stored.
+// "append", "merge" and "increment" operations are folded with previous operations
+// for the path, "test" operations are dropped. See patchesTree.add for details.
 func CompactPatches(existedOperations []*ValuesPatchOperation, newOperations []*ValuesPatchOperation) ValuesPatch {
-	patchesTree := make(map[string][]*ValuesPatchOperation)
+	patchesTree := make(patchesTree)
 
 	// Fill the map with paths from existed operations.
 	for _, op := range existedOperations {
-		if _, ok := patchesTree[op.Path]; !ok {
-			patchesTree[op.Path] = make([]*ValuesPatchOperation, 0)
+		if op.Op == TestOp {
+			continue
 		}
 		patchesTree[op.Path] = append(patchesTree[op.Path], op)
 	}
 
 	for _, op := range newOperations {
-		// Remove previous operations for subpaths if there is a new operation for the parent path.
-		// Subpaths removing is obvious for the "remove" operation, but not for the "add" operation.
-		// This value in the "add" operation may create new subpaths and
-		// other new patches may depends on these subpaths.
-		// Consider this operations:
-		// {"op":"app", "path":"/obj/settings", "value":{"parent_1":{}} }
-		// {"op":"app", "path":"/obj/settings/parent_1/field1", "value":"foo"}
-		// {"op":"app", "path":"/obj/settings/parent_1/field2", "value":"bar"}
-		// The next operations from the hook may reset /obj/settings in this manner:
-		// {"op":"app", "path":"/obj/settings", "value":{} }
-		// The result without subpaths removing will be this:
-		// {"op":"app", "path":"/obj/settings", "value":{} }
-		// {"op":"app", "path":"/obj/settings/parent_1/field1", "value":"foo"}
-		// {"op":"app", "path":"/obj/settings/parent_1/field2", "value":"bar"}
-		// There are two problems here:
-		// 1. /obj/settings/parent_1/field1 and /obj/settings/parent_1/field2
-		//    were not in the latest operations from the hooks. These subpaths are not actual.
-		// 2. There is no operation for "parent_1" node! Apply will fail.
-		// Subpaths removing is the solution for this problems.
-		if op.Op == "remove" || op.Op == "add" {
-			for subPath := range patchesTree {
-				if len(subPath) > len(op.Path) && strings.HasPrefix(subPath, op.Path+"/") {
-					delete(patchesTree, subPath)
-				}
-			}
-		}
-
-		// Prepare array for the path.
-		if _, ok := patchesTree[op.Path]; !ok {
-			patchesTree[op.Path] = make([]*ValuesPatchOperation, 0)
-		}
-
-		// Only one last operation is stored for the same path.
-		patchesTree[op.Path] = []*ValuesPatchOperation{op}
+		patchesTree.add(op)
 	}
 
 	// Sort paths for proper 'add' sequence
@@ -325,6 +313,9 @@ func ValidateHookValuesPatch(valuesPatch ValuesPatch, permittedRootKey string) e
 		if op.Op == "replace" {
 			return fmt.Errorf("unsupported patch operation '%s': '%s'", op.Op, op.ToString())
 		}
+		if err := op.validateValue(); err != nil {
+			return err
+		}
 
 		pathParts := strings.Split(op.Path, "/")
 		if len(pathParts) > 1 {
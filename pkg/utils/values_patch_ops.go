@@ -0,0 +1,347 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/evanphx/json-patch"
+)
+
+// Operations in addition to JSON Patch "add" and "remove":
+//
+//	{"op":"append", "path":"/module/hosts", "value":"host-1"}
+//	  appends the value to the array. The array is created if the path does not exist.
+//	{"op":"merge", "path":"/module/settings", "value":{"a":1, "b":null}}
+//	  applies the value as a JSON Merge Patch (RFC 7386) to the object. The object is created if the path does not exist.
+//	{"op":"increment", "path":"/module/generation", "value":1}
+//	  adds the number to the number at the path. The path that does not exist is treated as 0.
+//	{"op":"test", "path":"/module/state", "value":"pending"}
+//	  fails the whole patch if the value at the path is not equal to the value.
+//	  A null value means that the path does not exist or has a null value.
+const (
+	AppendOp    = "append"
+	MergeOp     = "merge"
+	IncrementOp = "increment"
+	TestOp      = "test"
+)
+
+// isExtendedOp returns true for operations that are not applied by the json-patch library.
+func isExtendedOp(op string) bool {
+	switch op {
+	case AppendOp, MergeOp, IncrementOp, TestOp:
+		return true
+	}
+	return false
+}
+
+// validateValue checks the value type for extended operations.
+func (op *ValuesPatchOperation) validateValue() error {
+	switch op.Op {
+	case MergeOp:
+		if _, ok := normalizeValue(op.Value).(map[string]interface{}); !ok {
+			return fmt.Errorf("'%s' operation for path '%s' expects an object value", op.Op, op.Path)
+		}
+	case IncrementOp:
+		if _, ok := toFloat64(op.Value); !ok {
+			return fmt.Errorf("'%s' operation for path '%s' expects a number value", op.Op, op.Path)
+		}
+	}
+	return nil
+}
+
+// Apply applies one operation to the JSON document.
+func (op *ValuesPatchOperation) Apply(doc []byte) ([]byte, error) {
+	if !isExtendedOp(op.Op) {
+		patch, err := op.ToJsonPatch()
+		if err != nil {
+			return nil, err
+		}
+		return patch.Apply(doc)
+	}
+
+	if err := op.validateValue(); err != nil {
+		return nil, err
+	}
+
+	var obj interface{}
+	if err := json.Unmarshal(doc, &obj); err != nil {
+		return nil, err
+	}
+	current, exists, err := valueAtPointer(obj, op.Path)
+	if err != nil {
+		return nil, fmt.Errorf("%s operation for path '%s': %v", op.Op, op.Path, err)
+	}
+
+	var newValue interface{}
+	switch op.Op {
+	case TestOp:
+		if !reflect.DeepEqual(current, normalizeValue(op.Value)) {
+			return nil, fmt.Errorf("test operation failed for path '%s'", op.Path)
+		}
+		return doc, nil
+	case AppendOp:
+		items, isArray := current.([]interface{})
+		if exists && current != nil && !isArray {
+			return nil, fmt.Errorf("append operation for path '%s': value is not an array", op.Path)
+		}
+		newValue = append(append(make([]interface{}, 0, len(items)+1), items...), normalizeValue(op.Value))
+	case MergeOp:
+		newValue = mergePatch(current, normalizeValue(op.Value))
+	case IncrementOp:
+		number := 0.0
+		if exists && current != nil {
+			var isNumber bool
+			number, isNumber = toFloat64(current)
+			if !isNumber {
+				return nil, fmt.Errorf("increment operation for path '%s': value is not a number", op.Path)
+			}
+		}
+		delta, _ := toFloat64(op.Value)
+		newValue = number + delta
+	}
+
+	// "add" inserts into arrays, so an existing array item is replaced.
+	setOp := "add"
+	if exists {
+		setOp = "replace"
+	}
+	setPatch, err := json.Marshal([]map[string]interface{}{{"op": setOp, "path": op.Path, "value": newValue}})
+	if err != nil {
+		return nil, err
+	}
+	patch, err := jsonpatch.DecodePatch(setPatch)
+	if err != nil {
+		return nil, err
+	}
+	return patch.Apply(doc)
+}
+
+// valueAtPointer returns the value at the JSON pointer and true if it exists.
+func valueAtPointer(doc interface{}, pointer string) (interface{}, bool, error) {
+	if pointer == "" {
+		return doc, true, nil
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, false, fmt.Errorf("path should start with '/'")
+	}
+	unescaper := strings.NewReplacer("~1", "/", "~0", "~")
+	current := doc
+	for _, token := range strings.Split(pointer[1:], "/") {
+		token = unescaper.Replace(token)
+		switch v := current.(type) {
+		case map[string]interface{}:
+			item, has := v[token]
+			if !has {
+				return nil, false, nil
+			}
+			current = item
+		case []interface{}:
+			idx, err := strconv.Atoi(token)
+			if err != nil || idx < 0 || idx >= len(v) {
+				return nil, false, nil
+			}
+			current = v[idx]
+		default:
+			return nil, false, nil
+		}
+	}
+	return current, true, nil
+}
+
+// mergePatch applies the JSON Merge Patch to the target. The target is not modified.
+func mergePatch(target interface{}, patch interface{}) interface{} {
+	patchObj, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+	targetObj, _ := target.(map[string]interface{})
+	res := make(map[string]interface{}, len(targetObj)+len(patchObj))
+	for k, v := range targetObj {
+		res[k] = v
+	}
+	for k, v := range patchObj {
+		if v == nil {
+			delete(res, k)
+			continue
+		}
+		res[k] = mergePatch(res[k], v)
+	}
+	return res
+}
+
+// normalizeValue converts Go values from hooks to the form of unmarshaled JSON:
+// maps, slices, strings, float64 numbers and bools.
+func normalizeValue(value interface{}) interface{} {
+	switch value.(type) {
+	case nil, string, bool, float64:
+		return value
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return value
+	}
+	var res interface{}
+	if err := json.Unmarshal(data, &res); err != nil {
+		return value
+	}
+	return res
+}
+
+func toFloat64(value interface{}) (float64, bool) {
+	switch v := normalizeValue(value).(type) {
+	case float64:
+		return v, true
+	}
+	return 0, false
+}
+
+// patchesTree stores operations by path for CompactPatches.
+type patchesTree map[string][]*ValuesPatchOperation
+
+// add stores the new operation. Operations for the same path are folded when possible:
+//
+//   - "add" and "remove" replace previous operations for the path and its subpaths.
+//   - "append" to the array from "add" or after "remove" becomes "add" with the resulting array.
+//   - "merge" into the value from "add" or after "remove" becomes "add" with the merged object.
+//     If there are operations for subpaths, "merge" is split into operations for each key,
+//     so subpath operations from the merge patch replace previous ones.
+//   - "increment" of the number from "add" or after "remove" becomes "add" with the sum,
+//     and consecutive "increment" operations are summed.
+//   - "test" is not stored, it is checked when the patch from the hook is applied.
+//
+// Operations that cannot be folded are stored after previous operations for the path.
+func (t patchesTree) add(op *ValuesPatchOperation) {
+	switch op.Op {
+	case TestOp:
+		return
+	case AppendOp, IncrementOp:
+		t.fold(op)
+		return
+	case MergeOp:
+		patch, isObject := normalizeValue(op.Value).(map[string]interface{})
+		if isObject && t.hasSubpaths(op.Path) {
+			t.fold(&ValuesPatchOperation{Op: MergeOp, Path: op.Path, Value: map[string]interface{}{}})
+			t.splitMerge(op.Path, patch)
+			return
+		}
+		t.fold(op)
+		return
+	case "remove", "add":
+		// Remove previous operations for subpaths if there is a new operation for the parent path.
+		// Subpaths removing is obvious for the "remove" operation, but not for the "add" operation.
+		// This value in the "add" operation may create new subpaths and
+		// other new patches may depends on these subpaths.
+		// Consider this operations:
+		// {"op":"app", "path":"/obj/settings", "value":{"parent_1":{}} }
+		// {"op":"app", "path":"/obj/settings/parent_1/field1", "value":"foo"}
+		// {"op":"app", "path":"/obj/settings/parent_1/field2", "value":"bar"}
+		// The next operations from the hook may reset /obj/settings in this manner:
+		// {"op":"app", "path":"/obj/settings", "value":{} }
+		// The result without subpaths removing will be this:
+		// {"op":"app", "path":"/obj/settings", "value":{} }
+		// {"op":"app", "path":"/obj/settings/parent_1/field1", "value":"foo"}
+		// {"op":"app", "path":"/obj/settings/parent_1/field2", "value":"bar"}
+		// There are two problems here:
+		// 1. /obj/settings/parent_1/field1 and /obj/settings/parent_1/field2
+		//    were not in the latest operations from the hooks. These subpaths are not actual.
+		// 2. There is no operation for "parent_1" node! Apply will fail.
+		// Subpaths removing is the solution for this problems.
+		for subPath := range t {
+			if isSubpath(subPath, op.Path) {
+				delete(t, subPath)
+			}
+		}
+	}
+
+	// Only one last operation is stored for the same path.
+	t[op.Path] = []*ValuesPatchOperation{op}
+}
+
+// fold combines the operation with the last operation for the path or stores it after previous operations.
+func (t patchesTree) fold(op *ValuesPatchOperation) {
+	ops := t[op.Path]
+	if len(ops) == 0 {
+		t[op.Path] = []*ValuesPatchOperation{op}
+		return
+	}
+
+	last := ops[len(ops)-1]
+	var folded *ValuesPatchOperation
+	switch {
+	case last.Op == "remove" || last.Op == "add":
+		// Apply the operation to the known value. Stored operations are not modified, they are shared with hook results.
+		var base interface{}
+		if last.Op == "add" {
+			base = normalizeValue(last.Value)
+		}
+		switch op.Op {
+		case AppendOp:
+			items, isArray := base.([]interface{})
+			if base == nil || isArray {
+				folded = &ValuesPatchOperation{Op: "add", Path: op.Path, Value: append(append(make([]interface{}, 0, len(items)+1), items...), normalizeValue(op.Value))}
+			}
+		case MergeOp:
+			if patch, isObject := normalizeValue(op.Value).(map[string]interface{}); isObject {
+				folded = &ValuesPatchOperation{Op: "add", Path: op.Path, Value: mergePatch(base, patch)}
+			}
+		case IncrementOp:
+			number, isNumber := toFloat64(base)
+			delta, isDelta := toFloat64(op.Value)
+			if (base == nil || isNumber) && isDelta {
+				folded = &ValuesPatchOperation{Op: "add", Path: op.Path, Value: number + delta}
+			}
+		}
+	case last.Op == IncrementOp && op.Op == IncrementOp:
+		sum, isSum := toFloat64(last.Value)
+		delta, isDelta := toFloat64(op.Value)
+		if isSum && isDelta {
+			folded = &ValuesPatchOperation{Op: IncrementOp, Path: op.Path, Value: sum + delta}
+		}
+	}
+
+	if folded == nil {
+		t[op.Path] = append(append(make([]*ValuesPatchOperation, 0, len(ops)+1), ops...), op)
+		return
+	}
+	t[op.Path] = append(append(make([]*ValuesPatchOperation, 0, len(ops)), ops[:len(ops)-1]...), folded)
+}
+
+// splitMerge stores the merge patch as "add" and "remove" operations for keys
+// and as "merge" operations for nested objects.
+func (t patchesTree) splitMerge(path string, patch map[string]interface{}) {
+	keys := make([]string, 0, len(patch))
+	for k := range patch {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	escaper := strings.NewReplacer("~", "~0", "/", "~1")
+	for _, k := range keys {
+		subPath := path + "/" + escaper.Replace(k)
+		switch v := patch[k].(type) {
+		case nil:
+			t.add(&ValuesPatchOperation{Op: "remove", Path: subPath})
+		case map[string]interface{}:
+			t.add(&ValuesPatchOperation{Op: MergeOp, Path: subPath, Value: v})
+		default:
+			t.add(&ValuesPatchOperation{Op: "add", Path: subPath, Value: v})
+		}
+	}
+}
+
+func (t patchesTree) hasSubpaths(path string) bool {
+	for subPath := range t {
+		if isSubpath(subPath, path) {
+			return true
+		}
+	}
+	return false
+}
+
+func isSubpath(subPath string, path string) bool {
+	return len(subPath) > len(path) && strings.HasPrefix(subPath, path+"/")
+}
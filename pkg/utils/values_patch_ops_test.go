@@ -0,0 +1,321 @@
+package utils
+
+import (
+	"encoding/json"
+	"testing"
+
+	jsonpatch "github.com/evanphx/json-patch"
+	. "github.com/onsi/gomega"
+)
+
+func Test_ValuesPatchFromBytes_ExtendedOps(t *testing.T) {
+	g := NewWithT(t)
+
+	input := `[{"op":"append","path":"/module/hosts","value":"host-1"},
+{"op":"merge","path":"/module/settings","value":{"a":1,"b":null}},
+{"op":"increment","path":"/module/generation","value":1},
+{"op":"test","path":"/module/state","value":null}]
+`
+
+	vp, err := ValuesPatchFromBytes([]byte(input))
+	g.Expect(err).ShouldNot(HaveOccurred())
+	g.Expect(vp.Operations).Should(HaveLen(4))
+	g.Expect(vp.Operations[0]).Should(Equal(&ValuesPatchOperation{Op: AppendOp, Path: "/module/hosts", Value: "host-1"}))
+	g.Expect(vp.Operations[1].Value).Should(Equal(map[string]interface{}{"a": 1.0, "b": nil}))
+	g.Expect(vp.Operations[3].Value).Should(BeNil())
+}
+
+func Test_ApplyValuesPatch_ExtendedOps(t *testing.T) {
+	values := func() Values {
+		return Values{
+			"module": map[string]interface{}{
+				"hosts":      []interface{}{"a"},
+				"settings":   map[string]interface{}{"a": 1.0, "b": "foo", "nested": map[string]interface{}{"c": true}},
+				"generation": 2.0,
+				"state":      "pending",
+				"items":      []interface{}{1.0, 2.0},
+			},
+		}
+	}
+
+	tests := []struct {
+		name     string
+		ops      []*ValuesPatchOperation
+		expected string
+		err      string
+	}{
+		{
+			name:     "append to existing array",
+			ops:      []*ValuesPatchOperation{{Op: AppendOp, Path: "/module/hosts", Value: "b"}},
+			expected: `{"hosts":["a","b"]}`,
+		},
+		{
+			name:     "append creates array",
+			ops:      []*ValuesPatchOperation{{Op: AppendOp, Path: "/module/ports", Value: map[string]interface{}{"port": 80}}},
+			expected: `{"ports":[{"port":80}]}`,
+		},
+		{
+			name: "append to non-array",
+			ops:  []*ValuesPatchOperation{{Op: AppendOp, Path: "/module/state", Value: "b"}},
+			err:  "append operation for path '/module/state': value is not an array",
+		},
+		{
+			name: "merge into existing object",
+			ops: []*ValuesPatchOperation{{Op: MergeOp, Path: "/module/settings", Value: map[string]interface{}{
+				"a": 2, "b": nil, "nested": map[string]interface{}{"d": "x"},
+			}}},
+			expected: `{"settings":{"a":2,"nested":{"c":true,"d":"x"}}}`,
+		},
+		{
+			name:     "merge creates object",
+			ops:      []*ValuesPatchOperation{{Op: MergeOp, Path: "/module/new", Value: map[string]interface{}{"a": 1, "b": nil}}},
+			expected: `{"new":{"a":1}}`,
+		},
+		{
+			name: "merge with non-object value",
+			ops:  []*ValuesPatchOperation{{Op: MergeOp, Path: "/module/settings", Value: "foo"}},
+			err:  "'merge' operation for path '/module/settings' expects an object value",
+		},
+		{
+			name:     "increment existing number",
+			ops:      []*ValuesPatchOperation{{Op: IncrementOp, Path: "/module/generation", Value: 3}},
+			expected: `{"generation":5}`,
+		},
+		{
+			name:     "increment array item",
+			ops:      []*ValuesPatchOperation{{Op: IncrementOp, Path: "/module/items/1", Value: -0.5}},
+			expected: `{"items":[1,1.5]}`,
+		},
+		{
+			name:     "increment creates number",
+			ops:      []*ValuesPatchOperation{{Op: IncrementOp, Path: "/module/counter", Value: 1}},
+			expected: `{"counter":1}`,
+		},
+		{
+			name: "increment non-number",
+			ops:  []*ValuesPatchOperation{{Op: IncrementOp, Path: "/module/state", Value: 1}},
+			err:  "increment operation for path '/module/state': value is not a number",
+		},
+		{
+			name: "test and set",
+			ops: []*ValuesPatchOperation{
+				{Op: TestOp, Path: "/module/state", Value: "pending"},
+				{Op: "add", Path: "/module/state", Value: "ready"},
+			},
+			expected: `{"state":"ready"}`,
+		},
+		{
+			name: "test and set for absent path",
+			ops: []*ValuesPatchOperation{
+				{Op: TestOp, Path: "/module/owner"},
+				{Op: "add", Path: "/module/owner", Value: "hook-1"},
+			},
+			expected: `{"owner":"hook-1"}`,
+		},
+		{
+			name: "test of object",
+			ops: []*ValuesPatchOperation{
+				{Op: TestOp, Path: "/module/settings/nested", Value: map[string]bool{"c": true}},
+			},
+			expected: `{}`,
+		},
+		{
+			name: "failed test",
+			ops: []*ValuesPatchOperation{
+				{Op: "add", Path: "/module/hosts", Value: []interface{}{}},
+				{Op: TestOp, Path: "/module/state", Value: "ready"},
+				{Op: "add", Path: "/module/state", Value: "done"},
+			},
+			err: "test operation failed for path '/module/state'",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			for _, mode := range []ApplyPatchMode{Strict, IgnoreNonExistentPaths} {
+				res, changed, err := ApplyValuesPatch(values(), ValuesPatch{Operations: tt.ops}, mode)
+				if tt.err != "" {
+					g.Expect(err).Should(MatchError(tt.err), "mode %s", mode)
+					continue
+				}
+				g.Expect(err).ShouldNot(HaveOccurred(), "mode %s", mode)
+
+				// Expected values are changes in the module section.
+				expected := values()
+				for k, v := range mustUnmarshal(g, tt.expected) {
+					expected["module"].(map[string]interface{})[k] = v
+				}
+				g.Expect(res).Should(Equal(expected), "mode %s", mode)
+				g.Expect(changed).Should(Equal(tt.expected != `{}`), "mode %s", mode)
+			}
+		})
+	}
+}
+
+func Test_CompactPatches_ExtendedOps(t *testing.T) {
+	tests := []struct {
+		name     string
+		existed  string
+		new      string
+		expected string
+	}{
+		{
+			"add+append == add",
+			`[{"op":"add", "path":"/hosts", "value":["a"]}]`,
+			`[{"op":"append", "path":"/hosts", "value":"b"}, {"op":"append", "path":"/hosts", "value":"c"}]`,
+			`[{"op":"add", "path":"/hosts", "value":["a","b","c"]}]`,
+		},
+		{
+			"remove+append == add",
+			`[{"op":"remove", "path":"/hosts"}]`,
+			`[{"op":"append", "path":"/hosts", "value":"a"}]`,
+			`[{"op":"add", "path":"/hosts", "value":["a"]}]`,
+		},
+		{
+			"append+append are kept",
+			`[{"op":"append", "path":"/hosts", "value":"a"}]`,
+			`[{"op":"append", "path":"/hosts", "value":"b"}]`,
+			`[{"op":"append", "path":"/hosts", "value":"a"}, {"op":"append", "path":"/hosts", "value":"b"}]`,
+		},
+		{
+			"append+add == add",
+			`[{"op":"append", "path":"/hosts", "value":"a"}]`,
+			`[{"op":"add", "path":"/hosts", "value":[]}]`,
+			`[{"op":"add", "path":"/hosts", "value":[]}]`,
+		},
+		{
+			"add+merge == add",
+			`[{"op":"add", "path":"/obj", "value":{"a":1, "b":2}}]`,
+			`[{"op":"merge", "path":"/obj", "value":{"b":null, "c":3}}]`,
+			`[{"op":"add", "path":"/obj", "value":{"a":1, "c":3}}]`,
+		},
+		{
+			"merge+merge are kept",
+			`[{"op":"merge", "path":"/obj", "value":{"a":1}}]`,
+			`[{"op":"merge", "path":"/obj", "value":{"b":2}}]`,
+			`[{"op":"merge", "path":"/obj", "value":{"a":1}}, {"op":"merge", "path":"/obj", "value":{"b":2}}]`,
+		},
+		{
+			"merge with subpaths is split",
+			`[{"op":"add", "path":"/obj/a", "value":1}, {"op":"add", "path":"/obj/nested/b", "value":2}, {"op":"add", "path":"/obj/keep", "value":3}]`,
+			`[{"op":"merge", "path":"/obj", "value":{"a":null, "nested":{"b":4}, "c":5}}]`,
+			`[{"op":"merge", "path":"/obj", "value":{}}, {"op":"remove", "path":"/obj/a"}, {"op":"add", "path":"/obj/c", "value":5}, {"op":"add", "path":"/obj/keep", "value":3}, {"op":"merge", "path":"/obj/nested", "value":{}}, {"op":"add", "path":"/obj/nested/b", "value":4}]`,
+		},
+		{
+			"add+increment == add",
+			`[{"op":"add", "path":"/counter", "value":2}]`,
+			`[{"op":"increment", "path":"/counter", "value":3}]`,
+			`[{"op":"add", "path":"/counter", "value":5}]`,
+		},
+		{
+			"increment+increment == increment",
+			`[{"op":"increment", "path":"/counter", "value":2}]`,
+			`[{"op":"increment", "path":"/counter", "value":-3}]`,
+			`[{"op":"increment", "path":"/counter", "value":-1}]`,
+		},
+		{
+			"add string+increment are kept",
+			`[{"op":"add", "path":"/counter", "value":"2"}]`,
+			`[{"op":"increment", "path":"/counter", "value":1}]`,
+			`[{"op":"add", "path":"/counter", "value":"2"}, {"op":"increment", "path":"/counter", "value":1}]`,
+		},
+		{
+			"test is dropped",
+			`[{"op":"add", "path":"/state", "value":"pending"}]`,
+			`[{"op":"test", "path":"/state", "value":"pending"}, {"op":"add", "path":"/state", "value":"ready"}]`,
+			`[{"op":"add", "path":"/state", "value":"ready"}]`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			existed, err := ValuesPatchFromBytes([]byte(tt.existed))
+			g.Expect(err).ShouldNot(HaveOccurred())
+			newPatch, err := ValuesPatchFromBytes([]byte(tt.new))
+			g.Expect(err).ShouldNot(HaveOccurred())
+
+			compacted := CompactPatches(existed.Operations, newPatch.Operations)
+			compactedBytes, err := json.Marshal(compacted.Operations)
+			g.Expect(err).ShouldNot(HaveOccurred())
+			g.Expect(jsonpatch.Equal(compactedBytes, []byte(tt.expected))).Should(BeTrue(), "%s should be equal to %s", compactedBytes, tt.expected)
+		})
+	}
+}
+
+// Applying compacted patches should give the same result as applying all operations.
+func Test_CompactPatches_ExtendedOps_Apply(t *testing.T) {
+	g := NewWithT(t)
+
+	values := Values{"obj": map[string]interface{}{"a": 1.0, "nested": map[string]interface{}{"x": "y"}}}
+	existed, err := ValuesPatchFromBytes([]byte(`[
+{"op":"add", "path":"/obj/nested/b", "value":2},
+{"op":"append", "path":"/list", "value":"a"},
+{"op":"increment", "path":"/counter", "value":1}
+]`))
+	g.Expect(err).ShouldNot(HaveOccurred())
+	newPatch, err := ValuesPatchFromBytes([]byte(`[
+{"op":"merge", "path":"/obj", "value":{"a":null, "nested":{"b":4}}},
+{"op":"append", "path":"/list", "value":"b"},
+{"op":"increment", "path":"/counter", "value":2},
+{"op":"test", "path":"/counter", "value":3}
+]`))
+	g.Expect(err).ShouldNot(HaveOccurred())
+
+	all := ValuesPatch{Operations: append(append([]*ValuesPatchOperation{}, existed.Operations...), newPatch.Operations...)}
+	expected, _, err := ApplyValuesPatch(values, all, Strict)
+	g.Expect(err).ShouldNot(HaveOccurred())
+
+	compacted := CompactPatches(existed.Operations, newPatch.Operations)
+	res, _, err := ApplyValuesPatch(values, compacted, IgnoreNonExistentPaths)
+	g.Expect(err).ShouldNot(HaveOccurred())
+	g.Expect(res).Should(Equal(expected))
+	g.Expect(res).Should(Equal(Values{
+		"obj":     map[string]interface{}{"nested": map[string]interface{}{"x": "y", "b": 4.0}},
+		"list":    []interface{}{"a", "b"},
+		"counter": 3.0,
+	}))
+}
+
+func Test_ValidateHookValuesPatch_Ops(t *testing.T) {
+	tests := []struct {
+		name string
+		op   *ValuesPatchOperation
+		err  string
+	}{
+		{"add", &ValuesPatchOperation{Op: "add", Path: "/module/a", Value: 1}, ""},
+		{"remove", &ValuesPatchOperation{Op: "remove", Path: "/module/a"}, ""},
+		{"append", &ValuesPatchOperation{Op: AppendOp, Path: "/module/a", Value: 1}, ""},
+		{"merge", &ValuesPatchOperation{Op: MergeOp, Path: "/module/a", Value: map[string]interface{}{"b": 1}}, ""},
+		{"increment", &ValuesPatchOperation{Op: IncrementOp, Path: "/module/a", Value: 1}, ""},
+		{"test", &ValuesPatchOperation{Op: TestOp, Path: "/module/a"}, ""},
+		{"replace", &ValuesPatchOperation{Op: "replace", Path: "/module/a", Value: 1}, "unsupported patch operation 'replace': '1'"},
+		// Operations other than 'replace' are accepted as before extended operations were added.
+		{"move", &ValuesPatchOperation{Op: "move", Path: "/module/a"}, ""},
+		{"copy", &ValuesPatchOperation{Op: "copy", Path: "/module/a"}, ""},
+		{"merge with array", &ValuesPatchOperation{Op: MergeOp, Path: "/module/a", Value: []interface{}{}}, "'merge' operation for path '/module/a' expects an object value"},
+		{"increment with string", &ValuesPatchOperation{Op: IncrementOp, Path: "/module/a", Value: "1"}, "'increment' operation for path '/module/a' expects a number value"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			err := ValidateHookValuesPatch(ValuesPatch{Operations: []*ValuesPatchOperation{tt.op}}, "module")
+			if tt.err == "" {
+				g.Expect(err).ShouldNot(HaveOccurred())
+				return
+			}
+			g.Expect(err).Should(MatchError(tt.err))
+		})
+	}
+}
+
+func mustUnmarshal(g *WithT, data string) map[string]interface{} {
+	res := make(map[string]interface{})
+	g.Expect(json.Unmarshal([]byte(data), &res)).Should(Succeed())
+	return res
+}
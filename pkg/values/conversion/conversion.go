@@ -0,0 +1,214 @@
+// Package conversion migrates module config values stored in the ConfigMap
+// from older versions of the config-values schema.
+//
+// A module declares the current version with 'x-config-version' in openapi/config-values.yaml.
+// Each version N > 1 can have conversion steps from the version N-1: declarative steps
+// in openapi/conversions/vN.yaml and a Go function registered with sdk.RegisterConfigConversion.
+// Config values without a version are considered to have version 1.
+package conversion
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// VersionKeySuffix is a suffix of the ConfigMap key with the version of the module config values.
+const VersionKeySuffix = "ConfigVersion"
+
+// Func converts module config values from the previous version.
+type Func func(values map[string]interface{}) (map[string]interface{}, error)
+
+// Step is a declarative conversion step. Paths are dot-separated paths inside the module section.
+type Step struct {
+	// Move moves the value from one path to another. It is a rename if the parent path is the same.
+	Move *MoveStep `json:"move,omitempty"`
+	// Delete removes the value.
+	Delete string `json:"delete,omitempty"`
+}
+
+type MoveStep struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// Chain is a list of conversions for the module.
+type Chain struct {
+	LatestVersion int
+	steps         map[int][]Step
+	funcs         map[int]Func
+}
+
+func NewChain() *Chain {
+	return &Chain{
+		LatestVersion: 1,
+		steps:         make(map[int][]Step),
+		funcs:         make(map[int]Func),
+	}
+}
+
+// Versions returns versions with conversions in ascending order.
+func (c *Chain) Versions() []int {
+	versions := make([]int, 0)
+	for v := 2; v <= c.LatestVersion; v++ {
+		if len(c.steps[v]) > 0 || c.funcs[v] != nil {
+			versions = append(versions, v)
+		}
+	}
+	return versions
+}
+
+// Convert applies conversions from the version 'from' to the latest version.
+// Steps are applied before the Go function for the same version. Input values are not modified.
+func (c *Chain) Convert(from int, values map[string]interface{}) (map[string]interface{}, error) {
+	if from > c.LatestVersion {
+		return nil, fmt.Errorf("config version %d is newer than the latest version %d", from, c.LatestVersion)
+	}
+	if from < 1 {
+		return nil, fmt.Errorf("config version %d is invalid, should be positive", from)
+	}
+
+	res, _ := copyValue(values).(map[string]interface{})
+	for v := from + 1; v <= c.LatestVersion; v++ {
+		var err error
+		for _, step := range c.steps[v] {
+			res, err = applyStep(res, step)
+			if err != nil {
+				return nil, fmt.Errorf("convert to version %d: %v", v, err)
+			}
+		}
+		if fn := c.funcs[v]; fn != nil {
+			res, err = fn(res)
+			if err != nil {
+				return nil, fmt.Errorf("convert to version %d: %v", v, err)
+			}
+		}
+	}
+	return res, nil
+}
+
+// copyValue returns a deep copy of maps and arrays.
+func copyValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		res := make(map[string]interface{}, len(v))
+		for k, item := range v {
+			res[k] = copyValue(item)
+		}
+		return res
+	case []interface{}:
+		res := make([]interface{}, 0, len(v))
+		for _, item := range v {
+			res = append(res, copyValue(item))
+		}
+		return res
+	}
+	return value
+}
+
+func applyStep(values map[string]interface{}, step Step) (map[string]interface{}, error) {
+	if values == nil {
+		values = make(map[string]interface{})
+	}
+	switch {
+	case step.Move != nil:
+		if step.Move.From == "" || step.Move.To == "" {
+			return nil, fmt.Errorf("move: 'from' and 'to' are required")
+		}
+		value, has := removePath(values, strings.Split(step.Move.From, "."))
+		if has {
+			if err := setPath(values, strings.Split(step.Move.To, "."), value); err != nil {
+				return nil, fmt.Errorf("move '%s' to '%s': %v", step.Move.From, step.Move.To, err)
+			}
+		}
+	case step.Delete != "":
+		removePath(values, strings.Split(step.Delete, "."))
+	default:
+		return nil, fmt.Errorf("step should have 'move' or 'delete'")
+	}
+	return values, nil
+}
+
+// removePath removes the value by the path and returns it. Empty parent maps are kept.
+func removePath(values map[string]interface{}, path []string) (interface{}, bool) {
+	cur := values
+	for _, key := range path[:len(path)-1] {
+		next, ok := cur[key].(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		cur = next
+	}
+	last := path[len(path)-1]
+	value, has := cur[last]
+	delete(cur, last)
+	return value, has
+}
+
+// setPath sets the value by the path and creates missing parent maps.
+func setPath(values map[string]interface{}, path []string, value interface{}) error {
+	cur := values
+	for i, key := range path[:len(path)-1] {
+		next, has := cur[key]
+		if !has {
+			m := make(map[string]interface{})
+			cur[key] = m
+			cur = m
+			continue
+		}
+		m, ok := next.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("'%s' is not an object", strings.Join(path[:i+1], "."))
+		}
+		cur = m
+	}
+	cur[path[len(path)-1]] = value
+	return nil
+}
+
+// ConversionRegistry stores conversion chains for modules.
+type ConversionRegistry struct {
+	chains map[string]*Chain
+	m      sync.RWMutex
+}
+
+func NewRegistry() *ConversionRegistry {
+	return &ConversionRegistry{chains: make(map[string]*Chain)}
+}
+
+func (r *ConversionRegistry) chain(moduleName string) *Chain {
+	c, has := r.chains[moduleName]
+	if !has {
+		c = NewChain()
+		r.chains[moduleName] = c
+	}
+	return c
+}
+
+// SetLatestVersion sets the version of the module config-values schema.
+func (r *ConversionRegistry) SetLatestVersion(moduleName string, version int) {
+	r.m.Lock()
+	defer r.m.Unlock()
+	r.chain(moduleName).LatestVersion = version
+}
+
+// AddSteps sets declarative steps to convert module config values to the version.
+func (r *ConversionRegistry) AddSteps(moduleName string, version int, steps []Step) {
+	r.m.Lock()
+	defer r.m.Unlock()
+	r.chain(moduleName).steps[version] = steps
+}
+
+// AddFunc sets a Go function to convert module config values to the version.
+func (r *ConversionRegistry) AddFunc(moduleName string, version int, fn Func) {
+	r.m.Lock()
+	defer r.m.Unlock()
+	r.chain(moduleName).funcs[version] = fn
+}
+
+// Get returns conversions for the module or nil if module has no versions.
+func (r *ConversionRegistry) Get(moduleName string) *Chain {
+	r.m.RLock()
+	defer r.m.RUnlock()
+	return r.chains[moduleName]
+}
@@ -0,0 +1,83 @@
+package conversion
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Chain_Convert(t *testing.T) {
+	chain := NewChain()
+	chain.LatestVersion = 3
+	chain.steps[2] = []Step{
+		{Move: &MoveStep{From: "auth.password", To: "auth.credentials.password"}},
+		{Delete: "legacy"},
+	}
+	chain.funcs[3] = func(values map[string]interface{}) (map[string]interface{}, error) {
+		values["replicas"] = 2
+		return values, nil
+	}
+
+	assert.Equal(t, []int{2, 3}, chain.Versions())
+
+	res, err := chain.Convert(1, map[string]interface{}{
+		"auth":   map[string]interface{}{"password": "secret"},
+		"legacy": true,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"auth":     map[string]interface{}{"credentials": map[string]interface{}{"password": "secret"}},
+		"replicas": 2,
+	}, res)
+
+	// Only the function is applied from the version 2.
+	res, err = chain.Convert(2, map[string]interface{}{"legacy": true})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"legacy": true, "replicas": 2}, res)
+
+	_, err = chain.Convert(4, map[string]interface{}{})
+	assert.Error(t, err)
+}
+
+func Test_Chain_Convert_MoveToScalar(t *testing.T) {
+	chain := NewChain()
+	chain.LatestVersion = 2
+	chain.steps[2] = []Step{{Move: &MoveStep{From: "a", To: "b.c"}}}
+
+	_, err := chain.Convert(1, map[string]interface{}{"a": 1, "b": "scalar"})
+	assert.Error(t, err)
+}
+
+func Test_LoadModuleConversions(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, ConversionsDir), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, ConversionsDir, "v2.yaml"), []byte(`
+steps:
+- move:
+    from: oldName
+    to: newName
+`), 0o644))
+
+	r := NewRegistry()
+	err := r.LoadModuleConversions("testLoadModule", dir, []byte("x-config-version: 2\ntype: object\n"))
+	require.NoError(t, err)
+
+	chain := r.Get("testLoadModule")
+	require.NotNil(t, chain)
+	assert.Equal(t, 2, chain.LatestVersion)
+
+	res, err := chain.Convert(1, map[string]interface{}{"oldName": "x"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"newName": "x"}, res)
+
+	// Conversions without x-config-version.
+	err = r.LoadModuleConversions("testLoadModuleNoVersion", dir, []byte("type: object\n"))
+	assert.Error(t, err)
+
+	// Conversion to the version newer than x-config-version.
+	err = r.LoadModuleConversions("testLoadModuleOld", dir, []byte("x-config-version: 1\n"))
+	assert.Error(t, err)
+}
@@ -0,0 +1,118 @@
+package conversion
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+
+	"sigs.k8s.io/yaml"
+)
+
+// ConversionsDir is a directory in the module openapi directory with declarative conversions.
+const ConversionsDir = "conversions"
+
+// conversionFileRe matches files with conversions to the version: v2.yaml, v3.yaml, etc.
+var conversionFileRe = regexp.MustCompile(`^v([0-9]+)\.yaml$`)
+
+type conversionFile struct {
+	Steps []Step `json:"steps"`
+}
+
+// LoadModuleConversions reads 'x-config-version' from the config-values schema and
+// declarative conversions from the openapi/conversions directory of the module.
+func (r *ConversionRegistry) LoadModuleConversions(moduleName string, openAPIDir string, configSchemaBytes []byte) error {
+	version, err := schemaVersion(configSchemaBytes)
+	if err != nil {
+		return fmt.Errorf("read x-config-version: %v", err)
+	}
+
+	steps, err := readConversionFiles(filepath.Join(openAPIDir, ConversionsDir))
+	if err != nil {
+		return err
+	}
+
+	chain := r.Get(moduleName)
+	if version == 0 {
+		if len(steps) > 0 || chain != nil {
+			return fmt.Errorf("module has config conversions, but 'x-config-version' is not set in config-values.yaml")
+		}
+		return nil
+	}
+
+	for v, vSteps := range steps {
+		if v < 2 || v > version {
+			return fmt.Errorf("conversion to version %d: version should be from 2 to %d", v, version)
+		}
+		r.AddSteps(moduleName, v, vSteps)
+	}
+	r.SetLatestVersion(moduleName, version)
+
+	chain = r.Get(moduleName)
+	for v := range chain.funcs {
+		if v < 2 || v > version {
+			return fmt.Errorf("conversion function to version %d: version should be from 2 to %d", v, version)
+		}
+	}
+	return nil
+}
+
+// schemaVersion returns 'x-config-version' or 0 if it is not set.
+func schemaVersion(configSchemaBytes []byte) (int, error) {
+	if len(configSchemaBytes) == 0 {
+		return 0, nil
+	}
+	var schema struct {
+		Version *int `json:"x-config-version"`
+	}
+	if err := yaml.Unmarshal(configSchemaBytes, &schema); err != nil {
+		return 0, err
+	}
+	if schema.Version == nil {
+		return 0, nil
+	}
+	if *schema.Version < 1 {
+		return 0, fmt.Errorf("should be positive, got %d", *schema.Version)
+	}
+	return *schema.Version, nil
+}
+
+func readConversionFiles(dir string) (map[int][]Step, error) {
+	res := make(map[int][]Step)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return res, nil
+		}
+		return nil, err
+	}
+
+	for _, entry := range entries {
+		matches := conversionFileRe.FindStringSubmatch(entry.Name())
+		if entry.IsDir() || matches == nil {
+			continue
+		}
+		version, _ := strconv.Atoi(matches[1])
+
+		path := filepath.Join(dir, entry.Name())
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("read file '%s': %v", path, err)
+		}
+		var file conversionFile
+		if err := yaml.UnmarshalStrict(data, &file); err != nil {
+			return nil, fmt.Errorf("parse file '%s': %v", path, err)
+		}
+		for i, step := range file.Steps {
+			if (step.Move == nil) == (step.Delete == "") {
+				return nil, fmt.Errorf("parse file '%s': step %d should have either 'move' or 'delete'", path, i)
+			}
+			if step.Move != nil && (step.Move.From == "" || step.Move.To == "") {
+				return nil, fmt.Errorf("parse file '%s': step %d: 'move' should have 'from' and 'to'", path, i)
+			}
+		}
+		res[version] = file.Steps
+	}
+	return res, nil
+}
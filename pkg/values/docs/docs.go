@@ -0,0 +1,296 @@
+package docs
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/go-openapi/spec"
+
+	"github.com/flant/addon-operator/pkg/values/validation"
+)
+
+/**
+ * This package generates reference documentation for global and module settings
+ * from OpenAPI schemas for config values:
+ *  /global/openapi/config-values.yaml
+ *  /modules/XXXX/openapi/config-values.yaml
+ */
+
+// Page describes settings of the global section or of the module.
+type Page struct {
+	// Name is a module name or "global". It is used as a file name.
+	Name  string
+	Title string
+	// ConfigKey is a key of the section in the ConfigMap.
+	ConfigKey string
+	Schema    *spec.Schema
+	Settings  []*Setting
+}
+
+// Setting is a node of the settings tree.
+type Setting struct {
+	// Path is a dotted path from the section root: https.mode. '[]' marks array items: ports[].name.
+	Path        string
+	Name        string
+	Depth       int
+	Type        string
+	Title       string
+	Description string
+	Default     interface{}
+	HasDefault  bool
+	Required    bool
+	Enum        []interface{}
+	Examples    []interface{}
+	// Constraints are validation keywords in a human-readable form: "minimum: 1".
+	Constraints []string
+	Deprecated  bool
+	// DeprecationMessage is a text from 'x-deprecated' if it is a string.
+	DeprecationMessage string
+	// RenamedTo is a new name from 'x-renamed-to'.
+	RenamedTo string
+	// Sensitive is true if the value is masked in logs and debug output.
+	Sensitive bool
+}
+
+// NewPage returns a page with the settings tree of the schema. Schema can be nil.
+func NewPage(name string, title string, configKey string, s *spec.Schema) *Page {
+	page := &Page{
+		Name:      name,
+		Title:     title,
+		ConfigKey: configKey,
+		Schema:    s,
+	}
+	if s != nil {
+		page.Settings = Settings(s)
+	}
+	return page
+}
+
+// Settings returns a flat list of settings in the schema in depth-first order.
+// Properties on the same level are sorted by name.
+func Settings(s *spec.Schema) []*Setting {
+	res := make([]*Setting, 0)
+	collectSettings(s, "", 0, &res)
+	return res
+}
+
+func collectSettings(s *spec.Schema, prefix string, depth int, res *[]*Setting) {
+	names := make([]string, 0, len(s.Properties))
+	for name := range s.Properties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	required := make(map[string]bool, len(s.Required))
+	for _, name := range s.Required {
+		required[name] = true
+	}
+
+	for _, name := range names {
+		prop := s.Properties[name]
+		path := name
+		if prefix != "" {
+			path = prefix + "." + name
+		}
+		*res = append(*res, newSetting(&prop, name, path, depth, required[name]))
+
+		// Nested settings: object properties, properties of array items and of map values.
+		switch {
+		case len(prop.Properties) > 0:
+			collectSettings(&prop, path, depth+1, res)
+		case prop.Items != nil && prop.Items.Schema != nil && len(prop.Items.Schema.Properties) > 0:
+			collectSettings(prop.Items.Schema, path+"[]", depth+1, res)
+		case prop.AdditionalProperties != nil && prop.AdditionalProperties.Schema != nil && len(prop.AdditionalProperties.Schema.Properties) > 0:
+			collectSettings(prop.AdditionalProperties.Schema, path+".<name>", depth+1, res)
+		}
+	}
+}
+
+func newSetting(s *spec.Schema, name string, path string, depth int, required bool) *Setting {
+	setting := &Setting{
+		Path:        path,
+		Name:        name,
+		Depth:       depth,
+		Type:        typeName(s),
+		Title:       s.Title,
+		Description: strings.TrimSpace(s.Description),
+		Default:     s.Default,
+		HasDefault:  s.Default != nil,
+		Required:    required,
+		Enum:        s.Enum,
+		Examples:    Examples(s),
+		Constraints: constraints(s),
+	}
+	setting.Deprecated, setting.DeprecationMessage = deprecation(s)
+	setting.RenamedTo, _ = s.Extensions[validation.XRenamedTo].(string)
+	setting.Sensitive, _ = s.Extensions[validation.XSensitive].(bool)
+	return setting
+}
+
+// typeName returns a type of the value: "string", "array of integer", "integer or string".
+func typeName(s *spec.Schema) string {
+	types := make([]string, 0)
+	for _, t := range s.Type {
+		if t == "array" && s.Items != nil && s.Items.Schema != nil {
+			if itemsType := typeName(s.Items.Schema); itemsType != "" {
+				t = "array of " + itemsType
+			}
+		}
+		types = append(types, t)
+	}
+	if len(types) == 0 {
+		switch {
+		case s.Extensions["x-kubernetes-int-or-string"] == true:
+			types = append(types, "integer", "string")
+		case len(s.Properties) > 0 || s.AdditionalProperties != nil:
+			types = append(types, "object")
+		}
+	}
+	if s.Nullable && len(types) > 0 {
+		types = append(types, "null")
+	}
+	return strings.Join(types, " or ")
+}
+
+// constraints returns validation keywords except type and enum.
+func constraints(s *spec.Schema) []string {
+	res := make([]string, 0)
+	if s.Format != "" {
+		res = append(res, fmt.Sprintf("format: %s", s.Format))
+	}
+	if s.Pattern != "" {
+		res = append(res, fmt.Sprintf("pattern: %s", s.Pattern))
+	}
+	if s.Minimum != nil {
+		op := ">="
+		if s.ExclusiveMinimum {
+			op = ">"
+		}
+		res = append(res, fmt.Sprintf("value %s %v", op, *s.Minimum))
+	}
+	if s.Maximum != nil {
+		op := "<="
+		if s.ExclusiveMaximum {
+			op = "<"
+		}
+		res = append(res, fmt.Sprintf("value %s %v", op, *s.Maximum))
+	}
+	if s.MultipleOf != nil {
+		res = append(res, fmt.Sprintf("multiple of %v", *s.MultipleOf))
+	}
+	if s.MinLength != nil {
+		res = append(res, fmt.Sprintf("length >= %d", *s.MinLength))
+	}
+	if s.MaxLength != nil {
+		res = append(res, fmt.Sprintf("length <= %d", *s.MaxLength))
+	}
+	if s.MinItems != nil {
+		res = append(res, fmt.Sprintf("items >= %d", *s.MinItems))
+	}
+	if s.MaxItems != nil {
+		res = append(res, fmt.Sprintf("items <= %d", *s.MaxItems))
+	}
+	if s.UniqueItems {
+		res = append(res, "unique items")
+	}
+	if s.MinProperties != nil {
+		res = append(res, fmt.Sprintf("properties >= %d", *s.MinProperties))
+	}
+	if s.MaxProperties != nil {
+		res = append(res, fmt.Sprintf("properties <= %d", *s.MaxProperties))
+	}
+	return res
+}
+
+// deprecation returns true if the schema has 'x-deprecated' or 'deprecated' keyword.
+// 'x-deprecated' can be a string with a message.
+func deprecation(s *spec.Schema) (bool, string) {
+	switch v := s.Extensions[validation.XDeprecated].(type) {
+	case bool:
+		return v, ""
+	case string:
+		return true, v
+	}
+	if v, ok := s.ExtraProps["deprecated"].(bool); ok {
+		return v, ""
+	}
+	return false, ""
+}
+
+// Examples returns values from 'x-examples', 'examples' and 'example' keywords.
+func Examples(s *spec.Schema) []interface{} {
+	res := make([]interface{}, 0)
+	for _, examples := range []interface{}{s.Extensions["x-examples"], s.ExtraProps["examples"]} {
+		if items, ok := examples.([]interface{}); ok {
+			res = append(res, items...)
+		}
+	}
+	if s.Example != nil {
+		res = append(res, s.Example)
+	}
+	return res
+}
+
+// ExampleValues returns example values for the schema. The first example is used
+// if present, then the default value and the first enum value. Object values are
+// built from properties with examples or defaults and from required properties.
+// It returns nil if the schema has nothing to show.
+func ExampleValues(s *spec.Schema) interface{} {
+	if s == nil {
+		return nil
+	}
+	if examples := Examples(s); len(examples) > 0 {
+		return examples[0]
+	}
+	if s.Default != nil {
+		return s.Default
+	}
+	if len(s.Enum) > 0 {
+		return s.Enum[0]
+	}
+
+	if len(s.Properties) > 0 {
+		required := make(map[string]bool, len(s.Required))
+		for _, name := range s.Required {
+			required[name] = true
+		}
+		obj := make(map[string]interface{})
+		for name, prop := range s.Properties {
+			prop := prop
+			value := ExampleValues(&prop)
+			if value == nil && required[name] {
+				value = zeroValue(&prop)
+			}
+			if value != nil {
+				obj[name] = value
+			}
+		}
+		if len(obj) > 0 {
+			return obj
+		}
+		return nil
+	}
+
+	if s.Items != nil && s.Items.Schema != nil {
+		if item := ExampleValues(s.Items.Schema); item != nil {
+			return []interface{}{item}
+		}
+	}
+	return nil
+}
+
+// zeroValue returns an empty value of the schema type for required properties without examples.
+func zeroValue(s *spec.Schema) interface{} {
+	switch {
+	case s.Type.Contains("string"):
+		return ""
+	case s.Type.Contains("integer"), s.Type.Contains("number"):
+		return 0
+	case s.Type.Contains("boolean"):
+		return false
+	case s.Type.Contains("array"):
+		return []interface{}{}
+	}
+	return map[string]interface{}{}
+}
@@ -0,0 +1,127 @@
+package docs
+
+import (
+	"bytes"
+	"testing"
+
+	. "github.com/onsi/gomega"
+
+	"github.com/flant/addon-operator/pkg/values/validation"
+)
+
+const configSchemaYaml = `
+type: object
+required: [storageClass]
+properties:
+  storageClass:
+    type: string
+    description: Storage class for PVC.
+  replicas:
+    type: integer
+    default: 2
+    minimum: 1
+  replicaCount:
+    x-renamed-to: replicas
+  debug:
+    type: boolean
+    default: false
+    x-deprecated: Use logLevel instead.
+  logLevel:
+    type: string
+    enum: [Info, Debug]
+  https:
+    type: object
+    title: HTTPS settings
+    properties:
+      mode:
+        type: string
+        x-examples: [CertManager]
+  password:
+    type: string
+    x-sensitive: true
+  ports:
+    type: array
+    items:
+      type: object
+      properties:
+        port:
+          type: integer
+`
+
+func loadPage(t *testing.T) *Page {
+	st := validation.NewSchemaStorage()
+	err := st.AddModuleValuesSchemas("moduleOne", []byte(configSchemaYaml), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewPage("module-one", "Module module-one", "moduleOne", st.ModuleValuesSchema("moduleOne", validation.ConfigValuesSchema))
+}
+
+func Test_Settings(t *testing.T) {
+	g := NewWithT(t)
+
+	page := loadPage(t)
+
+	paths := make([]string, 0)
+	for _, s := range page.Settings {
+		paths = append(paths, s.Path)
+	}
+	g.Expect(paths).To(Equal([]string{"debug", "https", "https.mode", "logLevel", "password", "ports", "ports[].port", "replicaCount", "replicas", "storageClass"}))
+
+	settings := make(map[string]*Setting)
+	for _, s := range page.Settings {
+		settings[s.Path] = s
+	}
+	g.Expect(settings["debug"].HasDefault).To(BeTrue())
+	g.Expect(settings["debug"].Deprecated).To(BeTrue())
+	g.Expect(settings["debug"].DeprecationMessage).To(Equal("Use logLevel instead."))
+	g.Expect(settings["https.mode"].Depth).To(Equal(1))
+	g.Expect(settings["https.mode"].Examples).To(Equal([]interface{}{"CertManager"}))
+	g.Expect(settings["replicaCount"].RenamedTo).To(Equal("replicas"))
+	g.Expect(settings["password"].Sensitive).To(BeTrue())
+	g.Expect(settings["ports"].Type).To(Equal("array of object"))
+	g.Expect(settings["replicas"].Constraints).To(Equal([]string{"value >= 1"}))
+	g.Expect(settings["storageClass"].Required).To(BeTrue())
+}
+
+func Test_ConfigMapExample(t *testing.T) {
+	g := NewWithT(t)
+
+	cm, err := ConfigMapExample("addon-operator", []*Page{loadPage(t), NewPage("module-two", "Module module-two", "moduleTwo", nil)})
+	g.Expect(err).ShouldNot(HaveOccurred())
+	g.Expect(cm).To(Equal(`apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: addon-operator
+data:
+  moduleOne: |
+    debug: false
+    https:
+      mode: CertManager
+    logLevel: Info
+    replicas: 2
+    storageClass: ""
+`))
+}
+
+func Test_RenderPage(t *testing.T) {
+	g := NewWithT(t)
+
+	page := loadPage(t)
+
+	buf := new(bytes.Buffer)
+	err := RenderPage(buf, Markdown, page)
+	g.Expect(err).ShouldNot(HaveOccurred())
+	g.Expect(buf.String()).To(ContainSubstring("## `debug`\n\n> **Deprecated.** Use logLevel instead.\n"))
+	g.Expect(buf.String()).To(ContainSubstring("### `https.mode`\n"))
+	g.Expect(buf.String()).To(ContainSubstring("## `replicaCount`\n\n> **Renamed** to `replicas`."))
+	g.Expect(buf.String()).To(ContainSubstring("- Allowed values: `\"Info\"`, `\"Debug\"`\n"))
+	g.Expect(buf.String()).To(ContainSubstring("- Type: `integer`\n- Default: `2`\n- value >= 1\n"))
+	g.Expect(buf.String()).To(ContainSubstring("## `password`\n\n- Type: `string`\n- Sensitive: the value is masked in logs and debug output.\n"))
+
+	buf.Reset()
+	err = RenderPage(buf, HTML, page)
+	g.Expect(err).ShouldNot(HaveOccurred())
+	g.Expect(buf.String()).To(ContainSubstring(`<h3 id="https.mode"><code>https.mode</code></h3>`))
+	g.Expect(buf.String()).To(ContainSubstring(`<li>value &gt;= 1</li>`))
+}
@@ -0,0 +1,234 @@
+package docs
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	html_template "html/template"
+	"io"
+	"strings"
+	text_template "text/template"
+
+	"sigs.k8s.io/yaml"
+)
+
+type Format string
+
+const (
+	Markdown Format = "markdown"
+	HTML     Format = "html"
+)
+
+// Ext returns a file extension for the format.
+func (f Format) Ext() string {
+	if f == HTML {
+		return "html"
+	}
+	return "md"
+}
+
+// Index is a page with links to pages and an example ConfigMap with all sections.
+type Index struct {
+	Title     string
+	Pages     []*Page
+	ConfigMap string
+	// Ext is an extension for links to pages.
+	Ext string
+}
+
+// ConfigMapExample returns a ConfigMap manifest with example values for each page.
+// Pages without example values are skipped.
+func ConfigMapExample(name string, pages []*Page) (string, error) {
+	data := make(map[string]string)
+	for _, page := range pages {
+		values := ExampleValues(page.Schema)
+		if values == nil {
+			continue
+		}
+		valuesYaml, err := yaml.Marshal(values)
+		if err != nil {
+			return "", fmt.Errorf("marshal '%s' example values: %v", page.ConfigKey, err)
+		}
+		data[page.ConfigKey] = string(valuesYaml)
+	}
+
+	dataYaml, err := yaml.Marshal(data)
+	if err != nil {
+		return "", err
+	}
+
+	var sb strings.Builder
+	sb.WriteString("apiVersion: v1\nkind: ConfigMap\nmetadata:\n")
+	sb.WriteString(fmt.Sprintf("  name: %s\n", name))
+	sb.WriteString("data:")
+	if len(data) == 0 {
+		sb.WriteString(" {}\n")
+		return sb.String(), nil
+	}
+	sb.WriteString("\n")
+	sb.WriteString(indent(string(dataYaml), "  "))
+	return sb.String(), nil
+}
+
+// RenderPage writes a reference page with settings in the format.
+func RenderPage(w io.Writer, format Format, page *Page) error {
+	if format == HTML {
+		return htmlTemplates.ExecuteTemplate(w, "page", page)
+	}
+	return markdownTemplates.ExecuteTemplate(w, "page", page)
+}
+
+// RenderIndex writes an index page in the format.
+func RenderIndex(w io.Writer, format Format, index *Index) error {
+	if format == HTML {
+		return htmlTemplates.ExecuteTemplate(w, "index", index)
+	}
+	return markdownTemplates.ExecuteTemplate(w, "index", index)
+}
+
+var templateFuncs = map[string]interface{}{
+	"json":   jsonValue,
+	"yaml":   yamlValue,
+	"repeat": strings.Repeat,
+	"add":    func(a, b int) int { return a + b },
+}
+
+var markdownTemplates = text_template.Must(text_template.New("markdown").Funcs(templateFuncs).Parse(markdownTemplate))
+
+var htmlTemplates = html_template.Must(html_template.New("html").Funcs(templateFuncs).Parse(htmlTemplate))
+
+const markdownTemplate = `
+{{- define "page" -}}
+# {{ .Title }}
+{{ if not .Settings }}
+No settings.
+{{ else }}
+Settings are stored in the ` + "`{{ .ConfigKey }}`" + ` key of the ConfigMap.
+{{ range .Settings }}
+{{ repeat "#" (add .Depth 2) }} ` + "`{{ .Path }}`" + `
+{{ if .RenamedTo }}
+> **Renamed** to ` + "`{{ .RenamedTo }}`" + `. The value is moved to the new setting.
+{{ else if .Deprecated }}
+> **Deprecated.**{{ if .DeprecationMessage }} {{ .DeprecationMessage }}{{ end }}
+{{ end }}{{ if .Title }}
+**{{ .Title }}**
+{{ end }}{{ if .Description }}
+{{ .Description }}
+{{ end }}
+{{ if .Type }}- Type: ` + "`{{ .Type }}`" + `
+{{ end }}{{ if .Required }}- Required.
+{{ end }}{{ if .Sensitive }}- Sensitive: the value is masked in logs and debug output.
+{{ end }}{{ if .HasDefault }}- Default: ` + "`{{ json .Default }}`" + `
+{{ end }}{{ if .Enum }}- Allowed values:{{ range $i, $v := .Enum }}{{ if $i }},{{ end }} ` + "`{{ json $v }}`" + `{{ end }}
+{{ end }}{{ range .Constraints }}- {{ . }}
+{{ end }}{{ range .Examples }}
+Example:
+
+` + "```yaml" + `
+{{ yaml . }}` + "```" + `
+{{ end }}{{ end }}{{ end }}
+{{- end -}}
+
+{{- define "index" -}}
+# {{ .Title }}
+{{ range .Pages }}
+- [{{ .Title }}]({{ .Name }}.{{ $.Ext }})
+{{- end }}
+{{ if .ConfigMap }}
+## ConfigMap example
+
+` + "```yaml" + `
+{{ .ConfigMap }}` + "```" + `
+{{ end }}
+{{- end -}}
+`
+
+const htmlTemplate = `
+{{- define "header" -}}
+<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{ . }}</title>
+</head>
+<body>
+{{ end -}}
+
+{{- define "page" -}}
+{{ template "header" .Title -}}
+<h1>{{ .Title }}</h1>
+{{ if not .Settings -}}
+<p>No settings.</p>
+{{ else -}}
+<p>Settings are stored in the <code>{{ .ConfigKey }}</code> key of the ConfigMap.</p>
+{{ range .Settings -}}
+<div class="setting" style="margin-left: {{ .Depth }}em">
+<h3 id="{{ .Path }}"><code>{{ .Path }}</code></h3>
+{{ if .RenamedTo }}<p><strong>Renamed</strong> to <code>{{ .RenamedTo }}</code>. The value is moved to the new setting.</p>
+{{ else if .Deprecated }}<p><strong>Deprecated.</strong>{{ if .DeprecationMessage }} {{ .DeprecationMessage }}{{ end }}</p>
+{{ end }}{{ if .Title }}<p><strong>{{ .Title }}</strong></p>
+{{ end }}{{ if .Description }}<p>{{ .Description }}</p>
+{{ end -}}
+<ul>
+{{ if .Type }}<li>Type: <code>{{ .Type }}</code></li>
+{{ end }}{{ if .Required }}<li>Required.</li>
+{{ end }}{{ if .Sensitive }}<li>Sensitive: the value is masked in logs and debug output.</li>
+{{ end }}{{ if .HasDefault }}<li>Default: <code>{{ json .Default }}</code></li>
+{{ end }}{{ if .Enum }}<li>Allowed values:{{ range $i, $v := .Enum }}{{ if $i }},{{ end }} <code>{{ json $v }}</code>{{ end }}</li>
+{{ end }}{{ range .Constraints }}<li>{{ . }}</li>
+{{ end -}}
+</ul>
+{{ range .Examples }}<p>Example:</p>
+<pre><code>{{ yaml . }}</code></pre>
+{{ end -}}
+</div>
+{{ end -}}
+{{ end -}}
+</body>
+</html>
+{{ end -}}
+
+{{- define "index" -}}
+{{ template "header" .Title -}}
+<h1>{{ .Title }}</h1>
+<ul>
+{{ range .Pages }}<li><a href="{{ .Name }}.{{ $.Ext }}">{{ .Title }}</a></li>
+{{ end -}}
+</ul>
+{{ if .ConfigMap -}}
+<h2>ConfigMap example</h2>
+<pre><code>{{ .ConfigMap }}</code></pre>
+{{ end -}}
+</body>
+</html>
+{{ end -}}
+`
+
+func jsonValue(value interface{}) string {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprintf("%v", value)
+	}
+	return string(data)
+}
+
+func yamlValue(value interface{}) string {
+	data, err := yaml.Marshal(value)
+	if err != nil {
+		return fmt.Sprintf("%v\n", value)
+	}
+	return string(data)
+}
+
+func indent(text string, prefix string) string {
+	var buf bytes.Buffer
+	for _, line := range strings.SplitAfter(text, "\n") {
+		if line == "" || line == "\n" {
+			buf.WriteString(line)
+			continue
+		}
+		buf.WriteString(prefix)
+		buf.WriteString(line)
+	}
+	return buf.String()
+}
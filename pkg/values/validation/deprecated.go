@@ -0,0 +1,249 @@
+package validation
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/go-openapi/spec"
+
+	"github.com/flant/addon-operator/pkg/utils"
+)
+
+const (
+	// XDeprecated marks a setting as deprecated. The value is true or a message for users.
+	XDeprecated = "x-deprecated"
+	// XRenamedTo moves the value of the setting to another setting before validation.
+	// The value is a name of the sibling property or a JSON pointer from the root of the section: '/https/mode'.
+	XRenamedTo = "x-renamed-to"
+)
+
+// Deprecation is a warning about a deprecated or renamed setting that is used in config values.
+type Deprecation struct {
+	// Field is a dotted path to the setting with the root key: moduleName.oldParam.
+	Field string `json:"field"`
+	// Message is a text from 'x-deprecated'.
+	Message string `json:"message,omitempty"`
+	// RenamedTo is a dotted path to the new setting if the setting is renamed.
+	RenamedTo string `json:"renamedTo,omitempty"`
+	// Ignored is true if the new setting is already set and the value of the renamed setting is dropped.
+	Ignored bool `json:"ignored,omitempty"`
+}
+
+func (d Deprecation) String() string {
+	var msg string
+	switch {
+	case d.RenamedTo != "" && d.Ignored:
+		msg = fmt.Sprintf("'%s' is renamed to '%s', value is ignored because '%s' is set", d.Field, d.RenamedTo, d.RenamedTo)
+	case d.RenamedTo != "":
+		msg = fmt.Sprintf("'%s' is renamed to '%s'", d.Field, d.RenamedTo)
+	default:
+		msg = fmt.Sprintf("'%s' is deprecated", d.Field)
+	}
+	if d.Message != "" {
+		msg += ": " + d.Message
+	}
+	return msg
+}
+
+// MigrateConfigValues moves values of settings with 'x-renamed-to' to new settings and returns
+// warnings for used settings with 'x-deprecated' or 'x-renamed-to'. Input values are not modified,
+// a copy is returned if there are renamed settings.
+func (v *ValuesValidator) MigrateConfigValues(schemaType SchemaType, moduleName string, values utils.Values) (utils.Values, []Deprecation) {
+	s := v.GetSchema(schemaType, ConfigValuesSchema, moduleName)
+	if s == nil {
+		return values, nil
+	}
+
+	rootName := utils.GlobalValuesKey
+	if schemaType == ModuleSchema {
+		rootName = moduleName
+	}
+
+	section, ok := values[rootName].(map[string]interface{})
+	if !ok {
+		return values, nil
+	}
+
+	m := &migration{rootName: rootName}
+	if hasRenames(s) {
+		section = copyValue(section).(map[string]interface{})
+		m.root = section
+		m.rename(s, section, []string{})
+		if len(m.deprecations) > 0 {
+			res := make(utils.Values, len(values))
+			for k, val := range values {
+				res[k] = val
+			}
+			res[rootName] = section
+			values = res
+		}
+	}
+	m.findDeprecated(s, section, []string{})
+
+	return values, m.deprecations
+}
+
+type migration struct {
+	rootName     string
+	root         map[string]interface{}
+	deprecations []Deprecation
+}
+
+// rename moves values of renamed properties. The new value wins if both are set.
+func (m *migration) rename(s *spec.Schema, obj map[string]interface{}, tokens []string) {
+	for _, name := range sortedPropertyNames(s) {
+		prop := s.Properties[name]
+		value, has := obj[name]
+		if !has {
+			continue
+		}
+		propTokens := appendToken(tokens, name)
+
+		renamedTo, _ := prop.Extensions[XRenamedTo].(string)
+		if renamedTo == "" {
+			m.walk(&prop, value, propTokens, m.rename)
+			continue
+		}
+
+		// A sibling property or a property from the root of the section.
+		target, targetTokens := obj, []string{renamedTo}
+		fieldTokens := appendToken(tokens, renamedTo)
+		if strings.HasPrefix(renamedTo, "/") {
+			target, targetTokens = m.root, pointerTokens(renamedTo)
+			fieldTokens = targetTokens
+		}
+		delete(obj, name)
+		_, message := deprecation(&prop)
+		m.deprecations = append(m.deprecations, Deprecation{
+			Field:     toField(m.rootName, propTokens),
+			Message:   message,
+			RenamedTo: toField(m.rootName, fieldTokens),
+			Ignored:   !setValueAt(target, targetTokens, value),
+		})
+	}
+}
+
+// findDeprecated returns warnings for deprecated properties with values.
+func (m *migration) findDeprecated(s *spec.Schema, obj map[string]interface{}, tokens []string) {
+	for _, name := range sortedPropertyNames(s) {
+		prop := s.Properties[name]
+		value, has := obj[name]
+		if !has {
+			continue
+		}
+		propTokens := appendToken(tokens, name)
+
+		if isDeprecated, message := deprecation(&prop); isDeprecated {
+			m.deprecations = append(m.deprecations, Deprecation{
+				Field:   toField(m.rootName, propTokens),
+				Message: message,
+			})
+		}
+		m.walk(&prop, value, propTokens, m.findDeprecated)
+	}
+}
+
+// walk calls fn for nested objects: object properties and array items.
+func (m *migration) walk(s *spec.Schema, value interface{}, tokens []string, fn func(*spec.Schema, map[string]interface{}, []string)) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		if len(s.Properties) > 0 {
+			fn(s, v, tokens)
+		}
+	case []interface{}:
+		if s.Items == nil || s.Items.Schema == nil || len(s.Items.Schema.Properties) == 0 {
+			return
+		}
+		for i, item := range v {
+			if itemObj, ok := item.(map[string]interface{}); ok {
+				fn(s.Items.Schema, itemObj, appendToken(tokens, strconv.Itoa(i)))
+			}
+		}
+	}
+}
+
+// deprecation returns true and a message if the schema has 'x-deprecated'.
+func deprecation(s *spec.Schema) (bool, string) {
+	switch v := s.Extensions[XDeprecated].(type) {
+	case bool:
+		return v, ""
+	case string:
+		return true, v
+	}
+	return false, ""
+}
+
+func hasRenames(s *spec.Schema) bool {
+	for _, prop := range s.Properties {
+		prop := prop
+		if _, has := prop.Extensions[XRenamedTo]; has {
+			return true
+		}
+		if hasRenames(&prop) {
+			return true
+		}
+		if prop.Items != nil && prop.Items.Schema != nil && hasRenames(prop.Items.Schema) {
+			return true
+		}
+	}
+	return false
+}
+
+func sortedPropertyNames(s *spec.Schema) []string {
+	names := propertyNames(s.Properties)
+	sort.Strings(names)
+	return names
+}
+
+func appendToken(tokens []string, token string) []string {
+	res := make([]string, 0, len(tokens)+1)
+	res = append(res, tokens...)
+	return append(res, token)
+}
+
+// setValueAt sets the value by path tokens and creates intermediate objects.
+// It returns false if the value is already set or the path goes through a non-object value.
+func setValueAt(obj map[string]interface{}, tokens []string, value interface{}) bool {
+	cur := obj
+	for i, token := range tokens {
+		if i == len(tokens)-1 {
+			if _, has := cur[token]; has {
+				return false
+			}
+			cur[token] = value
+			return true
+		}
+		next, has := cur[token]
+		if !has {
+			next = make(map[string]interface{})
+			cur[token] = next
+		}
+		nextObj, ok := next.(map[string]interface{})
+		if !ok {
+			return false
+		}
+		cur = nextObj
+	}
+	return false
+}
+
+// copyValue returns a deep copy of objects and arrays.
+func copyValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		res := make(map[string]interface{}, len(v))
+		for k, item := range v {
+			res[k] = copyValue(item)
+		}
+		return res
+	case []interface{}:
+		res := make([]interface{}, 0, len(v))
+		for _, item := range v {
+			res = append(res, copyValue(item))
+		}
+		return res
+	}
+	return value
+}
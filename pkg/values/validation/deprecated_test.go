@@ -0,0 +1,97 @@
+package validation
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+
+	"github.com/flant/addon-operator/pkg/utils"
+)
+
+func Test_MigrateConfigValues(t *testing.T) {
+	g := NewWithT(t)
+
+	configSchemaYaml := `
+type: object
+properties:
+  replicas:
+    type: integer
+  replicaCount:
+    x-renamed-to: replicas
+  debug:
+    type: boolean
+    x-deprecated: Use logLevel instead.
+  logLevel:
+    type: string
+  httpsMode:
+    x-renamed-to: /https/mode
+  https:
+    type: object
+    properties:
+      mode:
+        type: string
+  nodes:
+    type: array
+    items:
+      type: object
+      properties:
+        name:
+          type: string
+        host:
+          x-renamed-to: name
+`
+	v := NewValuesValidator()
+	err := v.SchemaStorage.AddModuleValuesSchemas("moduleName", []byte(configSchemaYaml), nil)
+	g.Expect(err).ShouldNot(HaveOccurred())
+
+	values, err := utils.NewValuesFromBytes([]byte(`
+moduleName:
+  replicaCount: 3
+  debug: true
+  httpsMode: CertManager
+  nodes:
+  - host: node-1
+  - host: node-2
+    name: node-two
+`))
+	g.Expect(err).ShouldNot(HaveOccurred())
+
+	migrated, deprecations := v.MigrateConfigValues(ModuleSchema, "moduleName", values)
+
+	expected, _ := utils.NewValuesFromBytes([]byte(`
+moduleName:
+  replicas: 3
+  debug: true
+  https:
+    mode: CertManager
+  nodes:
+  - name: node-1
+  - name: node-two
+`))
+	g.Expect(migrated).To(Equal(expected))
+	g.Expect(values["moduleName"]).To(HaveKey("replicaCount"), "input values should not be modified")
+
+	messages := make([]string, 0)
+	for _, d := range deprecations {
+		messages = append(messages, d.String())
+	}
+	g.Expect(messages).To(Equal([]string{
+		"'moduleName.httpsMode' is renamed to 'moduleName.https.mode'",
+		"'moduleName.nodes.0.host' is renamed to 'moduleName.nodes.0.name'",
+		"'moduleName.nodes.1.host' is renamed to 'moduleName.nodes.1.name', value is ignored because 'moduleName.nodes.1.name' is set",
+		"'moduleName.replicaCount' is renamed to 'moduleName.replicas'",
+		"'moduleName.debug' is deprecated: Use logLevel instead.",
+	}))
+
+	// Migrated values are valid.
+	g.Expect(v.ValidateModuleConfigValues("moduleName", migrated)).ShouldNot(HaveOccurred())
+
+	// No copy without renames.
+	values, _ = utils.NewValuesFromBytes([]byte(`
+moduleName:
+  replicas: 1
+`))
+	migrated, deprecations = v.MigrateConfigValues(ModuleSchema, "moduleName", values)
+	g.Expect(deprecations).To(BeEmpty())
+	g.Expect(migrated).To(Equal(values))
+}
@@ -0,0 +1,457 @@
+package validation
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/go-openapi/errors"
+	"github.com/go-openapi/spec"
+	"github.com/hashicorp/go-multierror"
+	"github.com/santhosh-tekuri/jsonschema/v5"
+
+	"github.com/flant/addon-operator/pkg/utils"
+)
+
+// ValidationError describes a value that doesn't match the schema.
+// Error() returns a text for logs, fields are used to render JSON in the debug server
+// and field-level messages in the validating webhook.
+type ValidationError struct {
+	// Path is a JSON pointer to the value relative to the root key: /https/mode.
+	Path string `json:"path"`
+	// Field is a dotted path with the root key: moduleName.https.mode.
+	Field string `json:"field"`
+	// Value is the offending value. It is empty for missing properties.
+	Value interface{} `json:"value,omitempty"`
+	// Keyword is the failed schema keyword: type, minimum, enum, required, etc.
+	Keyword string `json:"keyword,omitempty"`
+	// Expected is the value of the failed keyword in the schema as JSON.
+	Expected string `json:"expected,omitempty"`
+	// Title and Description are copied from the schema of the value.
+	Title       string `json:"title,omitempty"`
+	Description string `json:"description,omitempty"`
+	// Suggestion is a known property name or enum value similar to the offending one.
+	Suggestion string `json:"suggestion,omitempty"`
+	// Message is an original message from the validator.
+	Message string `json:"message"`
+}
+
+func (e *ValidationError) Error() string {
+	var sb strings.Builder
+	sb.WriteString(e.Message)
+	if value, ok := scalarString(e.Value); ok && e.Keyword != "required" && !isPropertyKeyword(e.Keyword) {
+		sb.WriteString(", got ")
+		sb.WriteString(value)
+	}
+	if e.Suggestion != "" {
+		sb.WriteString(fmt.Sprintf(". Did you mean '%s'?", e.Suggestion))
+	}
+	if hint := e.hint(); hint != "" {
+		sb.WriteString(fmt.Sprintf(" (%s)", hint))
+	}
+	return sb.String()
+}
+
+// hint returns a title or the first line of the description to help to fix the value.
+func (e *ValidationError) hint() string {
+	if e.Title != "" {
+		return e.Title
+	}
+	line, _, _ := strings.Cut(strings.TrimSpace(e.Description), "\n")
+	return strings.TrimSpace(line)
+}
+
+// ValidationErrors returns structured errors from the error returned by ValidateObject.
+// Other errors are returned as ValidationError with the message only.
+func ValidationErrors(err error) []*ValidationError {
+	if err == nil {
+		return nil
+	}
+	switch e := err.(type) {
+	case *ValidationError:
+		return []*ValidationError{e}
+	case *multierror.Error:
+		res := make([]*ValidationError, 0, len(e.Errors))
+		for _, item := range e.Errors {
+			res = append(res, ValidationErrors(item)...)
+		}
+		return res
+	}
+	return []*ValidationError{{Message: err.Error()}}
+}
+
+// redactValue sets the offending value. Values resolved from Secrets and values of sensitive
+// settings are not shown in errors. Values are not registered for masking as they may be
+// rejected, so values of sensitive settings are masked in the message explicitly.
+func redactValue(res *ValidationError, s *spec.Schema, tokens []string, value interface{}) {
+	schemas := []*spec.Schema{s}
+	res.Value = utils.RedactSecrets(redactSensitiveAt(schemas, tokens, value))
+	res.Message = utils.RedactStringsInText(res.Message, sensitiveStringsAt(schemas, tokens, value))
+}
+
+// newOpenAPIValidationError converts an error from go-openapi validator.
+func newOpenAPIValidationError(err error, dataObj interface{}, s *spec.Schema, rootName string) error {
+	verr, ok := err.(*errors.Validation)
+	if !ok {
+		return err
+	}
+
+	keyword := openAPIKeywords[verr.Code()]
+	tokens := fieldTokens(rootName, verr.Name)
+	if isPropertyKeyword(keyword) {
+		// Name is a path to the object, Value is a forbidden key.
+		if key, ok := verr.Value.(string); ok {
+			tokens = append(tokens, key)
+		}
+	}
+
+	res := &ValidationError{
+		Path:    toPointer(tokens),
+		Field:   toField(rootName, tokens),
+		Keyword: keyword,
+		Message: utils.RedactSecretsInText(verr.Error()),
+	}
+	if keyword != "required" {
+		value, _ := valueAt(dataObj, tokens)
+		redactValue(res, s, tokens, value)
+	}
+
+	if isPropertyKeyword(keyword) {
+		if len(tokens) == 0 {
+			return res
+		}
+		if parent := schemaAt(s, tokens[:len(tokens)-1]); parent != nil {
+			res.Suggestion = suggest(tokens[len(tokens)-1], propertyNames(parent.Properties))
+		}
+		return res
+	}
+
+	sub := schemaAt(s, tokens)
+	if sub == nil {
+		return res
+	}
+	res.Title = sub.Title
+	res.Description = sub.Description
+	if keyword != "required" && keyword != "" {
+		res.Expected = schemaKeyword(sub, keyword)
+	}
+	if keyword == "enum" {
+		res.Suggestion = suggestEnum(res.Value, sub.Enum)
+	}
+	return res
+}
+
+// newDraft202012ValidationError converts a leaf error from JSON Schema 2020-12 validator.
+// schemaDoc is a prepared schema as a generic object.
+func newDraft202012ValidationError(leaf *jsonschema.ValidationError, dataObj interface{}, s *spec.Schema, schemaDoc interface{}, rootName string) *ValidationError {
+	tokens := pointerTokens(leaf.InstanceLocation)
+	keywordTokens := pointerTokens(absoluteKeywordPointer(leaf))
+	keyword := ""
+	if len(keywordTokens) > 0 {
+		keyword = keywordTokens[len(keywordTokens)-1]
+	}
+
+	res := &ValidationError{
+		Path:    toPointer(tokens),
+		Field:   toField(rootName, tokens),
+		Keyword: keyword,
+		Message: utils.RedactSecretsInText(fmt.Sprintf("%s %s", toField(rootName, tokens), leaf.Message)),
+	}
+	if keyword != "required" {
+		value, _ := valueAt(dataObj, tokens)
+		redactValue(res, s, tokens, value)
+	}
+
+	// Keyword location points to the keyword, its parent is a schema with the keyword.
+	// It is a location in the schema document, so keywords behind '$ref' are found too.
+	parentPointer := toPointer(keywordTokens[:maxInt(len(keywordTokens)-1, 0)])
+	parent, err := lookupPointer(schemaDoc, parentPointer)
+	if err != nil {
+		return res
+	}
+	parentSchema, ok := parent.(map[string]interface{})
+	if !ok {
+		return res
+	}
+
+	if keyword == "unevaluatedProperties" && len(tokens) > 0 {
+		props, _ := parentSchema["properties"].(map[string]interface{})
+		names := make([]string, 0, len(props))
+		for name := range props {
+			names = append(names, name)
+		}
+		res.Suggestion = suggest(tokens[len(tokens)-1], names)
+		return res
+	}
+	if isPropertyKeyword(keyword) {
+		return res
+	}
+
+	res.Title, _ = parentSchema["title"].(string)
+	res.Description, _ = parentSchema["description"].(string)
+	if expected, has := parentSchema[keyword]; has && keyword != "required" {
+		res.Expected = jsonString(expected)
+	}
+	if enum, ok := parentSchema["enum"].([]interface{}); ok && keyword == "enum" {
+		res.Suggestion = suggestEnum(res.Value, enum)
+	}
+	return res
+}
+
+// absoluteKeywordPointer returns a JSON pointer to the failed keyword in the compiled schema.
+// KeywordLocation is a path of evaluation, it contains '$ref' and cannot be resolved in
+// the schema document. AbsoluteKeywordLocation is a URI of the keyword with resolved references.
+func absoluteKeywordPointer(leaf *jsonschema.ValidationError) string {
+	location := leaf.AbsoluteKeywordLocation
+	if !strings.HasPrefix(location, compiledSchemaURL+"#") {
+		return leaf.KeywordLocation
+	}
+	pointer, err := url.PathUnescape(strings.TrimPrefix(location, compiledSchemaURL+"#"))
+	if err != nil {
+		return leaf.KeywordLocation
+	}
+	return pointer
+}
+
+// openAPIKeywords maps codes of go-openapi errors to schema keywords.
+var openAPIKeywords = map[int32]string{
+	errors.InvalidTypeCode:           "type",
+	errors.RequiredFailCode:          "required",
+	errors.TooLongFailCode:           "maxLength",
+	errors.TooShortFailCode:          "minLength",
+	errors.PatternFailCode:           "pattern",
+	errors.EnumFailCode:              "enum",
+	errors.MultipleOfFailCode:        "multipleOf",
+	errors.MaxFailCode:               "maximum",
+	errors.MinFailCode:               "minimum",
+	errors.UniqueFailCode:            "uniqueItems",
+	errors.MaxItemsFailCode:          "maxItems",
+	errors.MinItemsFailCode:          "minItems",
+	errors.NoAdditionalItemsCode:     "additionalItems",
+	errors.TooFewPropertiesCode:      "minProperties",
+	errors.TooManyPropertiesCode:     "maxProperties",
+	errors.UnallowedPropertyCode:     "additionalProperties",
+	errors.FailedAllPatternPropsCode: "patternProperties",
+	errors.ReadOnlyFailCode:          "readOnly",
+}
+
+// isPropertyKeyword returns true if keyword forbids unknown properties.
+func isPropertyKeyword(keyword string) bool {
+	return keyword == "additionalProperties" || keyword == "unevaluatedProperties" || keyword == "patternProperties"
+}
+
+// fieldTokens splits a dotted path from go-openapi error into tokens without the root name.
+func fieldTokens(rootName string, name string) []string {
+	if name == rootName {
+		return []string{}
+	}
+	name = strings.TrimPrefix(name, rootName+".")
+	if name == "" {
+		return []string{}
+	}
+	return strings.Split(name, ".")
+}
+
+func pointerTokens(pointer string) []string {
+	res := make([]string, 0)
+	for _, token := range strings.Split(pointer, "/") {
+		if token == "" {
+			continue
+		}
+		token = strings.ReplaceAll(token, "~1", "/")
+		token = strings.ReplaceAll(token, "~0", "~")
+		res = append(res, token)
+	}
+	return res
+}
+
+func toPointer(tokens []string) string {
+	if len(tokens) == 0 {
+		return ""
+	}
+	escaped := make([]string, 0, len(tokens))
+	for _, token := range tokens {
+		token = strings.ReplaceAll(token, "~", "~0")
+		token = strings.ReplaceAll(token, "/", "~1")
+		escaped = append(escaped, token)
+	}
+	return "/" + strings.Join(escaped, "/")
+}
+
+func toField(rootName string, tokens []string) string {
+	return strings.Join(append([]string{rootName}, tokens...), ".")
+}
+
+// valueAt returns a value from the object by path tokens.
+func valueAt(obj interface{}, tokens []string) (interface{}, bool) {
+	cur := obj
+	for _, token := range tokens {
+		switch v := cur.(type) {
+		case map[string]interface{}:
+			next, has := v[token]
+			if !has {
+				return nil, false
+			}
+			cur = next
+		case []interface{}:
+			idx, err := strconv.Atoi(token)
+			if err != nil || idx < 0 || idx >= len(v) {
+				return nil, false
+			}
+			cur = v[idx]
+		default:
+			return nil, false
+		}
+	}
+	return cur, true
+}
+
+// schemaAt returns a schema for the value by path tokens.
+func schemaAt(s *spec.Schema, tokens []string) *spec.Schema {
+	cur := s
+	for _, token := range tokens {
+		if cur == nil {
+			return nil
+		}
+		if prop, has := cur.Properties[token]; has {
+			cur = &prop
+			continue
+		}
+		if cur.AdditionalProperties != nil && cur.AdditionalProperties.Schema != nil {
+			cur = cur.AdditionalProperties.Schema
+			continue
+		}
+		if cur.Items != nil {
+			if cur.Items.Schema != nil {
+				cur = cur.Items.Schema
+				continue
+			}
+			idx, err := strconv.Atoi(token)
+			if err == nil && idx >= 0 && idx < len(cur.Items.Schemas) {
+				cur = &cur.Items.Schemas[idx]
+				continue
+			}
+		}
+		return nil
+	}
+	return cur
+}
+
+// schemaKeyword returns the value of the keyword in the schema as JSON.
+func schemaKeyword(s *spec.Schema, keyword string) string {
+	schemaBytes, err := json.Marshal(s)
+	if err != nil {
+		return ""
+	}
+	var obj map[string]interface{}
+	if err := json.Unmarshal(schemaBytes, &obj); err != nil {
+		return ""
+	}
+	value, has := obj[keyword]
+	if !has {
+		return ""
+	}
+	return jsonString(value)
+}
+
+func propertyNames(props map[string]spec.Schema) []string {
+	res := make([]string, 0, len(props))
+	for name := range props {
+		res = append(res, name)
+	}
+	return res
+}
+
+func jsonString(value interface{}) string {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprintf("%v", value)
+	}
+	return string(data)
+}
+
+// scalarString returns a text for the scalar value. Objects and arrays are not printed to keep messages short.
+func scalarString(value interface{}) (string, bool) {
+	switch v := value.(type) {
+	case nil, map[string]interface{}, []interface{}:
+		return "", false
+	case string:
+		return strconv.Quote(v), true
+	}
+	return fmt.Sprintf("%v", value), true
+}
+
+// suggestEnum returns an enum value similar to the offending string value.
+func suggestEnum(value interface{}, enum []interface{}) string {
+	str, ok := value.(string)
+	if !ok {
+		return ""
+	}
+	candidates := make([]string, 0, len(enum))
+	for _, item := range enum {
+		if s, ok := item.(string); ok {
+			candidates = append(candidates, s)
+		}
+	}
+	return suggest(str, candidates)
+}
+
+// suggest returns a candidate with the minimal edit distance to the word.
+// Candidates that are too different are ignored.
+func suggest(word string, candidates []string) string {
+	sort.Strings(candidates)
+	best := ""
+	bestDistance := maxInt(len(word)/3, 1) + 1
+	for _, candidate := range candidates {
+		if candidate == word {
+			continue
+		}
+		d := levenshtein(strings.ToLower(word), strings.ToLower(candidate))
+		if d < bestDistance {
+			best, bestDistance = candidate, d
+		}
+	}
+	return best
+}
+
+// levenshtein returns the edit distance between two strings.
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = minInt(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(rb)]
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+func minInt(values ...int) int {
+	res := values[0]
+	for _, v := range values[1:] {
+		if v < res {
+			res = v
+		}
+	}
+	return res
+}
@@ -0,0 +1,182 @@
+package validation
+
+import (
+	"encoding/json"
+	"testing"
+
+	. "github.com/onsi/gomega"
+
+	"github.com/flant/addon-operator/pkg/utils"
+)
+
+func Test_ValidationErrors_OpenAPI(t *testing.T) {
+	g := NewWithT(t)
+
+	moduleValues, err := utils.NewValuesFromBytes([]byte(`
+moduleName:
+  replica: 2
+  https:
+    mode: CertManagr
+  port: 0
+`))
+	g.Expect(err).ShouldNot(HaveOccurred())
+
+	configSchemaYaml := `
+type: object
+additionalProperties: false
+required:
+- storageClass
+properties:
+  storageClass:
+    type: string
+    description: |
+      Storage class for PVC.
+      Empty value means default storage class.
+  replicas:
+    type: integer
+  https:
+    type: object
+    properties:
+      mode:
+        type: string
+        title: The mode of HTTPS certificates
+        enum: [CertManager, CustomCertificate, Disabled]
+  port:
+    type: integer
+    minimum: 1
+`
+	v := NewValuesValidator()
+	err = v.SchemaStorage.AddModuleValuesSchemas("moduleName", []byte(configSchemaYaml), nil)
+	g.Expect(err).ShouldNot(HaveOccurred())
+
+	mErr := v.ValidateModuleConfigValues("moduleName", moduleValues)
+	g.Expect(mErr).Should(HaveOccurred())
+
+	errs := map[string]*ValidationError{}
+	for _, e := range ValidationErrors(mErr) {
+		errs[e.Keyword] = e
+	}
+	g.Expect(errs).Should(HaveLen(4), mErr.Error())
+
+	g.Expect(errs["additionalProperties"].Path).Should(Equal("/replica"))
+	g.Expect(errs["additionalProperties"].Suggestion).Should(Equal("replicas"))
+	g.Expect(errs["additionalProperties"].Error()).Should(Equal("moduleName.replica is a forbidden property. Did you mean 'replicas'?"))
+
+	g.Expect(errs["required"].Path).Should(Equal("/storageClass"))
+	g.Expect(errs["required"].Value).Should(BeNil())
+	g.Expect(errs["required"].Error()).Should(Equal("moduleName.storageClass is required (Storage class for PVC.)"))
+
+	g.Expect(errs["enum"].Path).Should(Equal("/https/mode"))
+	g.Expect(errs["enum"].Field).Should(Equal("moduleName.https.mode"))
+	g.Expect(errs["enum"].Value).Should(Equal("CertManagr"))
+	g.Expect(errs["enum"].Expected).Should(Equal(`["CertManager","CustomCertificate","Disabled"]`))
+	g.Expect(errs["enum"].Suggestion).Should(Equal("CertManager"))
+	g.Expect(errs["enum"].Error()).Should(ContainSubstring(`, got "CertManagr". Did you mean 'CertManager'? (The mode of HTTPS certificates)`))
+
+	g.Expect(errs["minimum"].Path).Should(Equal("/port"))
+	g.Expect(errs["minimum"].Expected).Should(Equal("1"))
+	g.Expect(errs["minimum"].Error()).Should(Equal("moduleName.port should be greater than or equal to 1, got 0"))
+
+	// JSON for the debug server.
+	data, err := json.Marshal(errs["minimum"])
+	g.Expect(err).ShouldNot(HaveOccurred())
+	g.Expect(string(data)).Should(MatchJSON(`{
+  "path": "/port",
+  "field": "moduleName.port",
+  "value": 0,
+  "keyword": "minimum",
+  "expected": "1",
+  "message": "moduleName.port should be greater than or equal to 1"
+}`))
+}
+
+func Test_ValidationErrors_Draft202012(t *testing.T) {
+	g := NewWithT(t)
+
+	moduleValues, err := utils.NewValuesFromBytes([]byte(`
+moduleName:
+  replica: 2
+  port: 0
+`))
+	g.Expect(err).ShouldNot(HaveOccurred())
+
+	configSchemaYaml := `
+$schema: "https://json-schema.org/draft/2020-12/schema"
+type: object
+properties:
+  replicas:
+    type: integer
+  port:
+    type: integer
+    title: Port to listen
+    minimum: 1
+`
+	v := NewValuesValidator()
+	err = v.SchemaStorage.AddModuleValuesSchemas("moduleName", []byte(configSchemaYaml), nil)
+	g.Expect(err).ShouldNot(HaveOccurred())
+
+	mErr := v.ValidateModuleConfigValues("moduleName", moduleValues)
+	g.Expect(mErr).Should(HaveOccurred())
+
+	errs := map[string]*ValidationError{}
+	for _, e := range ValidationErrors(mErr) {
+		errs[e.Keyword] = e
+	}
+	g.Expect(errs).Should(HaveLen(2), mErr.Error())
+
+	g.Expect(errs["unevaluatedProperties"].Path).Should(Equal("/replica"))
+	g.Expect(errs["unevaluatedProperties"].Suggestion).Should(Equal("replicas"))
+
+	g.Expect(errs["minimum"].Path).Should(Equal("/port"))
+	g.Expect(errs["minimum"].Expected).Should(Equal("1"))
+	g.Expect(errs["minimum"].Title).Should(Equal("Port to listen"))
+}
+
+// Keywords behind '$ref' should be found in the schema to fill Expected and Title.
+func Test_ValidationErrors_Draft202012_Ref(t *testing.T) {
+	g := NewWithT(t)
+
+	moduleValues, err := utils.NewValuesFromBytes([]byte(`
+moduleName:
+  port: 0
+`))
+	g.Expect(err).ShouldNot(HaveOccurred())
+
+	configSchemaYaml := `
+$schema: "https://json-schema.org/draft/2020-12/schema"
+type: object
+properties:
+  port:
+    $ref: "#/$defs/port"
+$defs:
+  port:
+    type: integer
+    title: Port to listen
+    description: TCP port of the server.
+    minimum: 1
+`
+	v := NewValuesValidator()
+	err = v.SchemaStorage.AddModuleValuesSchemas("moduleName", []byte(configSchemaYaml), nil)
+	g.Expect(err).ShouldNot(HaveOccurred())
+
+	mErr := v.ValidateModuleConfigValues("moduleName", moduleValues)
+	g.Expect(mErr).Should(HaveOccurred())
+
+	errs := ValidationErrors(mErr)
+	g.Expect(errs).Should(HaveLen(1), mErr.Error())
+	g.Expect(errs[0].Path).Should(Equal("/port"))
+	g.Expect(errs[0].Keyword).Should(Equal("minimum"))
+	g.Expect(errs[0].Expected).Should(Equal("1"))
+	g.Expect(errs[0].Title).Should(Equal("Port to listen"))
+	g.Expect(errs[0].Description).Should(Equal("TCP port of the server."))
+}
+
+func Test_suggest(t *testing.T) {
+	g := NewWithT(t)
+
+	candidates := []string{"onStartup", "schedule", "kubernetes"}
+	g.Expect(suggest("onStartuppp", candidates)).Should(Equal("onStartup"))
+	g.Expect(suggest("Schedule", candidates)).Should(Equal("schedule"))
+	g.Expect(suggest("kubernetes", candidates)).Should(Equal(""))
+	g.Expect(suggest("settings", candidates)).Should(Equal(""))
+}
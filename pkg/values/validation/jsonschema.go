@@ -0,0 +1,87 @@
+package validation
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"github.com/go-openapi/spec"
+	"github.com/hashicorp/go-multierror"
+	"github.com/santhosh-tekuri/jsonschema/v5"
+)
+
+// compiledSchemaURL is a fake location of the compiled schema. It is used in '$ref' resolution.
+const compiledSchemaURL = "file:///values.schema.json"
+
+// compileDraft202012 compiles the prepared schema with the JSON Schema 2020-12 validator.
+func compileDraft202012(s *spec.Schema) (*jsonschema.Schema, error) {
+	schemaBytes, err := s.MarshalJSON()
+	if err != nil {
+		return nil, fmt.Errorf("marshal schema: %v", err)
+	}
+
+	compiler := jsonschema.NewCompiler()
+	compiler.Draft = jsonschema.Draft2020
+	compiler.AssertFormat = true
+	err = compiler.AddResource(compiledSchemaURL, bytes.NewReader(schemaBytes))
+	if err != nil {
+		return nil, err
+	}
+	return compiler.Compile(compiledSchemaURL)
+}
+
+// validateDraft202012 validates dataObj with the compiled JSON Schema 2020-12 validator for the schema s
+// and returns an error for each failed keyword.
+func validateDraft202012(dataObj interface{}, s *spec.Schema, compiled *jsonschema.Schema, rootName string) error {
+	// Values from Go hooks may contain arbitrary Go types, validator expects JSON types.
+	dataBytes, err := json.Marshal(dataObj)
+	if err != nil {
+		return fmt.Errorf("marshal values: %v", err)
+	}
+	var obj interface{}
+	dec := json.NewDecoder(bytes.NewReader(dataBytes))
+	dec.UseNumber()
+	if err := dec.Decode(&obj); err != nil {
+		return fmt.Errorf("unmarshal values: %v", err)
+	}
+
+	err = compiled.Validate(obj)
+	if err == nil {
+		return nil
+	}
+	validationErr, ok := err.(*jsonschema.ValidationError)
+	if !ok {
+		return err
+	}
+
+	// Schema as a generic object to get keywords by the keyword location.
+	var schemaDoc interface{}
+	schemaBytes, err := s.MarshalJSON()
+	if err == nil {
+		err = json.Unmarshal(schemaBytes, &schemaDoc)
+	}
+	if err != nil {
+		return fmt.Errorf("marshal schema: %v", err)
+	}
+
+	var allErrs *multierror.Error
+	for _, leaf := range leafValidationErrors(validationErr) {
+		allErrs = multierror.Append(allErrs, newDraft202012ValidationError(leaf, obj, s, schemaDoc, rootName))
+	}
+	if allErrs == nil || allErrs.Len() == 0 {
+		allErrs = multierror.Append(allErrs, fmt.Errorf("configuration is not valid"))
+	}
+	return allErrs.ErrorOrNil()
+}
+
+// leafValidationErrors returns errors without causes: they describe the failed keywords.
+func leafValidationErrors(err *jsonschema.ValidationError) []*jsonschema.ValidationError {
+	if len(err.Causes) == 0 {
+		return []*jsonschema.ValidationError{err}
+	}
+	res := make([]*jsonschema.ValidationError, 0)
+	for _, cause := range err.Causes {
+		res = append(res, leafValidationErrors(cause)...)
+	}
+	return res
+}
@@ -0,0 +1,156 @@
+package validation
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+
+	"github.com/flant/addon-operator/pkg/utils"
+)
+
+const draft202012ConfigSchema = `
+$schema: https://json-schema.org/draft/2020-12/schema
+type: object
+$defs:
+  port:
+    type: integer
+    minimum: 1
+properties:
+  mode:
+    enum: [http, https]
+  port:
+    $ref: "#/$defs/port"
+  tls:
+    type: object
+    properties:
+      secretName:
+        type: string
+  version:
+    const: 2
+dependentRequired:
+  tls: [port]
+if:
+  required: [mode]
+  properties:
+    mode:
+      const: https
+then:
+  required: [tls]
+  properties:
+    cert:
+      type: string
+`
+
+func Test_Validate_Draft202012(t *testing.T) {
+	v := NewValuesValidator()
+	err := v.SchemaStorage.AddModuleValuesSchemas("moduleName", []byte(draft202012ConfigSchema), nil)
+	NewWithT(t).Expect(err).ShouldNot(HaveOccurred())
+
+	tests := []struct {
+		name   string
+		values string
+		errors []string
+	}{
+		{"valid", `{mode: http, port: 80, version: 2}`, nil},
+		{"valid with if/then", `{mode: https, port: 443, tls: {secretName: tls}, cert: pem}`, nil},
+		{"$ref", `{port: 0}`, []string{"moduleName.port must be >= 1 but found 0"}},
+		{"const", `{version: 1}`, []string{`moduleName.version value must be "2"`}},
+		{"dependentRequired", `{tls: {secretName: tls}}`, []string{"moduleName property 'port' is required, if 'tls' property exists"}},
+		{"if/then", `{mode: https}`, []string{"moduleName missing properties: 'tls'"}},
+		{"unevaluatedProperties", `{mode: http, cert: pem}`, []string{"moduleName.cert not allowed"}},
+		{"nested unevaluatedProperties", `{tls: {secretName: tls, key: 1}, port: 1}`, []string{"moduleName.tls.key not allowed"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			values, err := utils.NewValuesFromBytes([]byte("moduleName: " + tt.values))
+			g.Expect(err).ShouldNot(HaveOccurred())
+
+			err = v.ValidateModuleConfigValues("moduleName", values)
+			if tt.errors == nil {
+				g.Expect(err).ShouldNot(HaveOccurred())
+				return
+			}
+			g.Expect(err).Should(HaveOccurred())
+			for _, msg := range tt.errors {
+				g.Expect(err.Error()).To(ContainSubstring(msg))
+			}
+		})
+	}
+}
+
+func Test_Validate_Draft202012_Validators(t *testing.T) {
+	g := NewWithT(t)
+
+	v := NewValuesValidator()
+	err := v.SchemaStorage.AddModuleValuesSchemas("moduleName", []byte(draft202012ConfigSchema), nil)
+	g.Expect(err).ShouldNot(HaveOccurred())
+	g.Expect(v.SchemaStorage.moduleValidator("moduleName", ConfigValuesSchema)).ShouldNot(BeNil())
+
+	// Validators are replaced with schemas.
+	err = v.SchemaStorage.AddModuleValuesSchemas("moduleName", []byte("type: object\n"), nil)
+	g.Expect(err).ShouldNot(HaveOccurred())
+	g.Expect(v.SchemaStorage.moduleValidator("moduleName", ConfigValuesSchema)).Should(BeNil())
+
+	err = v.SchemaStorage.AddGlobalValuesSchemas([]byte(draft202012ConfigSchema), nil)
+	g.Expect(err).ShouldNot(HaveOccurred())
+	g.Expect(v.SchemaStorage.globalValidator(ConfigValuesSchema)).ShouldNot(BeNil())
+	g.Expect(v.ValidateGlobalConfigValues(utils.Values{"global": map[string]interface{}{"port": 0}})).Should(HaveOccurred())
+}
+
+func Test_Validate_Draft202012_Extend(t *testing.T) {
+	g := NewWithT(t)
+
+	v := NewValuesValidator()
+	err := v.SchemaStorage.AddModuleValuesSchemas("moduleName", []byte(draft202012ConfigSchema), []byte(`
+x-extend:
+  schema: config-values.yaml
+type: object
+properties:
+  internal:
+    type: object
+    properties:
+      ready:
+        type: boolean
+`))
+	g.Expect(err).ShouldNot(HaveOccurred())
+
+	values, err := utils.NewValuesFromBytes([]byte(`
+moduleName:
+  mode: https
+  port: 443
+  tls:
+    secretName: tls
+  internal:
+    ready: true
+`))
+	g.Expect(err).ShouldNot(HaveOccurred())
+	g.Expect(v.ValidateModuleValues("moduleName", values)).Should(Succeed())
+
+	values, err = utils.NewValuesFromBytes([]byte(`
+moduleName:
+  mode: https
+  internal:
+    ready: true
+`))
+	g.Expect(err).ShouldNot(HaveOccurred())
+	err = v.ValidateModuleValues("moduleName", values)
+	g.Expect(err).Should(HaveOccurred())
+	g.Expect(err.Error()).To(ContainSubstring("missing properties: 'tls'"))
+}
+
+func Test_Validate_Draft202012_InvalidSchema(t *testing.T) {
+	g := NewWithT(t)
+
+	v := NewValuesValidator()
+	err := v.SchemaStorage.AddModuleValuesSchemas("moduleName", []byte(`
+$schema: https://json-schema.org/draft/2020-12/schema
+type: object
+properties:
+  param:
+    $ref: "#/$defs/missing"
+`), nil)
+	g.Expect(err).Should(HaveOccurred())
+}
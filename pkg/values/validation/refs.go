@@ -0,0 +1,236 @@
+package validation
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// RefAliasPrefix starts an alias of a directory with shared schemas in '$ref': '@common/resources.yaml#/requests'.
+const RefAliasPrefix = "@"
+
+// WithRefRoot adds directories to resolve '$ref' with the alias. Directories are searched in order.
+func (st *SchemaStorage) WithRefRoot(alias string, dirs ...string) {
+	if st.RefRoots == nil {
+		st.RefRoots = make(map[string][]string)
+	}
+	st.RefRoots[alias] = append(st.RefRoots[alias], dirs...)
+}
+
+// ResolveRefs inlines '$ref' to other files into the schema loaded from schemaPath.
+// Paths in '$ref' are relative to the file with the '$ref' or start with the alias: '@common/file.yaml'.
+// Local '$ref' in the schema itself are kept, local '$ref' in other files are inlined too.
+func (st *SchemaStorage) ResolveRefs(schemaBytes []byte, schemaPath string) ([]byte, error) {
+	if len(schemaBytes) == 0 {
+		return schemaBytes, nil
+	}
+
+	r := &refResolver{
+		roots: st.RefRoots,
+		docs:  make(map[string]interface{}),
+	}
+	doc, err := yamlToObject(schemaBytes)
+	if err != nil {
+		return nil, fmt.Errorf("load '%s': %v", schemaPath, err)
+	}
+	r.docs[schemaPath] = doc
+
+	resolved, err := r.walk(doc, schemaPath, true)
+	if err != nil {
+		return nil, err
+	}
+	if !r.resolved {
+		return schemaBytes, nil
+	}
+	// JSON is a valid YAML.
+	return json.Marshal(resolved)
+}
+
+type refResolver struct {
+	roots map[string][]string
+	// docs are loaded schema files by path.
+	docs map[string]interface{}
+	// stack is a chain of '$ref' being resolved to detect cycles.
+	stack []string
+	// resolved is true if at least one '$ref' is inlined.
+	resolved bool
+}
+
+// walk returns a copy of the node with resolved '$ref'. isRoot is true for the schema file itself.
+func (r *refResolver) walk(node interface{}, path string, isRoot bool) (interface{}, error) {
+	switch v := node.(type) {
+	case map[string]interface{}:
+		ref, hasRef := v["$ref"].(string)
+		if hasRef && !(isRoot && strings.HasPrefix(ref, "#")) {
+			return r.resolve(v, ref, path, isRoot)
+		}
+		res := make(map[string]interface{}, len(v))
+		for key, value := range v {
+			resolved, err := r.walk(value, path, isRoot)
+			if err != nil {
+				return nil, err
+			}
+			res[key] = resolved
+		}
+		return res, nil
+	case []interface{}:
+		res := make([]interface{}, 0, len(v))
+		for _, item := range v {
+			resolved, err := r.walk(item, path, isRoot)
+			if err != nil {
+				return nil, err
+			}
+			res = append(res, resolved)
+		}
+		return res, nil
+	}
+	return node, nil
+}
+
+// resolve returns the schema from '$ref' merged with sibling keywords of '$ref'.
+func (r *refResolver) resolve(node map[string]interface{}, ref string, path string, isRoot bool) (interface{}, error) {
+	refPath, pointer := ref, ""
+	if idx := strings.Index(ref, "#"); idx >= 0 {
+		refPath, pointer = ref[:idx], ref[idx+1:]
+	}
+
+	targetPath := path
+	if refPath != "" {
+		var err error
+		targetPath, err = r.targetPath(refPath, path)
+		if err != nil {
+			return nil, fmt.Errorf("resolve $ref '%s' in '%s': %v", ref, path, err)
+		}
+	}
+
+	key := targetPath + "#" + pointer
+	for i, item := range r.stack {
+		if item == key {
+			return nil, fmt.Errorf("resolve $ref '%s' in '%s': cycle detected: %s -> %s", ref, path, strings.Join(r.stack[i:], " -> "), key)
+		}
+	}
+
+	doc, err := r.load(targetPath)
+	if err != nil {
+		return nil, fmt.Errorf("resolve $ref '%s' in '%s': %v", ref, path, err)
+	}
+	target, err := lookupPointer(doc, pointer)
+	if err != nil {
+		return nil, fmt.Errorf("resolve $ref '%s' in '%s': %v", ref, path, err)
+	}
+
+	r.resolved = true
+	r.stack = append(r.stack, key)
+	resolved, err := r.walk(target, targetPath, false)
+	r.stack = r.stack[:len(r.stack)-1]
+	if err != nil {
+		return nil, err
+	}
+
+	if len(node) == 1 {
+		return resolved, nil
+	}
+	resolvedMap, ok := resolved.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("resolve $ref '%s' in '%s': target is not an object, it can't be merged with other keywords", ref, path)
+	}
+	// Keywords next to '$ref' override keywords from the target schema.
+	for k, v := range node {
+		if k == "$ref" {
+			continue
+		}
+		sibling, err := r.walk(v, path, isRoot)
+		if err != nil {
+			return nil, err
+		}
+		resolvedMap[k] = sibling
+	}
+	return resolvedMap, nil
+}
+
+// targetPath returns the path of the file in '$ref'.
+func (r *refResolver) targetPath(refPath string, path string) (string, error) {
+	if !strings.HasPrefix(refPath, RefAliasPrefix) {
+		if filepath.IsAbs(refPath) {
+			return refPath, nil
+		}
+		return filepath.Join(filepath.Dir(path), refPath), nil
+	}
+
+	alias, rest, _ := strings.Cut(strings.TrimPrefix(refPath, RefAliasPrefix), "/")
+	dirs, has := r.roots[alias]
+	if !has {
+		return "", fmt.Errorf("unknown alias '%s%s'", RefAliasPrefix, alias)
+	}
+	for _, dir := range dirs {
+		target := filepath.Join(dir, rest)
+		if _, err := os.Stat(target); err == nil {
+			return target, nil
+		}
+	}
+	return "", fmt.Errorf("file '%s' is not found in %v", rest, dirs)
+}
+
+func (r *refResolver) load(path string) (interface{}, error) {
+	if doc, has := r.docs[path]; has {
+		return doc, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	doc, err := yamlToObject(data)
+	if err != nil {
+		return nil, fmt.Errorf("load '%s': %v", path, err)
+	}
+	r.docs[path] = doc
+	return doc, nil
+}
+
+func yamlToObject(data []byte) (interface{}, error) {
+	jsonDoc, err := YAMLBytesToJSONDoc(data)
+	if err != nil {
+		return nil, err
+	}
+	var obj interface{}
+	if err := json.Unmarshal(jsonDoc, &obj); err != nil {
+		return nil, err
+	}
+	return obj, nil
+}
+
+// lookupPointer returns the value by the JSON pointer.
+func lookupPointer(doc interface{}, pointer string) (interface{}, error) {
+	if pointer == "" || pointer == "/" {
+		return doc, nil
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, fmt.Errorf("pointer '%s' should start with '/'", pointer)
+	}
+
+	cur := doc
+	for _, token := range strings.Split(pointer[1:], "/") {
+		token = strings.ReplaceAll(token, "~1", "/")
+		token = strings.ReplaceAll(token, "~0", "~")
+		switch v := cur.(type) {
+		case map[string]interface{}:
+			next, has := v[token]
+			if !has {
+				return nil, fmt.Errorf("pointer '%s': key '%s' is not found", pointer, token)
+			}
+			cur = next
+		case []interface{}:
+			idx, err := strconv.Atoi(token)
+			if err != nil || idx < 0 || idx >= len(v) {
+				return nil, fmt.Errorf("pointer '%s': index '%s' is out of range", pointer, token)
+			}
+			cur = v[idx]
+		default:
+			return nil, fmt.Errorf("pointer '%s': '%s' is not an object or an array", pointer, token)
+		}
+	}
+	return cur, nil
+}
@@ -0,0 +1,187 @@
+package validation
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/onsi/gomega"
+
+	"github.com/flant/addon-operator/pkg/utils"
+)
+
+func writeSchemaFiles(t *testing.T, files map[string]string) string {
+	dir := t.TempDir()
+	for path, content := range files {
+		fullPath := filepath.Join(dir, path)
+		if err := os.MkdirAll(filepath.Dir(fullPath), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(fullPath, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func Test_ResolveRefs(t *testing.T) {
+	g := NewWithT(t)
+
+	dir := writeSchemaFiles(t, map[string]string{
+		"modules/openapi-common/resources.yaml": `
+requests:
+  type: object
+  properties:
+    cpu:
+      $ref: "#/definitions/quantity"
+    memory:
+      $ref: "#/definitions/quantity"
+definitions:
+  quantity:
+    type: string
+    pattern: "^[0-9]+m?$"
+`,
+		"modules/openapi-common/node.yaml": `
+type: object
+properties:
+  nodeSelector:
+    type: object
+    additionalProperties:
+      type: string
+`,
+		"global/openapi/config-values.yaml": `
+type: object
+properties:
+  clusterDomain:
+    type: string
+`,
+		"modules/001-module-one/openapi/config-values.yaml": `
+type: object
+definitions:
+  replicas:
+    type: integer
+properties:
+  replicas:
+    $ref: "#/definitions/replicas"
+  resources:
+    $ref: "@common/resources.yaml#/requests"
+    description: Resources for the main container.
+  placement:
+    $ref: "../../openapi-common/node.yaml"
+  domain:
+    $ref: "@global/config-values.yaml#/properties/clusterDomain"
+`,
+	})
+
+	st := NewSchemaStorage()
+	st.WithRefRoot("common", filepath.Join(dir, "modules/openapi-common"))
+	st.WithRefRoot("global", filepath.Join(dir, "global/openapi"))
+
+	schemaPath := filepath.Join(dir, "modules/001-module-one/openapi/config-values.yaml")
+	schemaBytes, err := os.ReadFile(schemaPath)
+	g.Expect(err).ShouldNot(HaveOccurred())
+
+	resolved, err := st.ResolveRefs(schemaBytes, schemaPath)
+	g.Expect(err).ShouldNot(HaveOccurred())
+
+	err = st.AddModuleValuesSchemas("moduleOne", resolved, nil)
+	g.Expect(err).ShouldNot(HaveOccurred())
+
+	s := st.ModuleValuesSchema("moduleOne", ConfigValuesSchema)
+	g.Expect(s.Properties["resources"].Description).To(Equal("Resources for the main container."))
+	g.Expect(s.Properties["resources"].Properties["cpu"].Pattern).To(Equal("^[0-9]+m?$"))
+
+	v := &ValuesValidator{SchemaStorage: st}
+	values, err := utils.NewValuesFromBytes([]byte(`
+moduleOne:
+  replicas: 2
+  resources:
+    cpu: 100m
+  placement:
+    nodeSelector:
+      role: worker
+  domain: cluster.local
+`))
+	g.Expect(err).ShouldNot(HaveOccurred())
+	g.Expect(v.ValidateModuleConfigValues("moduleOne", values)).Should(Succeed())
+
+	values, err = utils.NewValuesFromBytes([]byte(`
+moduleOne:
+  resources:
+    cpu: lots
+`))
+	g.Expect(err).ShouldNot(HaveOccurred())
+	g.Expect(v.ValidateModuleConfigValues("moduleOne", values)).ShouldNot(Succeed())
+}
+
+func Test_ResolveRefs_NoExternalRefs(t *testing.T) {
+	g := NewWithT(t)
+
+	schemaBytes := []byte(`
+type: object
+properties:
+  param:
+    $ref: "#/definitions/param"
+definitions:
+  param:
+    type: string
+`)
+	resolved, err := NewSchemaStorage().ResolveRefs(schemaBytes, "/modules/001-module-one/openapi/config-values.yaml")
+	g.Expect(err).ShouldNot(HaveOccurred())
+	g.Expect(resolved).To(Equal(schemaBytes))
+}
+
+func Test_ResolveRefs_Errors(t *testing.T) {
+	dir := writeSchemaFiles(t, map[string]string{
+		"common/a.yaml": `
+type: object
+properties:
+  b:
+    $ref: "b.yaml"
+`,
+		"common/b.yaml": `
+type: object
+properties:
+  a:
+    $ref: "a.yaml"
+`,
+		"common/self.yaml": `
+node:
+  type: object
+  properties:
+    child:
+      $ref: "#/node"
+`,
+	})
+
+	tests := []struct {
+		name  string
+		ref   string
+		error string
+	}{
+		{"cycle between files", "@common/a.yaml", "cycle detected"},
+		{"recursive definition", "@common/self.yaml#/node", "cycle detected"},
+		{"missing file", "@common/missing.yaml", "file 'missing.yaml' is not found"},
+		{"unknown alias", "@unknown/a.yaml", "unknown alias '@unknown'"},
+		{"missing pointer", "@common/a.yaml#/definitions/missing", "key 'definitions' is not found"},
+	}
+
+	st := NewSchemaStorage()
+	st.WithRefRoot("common", filepath.Join(dir, "common"))
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			schemaBytes := []byte(`
+type: object
+properties:
+  param:
+    $ref: "` + tt.ref + `"
+`)
+			_, err := st.ResolveRefs(schemaBytes, filepath.Join(dir, "module/openapi/config-values.yaml"))
+			g.Expect(err).Should(HaveOccurred())
+			g.Expect(err.Error()).To(ContainSubstring(tt.error))
+		})
+	}
+}
@@ -2,8 +2,13 @@ package schema
 
 import "github.com/go-openapi/spec"
 
+const unevaluatedPropertiesKey = "unevaluatedProperties"
+
 type AdditionalPropertiesTransformer struct {
 	Parent *spec.Schema
+	// Unevaluated sets 'unevaluatedProperties' instead of 'additionalProperties' for
+	// JSON Schema 2020-12, so properties from allOf, if/then and $ref are allowed.
+	Unevaluated bool
 }
 
 // Transform sets undefined AdditionalProperties to false recursively.
@@ -12,17 +17,13 @@ func (t *AdditionalPropertiesTransformer) Transform(s *spec.Schema) *spec.Schema
 		return nil
 	}
 
-	if s.AdditionalProperties == nil {
-		s.AdditionalProperties = &spec.SchemaOrBool{
-			Allows: false,
-		}
+	if t.isUndefined(s) {
+		t.disallow(s)
 	}
 
 	for k, prop := range s.Properties {
-		if prop.AdditionalProperties == nil {
-			prop.AdditionalProperties = &spec.SchemaOrBool{
-				Allows: false,
-			}
+		if t.isUndefined(&prop) {
+			t.disallow(&prop)
 			ts := prop
 			s.Properties[k] = *t.Transform(&ts)
 		}
@@ -40,3 +41,27 @@ func (t *AdditionalPropertiesTransformer) Transform(s *spec.Schema) *spec.Schema
 
 	return s
 }
+
+func (t *AdditionalPropertiesTransformer) isUndefined(s *spec.Schema) bool {
+	if s.AdditionalProperties != nil {
+		return false
+	}
+	if t.Unevaluated {
+		_, has := s.ExtraProps[unevaluatedPropertiesKey]
+		return !has
+	}
+	return true
+}
+
+func (t *AdditionalPropertiesTransformer) disallow(s *spec.Schema) {
+	if t.Unevaluated {
+		if s.ExtraProps == nil {
+			s.ExtraProps = make(map[string]interface{})
+		}
+		s.ExtraProps[unevaluatedPropertiesKey] = false
+		return
+	}
+	s.AdditionalProperties = &spec.SchemaOrBool{
+		Allows: false,
+	}
+}
@@ -0,0 +1,19 @@
+package schema
+
+import (
+	"strings"
+
+	"github.com/go-openapi/spec"
+)
+
+// Draft202012 is a '$schema' value to validate values with JSON Schema draft 2020-12
+// instead of the OpenAPI v2 subset.
+const Draft202012 = "https://json-schema.org/draft/2020-12/schema"
+
+// IsDraft202012 returns true if the schema declares JSON Schema draft 2020-12 in '$schema'.
+func IsDraft202012(s *spec.Schema) bool {
+	if s == nil {
+		return false
+	}
+	return strings.TrimSuffix(string(s.Schema), "#") == Draft202012
+}
@@ -31,6 +31,10 @@ func (t *ExtendTransformer) Transform(s *spec.Schema) *spec.Schema {
 
 	s.Definitions = mergeDefinitions(s, t.Parent)
 	s.Extensions = mergeExtensions(s, t.Parent)
+	s.ExtraProps = mergeExtraProps(s, t.Parent)
+	if s.Schema == "" {
+		s.Schema = t.Parent.Schema
+	}
 	s.Required = mergeRequired(s, t.Parent)
 	s.Properties = mergeProperties(s, t.Parent)
 	s.PatternProperties = mergePatternProperties(s, t.Parent)
@@ -132,6 +136,23 @@ func mergeExtensions(s *spec.Schema, parent *spec.Schema) spec.Extensions {
 	return ext
 }
 
+// mergeExtraProps merges keywords unknown to OpenAPI v2, e.g. '$defs' or 'if' from JSON Schema 2020-12.
+func mergeExtraProps(s *spec.Schema, parent *spec.Schema) map[string]interface{} {
+	if len(s.ExtraProps) == 0 && len(parent.ExtraProps) == 0 {
+		return nil
+	}
+	res := make(map[string]interface{})
+
+	for k, v := range parent.ExtraProps {
+		res[k] = v
+	}
+	for k, v := range s.ExtraProps {
+		res[k] = v
+	}
+
+	return res
+}
+
 func mergeTitle(s *spec.Schema, parent *spec.Schema) string {
 	if s.Title != "" {
 		return s.Title
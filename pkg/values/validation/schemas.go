@@ -8,6 +8,7 @@ import (
 	"github.com/go-openapi/loads"
 	"github.com/go-openapi/spec"
 	"github.com/go-openapi/swag"
+	"github.com/santhosh-tekuri/jsonschema/v5"
 	"sigs.k8s.io/yaml"
 
 	"github.com/flant/addon-operator/pkg/values/validation/schema"
@@ -55,12 +56,21 @@ func YAMLDocLoader(path string) (json.RawMessage, error) {
 type SchemaStorage struct {
 	GlobalSchemas map[SchemaType]*spec.Schema
 	ModuleSchemas map[string]map[SchemaType]*spec.Schema
+	// RefRoots are directories with shared schemas for '$ref' aliases.
+	RefRoots map[string][]string
+
+	// Compiled validators for JSON Schema 2020-12 schemas. They are stored
+	// and replaced together with GlobalSchemas and ModuleSchemas.
+	globalValidators map[SchemaType]*jsonschema.Schema
+	moduleValidators map[string]map[SchemaType]*jsonschema.Schema
 }
 
 func NewSchemaStorage() *SchemaStorage {
 	return &SchemaStorage{
-		GlobalSchemas: map[SchemaType]*spec.Schema{},
-		ModuleSchemas: map[string]map[SchemaType]*spec.Schema{},
+		GlobalSchemas:    map[SchemaType]*spec.Schema{},
+		ModuleSchemas:    map[string]map[SchemaType]*spec.Schema{},
+		globalValidators: map[SchemaType]*jsonschema.Schema{},
+		moduleValidators: map[string]map[SchemaType]*jsonschema.Schema{},
 	}
 }
 
@@ -79,19 +89,32 @@ func (st *SchemaStorage) ModuleValuesSchema(moduleName string, schemaType Schema
 	return st.ModuleSchemas[moduleName][schemaType]
 }
 
+// globalValidator returns the compiled validator for the global schema or nil
+// if the schema is not a JSON Schema 2020-12.
+func (st *SchemaStorage) globalValidator(schemaType SchemaType) *jsonschema.Schema {
+	return st.globalValidators[schemaType]
+}
+
+// moduleValidator returns the compiled validator for the module schema or nil
+// if the schema is not a JSON Schema 2020-12.
+func (st *SchemaStorage) moduleValidator(moduleName string, schemaType SchemaType) *jsonschema.Schema {
+	return st.moduleValidators[moduleName][schemaType]
+}
+
 // AddGlobalValuesSchemas prepares and stores three schemas: config, config+values, config+values+required.
 func (st *SchemaStorage) AddGlobalValuesSchemas(configBytes, valuesBytes []byte) error {
-	schemas, err := PrepareSchemas(configBytes, valuesBytes)
+	schemas, validators, err := prepareSchemas(configBytes, valuesBytes)
 	if err != nil {
 		return fmt.Errorf("prepare global schemas: %s", err)
 	}
 	st.GlobalSchemas = schemas
+	st.globalValidators = validators
 	return nil
 }
 
 // AddModuleValuesSchemas creates schema for module values.
 func (st *SchemaStorage) AddModuleValuesSchemas(moduleName string, configBytes, valuesBytes []byte) error {
-	schemas, err := PrepareSchemas(configBytes, valuesBytes)
+	schemas, validators, err := prepareSchemas(configBytes, valuesBytes)
 	if err != nil {
 		return fmt.Errorf("prepare module '%s' schemas: %s", moduleName, err)
 	}
@@ -100,6 +123,10 @@ func (st *SchemaStorage) AddModuleValuesSchemas(moduleName string, configBytes,
 		st.ModuleSchemas[moduleName] = map[SchemaType]*spec.Schema{}
 	}
 	st.ModuleSchemas[moduleName] = schemas
+	if st.moduleValidators == nil {
+		st.moduleValidators = map[string]map[SchemaType]*jsonschema.Schema{}
+	}
+	st.moduleValidators[moduleName] = validators
 	return nil
 }
 
@@ -168,6 +195,11 @@ func LoadSchemaFromBytes(openApiContent []byte) (*spec.Schema, error) {
 		return nil, fmt.Errorf("json unmarshal: %v", err)
 	}
 
+	// JSON Schema 2020-12 validator resolves '$ref' itself, go-openapi can't expand '$defs'.
+	if schema.IsDraft202012(s) {
+		return s, nil
+	}
+
 	err = spec.ExpandSchema(s, s, nil /*new(noopResCache)*/)
 	if err != nil {
 		return nil, fmt.Errorf("expand schema: %v", err)
@@ -178,27 +210,37 @@ func LoadSchemaFromBytes(openApiContent []byte) (*spec.Schema, error) {
 
 // PrepareSchemas loads schemas for config values, values and helm values.
 func PrepareSchemas(configBytes, valuesBytes []byte) (schemas map[SchemaType]*spec.Schema, err error) {
+	schemas, _, err = prepareSchemas(configBytes, valuesBytes)
+	return schemas, err
+}
+
+// prepareSchemas loads schemas for config values, values and helm values
+// and compiles validators for JSON Schema 2020-12 schemas.
+func prepareSchemas(configBytes, valuesBytes []byte) (map[SchemaType]*spec.Schema, map[SchemaType]*jsonschema.Schema, error) {
 	res := make(map[SchemaType]*spec.Schema)
 	if configBytes != nil {
 		schemaObj, err := LoadSchemaFromBytes(configBytes)
 		if err != nil {
-			return nil, fmt.Errorf("load global '%s' schema: %s", ConfigValuesSchema, err)
+			return nil, nil, fmt.Errorf("load global '%s' schema: %s", ConfigValuesSchema, err)
 		}
 		res[ConfigValuesSchema] = schema.TransformSchema(
 			schemaObj,
-			&schema.AdditionalPropertiesTransformer{},
+			&schema.AdditionalPropertiesTransformer{Unevaluated: schema.IsDraft202012(schemaObj)},
 		)
 	}
 
 	if valuesBytes != nil {
 		schemaObj, err := LoadSchemaFromBytes(valuesBytes)
 		if err != nil {
-			return nil, fmt.Errorf("load global '%s' schema: %s", ValuesSchema, err)
+			return nil, nil, fmt.Errorf("load global '%s' schema: %s", ValuesSchema, err)
 		}
-		res[ValuesSchema] = schema.TransformSchema(
+		schemaObj = schema.TransformSchema(
 			schemaObj,
 			&schema.ExtendTransformer{Parent: res[ConfigValuesSchema]},
-			&schema.AdditionalPropertiesTransformer{},
+		)
+		res[ValuesSchema] = schema.TransformSchema(
+			schemaObj,
+			&schema.AdditionalPropertiesTransformer{Unevaluated: schema.IsDraft202012(schemaObj)},
 		)
 
 		res[HelmValuesSchema] = schema.TransformSchema(
@@ -210,5 +252,18 @@ func PrepareSchemas(configBytes, valuesBytes []byte) (schemas map[SchemaType]*sp
 		)
 	}
 
-	return res, nil
+	// Compile JSON Schema 2020-12 validators once, errors are reported on load.
+	validators := make(map[SchemaType]*jsonschema.Schema)
+	for schemaType, s := range res {
+		if !schema.IsDraft202012(s) {
+			continue
+		}
+		compiled, err := compileDraft202012(s)
+		if err != nil {
+			return nil, nil, fmt.Errorf("compile '%s' schema: %v", schemaType, err)
+		}
+		validators[schemaType] = compiled
+	}
+
+	return res, validators, nil
 }
@@ -0,0 +1,209 @@
+package validation
+
+import (
+	"regexp"
+	"strconv"
+
+	"github.com/go-openapi/spec"
+
+	"github.com/flant/addon-operator/pkg/utils"
+)
+
+// XSensitive marks a setting whose value is masked in debug output and logs.
+// Hooks and Helm receive the real value.
+const XSensitive = "x-sensitive"
+
+// RedactSensitive returns a copy of values with values of settings marked with 'x-sensitive'
+// replaced by the mask. Values may contain the global section and module sections.
+func (v *ValuesValidator) RedactSensitive(values utils.Values) utils.Values {
+	res := make(utils.Values, len(values))
+	for rootName, value := range values {
+		res[rootName] = v.RedactSensitiveAt(toPointer([]string{rootName}), value)
+	}
+	return res
+}
+
+// RedactSensitiveAt returns a copy of the value at the JSON pointer with values of sensitive
+// settings replaced by the mask. The pointer starts with a root key: /global/param or /moduleName/param.
+func (v *ValuesValidator) RedactSensitiveAt(pointer string, value interface{}) interface{} {
+	tokens := pointerTokens(pointer)
+	if len(tokens) == 0 || value == nil {
+		return value
+	}
+	return redactSensitiveAt(v.rootSchemas(tokens[0]), tokens[1:], value)
+}
+
+// RegisterSensitiveValues registers strings in values of sensitive settings to mask them
+// in logs and text output, e.g. in rendered manifests. Values may contain the global section
+// and module sections. Strings registered earlier for the same root key are replaced, so
+// call it only for values that are accepted: applied config or committed patches.
+func (v *ValuesValidator) RegisterSensitiveValues(values utils.Values) {
+	for rootName, value := range values {
+		utils.SetSecretValues(XSensitive+"/"+rootName, sensitiveStringsAt(v.rootSchemas(rootName), nil, value))
+	}
+}
+
+// redactSensitiveAt masks sensitive settings in the value at the path relative to schemas.
+func redactSensitiveAt(schemas []*spec.Schema, tokens []string, value interface{}) interface{} {
+	for _, token := range tokens {
+		if isSensitive(schemas) {
+			return utils.SecretValueMask
+		}
+		schemas = childSchemas(schemas, token)
+	}
+	return redactSensitive(value, schemas)
+}
+
+// sensitiveStringsAt returns strings in values of sensitive settings in the value
+// at the path relative to schemas.
+func sensitiveStringsAt(schemas []*spec.Schema, tokens []string, value interface{}) []string {
+	for _, token := range tokens {
+		if isSensitive(schemas) {
+			return appendStrings(nil, value)
+		}
+		schemas = childSchemas(schemas, token)
+	}
+
+	values := make([]string, 0)
+	var walk func(value interface{}, schemas []*spec.Schema)
+	walk = func(value interface{}, schemas []*spec.Schema) {
+		if len(schemas) == 0 {
+			return
+		}
+		if isSensitive(schemas) {
+			values = appendStrings(values, value)
+			return
+		}
+		switch v := value.(type) {
+		case map[string]interface{}:
+			for k, item := range v {
+				walk(item, childSchemas(schemas, k))
+			}
+		case []interface{}:
+			for i, item := range v {
+				walk(item, childSchemas(schemas, strconv.Itoa(i)))
+			}
+		}
+	}
+	walk(value, schemas)
+	return values
+}
+
+// rootSchemas returns config values and values schemas for the root key.
+func (v *ValuesValidator) rootSchemas(rootName string) []*spec.Schema {
+	schemaType, moduleName := ModuleSchema, rootName
+	if rootName == utils.GlobalValuesKey {
+		schemaType, moduleName = GlobalSchema, ""
+	}
+	res := make([]*spec.Schema, 0, 2)
+	for _, valuesType := range []SchemaType{ConfigValuesSchema, ValuesSchema} {
+		if s := v.GetSchema(schemaType, valuesType, moduleName); s != nil {
+			res = append(res, s)
+		}
+	}
+	return res
+}
+
+func redactSensitive(value interface{}, schemas []*spec.Schema) interface{} {
+	// Nothing to hide in null values and in 'remove' operations.
+	if value == nil || len(schemas) == 0 {
+		return value
+	}
+	if isSensitive(schemas) {
+		return utils.SecretValueMask
+	}
+	switch v := value.(type) {
+	case map[string]interface{}:
+		res := make(map[string]interface{}, len(v))
+		for k, item := range v {
+			res[k] = redactSensitive(item, childSchemas(schemas, k))
+		}
+		return res
+	case []interface{}:
+		res := make([]interface{}, 0, len(v))
+		for i, item := range v {
+			res = append(res, redactSensitive(item, childSchemas(schemas, strconv.Itoa(i))))
+		}
+		return res
+	}
+	return value
+}
+
+// isSensitive returns true if one of schemas or their subschemas in allOf, anyOf
+// and oneOf has 'x-sensitive: true'.
+func isSensitive(schemas []*spec.Schema) bool {
+	for _, s := range expandSchemas(schemas) {
+		if sensitive, ok := s.Extensions[XSensitive].(bool); ok && sensitive {
+			return true
+		}
+	}
+	return false
+}
+
+// childSchemas returns schemas for the object property or the array item.
+func childSchemas(schemas []*spec.Schema, token string) []*spec.Schema {
+	res := make([]*spec.Schema, 0)
+	for _, s := range expandSchemas(schemas) {
+		matched := false
+		if prop, has := s.Properties[token]; has {
+			prop := prop
+			res = append(res, &prop)
+			matched = true
+		}
+		for pattern, prop := range s.PatternProperties {
+			if re, err := regexp.Compile(pattern); err == nil && re.MatchString(token) {
+				prop := prop
+				res = append(res, &prop)
+				matched = true
+			}
+		}
+		if !matched && s.AdditionalProperties != nil && s.AdditionalProperties.Schema != nil {
+			res = append(res, s.AdditionalProperties.Schema)
+		}
+		if s.Items != nil {
+			idx, err := strconv.Atoi(token)
+			switch {
+			case err != nil:
+			case s.Items.Schema != nil:
+				res = append(res, s.Items.Schema)
+			case idx < len(s.Items.Schemas):
+				res = append(res, &s.Items.Schemas[idx])
+			}
+		}
+	}
+	return res
+}
+
+// expandSchemas returns schemas with their subschemas in allOf, anyOf and oneOf.
+func expandSchemas(schemas []*spec.Schema) []*spec.Schema {
+	res := make([]*spec.Schema, 0, len(schemas))
+	for _, s := range schemas {
+		if s == nil {
+			continue
+		}
+		res = append(res, s)
+		for _, group := range [][]spec.Schema{s.AllOf, s.AnyOf, s.OneOf} {
+			for i := range group {
+				res = append(res, expandSchemas([]*spec.Schema{&group[i]})...)
+			}
+		}
+	}
+	return res
+}
+
+// appendStrings appends all strings in the value to the list.
+func appendStrings(list []string, value interface{}) []string {
+	switch v := value.(type) {
+	case string:
+		list = append(list, v)
+	case map[string]interface{}:
+		for _, item := range v {
+			list = appendStrings(list, item)
+		}
+	case []interface{}:
+		for _, item := range v {
+			list = appendStrings(list, item)
+		}
+	}
+	return list
+}
@@ -0,0 +1,155 @@
+package validation
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+
+	"github.com/flant/addon-operator/pkg/utils"
+)
+
+func Test_RedactSensitive(t *testing.T) {
+	g := NewWithT(t)
+
+	configSchemaYaml := `
+type: object
+properties:
+  user:
+    type: string
+  password:
+    type: string
+    x-sensitive: true
+  port:
+    type: integer
+    x-sensitive: true
+  tls:
+    type: object
+    x-sensitive: true
+    properties:
+      key:
+        type: string
+  tokens:
+    type: array
+    items:
+      type: object
+      properties:
+        name:
+          type: string
+        value:
+          type: string
+          x-sensitive: true
+  extra:
+    type: object
+    additionalProperties:
+      type: string
+      x-sensitive: true
+`
+	valuesSchemaYaml := `
+x-extend:
+  schema: config-values.yaml
+type: object
+properties:
+  internal:
+    type: object
+    properties:
+      generatedPassword:
+        type: string
+        x-sensitive: true
+`
+	v := NewValuesValidator()
+	err := v.SchemaStorage.AddModuleValuesSchemas("moduleName", []byte(configSchemaYaml), []byte(valuesSchemaYaml))
+	g.Expect(err).ShouldNot(HaveOccurred())
+
+	values, err := utils.NewValuesFromBytes([]byte(`
+global:
+  clusterName: main
+moduleName:
+  user: admin
+  password: sensitive-test-password
+  port: 5432
+  tls:
+    key: sensitive-test-key
+  tokens:
+  - name: first
+    value: sensitive-test-token
+  extra:
+    apiKey: sensitive-test-api-key
+  internal:
+    generatedPassword: sensitive-test-generated
+`))
+	g.Expect(err).ShouldNot(HaveOccurred())
+
+	redacted := v.RedactSensitive(values)
+	g.Expect(redacted).To(Equal(utils.Values{
+		"global": map[string]interface{}{"clusterName": "main"},
+		"moduleName": map[string]interface{}{
+			"user":     "admin",
+			"password": utils.SecretValueMask,
+			"port":     utils.SecretValueMask,
+			"tls":      utils.SecretValueMask,
+			"tokens": []interface{}{
+				map[string]interface{}{"name": "first", "value": utils.SecretValueMask},
+			},
+			"extra":    map[string]interface{}{"apiKey": utils.SecretValueMask},
+			"internal": map[string]interface{}{"generatedPassword": utils.SecretValueMask},
+		},
+	}))
+	g.Expect(values["moduleName"].(map[string]interface{})["password"]).To(Equal("sensitive-test-password"), "input values should not be modified")
+
+	// Values by path, e.g. values in patches and explanation leaves.
+	g.Expect(v.RedactSensitiveAt("/moduleName/password", "sensitive-test-password")).To(Equal(utils.SecretValueMask))
+	g.Expect(v.RedactSensitiveAt("/moduleName/tls/key", "sensitive-test-key")).To(Equal(utils.SecretValueMask))
+	g.Expect(v.RedactSensitiveAt("/moduleName/tokens/0", map[string]interface{}{"name": "a", "value": "b"})).
+		To(Equal(map[string]interface{}{"name": "a", "value": utils.SecretValueMask}))
+	g.Expect(v.RedactSensitiveAt("/moduleName/user", "admin")).To(Equal("admin"))
+	g.Expect(v.RedactSensitiveAt("/moduleName/password", nil)).To(BeNil())
+
+	// Validation does not register values: they may be rejected.
+	err = v.ValidateModuleValues("moduleName", values)
+	g.Expect(err).ShouldNot(HaveOccurred())
+	g.Expect(utils.IsSecretValue("sensitive-test-password")).To(BeFalse())
+
+	// Accepted values are registered to mask them in logs.
+	v.RegisterSensitiveValues(values)
+	defer v.RegisterSensitiveValues(utils.Values{"moduleName": nil})
+	debugString := values.DebugString()
+	for _, secret := range []string{"sensitive-test-password", "sensitive-test-key", "sensitive-test-token", "sensitive-test-api-key", "sensitive-test-generated"} {
+		g.Expect(debugString).ShouldNot(ContainSubstring(secret))
+	}
+	g.Expect(debugString).Should(ContainSubstring("user: admin"))
+}
+
+func Test_ValidationError_sensitive(t *testing.T) {
+	g := NewWithT(t)
+
+	schemaYaml := `
+type: object
+properties:
+  password:
+    type: string
+    x-sensitive: true
+    enum: [first-allowed-password]
+  tls:
+    type: object
+    x-sensitive: true
+    properties:
+      key:
+        type: integer
+`
+	v := NewValuesValidator()
+	err := v.SchemaStorage.AddModuleValuesSchemas("moduleName", []byte(schemaYaml), nil)
+	g.Expect(err).ShouldNot(HaveOccurred())
+
+	values := utils.Values{"moduleName": map[string]interface{}{
+		"password": "rejected-test-password",
+		"tls":      map[string]interface{}{"key": "rejected-test-key"},
+	}}
+	err = v.ValidateModuleConfigValues("moduleName", values)
+	g.Expect(err).Should(HaveOccurred())
+	g.Expect(err.Error()).ShouldNot(ContainSubstring("rejected-test-password"))
+	g.Expect(err.Error()).ShouldNot(ContainSubstring("rejected-test-key"))
+	for _, e := range ValidationErrors(err) {
+		g.Expect(e.Value).To(Equal(utils.SecretValueMask), e.Field)
+	}
+	g.Expect(utils.IsSecretValue("rejected-test-password")).To(BeFalse())
+}
@@ -7,9 +7,11 @@ import (
 	"github.com/go-openapi/strfmt"
 	"github.com/go-openapi/validate"
 	"github.com/hashicorp/go-multierror"
+	"github.com/santhosh-tekuri/jsonschema/v5"
 	log "github.com/sirupsen/logrus"
 
 	"github.com/flant/addon-operator/pkg/utils"
+	"github.com/flant/addon-operator/pkg/values/validation/schema"
 )
 
 type ValuesValidator struct {
@@ -42,6 +44,17 @@ func (v *ValuesValidator) ValidateModuleHelmValues(moduleName string, values uti
 	return v.ValidateValues(ModuleSchema, HelmValuesSchema, moduleName, values)
 }
 
+// getValidator returns a compiled JSON Schema 2020-12 validator from the schema storage.
+func (v *ValuesValidator) getValidator(schemaType SchemaType, valuesType SchemaType, modName string) *jsonschema.Schema {
+	switch schemaType {
+	case GlobalSchema:
+		return v.SchemaStorage.globalValidator(valuesType)
+	case ModuleSchema:
+		return v.SchemaStorage.moduleValidator(modName, valuesType)
+	}
+	return nil
+}
+
 // GetSchema returns a schema from the schema storage.
 func (v *ValuesValidator) GetSchema(schemaType SchemaType, valuesType SchemaType, modName string) *spec.Schema {
 	switch schemaType {
@@ -70,7 +83,7 @@ func (v *ValuesValidator) ValidateValues(schemaType SchemaType, valuesType Schem
 		return fmt.Errorf("root key '%s' not found in input values", rootName)
 	}
 
-	validationErr := ValidateObject(obj, s, rootName)
+	validationErr := validateObject(obj, s, v.getValidator(schemaType, valuesType, moduleName), rootName)
 	if validationErr == nil {
 		log.Debugf("'%s' '%s' values are valid", schemaType, valuesType)
 	} else {
@@ -80,12 +93,32 @@ func (v *ValuesValidator) ValidateValues(schemaType SchemaType, valuesType Schem
 }
 
 // ValidateObject uses schema to validate data structure in the dataObj.
+// It returns a multierror with ValidationError items, use ValidationErrors to get them.
 // See https://github.com/kubernetes/apiextensions-apiserver/blob/1bb376f70aa2c6f2dec9a8c7f05384adbfac7fbb/pkg/apiserver/validation/validation.go#L47
+// JSON Schema 2020-12 schemas are compiled on each call, ValuesValidator uses validators
+// compiled once when schemas are added to the storage.
 func ValidateObject(dataObj interface{}, s *spec.Schema, rootName string) (multiErr error) {
+	return validateObject(dataObj, s, nil, rootName)
+}
+
+// validateObject validates dataObj with the schema. compiled is a validator for
+// the JSON Schema 2020-12 schema, it is compiled if nil.
+func validateObject(dataObj interface{}, s *spec.Schema, compiled *jsonschema.Schema, rootName string) error {
 	if s == nil {
 		return fmt.Errorf("validate config: schema is not provided")
 	}
 
+	if schema.IsDraft202012(s) {
+		if compiled == nil {
+			var err error
+			compiled, err = compileDraft202012(s)
+			if err != nil {
+				return fmt.Errorf("compile schema: %v", err)
+			}
+		}
+		return validateDraft202012(dataObj, s, compiled, rootName)
+	}
+
 	validator := validate.NewSchemaValidator(s, nil, rootName, strfmt.Default) //, validate.DisableObjectArrayTypeCheck(true)
 
 	result := validator.Validate(dataObj)
@@ -95,7 +128,7 @@ func ValidateObject(dataObj interface{}, s *spec.Schema, rootName string) (multi
 
 	var allErrs *multierror.Error
 	for _, err := range result.Errors {
-		allErrs = multierror.Append(allErrs, err)
+		allErrs = multierror.Append(allErrs, newOpenAPIValidationError(err, dataObj, s, rootName))
 	}
 	// NOTE: no validation errors, but config is not valid!
 	if allErrs == nil || allErrs.Len() == 0 {
@@ -0,0 +1,117 @@
+package plugin
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/flant/addon-operator/pkg/module_manager/go_hook"
+)
+
+// groupName is a group for all bindings, so each binding context contains
+// snapshots for all kubernetes bindings as for in-process Go hooks.
+const groupName = "main"
+
+// ConfigV1 converts the Go hook config into the hook configuration v1 format.
+// Kubernetes bindings have no jqFilter: full objects are passed to the plugin
+// and FilterFunc is applied to them before the hook run.
+func ConfigV1(cfg *go_hook.HookConfig) (map[string]interface{}, error) {
+	res := map[string]interface{}{
+		"configVersion": "v1",
+	}
+
+	if cfg.Settings != nil {
+		if cfg.Settings.EnableSchedulesOnStartup {
+			return nil, errors.New("settings.EnableSchedulesOnStartup is not supported for plugin hooks")
+		}
+		settings := map[string]interface{}{
+			"executionMinInterval": cfg.Settings.ExecutionMinInterval.String(),
+			"executionBurst":       cfg.Settings.ExecutionBurst,
+		}
+		if cfg.Settings.Timeout > 0 {
+			settings["timeout"] = cfg.Settings.Timeout.String()
+		}
+		if cfg.Settings.Retry != nil {
+			settings["retry"] = cfg.Settings.Retry
+		}
+		res["settings"] = settings
+	}
+
+	for key, ordered := range map[string]*go_hook.OrderedConfig{
+		"onStartup":       cfg.OnStartup,
+		"beforeHelm":      cfg.OnBeforeHelm,
+		"afterHelm":       cfg.OnAfterHelm,
+		"afterDeleteHelm": cfg.OnAfterDeleteHelm,
+		"beforeAll":       cfg.OnBeforeAll,
+		"afterAll":        cfg.OnAfterAll,
+	} {
+		if ordered != nil {
+			res[key] = ordered.Order
+		}
+	}
+
+	if len(cfg.Kubernetes) > 0 {
+		kubernetes := make([]map[string]interface{}, 0, len(cfg.Kubernetes))
+		for i, kubeCfg := range cfg.Kubernetes {
+			if kubeCfg.Name == "" {
+				return nil, fmt.Errorf(`"name" is a required field in kubernetes binding %d`, i)
+			}
+			if kubeCfg.FilterFunc == nil {
+				return nil, fmt.Errorf(`"FilterFunc" in kubernetes binding '%s' cannot be nil`, kubeCfg.Name)
+			}
+
+			binding := map[string]interface{}{
+				"name":                         kubeCfg.Name,
+				"kind":                         kubeCfg.Kind,
+				"executeHookOnSynchronization": go_hook.BoolDeref(kubeCfg.ExecuteHookOnSynchronization, true),
+				"waitForSynchronization":       go_hook.BoolDeref(kubeCfg.WaitForSynchronization, true),
+				"allowFailure":                 cfg.AllowFailure,
+				"group":                        groupName,
+			}
+			if kubeCfg.ApiVersion != "" {
+				binding["apiVersion"] = kubeCfg.ApiVersion
+			}
+			if kubeCfg.NameSelector != nil {
+				binding["nameSelector"] = kubeCfg.NameSelector
+			}
+			if kubeCfg.NamespaceSelector != nil {
+				binding["namespace"] = kubeCfg.NamespaceSelector
+			}
+			if kubeCfg.LabelSelector != nil {
+				binding["labelSelector"] = kubeCfg.LabelSelector
+			}
+			if kubeCfg.FieldSelector != nil {
+				binding["fieldSelector"] = kubeCfg.FieldSelector
+			}
+			if !go_hook.BoolDeref(kubeCfg.ExecuteHookOnEvents, true) {
+				binding["executeHookOnEvent"] = []string{}
+			}
+			if cfg.Queue != "" {
+				binding["queue"] = cfg.Queue
+			}
+			kubernetes = append(kubernetes, binding)
+		}
+		res["kubernetes"] = kubernetes
+	}
+
+	if len(cfg.Schedule) > 0 {
+		schedules := make([]map[string]interface{}, 0, len(cfg.Schedule))
+		for i, sch := range cfg.Schedule {
+			if sch.Name == "" {
+				return nil, fmt.Errorf(`"name" is a required field in schedule binding %d`, i)
+			}
+			schedule := map[string]interface{}{
+				"name":         sch.Name,
+				"crontab":      sch.Crontab,
+				"allowFailure": cfg.AllowFailure,
+				"group":        groupName,
+			}
+			if cfg.Queue != "" {
+				schedule["queue"] = cfg.Queue
+			}
+			schedules = append(schedules, schedule)
+		}
+		res["schedule"] = schedules
+	}
+
+	return res, nil
+}
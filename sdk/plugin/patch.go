@@ -0,0 +1,183 @@
+package plugin
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+
+	"github.com/flant/shell-operator/pkg/kube/object_patch"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/flant/addon-operator/pkg/module_manager/go_hook"
+)
+
+// PatchCollector collects operations of a plugin hook as specs for the KUBERNETES_PATCH_PATH file.
+//
+// Each call is mapped to an object_patch.OperationSpec, so the operator applies the same
+// operation as for an in-process Go hook. Filter is not supported: FilterFunc can not be
+// passed to the operator process. Unsupported calls are reported by Collect.
+type PatchCollector struct {
+	specs []object_patch.OperationSpec
+	err   error
+}
+
+var _ go_hook.PatchCollector = (*PatchCollector)(nil)
+
+// NewPatchCollector creates a collector of operation specs for plugin hooks.
+func NewPatchCollector() *PatchCollector {
+	return &PatchCollector{
+		specs: make([]object_patch.OperationSpec, 0),
+	}
+}
+
+// Create collects a Create, CreateIfNotExists or CreateOrUpdate operation.
+func (c *PatchCollector) Create(object interface{}, options ...object_patch.CreateOption) {
+	spec := object_patch.OperationSpec{
+		Operation: object_patch.Create,
+		Object:    object,
+	}
+	for _, option := range options {
+		switch {
+		case isSubresource(option):
+			spec.Subresource = subresource(option)
+		case reflect.DeepEqual(option, object_patch.IgnoreIfExists()):
+			spec.Operation = object_patch.CreateIfNotExists
+		case reflect.DeepEqual(option, object_patch.UpdateIfExists()):
+			spec.Operation = object_patch.CreateOrUpdate
+		default:
+			c.fail(fmt.Errorf("create: unknown option %T", option))
+			return
+		}
+	}
+	c.specs = append(c.specs, spec)
+}
+
+// Delete collects a Delete, DeleteInBackground or DeleteNonCascading operation.
+func (c *PatchCollector) Delete(apiVersion, kind, namespace, name string, options ...object_patch.DeleteOption) {
+	spec := object_patch.OperationSpec{
+		Operation:  object_patch.Delete,
+		ApiVersion: apiVersion,
+		Kind:       kind,
+		Namespace:  namespace,
+		Name:       name,
+	}
+	for _, option := range options {
+		switch {
+		case isSubresource(option):
+			spec.Subresource = subresource(option)
+		case reflect.DeepEqual(option, object_patch.InForeground()):
+			spec.Operation = object_patch.Delete
+		case reflect.DeepEqual(option, object_patch.InBackground()):
+			spec.Operation = object_patch.DeleteInBackground
+		case reflect.DeepEqual(option, object_patch.NonCascading()):
+			spec.Operation = object_patch.DeleteNonCascading
+		default:
+			c.fail(fmt.Errorf("delete %s/%s/%s: unknown option %T", namespace, kind, name, option))
+			return
+		}
+	}
+	c.specs = append(c.specs, spec)
+}
+
+// MergePatch collects a MergePatch operation.
+func (c *PatchCollector) MergePatch(mergePatch interface{}, apiVersion, kind, namespace, name string, options ...object_patch.PatchOption) {
+	spec, err := patchSpec(object_patch.MergePatch, mergePatch, apiVersion, kind, namespace, name, options)
+	if err != nil {
+		c.fail(fmt.Errorf("merge patch %s/%s/%s: %v", namespace, kind, name, err))
+		return
+	}
+	c.specs = append(c.specs, spec)
+}
+
+// JSONPatch collects a JSONPatch operation.
+func (c *PatchCollector) JSONPatch(jsonPatch interface{}, apiVersion, kind, namespace, name string, options ...object_patch.PatchOption) {
+	spec, err := patchSpec(object_patch.JSONPatch, jsonPatch, apiVersion, kind, namespace, name, options)
+	if err != nil {
+		c.fail(fmt.Errorf("json patch %s/%s/%s: %v", namespace, kind, name, err))
+		return
+	}
+	c.specs = append(c.specs, spec)
+}
+
+// Filter is not supported for plugin hooks, the error is returned by Collect.
+func (c *PatchCollector) Filter(
+	_ func(*unstructured.Unstructured) (*unstructured.Unstructured, error),
+	_, kind, namespace, name string, _ ...object_patch.FilterOption,
+) {
+	c.fail(fmt.Errorf("filter %s/%s/%s: Filter is not supported for plugin hooks, use MergePatch or JSONPatch", namespace, kind, name))
+}
+
+// Collect returns collected operation specs. It returns an error if the hook
+// used an operation or a binding action that is not supported for plugin hooks.
+func (c *PatchCollector) Collect(bindingActions []go_hook.BindingAction) ([]object_patch.OperationSpec, error) {
+	if c.err != nil {
+		return nil, c.err
+	}
+	if len(bindingActions) > 0 {
+		return nil, errors.New("binding actions are not supported for plugin hooks")
+	}
+	return c.specs, nil
+}
+
+func (c *PatchCollector) fail(err error) {
+	if c.err == nil {
+		c.err = err
+	}
+}
+
+func patchSpec(operation object_patch.OperationType, patch interface{}, apiVersion, kind, namespace, name string, options []object_patch.PatchOption) (object_patch.OperationSpec, error) {
+	spec := object_patch.OperationSpec{
+		Operation:  operation,
+		ApiVersion: apiVersion,
+		Kind:       kind,
+		Namespace:  namespace,
+		Name:       name,
+	}
+
+	// Patch as string or []byte is written as JSON, not as a JSON string.
+	var data []byte
+	switch v := patch.(type) {
+	case string:
+		data = []byte(v)
+	case []byte:
+		data = v
+	}
+	if data != nil {
+		if err := json.Unmarshal(data, &patch); err != nil {
+			return spec, fmt.Errorf("patch is not a valid JSON: %v", err)
+		}
+	}
+	if operation == object_patch.MergePatch {
+		spec.MergePatch = patch
+	} else {
+		spec.JSONPatch = patch
+	}
+
+	for _, option := range options {
+		switch {
+		case isSubresource(option):
+			spec.Subresource = subresource(option)
+		case reflect.DeepEqual(option, object_patch.WithIgnoreMissingObject(true)):
+			spec.IgnoreMissingObject = true
+		case reflect.DeepEqual(option, object_patch.WithIgnoreMissingObject(false)):
+			spec.IgnoreMissingObject = false
+		default:
+			return spec, fmt.Errorf("unknown option %T", option)
+		}
+	}
+	return spec, nil
+}
+
+// Options from object_patch have no exported fields. The subresource is read
+// from the value returned by WithSubresource, other options are compared with
+// the values of their constructors.
+var subresourceType = reflect.TypeOf(object_patch.WithSubresource(""))
+
+func isSubresource(option interface{}) bool {
+	return reflect.TypeOf(option) == subresourceType
+}
+
+func subresource(option interface{}) string {
+	return reflect.ValueOf(option).Elem().FieldByName("subresource").String()
+}
@@ -0,0 +1,296 @@
+// Package plugin runs a Go hook as a separate executable.
+//
+// A plugin hook is a binary in the 'hooks' directory of the module or in the global hooks
+// directory. Addon-operator runs it as a shell hook: the binary prints its configuration
+// on '--config' and exchanges values, snapshots, patches and metrics via files.
+// This package implements this contract for go_hook.GoHook, so the same hook code can be
+// compiled into the operator with sdk.RegisterFunc or built separately:
+//
+//	func main() {
+//		plugin.Serve(&go_hook.HookConfig{...}, handle)
+//	}
+package plugin
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"regexp"
+	"syscall"
+
+	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/flant/addon-operator/pkg/module_manager/go_hook"
+	"github.com/flant/addon-operator/pkg/module_manager/go_hook/metrics"
+	"github.com/flant/addon-operator/pkg/utils"
+)
+
+// Environment variables with paths to files for the hook run.
+const (
+	ValuesPathEnv                = "VALUES_PATH"
+	ConfigValuesPathEnv          = "CONFIG_VALUES_PATH"
+	BindingContextPathEnv        = "BINDING_CONTEXT_PATH"
+	ValuesJSONPatchPathEnv       = "VALUES_JSON_PATCH_PATH"
+	ConfigValuesJSONPatchPathEnv = "CONFIG_VALUES_JSON_PATCH_PATH"
+	MetricsPathEnv               = "METRICS_PATH"
+	KubernetesPatchPathEnv       = "KUBERNETES_PATCH_PATH"
+)
+
+// hookNameRe extracts a hook name from the path as sdk.Registry does for in-process Go hooks.
+var hookNameRe = regexp.MustCompile(`/(?:modules|global[\/\-]hooks)/(.+)$`)
+
+type goHook struct {
+	config        *go_hook.HookConfig
+	reconcileFunc func(input *go_hook.HookInput) error
+}
+
+func (h *goHook) Config() *go_hook.HookConfig {
+	return h.config
+}
+
+func (h *goHook) Run(input *go_hook.HookInput) error {
+	return h.reconcileFunc(input)
+}
+
+// Serve runs the Go hook with the arguments of the process and exits.
+// It has the same signature as sdk.RegisterFunc.
+func Serve(config *go_hook.HookConfig, reconcileFunc func(input *go_hook.HookInput) error) {
+	hook := &goHook{config: config, reconcileFunc: reconcileFunc}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	err := Run(ctx, hook, os.Args, os.Stdout)
+	stop()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
+
+// Run prints the hook config if '--config' is passed or runs the hook with files from environment variables.
+func Run(ctx context.Context, hook go_hook.GoHook, args []string, stdout io.Writer) error {
+	if len(args) > 1 && args[1] == "--config" {
+		cfg, err := ConfigV1(hook.Config())
+		if err != nil {
+			return fmt.Errorf("convert config: %v", err)
+		}
+		return json.NewEncoder(stdout).Encode(cfg)
+	}
+
+	hookName := ""
+	if len(args) > 0 {
+		hookName = HookName(args[0])
+	}
+	return runHook(ctx, hook, hookName)
+}
+
+// HookName returns a hook name from the executable path. It is a default group for metrics.
+func HookName(path string) string {
+	matches := hookNameRe.FindStringSubmatch(path)
+	if matches != nil {
+		return matches[1]
+	}
+	return filepath.Base(path)
+}
+
+func runHook(ctx context.Context, hook go_hook.GoHook, hookName string) error {
+	cfg := hook.Config()
+
+	values, err := readValues(os.Getenv(ValuesPathEnv))
+	if err != nil {
+		return fmt.Errorf("read values: %v", err)
+	}
+	patchableValues, err := go_hook.NewPatchableValues(values)
+	if err != nil {
+		return err
+	}
+
+	configValues, err := readValues(os.Getenv(ConfigValuesPathEnv))
+	if err != nil {
+		return fmt.Errorf("read config values: %v", err)
+	}
+	patchableConfigValues, err := go_hook.NewPatchableValues(configValues)
+	if err != nil {
+		return err
+	}
+
+	snapshots, err := readSnapshots(os.Getenv(BindingContextPathEnv), cfg)
+	if err != nil {
+		return fmt.Errorf("read binding context: %v", err)
+	}
+
+	if cfg.Settings != nil && cfg.Settings.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, cfg.Settings.Timeout)
+		defer cancel()
+	}
+
+	bindingActions := new([]go_hook.BindingAction)
+	metricsCollector := metrics.NewCollector(hookName)
+	patchCollector := NewPatchCollector()
+
+	err = hook.Run(&go_hook.HookInput{
+		Context:          ctx,
+		Snapshots:        snapshots,
+		Values:           patchableValues,
+		ConfigValues:     patchableConfigValues,
+		MetricsCollector: metricsCollector,
+		PatchCollector:   patchCollector,
+		LogEntry:         logrus.NewEntry(logrus.StandardLogger()).WithField("output", "gohook"),
+		BindingActions:   bindingActions,
+	})
+	if err != nil {
+		return err
+	}
+
+	specs, err := patchCollector.Collect(*bindingActions)
+	if err != nil {
+		return err
+	}
+
+	err = writeValuesPatch(os.Getenv(ValuesJSONPatchPathEnv), patchableValues.GetPatches())
+	if err != nil {
+		return fmt.Errorf("write values patch: %v", err)
+	}
+	err = writeValuesPatch(os.Getenv(ConfigValuesJSONPatchPathEnv), patchableConfigValues.GetPatches())
+	if err != nil {
+		return fmt.Errorf("write config values patch: %v", err)
+	}
+
+	metricOperations := make([]interface{}, 0)
+	for _, op := range metricsCollector.CollectedMetrics() {
+		metricOperations = append(metricOperations, op)
+	}
+	err = writeJSONLines(os.Getenv(MetricsPathEnv), metricOperations)
+	if err != nil {
+		return fmt.Errorf("write metrics: %v", err)
+	}
+
+	kubernetesOperations := make([]interface{}, 0, len(specs))
+	for _, spec := range specs {
+		kubernetesOperations = append(kubernetesOperations, spec)
+	}
+	err = writeJSONLines(os.Getenv(KubernetesPatchPathEnv), kubernetesOperations)
+	if err != nil {
+		return fmt.Errorf("write kubernetes patch: %v", err)
+	}
+
+	return nil
+}
+
+func readValues(path string) (utils.Values, error) {
+	values := utils.Values{}
+	if path == "" {
+		return values, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(bytes.TrimSpace(data)) == 0 {
+		return values, nil
+	}
+	err = json.Unmarshal(data, &values)
+	return values, err
+}
+
+// bindingContext is a part of the binding context v1 with snapshots.
+type bindingContext struct {
+	Snapshots map[string][]struct {
+		Object *unstructured.Unstructured `json:"object"`
+	} `json:"snapshots"`
+}
+
+// readSnapshots applies FilterFunc from the config to snapshot objects from all binding contexts.
+func readSnapshots(path string, cfg *go_hook.HookConfig) (go_hook.Snapshots, error) {
+	snapshots := make(go_hook.Snapshots)
+	if path == "" {
+		return snapshots, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var contexts []bindingContext
+	if err := json.Unmarshal(data, &contexts); err != nil {
+		return nil, err
+	}
+
+	filters := make(map[string]go_hook.FilterFunc, len(cfg.Kubernetes))
+	for _, kubeCfg := range cfg.Kubernetes {
+		filters[kubeCfg.Name] = kubeCfg.FilterFunc
+	}
+
+	for _, bc := range contexts {
+		for bindingName, items := range bc.Snapshots {
+			filterFunc, has := filters[bindingName]
+			if !has {
+				return nil, fmt.Errorf("snapshot for unknown binding '%s'", bindingName)
+			}
+			for _, item := range items {
+				if item.Object == nil {
+					return nil, fmt.Errorf("snapshot '%s' has no object", bindingName)
+				}
+				filterResult, err := filterFunc(item.Object)
+				if err != nil {
+					return nil, fmt.Errorf("filter object for snapshot '%s': %v", bindingName, err)
+				}
+				snapshots[bindingName] = append(snapshots[bindingName], filterResult)
+			}
+		}
+	}
+
+	return snapshots, nil
+}
+
+// valuesPatchOperation is a JSON patch operation. Unlike utils.ValuesPatchOperation,
+// 'value' is not omitted for false, 0 and empty strings.
+type valuesPatchOperation struct {
+	Op    string
+	Path  string
+	Value interface{}
+}
+
+func (o valuesPatchOperation) MarshalJSON() ([]byte, error) {
+	if o.Op == "remove" {
+		return json.Marshal(map[string]interface{}{"op": o.Op, "path": o.Path})
+	}
+	return json.Marshal(map[string]interface{}{"op": o.Op, "path": o.Path, "value": o.Value})
+}
+
+func writeValuesPatch(path string, operations []*utils.ValuesPatchOperation) error {
+	if path == "" || len(operations) == 0 {
+		return nil
+	}
+	patch := make([]valuesPatchOperation, 0, len(operations))
+	for _, op := range operations {
+		patch = append(patch, valuesPatchOperation{Op: op.Op, Path: op.Path, Value: op.Value})
+	}
+	data, err := json.Marshal(patch)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
+
+// writeJSONLines writes items as a stream of JSON objects.
+func writeJSONLines(path string, items []interface{}) error {
+	if path == "" || len(items) == 0 {
+		return nil
+	}
+	buf := new(bytes.Buffer)
+	enc := json.NewEncoder(buf)
+	for _, item := range items {
+		if err := enc.Encode(item); err != nil {
+			return err
+		}
+	}
+	return os.WriteFile(path, buf.Bytes(), 0o644)
+}
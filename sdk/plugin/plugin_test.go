@@ -0,0 +1,280 @@
+package plugin
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/flant/shell-operator/pkg/kube/object_patch"
+	"github.com/flant/shell-operator/pkg/kube_events_manager/types"
+	"github.com/flant/shell-operator/pkg/metric_storage/operation"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/flant/addon-operator/pkg/module_manager"
+	"github.com/flant/addon-operator/pkg/module_manager/go_hook"
+	"github.com/flant/addon-operator/pkg/utils"
+)
+
+func filterName(obj *unstructured.Unstructured) (go_hook.FilterResult, error) {
+	return obj.GetName(), nil
+}
+
+func testHook(reconcileFunc func(input *go_hook.HookInput) error) *goHook {
+	return &goHook{
+		config: &go_hook.HookConfig{
+			Kubernetes: []go_hook.KubernetesConfig{
+				{
+					Name:                "pods",
+					ApiVersion:          "v1",
+					Kind:                "Pod",
+					NameSelector:        &types.NameSelector{MatchNames: []string{"pod-1", "pod-2"}},
+					ExecuteHookOnEvents: go_hook.Bool(false),
+					FilterFunc:          filterName,
+				},
+			},
+			Schedule: []go_hook.ScheduleConfig{
+				{Name: "every-minute", Crontab: "* * * * *"},
+			},
+			OnBeforeHelm: &go_hook.OrderedConfig{Order: 10},
+			Queue:        "pods",
+			Settings: &go_hook.HookConfigSettings{
+				ExecutionMinInterval: 5 * time.Second,
+				ExecutionBurst:       3,
+				Timeout:              time.Minute,
+				Retry: &utils.RetryPolicy{
+					MaxAttempts:  3,
+					InitialDelay: time.Second,
+					GiveUpAction: utils.GiveUpSkip,
+				},
+			},
+		},
+		reconcileFunc: reconcileFunc,
+	}
+}
+
+func TestConfig(t *testing.T) {
+	out := new(bytes.Buffer)
+	err := Run(context.Background(), testHook(nil), []string{"/modules/001-test/hooks/hook", "--config"}, out)
+	require.NoError(t, err)
+
+	cfg := &module_manager.ModuleHookConfig{}
+	err = cfg.LoadAndValidate(out.Bytes())
+	require.NoError(t, err, out.String())
+
+	require.NotNil(t, cfg.BeforeHelm)
+	assert.Equal(t, 10.0, cfg.BeforeHelm.Order)
+	assert.Equal(t, time.Minute, cfg.Timeout)
+	require.NotNil(t, cfg.Settings)
+	assert.Equal(t, 5*time.Second, cfg.Settings.ExecutionMinInterval)
+	assert.Equal(t, &utils.RetryPolicy{MaxAttempts: 3, InitialDelay: time.Second, GiveUpAction: utils.GiveUpSkip}, cfg.Retry)
+
+	require.Len(t, cfg.OnKubernetesEvents, 1)
+	kubeCfg := cfg.OnKubernetesEvents[0]
+	assert.Equal(t, "pods", kubeCfg.BindingName)
+	assert.Equal(t, "pods", kubeCfg.Queue)
+	assert.Equal(t, "main", kubeCfg.Group)
+	assert.True(t, kubeCfg.ExecuteHookOnSynchronization)
+	assert.True(t, kubeCfg.KeepFullObjectsInMemory)
+	assert.Empty(t, kubeCfg.Monitor.EventTypes)
+	assert.Equal(t, []string{"pod-1", "pod-2"}, kubeCfg.Monitor.NameSelector.MatchNames)
+
+	require.Len(t, cfg.Schedules, 1)
+	assert.Equal(t, "every-minute", cfg.Schedules[0].BindingName)
+	assert.Equal(t, []string{"pods"}, cfg.Schedules[0].IncludeSnapshotsFrom)
+}
+
+func TestConfigWithoutFilterFunc(t *testing.T) {
+	_, err := ConfigV1(&go_hook.HookConfig{
+		Kubernetes: []go_hook.KubernetesConfig{{Name: "pods", Kind: "Pod"}},
+	})
+	require.Error(t, err)
+}
+
+func writeFile(t *testing.T, dir, name, content string) string {
+	path := filepath.Join(dir, name)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+	return path
+}
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv(ValuesPathEnv, writeFile(t, dir, "values.json", `{"global":{},"test":{"replicas":1,"enabled":true}}`))
+	t.Setenv(ConfigValuesPathEnv, writeFile(t, dir, "config_values.json", `{"global":{},"test":{}}`))
+	t.Setenv(BindingContextPathEnv, writeFile(t, dir, "binding_context.json", `[
+{"binding":"main","type":"Group","snapshots":{"pods":[
+  {"object":{"apiVersion":"v1","kind":"Pod","metadata":{"name":"pod-1","namespace":"default"}}},
+  {"object":{"apiVersion":"v1","kind":"Pod","metadata":{"name":"pod-2","namespace":"default"}}}
+]}}]`))
+	valuesPatchPath := writeFile(t, dir, "values_patch.json", "")
+	t.Setenv(ValuesJSONPatchPathEnv, valuesPatchPath)
+	configValuesPatchPath := writeFile(t, dir, "config_values_patch.json", "")
+	t.Setenv(ConfigValuesJSONPatchPathEnv, configValuesPatchPath)
+	metricsPath := writeFile(t, dir, "metrics.json", "")
+	t.Setenv(MetricsPathEnv, metricsPath)
+	kubernetesPatchPath := writeFile(t, dir, "kubernetes_patch.json", "")
+	t.Setenv(KubernetesPatchPathEnv, kubernetesPatchPath)
+
+	hook := testHook(func(input *go_hook.HookInput) error {
+		require.NotNil(t, input.Context)
+		_, hasDeadline := input.Context.Deadline()
+		assert.True(t, hasDeadline)
+
+		assert.Equal(t, []go_hook.FilterResult{"pod-1", "pod-2"}, input.Snapshots["pods"])
+		assert.Equal(t, int64(1), input.Values.Get("test.replicas").Int())
+
+		input.Values.Set("test.replicas", len(input.Snapshots["pods"]))
+		input.Values.Set("test.enabled", false)
+		input.ConfigValues.Set("test.mode", "")
+		input.MetricsCollector.Set("pods_total", 2, map[string]string{"namespace": "default"})
+
+		input.PatchCollector.Create(&unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "v1",
+			"kind":       "ConfigMap",
+			"metadata":   map[string]interface{}{"name": "pods", "namespace": "default"},
+		}}, object_patch.UpdateIfExists())
+		input.PatchCollector.MergePatch(`{"metadata":{"labels":{"seen":"true"}}}`, "v1", "Pod", "default", "pod-1", object_patch.IgnoreMissingObject())
+		input.PatchCollector.Delete("v1", "Pod", "default", "pod-2", object_patch.InBackground())
+		return nil
+	})
+
+	err := Run(context.Background(), hook, []string{"/modules/001-test/hooks/hook"}, nil)
+	require.NoError(t, err)
+
+	valuesPatch, err := utils.ValuesPatchFromFile(valuesPatchPath)
+	require.NoError(t, err)
+	require.Len(t, valuesPatch.Operations, 2)
+	assert.Equal(t, &utils.ValuesPatchOperation{Op: "add", Path: "/test/replicas", Value: 2.0}, valuesPatch.Operations[0])
+	assert.Equal(t, &utils.ValuesPatchOperation{Op: "add", Path: "/test/enabled", Value: false}, valuesPatch.Operations[1])
+
+	configValuesPatch, err := utils.ValuesPatchFromFile(configValuesPatchPath)
+	require.NoError(t, err)
+	require.Len(t, configValuesPatch.Operations, 1)
+	assert.Equal(t, &utils.ValuesPatchOperation{Op: "add", Path: "/test/mode", Value: ""}, configValuesPatch.Operations[0])
+
+	metricOperations, err := operation.MetricOperationsFromFile(metricsPath)
+	require.NoError(t, err)
+	require.Len(t, metricOperations, 1)
+	assert.Equal(t, "pods_total", metricOperations[0].Name)
+	assert.Equal(t, "001-test/hooks/hook", metricOperations[0].Group)
+
+	kubernetesPatch, err := os.ReadFile(kubernetesPatchPath)
+	require.NoError(t, err)
+	operations, err := object_patch.ParseOperations(kubernetesPatch)
+	require.NoError(t, err)
+	require.Len(t, operations, 3)
+	assert.Contains(t, string(kubernetesPatch), `"operation":"CreateOrUpdate"`)
+	assert.Contains(t, string(kubernetesPatch), `"mergePatch":{"metadata":{"labels":{"seen":"true"}}}`)
+	assert.Contains(t, string(kubernetesPatch), `"operation":"DeleteInBackground"`)
+}
+
+func TestRunWithFilterOperation(t *testing.T) {
+	hook := testHook(func(input *go_hook.HookInput) error {
+		input.PatchCollector.Filter(func(u *unstructured.Unstructured) (*unstructured.Unstructured, error) {
+			return u, nil
+		}, "v1", "Pod", "default", "pod-1")
+		return nil
+	})
+
+	err := Run(context.Background(), hook, []string{"/modules/001-test/hooks/hook"}, nil)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "Filter is not supported")
+}
+
+func TestHookName(t *testing.T) {
+	assert.Equal(t, "001-test/hooks/sub/hook", HookName("/modules/001-test/hooks/sub/hook"))
+	assert.Equal(t, "hook", HookName("/global-hooks/hook"))
+	assert.Equal(t, "hook", HookName("/tmp/hook"))
+}
+
+func TestPatchCollector(t *testing.T) {
+	object := map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "ConfigMap",
+		"metadata":   map[string]interface{}{"name": "cm", "namespace": "default"},
+	}
+	tests := []struct {
+		collect func(c *PatchCollector)
+		spec    object_patch.OperationSpec
+	}{
+		{
+			func(c *PatchCollector) { c.Create(object) },
+			object_patch.OperationSpec{Operation: object_patch.Create, Object: object},
+		},
+		{
+			func(c *PatchCollector) {
+				c.Create(object, object_patch.WithSubresource("status"), object_patch.IgnoreIfExists())
+			},
+			object_patch.OperationSpec{Operation: object_patch.CreateIfNotExists, Object: object, Subresource: "status"},
+		},
+		{
+			func(c *PatchCollector) { c.Create(object, object_patch.UpdateIfExists()) },
+			object_patch.OperationSpec{Operation: object_patch.CreateOrUpdate, Object: object},
+		},
+		{
+			func(c *PatchCollector) {
+				c.Delete("apps/v1", "Deployment", "default", "app", object_patch.InForeground())
+			},
+			object_patch.OperationSpec{Operation: object_patch.Delete, ApiVersion: "apps/v1", Kind: "Deployment", Namespace: "default", Name: "app"},
+		},
+		{
+			func(c *PatchCollector) { c.Delete("v1", "Pod", "default", "pod-1", object_patch.InBackground()) },
+			object_patch.OperationSpec{Operation: object_patch.DeleteInBackground, ApiVersion: "v1", Kind: "Pod", Namespace: "default", Name: "pod-1"},
+		},
+		{
+			func(c *PatchCollector) { c.Delete("v1", "Namespace", "", "ns", object_patch.NonCascading()) },
+			object_patch.OperationSpec{Operation: object_patch.DeleteNonCascading, ApiVersion: "v1", Kind: "Namespace", Name: "ns"},
+		},
+		{
+			func(c *PatchCollector) {
+				c.MergePatch([]byte(`{"metadata":{"labels":{"a":"b"}}}`), "v1", "Pod", "default", "pod-1")
+			},
+			object_patch.OperationSpec{Operation: object_patch.MergePatch, ApiVersion: "v1", Kind: "Pod", Namespace: "default", Name: "pod-1",
+				MergePatch: map[string]interface{}{"metadata": map[string]interface{}{"labels": map[string]interface{}{"a": "b"}}}},
+		},
+		{
+			func(c *PatchCollector) {
+				c.JSONPatch([]interface{}{map[string]interface{}{"op": "replace", "path": "/spec/replicas", "value": 2.0}},
+					"apps/v1", "Deployment", "default", "app",
+					object_patch.WithSubresource("scale"), object_patch.IgnoreMissingObject())
+			},
+			object_patch.OperationSpec{Operation: object_patch.JSONPatch, ApiVersion: "apps/v1", Kind: "Deployment", Namespace: "default", Name: "app", Subresource: "scale",
+				JSONPatch: []interface{}{map[string]interface{}{"op": "replace", "path": "/spec/replicas", "value": 2.0}}, IgnoreMissingObject: true},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.spec.Operation), func(t *testing.T) {
+			c := NewPatchCollector()
+			tt.collect(c)
+			specs, err := c.Collect(nil)
+			require.NoError(t, err)
+			assert.Equal(t, []object_patch.OperationSpec{tt.spec}, specs)
+
+			// The spec is parsed by the operator as an operation of the same type.
+			data, err := json.Marshal(specs[0])
+			require.NoError(t, err)
+			operations, err := object_patch.ParseOperations(data)
+			require.NoError(t, err)
+			assert.Equal(t, object_patch.NewFromOperationSpec(tt.spec).Description(), operations[0].Description())
+		})
+	}
+}
+
+func TestPatchCollector_unsupported(t *testing.T) {
+	c := NewPatchCollector()
+	c.MergePatch(`{"metadata":`, "v1", "Pod", "default", "pod-1")
+	_, err := c.Collect(nil)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "patch is not a valid JSON")
+
+	c = NewPatchCollector()
+	_, err = c.Collect([]go_hook.BindingAction{{Name: "pods", Action: "Disable"}})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "binding actions are not supported")
+}
@@ -6,6 +6,7 @@ import (
 	"sync"
 
 	"github.com/flant/addon-operator/pkg/module_manager/go_hook"
+	"github.com/flant/addon-operator/pkg/values/conversion"
 )
 
 const bindingsPanicMsg = "OnStartup hook always has binding context without Kubernetes snapshots. To prevent logic errors, don't use OnStartup and Kubernetes bindings in the same Go hook configuration."
@@ -29,6 +30,13 @@ var RegisterFunc = func(config *go_hook.HookConfig, reconcileFunc reconcileFunc)
 	return true
 }
 
+// RegisterConfigConversion registers a function to convert config values of the module
+// from version-1 to version of the config-values schema. See VALUES.md for details.
+var RegisterConfigConversion = func(moduleName string, version int, fn conversion.Func) bool {
+	Registry().AddConfigConversion(moduleName, version, fn)
+	return true
+}
+
 type HookWithMetadata struct {
 	Hook     go_hook.GoHook
 	Metadata *go_hook.HookMetadata
@@ -36,7 +44,9 @@ type HookWithMetadata struct {
 
 type HookRegistry struct {
 	hooks []HookWithMetadata
-	m     sync.Mutex
+	// conversions are functions to convert module config values by module name and version.
+	conversions map[string]map[int]conversion.Func
+	m           sync.Mutex
 }
 
 var (
@@ -55,6 +65,32 @@ func (h *HookRegistry) Hooks() []HookWithMetadata {
 	return h.hooks
 }
 
+// AddConfigConversion adds a function to convert config values of the module to the version.
+func (h *HookRegistry) AddConfigConversion(moduleName string, version int, fn conversion.Func) {
+	h.m.Lock()
+	defer h.m.Unlock()
+
+	if h.conversions == nil {
+		h.conversions = make(map[string]map[int]conversion.Func)
+	}
+	if h.conversions[moduleName] == nil {
+		h.conversions[moduleName] = make(map[int]conversion.Func)
+	}
+	h.conversions[moduleName][version] = fn
+}
+
+// ConfigConversions returns functions to convert config values of the module by version.
+func (h *HookRegistry) ConfigConversions(moduleName string) map[int]conversion.Func {
+	h.m.Lock()
+	defer h.m.Unlock()
+
+	res := make(map[int]conversion.Func, len(h.conversions[moduleName]))
+	for version, fn := range h.conversions[moduleName] {
+		res[version] = fn
+	}
+	return res
+}
+
 func (h *HookRegistry) Add(hook go_hook.GoHook) {
 	h.m.Lock()
 	defer h.m.Unlock()